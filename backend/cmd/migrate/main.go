@@ -0,0 +1,182 @@
+// migrate manages the versioned database migrations in internal/database/migrations.
+//
+// Usage (from backend/): go run ./cmd/migrate <status|up|down|create> [args]
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/database"
+	"pos-system/backend/internal/migrate"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+	args := os.Args[2:]
+	var err error
+	switch os.Args[1] {
+	case "status":
+		err = status()
+	case "up":
+		err = up(args)
+	case "down":
+		err = down(args)
+	case "create":
+		err = create(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		printUsage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  migrate status               list migrations and whether each is applied
+  migrate up [version]         apply pending migrations (up to version, if given)
+  migrate down [n] [--all]     roll back the newest n migrations (default 1);
+                               rolling back the baseline drops every table and needs --all
+  migrate create <name>        add empty NNNN_<name>.up.sql / .down.sql files
+
+DATABASE_URL is read from the environment or backend/.env. Run from backend/:
+  go run ./cmd/migrate status
+`)
+}
+
+func connect() (*gorm.DB, error) {
+	cfg := config.Load()
+	if cfg.DatabaseURL == "" {
+		return nil, fmt.Errorf("DATABASE_URL is not set")
+	}
+	db, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Warn)}), nil
+}
+
+func status() error {
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	list, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	legacy, err := database.IsLegacySchema(db)
+	if err != nil {
+		return err
+	}
+	if err := migrate.EnsureTable(db); err != nil {
+		return err
+	}
+	rows, err := migrate.Status(db, list)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, r := range rows {
+		state := "pending"
+		switch {
+		case r.Unknown:
+			state = "applied " + r.AppliedAt.Format("2006-01-02 15:04:05") + "  NOT IN THIS BUILD"
+		case r.AppliedAt != nil:
+			state = "applied " + r.AppliedAt.Format("2006-01-02 15:04:05")
+		default:
+			pending++
+		}
+		fmt.Printf("%04d  %-40s %s\n", r.Version, r.Name, state)
+	}
+	if legacy {
+		fmt.Println("\nThis database was created before versioned migrations; `migrate up` adopts it as the baseline.")
+	}
+	fmt.Printf("\n%d pending\n", pending)
+	return migrate.Check(db, list)
+}
+
+func up(args []string) error {
+	var target int64
+	if len(args) > 0 {
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("version must be a positive number, got %q", args[0])
+		}
+		target = v
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	if err := database.MigrateUp(db, target); err != nil {
+		return err
+	}
+	fmt.Println("Database is up to date.")
+	return nil
+}
+
+func down(args []string) error {
+	steps, all := 1, false
+	for _, a := range args {
+		if a == "--all" || a == "-all" {
+			all = true
+			continue
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("number of migrations must be a positive number, got %q", a)
+		}
+		steps = n
+	}
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+	if err := migrate.EnsureTable(db); err != nil {
+		return err
+	}
+	applied, err := migrate.Applied(db)
+	if err != nil {
+		return err
+	}
+	if steps >= len(applied) && len(applied) > 0 && !all {
+		return fmt.Errorf("rolling back %d migration(s) includes the baseline and drops every table; pass --all to confirm", steps)
+	}
+	reverted, err := database.MigrateDown(db, steps)
+	if len(reverted) == 0 && err == nil {
+		fmt.Println("Nothing to roll back.")
+	}
+	return err
+}
+
+func create(args []string) error {
+	name := strings.TrimSpace(strings.Join(args, "_"))
+	if name == "" {
+		return fmt.Errorf("usage: migrate create <name>")
+	}
+	list, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	upFile, downFile, err := migrate.Create(database.MigrationsDir, name, list)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Created", upFile)
+	fmt.Println("Created", downFile)
+	return nil
+}
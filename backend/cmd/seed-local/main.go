@@ -1,4 +1,4 @@
-// seed-local creates the database schema (via GORM migrations) and a default admin user
+// seed-local creates the database schema (via the versioned migrations) and a default admin user
 // for local development. Safe to re-run — skips seeding when a management user already exists.
 //
 // Usage (from backend/): go run ./cmd/seed-local
@@ -23,7 +23,7 @@ func main() {
 		log.Fatal("DATABASE_URL is not set — run INSTALL-LOCAL first or create backend/.env")
 	}
 
-	db, err := database.Initialize(cfg.DatabaseURL)
+	db, err := database.Initialize(cfg.DatabaseURL, true)
 	if err != nil {
 		log.Fatalf("Database setup failed: %v", err)
 	}
@@ -0,0 +1,675 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	accountingFormatXero = "xero"
+	accountingFormatSage = "sage"
+
+	accountingDocInvoices    = "invoices"
+	accountingDocCreditNotes = "credit_notes"
+	accountingDocPayments    = "payments"
+	accountingDocPOSSales    = "pos_sales"
+
+	nominalKeyDefault  = "_default"
+	nominalKeyShipping = "_shipping"
+	nominalKeyBank     = "_bank"
+	nominalKeyPOSSales = "_pos_sales"
+)
+
+// defaultNominalCodes are the Sage 50 UK standard chart codes, used until management configures their own.
+var defaultNominalCodes = map[string]string{
+	nominalKeyDefault:  "4000",
+	nominalKeyShipping: "4905",
+	nominalKeyBank:     "1200",
+	nominalKeyPOSSales: "4000",
+}
+
+type AccountingExportHandler struct {
+	db *gorm.DB
+}
+
+func NewAccountingExportHandler(db *gorm.DB) *AccountingExportHandler {
+	return &AccountingExportHandler{db: db}
+}
+
+// accountingLine is one nominal line of an exported document; amounts are positive for invoices and payments.
+type accountingLine struct {
+	Description string
+	Quantity    float64
+	UnitAmount  float64
+	Net         float64
+	VAT         float64
+	Nominal     string
+}
+
+// accountingDoc is a document (invoice, credit note, payment or POS day) ready to be written in either format.
+type accountingDoc struct {
+	Ref         string // key recorded in the export batch
+	Number      string // invoice / credit note number shown in the ledger
+	Date        time.Time
+	DueDate     time.Time
+	ContactName string
+	AccountCode string
+	Reference   string
+	Standard    bool // VAT charged at standard rate; otherwise zero rated
+	Lines       []accountingLine
+}
+
+func (d *accountingDoc) total() float64 {
+	var t float64
+	for _, l := range d.Lines {
+		t += l.Net + l.VAT
+	}
+	return math.Round(t*100) / 100
+}
+
+func roundPence(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func formatAccountingAmount(v float64) string {
+	return strconv.FormatFloat(roundPence(v), 'f', 2, 64)
+}
+
+var paymentTermsDaysRe = regexp.MustCompile(`(\d{1,3})\s*days?`)
+
+// dueDateFromTerms reads "30 days" style payment terms; due on the document date otherwise.
+func dueDateFromTerms(date time.Time, terms string) time.Time {
+	if m := paymentTermsDaysRe.FindStringSubmatch(strings.ToLower(terms)); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return date.AddDate(0, 0, n)
+		}
+	}
+	return date
+}
+
+func xeroTaxType(standard bool) string {
+	if standard {
+		return "20% (VAT on Income)"
+	}
+	return "Zero Rated Income"
+}
+
+func sageTaxCode(standard bool) string {
+	if standard {
+		return "T1"
+	}
+	return "T0"
+}
+
+// writeXeroSalesCSV writes the Xero sales invoice import template. Credit notes are written with negative
+// unit amounts, which Xero imports as credit notes.
+func writeXeroSalesCSV(docs []accountingDoc, credit bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"*ContactName", "AccountNumber", "*InvoiceNumber", "Reference", "*InvoiceDate", "*DueDate",
+		"*Description", "*Quantity", "*UnitAmount", "*AccountCode", "*TaxType", "TaxAmount", "Currency"})
+	sign := 1.0
+	if credit {
+		sign = -1
+	}
+	for _, d := range docs {
+		for _, l := range d.Lines {
+			w.Write([]string{d.ContactName, d.AccountCode, d.Number, d.Reference, d.Date.Format("02/01/2006"), d.DueDate.Format("02/01/2006"),
+				l.Description, strconv.FormatFloat(l.Quantity, 'f', -1, 64), formatAccountingAmount(sign * l.UnitAmount), l.Nominal,
+				xeroTaxType(d.Standard), formatAccountingAmount(sign * l.VAT), "GBP"})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeXeroBankCSV writes received payments as a Xero bank statement import.
+func writeXeroBankCSV(docs []accountingDoc) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"*Date", "*Amount", "Payee", "Description", "Reference"})
+	for _, d := range docs {
+		w.Write([]string{d.Date.Format("02/01/2006"), formatAccountingAmount(d.total()), d.ContactName,
+			"Payment for " + d.Number, d.Reference})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeXeroJournalCSV writes POS takings as a Xero manual journal: sales credited per nominal, bank debited.
+func writeXeroJournalCSV(docs []accountingDoc, bankNominal string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount"})
+	for _, d := range docs {
+		narration := d.ContactName + " takings " + d.Date.Format("02/01/2006")
+		for _, l := range d.Lines {
+			w.Write([]string{narration, d.Date.Format("02/01/2006"), l.Description, l.Nominal, xeroTaxType(d.Standard),
+				formatAccountingAmount(-(l.Net + l.VAT))})
+		}
+		w.Write([]string{narration, d.Date.Format("02/01/2006"), "Takings banked", bankNominal, "No VAT", formatAccountingAmount(d.total())})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeSageAuditTrailCSV writes the Sage 50 audit trail transaction import (no header row).
+// Types: SI sales invoice, SC sales credit, SA payment on account, JC/JD journal credit/debit.
+func writeSageAuditTrailCSV(docs []accountingDoc, docType, bankNominal string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, d := range docs {
+		date := d.Date.Format("02/01/2006")
+		switch docType {
+		case accountingDocPayments:
+			w.Write([]string{"SA", d.AccountCode, bankNominal, "0", date, d.Number, "Payment " + d.Reference,
+				formatAccountingAmount(d.total()), "T9", "0.00"})
+		case accountingDocPOSSales:
+			for _, l := range d.Lines {
+				w.Write([]string{"JC", "", l.Nominal, "0", date, d.Number, l.Description, formatAccountingAmount(l.Net + l.VAT), "T9", "0.00"})
+			}
+			w.Write([]string{"JD", "", bankNominal, "0", date, d.Number, d.ContactName + " takings", formatAccountingAmount(d.total()), "T9", "0.00"})
+		default:
+			t := "SI"
+			if docType == accountingDocCreditNotes {
+				t = "SC"
+			}
+			for _, l := range d.Lines {
+				w.Write([]string{t, d.AccountCode, l.Nominal, "0", date, d.Number, l.Description,
+					formatAccountingAmount(l.Net), sageTaxCode(d.Standard), formatAccountingAmount(l.VAT)})
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// nominalCodes loads configured codes over the defaults.
+func (h *AccountingExportHandler) nominalCodes() map[string]string {
+	codes := make(map[string]string, len(defaultNominalCodes))
+	for k, v := range defaultNominalCodes {
+		codes[k] = v
+	}
+	var rows []models.AccountingNominalCode
+	h.db.Find(&rows)
+	for _, r := range rows {
+		if strings.TrimSpace(r.NominalCode) != "" {
+			codes[normalizeCategory(r.Key)] = strings.TrimSpace(r.NominalCode)
+		}
+	}
+	return codes
+}
+
+func nominalForCategory(codes map[string]string, category string) string {
+	if code, ok := codes[normalizeCategory(category)]; ok && category != "" {
+		return code
+	}
+	return codes[nominalKeyDefault]
+}
+
+// exportedRefs returns document refs of the given type already in a batch that has not been voided.
+func (h *AccountingExportHandler) exportedRefs(docType string) map[string]bool {
+	var refs []string
+	h.db.Model(&models.AccountingExportDocument{}).
+		Joins("JOIN accounting_exports e ON e.id = accounting_export_documents.accounting_export_id").
+		Where("accounting_export_documents.document_type = ? AND e.voided_at IS NULL", docType).
+		Pluck("accounting_export_documents.document_ref", &refs)
+	out := make(map[string]bool, len(refs))
+	for _, r := range refs {
+		out[r] = true
+	}
+	return out
+}
+
+// apportionVAT spreads an order's VAT total across lines in proportion to net amount.
+func apportionVAT(lines []accountingLine, vatTotal float64) {
+	var net float64
+	for _, l := range lines {
+		net += l.Net
+	}
+	if net <= 0 || vatTotal == 0 {
+		return
+	}
+	var allocated float64
+	for i := range lines {
+		if i == len(lines)-1 {
+			lines[i].VAT = roundPence(vatTotal - allocated)
+			break
+		}
+		lines[i].VAT = roundPence(vatTotal * lines[i].Net / net)
+		allocated += lines[i].VAT
+	}
+}
+
+// wholesaleInvoiceDocs collects issued wholesale invoices dated within [from, to].
+func (h *AccountingExportHandler) wholesaleInvoiceDocs(from, to time.Time, codes map[string]string) ([]accountingDoc, error) {
+	var orders []models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("Documents", "type = ?", "invoice").
+		Where("status NOT IN ?", []string{models.WholesaleOrderStatusRejected, models.WholesaleOrderStatusDeleted}).
+		Where("(invoice_date BETWEEN ? AND ? OR EXISTS (SELECT 1 FROM wholesale_order_documents d WHERE d.wholesale_order_id = wholesale_orders.id AND d.type = 'invoice' AND d.created_at BETWEEN ? AND ?))",
+			from, to, from, to.Add(24*time.Hour-time.Second)).
+		Order("id ASC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]accountingDoc, 0, len(orders))
+	for i := range orders {
+		wo := &orders[i]
+		if len(wo.Documents) == 0 {
+			continue
+		}
+		date := wo.Documents[0].CreatedAt
+		if wo.InvoiceDate != nil {
+			date = *wo.InvoiceDate
+		}
+		date = dateOnly(date)
+		if date.Before(from) || date.After(to) {
+			continue
+		}
+		lines := make([]accountingLine, 0, len(wo.Items)+2)
+		for _, it := range wo.Items {
+			lines = append(lines, accountingLine{
+				Description: it.Product.Name,
+				Quantity:    it.Quantity,
+				UnitAmount:  it.LineTotal / math.Max(it.Quantity, 0.001),
+				Net:         it.LineTotal,
+				Nominal:     nominalForCategory(codes, it.Product.Category),
+			})
+		}
+		if wo.DiscountAmount > 0 {
+			lines = append(lines, accountingLine{Description: "Discount", Quantity: 1, UnitAmount: -wo.DiscountAmount, Net: -wo.DiscountAmount, Nominal: codes[nominalKeyDefault]})
+		}
+		if wo.ShippingFee > 0 {
+			lines = append(lines, accountingLine{Description: "Shipping", Quantity: 1, UnitAmount: wo.ShippingFee, Net: wo.ShippingFee, Nominal: codes[nominalKeyShipping]})
+		}
+		apportionVAT(lines, wo.VATTotal)
+		docs = append(docs, accountingDoc{
+			Ref:         strconv.FormatUint(uint64(wo.ID), 10),
+			Number:      wholesaleInvoiceNumberLabel(wo),
+			Date:        date,
+			DueDate:     dueDateFromTerms(date, wo.PaymentTerms),
+			ContactName: wo.WholesaleClient.Name,
+			AccountCode: wo.WholesaleClient.AccountCode,
+			Reference:   wo.PONumber,
+			Standard:    wo.VATTotal > 0,
+			Lines:       lines,
+		})
+	}
+	return docs, nil
+}
+
+// wholesaleCreditNoteDocs collects return credit notes issued within [from, to].
+func (h *AccountingExportHandler) wholesaleCreditNoteDocs(from, to time.Time, codes map[string]string) ([]accountingDoc, error) {
+	var returns []models.WholesaleReturn
+	if err := h.db.Preload("Items.Product").Preload("WholesaleOrder").Preload("WholesaleClient").
+		Where("credit_note_number <> '' AND credited_at BETWEEN ? AND ?", from, to.Add(24*time.Hour-time.Second)).
+		Order("id ASC").Find(&returns).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]accountingDoc, 0, len(returns))
+	for i := range returns {
+		ret := &returns[i]
+		standard := ret.WholesaleOrder.VATTotal > 0
+		lines := make([]accountingLine, 0, len(ret.Items))
+		for _, it := range ret.Items {
+			lines = append(lines, accountingLine{
+				Description: it.Product.Name,
+				Quantity:    it.Quantity,
+				UnitAmount:  it.UnitPrice,
+				Net:         roundPence(it.UnitPrice * it.Quantity),
+				Nominal:     nominalForCategory(codes, it.Product.Category),
+			})
+		}
+		if standard && ret.WholesaleOrder.TotalNet > 0 {
+			apportionVAT(lines, roundPence(ret.CreditNoteTotal*ret.WholesaleOrder.VATTotal/ret.WholesaleOrder.TotalNet))
+		}
+		date := dateOnly(*ret.CreditedAt)
+		docs = append(docs, accountingDoc{
+			Ref:         strconv.FormatUint(uint64(ret.ID), 10),
+			Number:      ret.CreditNoteNumber,
+			Date:        date,
+			DueDate:     date,
+			ContactName: ret.WholesaleClient.Name,
+			AccountCode: ret.WholesaleClient.AccountCode,
+			Reference:   wholesaleInvoiceNumberLabel(&ret.WholesaleOrder),
+			Standard:    standard,
+			Lines:       lines,
+		})
+	}
+	return docs, nil
+}
+
+// wholesalePaymentDocs collects orders whose payment was confirmed within [from, to].
+func (h *AccountingExportHandler) wholesalePaymentDocs(from, to time.Time) ([]accountingDoc, error) {
+	var orders []models.WholesaleOrder
+	if err := h.db.Preload("WholesaleClient").
+		Where("status NOT IN ?", []string{models.WholesaleOrderStatusRejected, models.WholesaleOrderStatusDeleted}).
+		Where("payment_confirmed_at BETWEEN ? AND ?", from, to.Add(24*time.Hour-time.Second)).
+		Order("payment_confirmed_at ASC, id ASC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]accountingDoc, 0, len(orders))
+	for i := range orders {
+		wo := &orders[i]
+		amount := wholesaleOrderGrandTotal(wo) + wo.VATTotal
+		docs = append(docs, accountingDoc{
+			Ref:         strconv.FormatUint(uint64(wo.ID), 10),
+			Number:      wholesaleInvoiceNumberLabel(wo),
+			Date:        dateOnly(*wo.PaymentConfirmedAt),
+			ContactName: wo.WholesaleClient.Name,
+			AccountCode: wo.WholesaleClient.AccountCode,
+			Reference:   wholesaleOrderRefLabel(wo),
+			Lines:       []accountingLine{{Description: "Payment", Quantity: 1, UnitAmount: amount, Net: amount}},
+		})
+	}
+	return docs, nil
+}
+
+// posSalesDocs summarises paid POS orders per store and day, split by category nominal.
+func (h *AccountingExportHandler) posSalesDocs(from, to time.Time, codes map[string]string) ([]accountingDoc, error) {
+	var orders []models.Order
+	if err := h.db.Preload("Items.Product").Preload("Store").
+		Where("status IN ?", []string{"paid", "completed", "picked_up"}).
+		Where("COALESCE(paid_at, created_at) BETWEEN ? AND ?", from, to.Add(24*time.Hour-time.Second)).
+		Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	type dayKey struct {
+		StoreID uint
+		Day     string
+	}
+	byDay := make(map[dayKey]map[string]float64)
+	storeNames := make(map[uint]string)
+	for _, o := range orders {
+		when := o.CreatedAt
+		if o.PaidAt != nil {
+			when = *o.PaidAt
+		}
+		k := dayKey{o.StoreID, when.Format("2006-01-02")}
+		if byDay[k] == nil {
+			byDay[k] = make(map[string]float64)
+		}
+		storeNames[o.StoreID] = o.Store.Name
+		var itemsTotal float64
+		for _, it := range o.Items {
+			byDay[k][nominalForCategory(codes, it.Product.Category)] += it.LineTotal
+			itemsTotal += it.LineTotal
+		}
+		// Order-level discount and rounding go to the POS sales nominal so the day total matches takings.
+		if diff := o.TotalAmount - itemsTotal; math.Abs(diff) >= 0.005 {
+			byDay[k][codes[nominalKeyPOSSales]] += diff
+		}
+	}
+	keys := make([]dayKey, 0, len(byDay))
+	for k := range byDay {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Day != keys[j].Day {
+			return keys[i].Day < keys[j].Day
+		}
+		return keys[i].StoreID < keys[j].StoreID
+	})
+	docs := make([]accountingDoc, 0, len(keys))
+	for _, k := range keys {
+		date, _ := time.Parse("2006-01-02", k.Day)
+		nominals := make([]string, 0, len(byDay[k]))
+		for n := range byDay[k] {
+			nominals = append(nominals, n)
+		}
+		sort.Strings(nominals)
+		lines := make([]accountingLine, 0, len(nominals))
+		for _, n := range nominals {
+			amt := roundPence(byDay[k][n])
+			lines = append(lines, accountingLine{Description: "POS sales " + n, Quantity: 1, UnitAmount: amt, Net: amt, Nominal: n})
+		}
+		name := storeNames[k.StoreID]
+		if name == "" {
+			name = fmt.Sprintf("Store %d", k.StoreID)
+		}
+		docs = append(docs, accountingDoc{
+			Ref:         fmt.Sprintf("%d:%s", k.StoreID, k.Day),
+			Number:      fmt.Sprintf("POS-%d-%s", k.StoreID, date.Format("20060102")),
+			Date:        date,
+			DueDate:     date,
+			ContactName: name,
+			Lines:       lines,
+		})
+	}
+	return docs, nil
+}
+
+type accountingExportRequest struct {
+	Format          string `json:"format" form:"format" binding:"required"`               // xero, sage
+	DocumentType    string `json:"document_type" form:"document_type" binding:"required"` // invoices, credit_notes, payments, pos_sales
+	From            string `json:"from" form:"from" binding:"required"`                   // YYYY-MM-DD
+	To              string `json:"to" form:"to" binding:"required"`                       // YYYY-MM-DD
+	IncludeExported bool   `json:"include_exported" form:"include_exported"`              // preview only: show documents already exported
+}
+
+// collect validates the request and returns documents not yet exported (unless includeExported).
+func (h *AccountingExportHandler) collect(req *accountingExportRequest, includeExported bool) ([]accountingDoc, time.Time, time.Time, error) {
+	from, to := parsePODate(req.From), parsePODate(req.To)
+	if from == nil || to == nil || to.Before(*from) {
+		return nil, time.Time{}, time.Time{}, fmt.Errorf("from and to must be YYYY-MM-DD with from <= to")
+	}
+	if req.Format != accountingFormatXero && req.Format != accountingFormatSage {
+		return nil, *from, *to, fmt.Errorf("format must be xero or sage")
+	}
+	codes := h.nominalCodes()
+	var docs []accountingDoc
+	var err error
+	switch req.DocumentType {
+	case accountingDocInvoices:
+		docs, err = h.wholesaleInvoiceDocs(*from, *to, codes)
+	case accountingDocCreditNotes:
+		docs, err = h.wholesaleCreditNoteDocs(*from, *to, codes)
+	case accountingDocPayments:
+		docs, err = h.wholesalePaymentDocs(*from, *to)
+	case accountingDocPOSSales:
+		docs, err = h.posSalesDocs(*from, *to, codes)
+	default:
+		return nil, *from, *to, fmt.Errorf("document_type must be invoices, credit_notes, payments or pos_sales")
+	}
+	if err != nil || includeExported {
+		return docs, *from, *to, err
+	}
+	exported := h.exportedRefs(req.DocumentType)
+	pending := docs[:0]
+	for _, d := range docs {
+		if !exported[d.Ref] {
+			pending = append(pending, d)
+		}
+	}
+	return pending, *from, *to, nil
+}
+
+func (h *AccountingExportHandler) renderCSV(format, docType string, docs []accountingDoc) ([]byte, error) {
+	bank := h.nominalCodes()[nominalKeyBank]
+	if format == accountingFormatSage {
+		return writeSageAuditTrailCSV(docs, docType, bank)
+	}
+	switch docType {
+	case accountingDocPayments:
+		return writeXeroBankCSV(docs)
+	case accountingDocPOSSales:
+		return writeXeroJournalCSV(docs, bank)
+	default:
+		return writeXeroSalesCSV(docs, docType == accountingDocCreditNotes)
+	}
+}
+
+// PreviewExport lists the documents an export would contain without recording a batch.
+func (h *AccountingExportHandler) PreviewExport(c *gin.Context) {
+	var req accountingExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	docs, _, _, err := h.collect(&req, req.IncludeExported)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exported := h.exportedRefs(req.DocumentType)
+	rows := make([]gin.H, 0, len(docs))
+	var total float64
+	for _, d := range docs {
+		total += d.total()
+		rows = append(rows, gin.H{
+			"ref": d.Ref, "number": d.Number, "date": d.Date.Format("2006-01-02"), "contact_name": d.ContactName,
+			"account_code": d.AccountCode, "total": d.total(), "exported": exported[d.Ref],
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"documents": rows, "count": len(rows), "total": roundPence(total)})
+}
+
+// CreateExport writes the CSV for all documents in the range not yet exported and records the batch.
+func (h *AccountingExportHandler) CreateExport(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	var req accountingExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	docs, from, to, err := h.collect(&req, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No documents left to export in this date range"})
+		return
+	}
+	data, err := h.renderCSV(req.Format, req.DocumentType, docs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	export := models.AccountingExport{
+		Format:        req.Format,
+		DocumentType:  req.DocumentType,
+		DateFrom:      from,
+		DateTo:        to,
+		DocumentCount: len(docs),
+		Filename:      fmt.Sprintf("%s-%s-%s-%s.csv", req.Format, req.DocumentType, from.Format("20060102"), to.Format("20060102")),
+		Content:       string(data),
+		UserID:        userID,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&export).Error; err != nil {
+			return err
+		}
+		rows := make([]models.AccountingExportDocument, 0, len(docs))
+		for _, d := range docs {
+			export.Total += d.total()
+			rows = append(rows, models.AccountingExportDocument{
+				AccountingExportID: export.ID, DocumentType: req.DocumentType, DocumentRef: d.Ref, Amount: d.total(),
+			})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+		return tx.Model(&export).Update("total", roundPence(export.Total)).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+	c.Header("X-Accounting-Export-ID", strconv.FormatUint(uint64(export.ID), 10))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// ListExports lists export batches, newest first.
+func (h *AccountingExportHandler) ListExports(c *gin.Context) {
+	var list []models.AccountingExport
+	if err := h.db.Preload("User").Order("id DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// DownloadExport returns the CSV exactly as it was exported.
+func (h *AccountingExportHandler) DownloadExport(c *gin.Context) {
+	var export models.AccountingExport
+	if err := h.db.First(&export, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(export.Content))
+}
+
+// VoidExport releases a batch (e.g. the import failed) so its documents can be exported again.
+func (h *AccountingExportHandler) VoidExport(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	res := h.db.Model(&models.AccountingExport{}).Where("id = ? AND voided_at IS NULL", c.Param("id")).
+		Updates(map[string]interface{}{"voided_at": now, "void_reason": strings.TrimSpace(req.Reason)})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found or already voided"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Export voided"})
+}
+
+// ListNominalCodes returns the effective nominal code per category and reserved key.
+func (h *AccountingExportHandler) ListNominalCodes(c *gin.Context) {
+	c.JSON(http.StatusOK, h.nominalCodes())
+}
+
+// UpdateNominalCodes upserts nominal codes; an empty code resets the key to its default.
+func (h *AccountingExportHandler) UpdateNominalCodes(c *gin.Context) {
+	var req map[string]string
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for key, code := range req {
+		key = normalizeCategory(key)
+		code = strings.TrimSpace(code)
+		if key == "" {
+			continue
+		}
+		if code == "" {
+			h.db.Where("`key` = ?", key).Delete(&models.AccountingNominalCode{})
+			continue
+		}
+		var row models.AccountingNominalCode
+		if err := h.db.Where("`key` = ?", key).First(&row).Error; err != nil {
+			row = models.AccountingNominalCode{Key: key}
+		}
+		row.NominalCode = code
+		if err := h.db.Save(&row).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, h.nominalCodes())
+}
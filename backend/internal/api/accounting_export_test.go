@@ -0,0 +1,59 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDueDateFromTerms(t *testing.T) {
+	date := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	if got := dueDateFromTerms(date, "Net 30 days"); !got.Equal(date.AddDate(0, 0, 30)) {
+		t.Fatalf("30 days: got %v", got)
+	}
+	if got := dueDateFromTerms(date, "Cash on delivery"); !got.Equal(date) {
+		t.Fatalf("no days: got %v", got)
+	}
+}
+
+func TestApportionVATBalancesToTotal(t *testing.T) {
+	lines := []accountingLine{{Net: 10}, {Net: 10}, {Net: 10}}
+	apportionVAT(lines, 6.01)
+	var sum float64
+	for _, l := range lines {
+		sum += l.VAT
+	}
+	if roundPence(sum) != 6.01 {
+		t.Fatalf("VAT lines sum to %.2f, want 6.01", sum)
+	}
+}
+
+func TestSageCreditNoteRows(t *testing.T) {
+	docs := []accountingDoc{{
+		Number: "CN1", Date: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), AccountCode: "ACME", Standard: true,
+		Lines: []accountingLine{{Description: "Widget", Net: 50, VAT: 10, Nominal: "4000"}},
+	}}
+	data, err := writeSageAuditTrailCSV(docs, accountingDocCreditNotes, "1200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SC,ACME,4000,0,04/03/2025,CN1,Widget,50.00,T1,10.00\n"
+	if string(data) != want {
+		t.Fatalf("got %q, want %q", data, want)
+	}
+}
+
+func TestXeroCreditNoteAmountsAreNegative(t *testing.T) {
+	docs := []accountingDoc{{
+		Number: "CN1", ContactName: "Acme", Date: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+		Lines: []accountingLine{{Description: "Widget", Quantity: 2, UnitAmount: 25, Net: 50, Nominal: "4000"}},
+	}}
+	data, err := writeXeroSalesCSV(docs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != 2 || !strings.Contains(rows[1], ",-25.00,4000,Zero Rated Income,") {
+		t.Fatalf("unexpected rows: %q", rows)
+	}
+}
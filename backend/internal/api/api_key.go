@@ -0,0 +1,355 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks API keys so they can be told apart from JWTs in the Authorization header.
+const apiKeyPrefix = "pak_"
+
+type APIKeyHandler struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyHandler(db *gorm.DB) *APIKeyHandler {
+	return &APIKeyHandler{db: db}
+}
+
+// apiKeyFromRequest returns the API key in X-API-Key or a Bearer header, or "".
+func apiKeyFromRequest(c *gin.Context) string {
+	if k := strings.TrimSpace(c.GetHeader("X-API-Key")); k != "" {
+		return k
+	}
+	if k := bearerTokenFromHeader(c.GetHeader("Authorization")); strings.HasPrefix(k, apiKeyPrefix) {
+		return k
+	}
+	return ""
+}
+
+func contextAPIKeyID(c *gin.Context) *uint {
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get("api_key_id"); ok {
+		if id, ok := v.(uint); ok && id > 0 {
+			return &id
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == ' ' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseAllowedIPs validates a comma-separated list of IPs and CIDRs and returns it normalised, or the first bad entry.
+func parseAllowedIPs(s string) (string, string) {
+	entries := splitList(s)
+	for _, e := range entries {
+		if net.ParseIP(e) == nil {
+			if _, _, err := net.ParseCIDR(e); err != nil {
+				return "", e
+			}
+		}
+	}
+	return strings.Join(entries, ","), ""
+}
+
+// ipAllowed reports whether ip matches the allowlist; an empty list allows any address.
+func ipAllowed(allowed, ip string) bool {
+	entries := splitList(allowed)
+	if len(entries) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, e := range entries {
+		if _, cidr, err := net.ParseCIDR(e); err == nil {
+			if cidr.Contains(addr) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(e); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyMiddleware authenticates requests that present an API key instead of a login. The key acts as its user,
+// and permissionsMiddleware narrows that user's permissions to the key's scopes. Requests without a key pass
+// through to authMiddleware.
+func apiKeyMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := apiKeyFromRequest(c)
+		if presented == "" {
+			c.Next()
+			return
+		}
+		if db == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database connection not available. Please try again later."})
+			c.Abort()
+			return
+		}
+		var key models.APIKey
+		if err := db.Preload("User").Where("key_hash = ?", utils.HashToken(presented)).First(&key).Error; err != nil ||
+			key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) ||
+			key.User == nil || !key.User.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+			c.Abort()
+			return
+		}
+		if !ipAllowed(key.AllowedIPs, c.ClientIP()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key not allowed from this address"})
+			c.Abort()
+			return
+		}
+		if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > time.Minute || key.LastUsedIP != c.ClientIP() {
+			db.Model(&models.APIKey{}).Where("id = ?", key.ID).
+				Updates(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": c.ClientIP()})
+		}
+		c.Set("user_id", key.UserID)
+		c.Set("username", key.User.Username)
+		c.Set("role", key.User.Role)
+		c.Set("api_key_id", key.ID)
+		c.Set("api_key_scopes", splitList(key.Scopes))
+		c.Next()
+	}
+}
+
+// interactiveOnly refuses API keys on routes that act on a login rather than on data: sessions, 2FA and key
+// management itself.
+func interactiveOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if contextAPIKeyID(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available with an API key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type apiKeyResponse struct {
+	models.APIKey
+	ScopeList []string `json:"scopes"`
+	Key       string   `json:"key,omitempty"` // only in the create response
+}
+
+func toAPIKeyResponse(k models.APIKey) apiKeyResponse {
+	scopes := splitList(k.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return apiKeyResponse{APIKey: k, ScopeList: scopes}
+}
+
+func (h *APIKeyHandler) audit(c *gin.Context, action string, keyID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: "api_key",
+		EntityID:   &keyID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+// checkScopes validates requested scopes against the catalogue and refuses any the caller does not hold.
+func checkScopes(c *gin.Context, requested []string) ([]string, bool) {
+	scopes, unknown := normalisePermissions(requested)
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + unknown})
+		return nil, false
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one scope is required"})
+		return nil, false
+	}
+	held := contextPermissions(c)
+	for _, s := range scopes {
+		if !held.global[s] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant a scope you do not hold", "permission": s})
+			return nil, false
+		}
+	}
+	return scopes, true
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	q := h.db.Preload("User").Order("revoked_at IS NULL DESC, created_at DESC")
+	if c.Query("include_revoked") != "true" {
+		q = q.Where("revoked_at IS NULL")
+	}
+	if err := q.Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		if k.User != nil {
+			k.User.PasswordHash, k.User.PINHash = "", ""
+		}
+		out = append(out, toAPIKeyResponse(k))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+type SaveAPIKeyRequest struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs string     `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKey issues a key acting as the caller with the given scopes. The key is returned once and cannot be
+// shown again.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req SaveAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	scopes, ok := checkScopes(c, req.Scopes)
+	if !ok {
+		return
+	}
+	allowed, bad := parseAllowedIPs(req.AllowedIPs)
+	if bad != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP or CIDR: " + bad})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	token, err := utils.GenerateToken(apiKeyPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+		return
+	}
+	key := models.APIKey{
+		Name:        name,
+		KeyPrefix:   token[:12],
+		KeyHash:     utils.HashToken(token),
+		UserID:      *contextUserID(c),
+		Scopes:      strings.Join(scopes, ","),
+		AllowedIPs:  allowed,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedByID: contextUserID(c),
+	}
+	if err := h.db.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "api_key_create", key.ID, map[string]interface{}{
+		"name": key.Name, "scopes": scopes, "allowed_ips": key.AllowedIPs, "expires_at": key.ExpiresAt,
+	})
+	resp := toAPIKeyResponse(key)
+	resp.Key = token
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateAPIKey changes a key's name, scopes, IP allowlist or expiry. Fields left out are unchanged.
+func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
+	var key models.APIKey
+	if err := h.db.First(&key, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key is revoked"})
+		return
+	}
+	var req struct {
+		Name       *string    `json:"name"`
+		Scopes     []string   `json:"scopes"`
+		AllowedIPs *string    `json:"allowed_ips"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		updates["name"] = strings.TrimSpace(*req.Name)
+		changes["name"] = map[string]interface{}{"old": key.Name, "new": updates["name"]}
+	}
+	if req.Scopes != nil {
+		scopes, ok := checkScopes(c, req.Scopes)
+		if !ok {
+			return
+		}
+		updates["scopes"] = strings.Join(scopes, ",")
+		changes["scopes"] = map[string]interface{}{"old": splitList(key.Scopes), "new": scopes}
+	}
+	if req.AllowedIPs != nil {
+		allowed, bad := parseAllowedIPs(*req.AllowedIPs)
+		if bad != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP or CIDR: " + bad})
+			return
+		}
+		updates["allowed_ips"] = allowed
+		changes["allowed_ips"] = map[string]interface{}{"old": key.AllowedIPs, "new": allowed}
+	}
+	if req.ExpiresAt != nil {
+		updates["expires_at"] = *req.ExpiresAt
+		changes["expires_at"] = map[string]interface{}{"old": key.ExpiresAt, "new": *req.ExpiresAt}
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&key).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(c, "api_key_update", key.ID, changes)
+	}
+	h.db.First(&key, key.ID)
+	c.JSON(http.StatusOK, toAPIKeyResponse(key))
+}
+
+// RevokeAPIKey stops a key working immediately; revoked keys stay listed for the audit trail.
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	var key models.APIKey
+	if err := h.db.First(&key, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if err := h.db.Model(&key).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		key.RevokedAt = &now
+		h.audit(c, "api_key_revoke", key.ID, map[string]interface{}{"name": key.Name})
+	}
+	c.JSON(http.StatusOK, toAPIKeyResponse(key))
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestIPAllowed(t *testing.T) {
+	allowed, bad := parseAllowedIPs(" 10.0.0.0/24, 203.0.113.7\n2001:db8::/32 ")
+	if bad != "" || allowed != "10.0.0.0/24,203.0.113.7,2001:db8::/32" {
+		t.Fatalf("parseAllowedIPs = %q, %q", allowed, bad)
+	}
+	if _, bad := parseAllowedIPs("10.0.0.0/24, office"); bad != "office" {
+		t.Errorf("parseAllowedIPs bad entry = %q, want office", bad)
+	}
+	cases := map[string]bool{"10.0.0.42": true, "10.0.1.1": false, "203.0.113.7": true, "2001:db8::1": true, "bogus": false}
+	for ip, want := range cases {
+		if got := ipAllowed(allowed, ip); got != want {
+			t.Errorf("ipAllowed(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	if !ipAllowed("", "198.51.100.1") {
+		t.Error("an empty allowlist should allow any address")
+	}
+}
+
+func TestPermissionSetRestrictTo(t *testing.T) {
+	p := permissionSet{
+		global: map[string]bool{models.PermProductView: true, models.PermWholesaleCreate: true, models.PermUserManage: true},
+		stores: map[uint]map[string]bool{2: {models.PermStockAdjust: true}},
+	}
+	r := p.restrictTo([]string{models.PermProductView, models.PermStockAdjust, models.PermRoleManage})
+	if !r.allows(models.PermProductView, 0) || r.allows(models.PermUserManage, 0) || r.allows(models.PermRoleManage, 0) {
+		t.Errorf("restrictTo global = %v", r.global)
+	}
+	if !r.allows(models.PermStockAdjust, 2) || r.allows(models.PermStockAdjust, 0) {
+		t.Errorf("restrictTo stores = %v", r.stores)
+	}
+}
@@ -17,12 +17,13 @@ import (
 )
 
 type AuthHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db       *gorm.DB
+	cfg      *config.Config
+	throttle *loginThrottle
 }
 
 func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{db: db, cfg: cfg}
+	return &AuthHandler{db: db, cfg: cfg, throttle: newLoginThrottle(cfg, nil)}
 }
 
 type LoginRequest struct {
@@ -38,9 +39,12 @@ type PINLoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token            string      `json:"token"`
-	User             models.User `json:"user"`
-	LastStocktakeAt  *string     `json:"last_stocktake_at,omitempty"`
+	Token           string      `json:"token"`
+	RefreshToken    string      `json:"refresh_token,omitempty"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"` // access token expiry
+	SessionID       uint        `json:"session_id,omitempty"`
+	User            models.User `json:"user"`
+	LastStocktakeAt *string     `json:"last_stocktake_at,omitempty"`
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -56,9 +60,17 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	userKey, sourceKey := loginUserKey(req.Username), loginSourceKey(c, nil)
+	attempt := h.loginBlocked(c, userKey, sourceKey)
+	if attempt == nil {
+		return
+	}
+	defer attempt.release()
+
 	var user models.User
 	if err := h.db.Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.loginFailed(c, nil, "password", nil, attempt)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		} else {
 			// Log the actual error for debugging
@@ -69,6 +81,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Verify password (supports both Argon2 hash and plain text for easy setup)
 	if !utils.VerifyPassword(req.Password, user.PasswordHash) {
+		h.loginFailed(c, &user, "password", nil, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -87,17 +100,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		}
 	}
 
-	token, err := h.generateJWT(user.ID, user.Username, user.Role)
+	// The failure count is only cleared once any second step has passed too.
+	if h.passwordLoginChallenge(c, &user) {
+		return
+	}
+	attempt.succeeded()
+
+	tokens, err := h.startSession(c, &user, nil, "password")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Clear sensitive data
-	user.PasswordHash = ""
-	user.PINHash = ""
-
-	resp := LoginResponse{Token: token, User: user}
+	resp := loginResponse(user, tokens)
 	if req.DeviceCode != "" {
 		if lastAt := h.lastStocktakeAtForDevice(req.DeviceCode); lastAt != nil {
 			resp.LastStocktakeAt = lastAt
@@ -106,14 +121,54 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-// Refresh issues a new JWT when the caller presents a current or recently-expired token
-// (same calendar day grace). Keeps management sessions alive during long work days.
+// Refresh swaps a refresh token for a new access token and a new refresh token; each refresh token works once.
+// Without a refresh token it falls back to legacyRefresh.
 func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	presented := strings.TrimSpace(req.RefreshToken)
+	if presented == "" {
+		h.legacyRefresh(c)
+		return
+	}
 	if h.db == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database connection not available. Please try again later."})
 		return
 	}
 
+	session, refresh, err := h.rotateRefreshToken(c, presented)
+	if errors.Is(err, errRefreshRaced) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Refresh token already used; retry with the latest one"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+		return
+	}
+	user, device, ok := h.sessionUserAndDevice(c, session)
+	if !ok {
+		return
+	}
+	token, expiresAt, err := h.generateJWT(user, device, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(*user, sessionTokens{AccessToken: token, RefreshToken: refresh, ExpiresAt: expiresAt, SessionID: session.ID}))
+}
+
+// legacyRefresh renews an access token presented in the Authorization header (current or recently expired) when
+// SESSION_LEGACY_REFRESH is on, for clients that do not store refresh tokens yet. The token's session must still be
+// active, so logout and revocation apply; no refresh token is issued. A token from before sessions existed is moved
+// onto a new session only if legacyTokenConvertible accepts it. This path is due for removal after 30 April 2027
+// (see config.SessionLegacyRefresh).
+func (h *AuthHandler) legacyRefresh(c *gin.Context) {
+	if !h.cfg.SessionLegacyRefresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh_token is required"})
+		return
+	}
 	tokenString := bearerTokenFromHeader(c.GetHeader("Authorization"))
 	if tokenString == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -121,36 +176,142 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	}
 
 	claims, err := parseJWTClaimsWithGrace(tokenString, h.cfg.JWTSecret, refreshGracePeriod(h.cfg.JWTExpiration))
-	if err != nil {
+	if err != nil || !isAccessTokenClaims(claims) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
-
-	uid, ok := jwtClaimUint(claims, "user_id")
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database connection not available. Please try again later."})
+		return
+	}
+	uid, _ := jwtClaimUint(claims, "user_id")
+	sid, _ := jwtClaimUint(claims, "sid")
+	if sid == 0 {
+		sessionsSince, err := h.firstSessionAt()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+			return
+		}
+		if !legacyTokenConvertible(claims, sessionsSince, time.Now()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+			return
+		}
+		h.legacyTokenToSession(c, claims, uid)
+		return
+	}
+	var session models.UserSession
+	if err := h.db.First(&session, sid).Error; err != nil || sid == 0 || session.UserID != uid ||
+		session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+		return
+	}
+	user, device, ok := h.sessionUserAndDevice(c, &session)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: missing or invalid user_id"})
 		return
 	}
+	if device != nil {
+		if fp, _ := jwtClaimString(claims, "device_token_fp"); deviceTokenFingerprint(device) != fp {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Device access has been revoked"})
+			return
+		}
+	}
+	h.db.Model(&session).Updates(map[string]interface{}{"last_seen_at": time.Now(), "ip_address": c.ClientIP()})
 
+	token, expiresAt, err := h.generateJWT(user, device, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse(*user, sessionTokens{AccessToken: token, ExpiresAt: expiresAt, SessionID: session.ID}))
+}
+
+// isAccessTokenClaims reports whether claims belong to an access token: it has a role and no purpose, so a login
+// challenge or any other token that ever shared the signing secret is refused.
+func isAccessTokenClaims(claims jwt.MapClaims) bool {
+	if _, ok := jwtClaimString(claims, "role"); !ok {
+		return false
+	}
+	_, hasPurpose := claims["purpose"]
+	return !hasPurpose
+}
+
+// legacyTokenLeeway is how long past its expiry a pre-session access token can still be moved onto a session.
+const legacyTokenLeeway = time.Hour
+
+// legacyTokenConvertible reports whether claims look like an access token issued before sessions existed: it has a
+// role and no purpose (so it is not a challenge or other token signed with the same secret), was issued before the
+// first session (sessionsSince; nil when there is none yet) and expired no more than legacyTokenLeeway ago.
+func legacyTokenConvertible(claims jwt.MapClaims, sessionsSince *time.Time, now time.Time) bool {
+	if !isAccessTokenClaims(claims) {
+		return false
+	}
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil || (sessionsSince != nil && !iat.Before(*sessionsSince)) {
+		return false
+	}
+	exp, err := claims.GetExpirationTime()
+	return err == nil && exp != nil && now.Before(exp.Add(legacyTokenLeeway))
+}
+
+// firstSessionAt is when the first session was created, i.e. when sessions went live; nil if none has been yet.
+func (h *AuthHandler) firstSessionAt() (*time.Time, error) {
+	var first struct{ At *time.Time }
+	err := h.db.Model(&models.UserSession{}).Select("MIN(created_at) AS at").Scan(&first).Error
+	return first.At, err
+}
+
+// legacyTokenToSession moves a token issued before sessions existed onto a new session, so that logout and
+// revocation apply from now on. The response carries a refresh token for clients that store one.
+func (h *AuthHandler) legacyTokenToSession(c *gin.Context, claims jwt.MapClaims, uid uint) {
 	var user models.User
 	if err := h.db.Where("id = ? AND is_active = ?", uid, true).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
 		return
 	}
-
-	token, err := h.generateJWT(user.ID, user.Username, user.Role)
+	var device *models.POSDevice
+	if deviceID, ok := jwtClaimUint(claims, "device_id"); ok {
+		var d models.POSDevice
+		fp, _ := jwtClaimString(claims, "device_token_fp")
+		if err := h.db.First(&d, deviceID).Error; err != nil || !d.IsActive || d.RevokedAt != nil || d.TokenHash == "" ||
+			deviceTokenFingerprint(&d) != fp {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Device access has been revoked"})
+			return
+		}
+		device = &d
+	}
+	method := "password"
+	if device != nil {
+		method = "pin"
+	}
+	tokens, err := h.startSession(c, &user, device, method)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	c.JSON(http.StatusOK, loginResponse(user, tokens))
+}
 
-	user.PasswordHash = ""
-	user.PINHash = ""
-	c.JSON(http.StatusOK, LoginResponse{Token: token, User: user})
+// sessionUserAndDevice loads the session's user, and its till for PIN sessions, refusing inactive users and
+// revoked devices.
+func (h *AuthHandler) sessionUserAndDevice(c *gin.Context, session *models.UserSession) (*models.User, *models.POSDevice, bool) {
+	var user models.User
+	if err := h.db.Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+		}
+		return nil, nil, false
+	}
+	if session.DeviceID == nil {
+		return &user, nil, true
+	}
+	var device models.POSDevice
+	if err := h.db.First(&device, *session.DeviceID).Error; err != nil || !device.IsActive || device.RevokedAt != nil || device.TokenHash == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Device access has been revoked"})
+		return nil, nil, false
+	}
+	return &user, &device, true
 }
 
 func bearerTokenFromHeader(header string) string {
@@ -197,21 +358,30 @@ func (h *AuthHandler) PINLogin(c *gin.Context) {
 		return
 	}
 
-	// Verify device code if provided and get store for last_stocktake_at
+	// Verify device code and token if provided and get store for last_stocktake_at
 	var deviceStoreID *uint
+	var device *models.POSDevice
 	if req.DeviceCode != "" {
-		normalizedDeviceCode := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(req.DeviceCode))
-		var device models.POSDevice
-		if err := h.db.Where("device_code = ? AND is_active = ?", normalizedDeviceCode, true).First(&device).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid device code"})
+		d, _, msg := authenticateDevice(h.db, h.cfg, req.DeviceCode, deviceTokenFromRequest(c))
+		if d == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
 			return
 		}
+		device = d
 		deviceStoreID = &device.StoreID
 	}
 
+	userKey, sourceKey := loginUserKey(req.Username), loginSourceKey(c, device)
+	attempt := h.loginBlocked(c, userKey, sourceKey)
+	if attempt == nil {
+		return
+	}
+	defer attempt.release()
+
 	var user models.User
 	if err := h.db.Where("username = ? AND is_active = ?", req.Username, true).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.loginFailed(c, nil, "pin", device, attempt)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
@@ -226,10 +396,25 @@ func (h *AuthHandler) PINLogin(c *gin.Context) {
 
 	// Verify PIN (supports both Argon2 hash and plain text for easy setup)
 	if !utils.VerifyPassword(req.PIN, user.PINHash) {
+		h.loginFailed(c, &user, "pin", device, attempt)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid PIN"})
 		return
 	}
 
+	if names, err := rolePermissionNames(h.db, user.Role); err != nil || !stringSliceContains(names, models.PermPOSLogin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user cannot log in on a till"})
+		return
+	}
+
+	// Only PIN logins on an enrolled till skip the second factor: a till let in by code alone under
+	// DEVICE_LEGACY_ACCESS proves nothing about where the login comes from.
+	if !deviceEnrolled(device) && (confirmedTwoFactor(h.db, user.ID) != nil || roleRequiresTwoFactor(h.db, user.Role)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is on for this account; sign in with your password"})
+		return
+	}
+	// Only a login that passed every gate clears the throttle; a refused one just hands its reservation back.
+	attempt.succeeded()
+
 	// If PIN was plain text, hash it with Argon2 and update the database
 	if utils.IsPlainText(user.PINHash) {
 		hashedPIN, err := utils.HashPIN(req.PIN)
@@ -244,17 +429,13 @@ func (h *AuthHandler) PINLogin(c *gin.Context) {
 		}
 	}
 
-	token, err := h.generateJWT(user.ID, user.Username, user.Role)
+	tokens, err := h.startSession(c, &user, device, "pin")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Clear sensitive data
-	user.PasswordHash = ""
-	user.PINHash = ""
-
-	resp := LoginResponse{Token: token, User: user}
+	resp := loginResponse(user, tokens)
 	if deviceStoreID != nil {
 		if lastAt := h.lastStocktakeAtForStore(*deviceStoreID); lastAt != nil {
 			resp.LastStocktakeAt = lastAt
@@ -290,17 +471,26 @@ func (h *AuthHandler) lastStocktakeAtForStore(storeID uint) *string {
 	return &s
 }
 
-func (h *AuthHandler) generateJWT(userID uint, username, role string) (string, error) {
+// generateJWT signs a short-lived access token for a session. device is set for PIN logins on an enrolled till,
+// binding the token to it.
+func (h *AuthHandler) generateJWT(user *models.User, device *models.POSDevice, sessionID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(time.Duration(h.cfg.AccessTokenMinutes) * time.Minute)
 	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"role":     role,
-		"exp":      time.Now().Add(time.Duration(h.cfg.JWTExpiration) * time.Hour).Unix(),
+		"user_id":  user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+		"sid":      sessionID,
+		"exp":      expiresAt.Unix(),
 		"iat":      time.Now().Unix(),
 	}
+	if device != nil && device.TokenHash != "" {
+		claims["device_id"] = device.ID
+		claims["device_token_fp"] = deviceTokenFingerprint(device)
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.cfg.JWTSecret))
+	signed, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	return signed, expiresAt, err
 }
 
 // jwtClaimUint reads user_id-style numeric claims without panicking (jwt.JSON can decode numbers as float64).
@@ -341,6 +531,10 @@ func jwtClaimString(claims jwt.MapClaims, key string) (string, bool) {
 
 func authMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if contextAPIKeyID(c) != nil {
+			c.Next() // authenticated by apiKeyMiddleware
+			return
+		}
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -387,6 +581,14 @@ func authMiddleware(jwtSecret string) gin.HandlerFunc {
 		c.Set("user_id", uid)
 		c.Set("username", username)
 		c.Set("role", role)
+		if sid, ok := jwtClaimUint(claims, "sid"); ok {
+			c.Set("session_id", sid)
+		}
+		if deviceID, ok := jwtClaimUint(claims, "device_id"); ok {
+			fp, _ := jwtClaimString(claims, "device_token_fp")
+			c.Set("device_id", deviceID)
+			c.Set("device_token_fp", fp)
+		}
 
 		c.Next()
 	}
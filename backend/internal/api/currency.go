@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -153,24 +154,47 @@ func (h *CurrencyHandler) DeleteCurrencyRate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Currency rate deleted"})
 }
 
-// SyncCurrencyRates fetches latest rates from a free API and updates the database
-// Main purchasing currencies: CNY, USD, HKD, JPY (can be pinned after sync)
+// SyncCurrencyRates queues a currency_sync job and returns its id to poll; see syncRates.
 func (h *CurrencyHandler) SyncCurrencyRates(c *gin.Context) {
+	job, err := enqueueJob(c, h.db, jobTypeCurrencySync, struct{}{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue currency sync: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Currency rate sync queued",
+		"job_id":  job.ID,
+	})
+}
+
+func (h *CurrencyHandler) runSyncJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	updated, syncedAt, err := h.syncRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"updated_count": updated, "sync_date": syncedAt}, nil
+}
+
+// syncRates fetches latest rates from a free API and updates the database
+// Main purchasing currencies: CNY, USD, HKD, JPY (can be pinned after sync)
+func (h *CurrencyHandler) syncRates(ctx context.Context) (int, time.Time, error) {
 	// Using exchangerate-api.com free tier (no API key needed for basic usage)
 	// Base currency: GBP
 	url := "https://api.exchangerate-api.com/v4/latest/GBP"
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch rates: %v", err)})
-		return
+		return 0, time.Time{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("fetch rates: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("API returned status %d: %s", resp.StatusCode, string(body))})
-		return
+		return 0, time.Time{}, fmt.Errorf("rates API returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var apiResponse struct {
@@ -180,8 +204,7 @@ func (h *CurrencyHandler) SyncCurrencyRates(c *gin.Context) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse API response: %v", err)})
-		return
+		return 0, time.Time{}, fmt.Errorf("parse rates response: %w", err)
 	}
 
 	// Update or create currency rates
@@ -245,9 +268,5 @@ func (h *CurrencyHandler) SyncCurrencyRates(c *gin.Context) {
 		updatedCount++
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":       "Currency rates synced successfully",
-		"updated_count": updatedCount,
-		"sync_date":     now,
-	})
+	return updatedCount, now, nil
 }
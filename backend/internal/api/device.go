@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"pos-system/backend/internal/config"
 	"pos-system/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -12,11 +13,12 @@ import (
 )
 
 type DeviceHandler struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg *config.Config
 }
 
-func NewDeviceHandler(db *gorm.DB) *DeviceHandler {
-	return &DeviceHandler{db: db}
+func NewDeviceHandler(db *gorm.DB, cfg *config.Config) *DeviceHandler {
+	return &DeviceHandler{db: db, cfg: cfg}
 }
 
 // normalizeDeviceCodeForStorage wraps device code with braces for database storage
@@ -83,16 +85,20 @@ type ConfigureDeviceRequest struct {
 	DeviceCode string `json:"device_code" binding:"required"`
 	StoreID    uint   `json:"store_id" binding:"required"`
 	DeviceName string `json:"device_name"`
+	IssueToken bool   `json:"issue_token"` // rotate the device token even if one was already issued
 }
 
-// ConfigureDevice adds this device to a store or updates its store (management only).
-func (h *DeviceHandler) ConfigureDevice(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "management" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only management can configure devices"})
-		return
-	}
+// configuredDeviceResponse is the device plus its token when one was issued by this call.
+type configuredDeviceResponse struct {
+	models.POSDevice
+	DeviceToken string `json:"device_token,omitempty"`
+}
 
+// ConfigureDevice adds this device to a store or updates its store (device.manage). It is called from the till
+// itself, so it also enrols the device: a token is issued when the device has none (or issue_token is set). While
+// DEVICE_LEGACY_ACCESS is on, tills that cannot store a token yet keep working by code, so a token is only issued
+// when the till asks for one.
+func (h *DeviceHandler) ConfigureDevice(c *gin.Context) {
 	var req ConfigureDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -115,7 +121,17 @@ func (h *DeviceHandler) ConfigureDevice(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusCreated, device)
+		resp := configuredDeviceResponse{POSDevice: device}
+		if req.IssueToken || !h.legacyAccess() {
+			token, err := issueDeviceToken(h.db, &device)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue device token"})
+				return
+			}
+			resp.DeviceToken = token
+			h.audit(c, "pos_device_enrol", device.ID, map[string]interface{}{"method": "configure"})
+		}
+		c.JSON(http.StatusCreated, resp)
 		return
 	}
 
@@ -128,32 +144,34 @@ func (h *DeviceHandler) ConfigureDevice(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	resp := configuredDeviceResponse{}
+	if req.IssueToken || (device.TokenHash == "" && !h.legacyAccess()) {
+		token, err := issueDeviceToken(h.db, &device)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue device token"})
+			return
+		}
+		resp.DeviceToken = token
+		h.audit(c, "pos_device_enrol", device.ID, map[string]interface{}{"method": "configure"})
+	}
 	if err := h.db.Preload("Store").First(&device, device.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, device)
+	resp.POSDevice = device
+	c.JSON(http.StatusOK, resp)
 }
 
+// GetUsersForDevice lists staff who can log in on the device's store. Requires deviceAuthMiddleware.
 func (h *DeviceHandler) GetUsersForDevice(c *gin.Context) {
-	// Get device code from URL parameter and normalize it (strip braces if present)
-	rawDeviceCode := c.Param("device_code")
-	// Normalize for lookup: strip braces from URL parameter, then wrap for database lookup
-	normalizedDeviceCode := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(rawDeviceCode))
-
-	var device models.POSDevice
-	if err := h.db.Where("device_code = ? AND is_active = ?", normalizedDeviceCode, true).
-		Preload("Store").First(&device).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
-		return
-	}
+	device := deviceFromContext(c)
 
 	// Get users assigned to this store
 	var users []models.User
 	if err := h.db.Table("users").
 		Joins("JOIN user_stores ON users.id = user_stores.user_id").
-		Where("user_stores.store_id = ? AND users.is_active = ? AND users.role IN (?, ?, ?)",
-			device.StoreID, true, "pos_user", "supervisor", "management").
+		Where("user_stores.store_id = ? AND users.is_active = ? AND users.role IN (?)",
+			device.StoreID, true, posLoginRoles(h.db)).
 		Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -168,18 +186,10 @@ func (h *DeviceHandler) GetUsersForDevice(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
-// GetDeviceInfo returns device info including last_stocktake_at for the device's store (public).
+// GetDeviceInfo returns device info including last_stocktake_at for the device's store (device token required).
 // last_stocktake_at is the latest occurred_at for event_type = stocktake_day_start_done only (skip does not clear reminder).
 func (h *DeviceHandler) GetDeviceInfo(c *gin.Context) {
-	rawDeviceCode := c.Param("device_code")
-	normalizedDeviceCode := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(rawDeviceCode))
-
-	var device models.POSDevice
-	if err := h.db.Where("device_code = ? AND is_active = ?", normalizedDeviceCode, true).
-		Preload("Store").First(&device).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
-		return
-	}
+	device := deviceFromContext(c)
 
 	var lastAt *time.Time
 	var out struct {
@@ -211,17 +221,10 @@ func (h *DeviceHandler) GetDeviceInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// GetProductsForDevice returns active products with POS prices. Cost fields are stripped unless the device is
+// allowed cost data. Requires deviceAuthMiddleware.
 func (h *DeviceHandler) GetProductsForDevice(c *gin.Context) {
-	// Get device code from URL parameter and normalize it (strip braces if present)
-	rawDeviceCode := c.Param("device_code")
-	// Normalize for lookup: strip braces from URL parameter, then wrap for database lookup
-	normalizedDeviceCode := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(rawDeviceCode))
-
-	var device models.POSDevice
-	if err := h.db.Where("device_code = ? AND is_active = ?", normalizedDeviceCode, true).First(&device).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
-		return
-	}
+	device := deviceFromContext(c)
 
 	// Get all active products with current costs
 	var products []models.Product
@@ -274,6 +277,9 @@ func (h *DeviceHandler) GetProductsForDevice(c *gin.Context) {
 			posPrice = basePrice * (1 - defaultSectorDiscountRate/100.0)
 		}
 
+		if !device.AllowCostData {
+			product.CurrentCost = redactProductCost(product.CurrentCost)
+		}
 		productResponses = append(productResponses, ProductResponse{
 			Product:  product,
 			POSPrice: posPrice,
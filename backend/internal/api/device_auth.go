@@ -0,0 +1,337 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	deviceTokenPrefix          = "pdt_"
+	deviceEnrolmentCodeTTL     = 15 * time.Minute
+	deviceEnrolmentMaxAttempts = 5
+	// deviceTokenFingerprintLen is how much of the token digest a device login JWT carries, so re-enrolling or
+	// revoking a device also ends the staff sessions started on it.
+	deviceTokenFingerprintLen = 16
+	deviceLastSeenInterval    = time.Minute
+)
+
+// enrolmentCodeAlphabet leaves out characters that are easy to misread on a till screen (0/O, 1/I/L).
+const enrolmentCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// deviceTokenFromRequest reads the device token from "Authorization: Bearer <token>" or X-Device-Token.
+func deviceTokenFromRequest(c *gin.Context) string {
+	if t := strings.TrimSpace(c.GetHeader("X-Device-Token")); t != "" {
+		return t
+	}
+	if t := bearerTokenFromHeader(c.GetHeader("Authorization")); strings.HasPrefix(t, deviceTokenPrefix) {
+		return t
+	}
+	return ""
+}
+
+// authenticateDevice resolves a device code and checks the presented token. On failure it returns the HTTP status
+// and message to send; revoked and deactivated devices are refused even in legacy mode.
+func authenticateDevice(db *gorm.DB, cfg *config.Config, rawDeviceCode, token string) (*models.POSDevice, int, string) {
+	code := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(rawDeviceCode))
+	var device models.POSDevice
+	if err := db.Preload("Store").Where("device_code = ?", code).First(&device).Error; err != nil {
+		return nil, http.StatusNotFound, "Device not found"
+	}
+	if device.RevokedAt != nil {
+		return nil, http.StatusUnauthorized, "Device access has been revoked"
+	}
+	if !device.IsActive {
+		return nil, http.StatusNotFound, "Device not found"
+	}
+	if device.TokenHash == "" {
+		if cfg != nil && cfg.DeviceLegacyAccess && token == "" {
+			return &device, 0, ""
+		}
+		return nil, http.StatusUnauthorized, "Device is not enrolled"
+	}
+	if !utils.TokenMatches(token, device.TokenHash) {
+		return nil, http.StatusUnauthorized, "Invalid device token"
+	}
+	return &device, 0, ""
+}
+
+// deviceEnrolled reports whether device was authenticated by its own token, rather than by code alone.
+func deviceEnrolled(device *models.POSDevice) bool {
+	return device != nil && device.TokenHash != ""
+}
+
+// deviceAuthMiddleware guards /device/:device_code routes: the device must be active, not revoked, and present its
+// token. The device is checked on every request, so revoking it cuts sync off immediately.
+func deviceAuthMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		device, status, msg := authenticateDevice(db, cfg, c.Param("device_code"), deviceTokenFromRequest(c))
+		if device == nil {
+			c.JSON(status, gin.H{"error": msg})
+			c.Abort()
+			return
+		}
+		now := time.Now()
+		if device.LastSeenAt == nil || now.Sub(*device.LastSeenAt) > deviceLastSeenInterval {
+			db.Model(&models.POSDevice{}).Where("id = ?", device.ID).UpdateColumn("last_seen_at", now)
+		}
+		c.Set("pos_device", device)
+		c.Next()
+	}
+}
+
+// deviceFromContext returns the device authenticated by deviceAuthMiddleware.
+func deviceFromContext(c *gin.Context) *models.POSDevice {
+	v, _ := c.Get("pos_device")
+	d, _ := v.(*models.POSDevice)
+	return d
+}
+
+// deviceTokenFingerprint is the prefix of the token digest embedded in device login JWTs.
+func deviceTokenFingerprint(d *models.POSDevice) string {
+	if len(d.TokenHash) < deviceTokenFingerprintLen {
+		return ""
+	}
+	return d.TokenHash[:deviceTokenFingerprintLen]
+}
+
+// deviceSessionMiddleware ends staff sessions started on a till once that device is revoked, deactivated or
+// re-enrolled. Tokens without a device claim (management web, mobile) pass straight through.
+func deviceSessionMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get("device_id")
+		deviceID, _ := v.(uint)
+		if !ok || deviceID == 0 {
+			c.Next()
+			return
+		}
+		fp := c.GetString("device_token_fp")
+		var device models.POSDevice
+		if err := db.Select("id", "is_active", "revoked_at", "token_hash").First(&device, deviceID).Error; err != nil ||
+			!device.IsActive || device.RevokedAt != nil || deviceTokenFingerprint(&device) != fp {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Device access has been revoked"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// issueDeviceToken generates a new token for the device, replacing any previous one and lifting a revocation.
+// Staff sessions started under the old token end.
+func issueDeviceToken(db *gorm.DB, device *models.POSDevice) (string, error) {
+	token, err := utils.GenerateToken(deviceTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	updates := map[string]interface{}{
+		"token_hash": utils.HashToken(token), "token_issued_at": now, "revoked_at": nil, "is_active": true,
+		"enrolment_code_hash": "", "enrolment_code_expires_at": nil, "enrolment_attempts": 0,
+	}
+	if err := db.Model(device).Updates(updates).Error; err != nil {
+		return "", err
+	}
+	revokeDeviceSessions(db, device.ID)
+	device.TokenHash, device.TokenIssuedAt, device.RevokedAt, device.IsActive = updates["token_hash"].(string), &now, nil, true
+	device.EnrolmentCodeHash, device.EnrolmentCodeExpiresAt, device.EnrolmentAttempts = "", nil, 0
+	return token, nil
+}
+
+// newEnrolmentCode returns a one-time code formatted XXXX-XXXX.
+func newEnrolmentCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	out := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			out = append(out, '-')
+		}
+		out = append(out, enrolmentCodeAlphabet[int(v)%len(enrolmentCodeAlphabet)])
+	}
+	return string(out), nil
+}
+
+// normaliseEnrolmentCode drops separators and case so "abcd-efgh" and "ABCDEFGH" match.
+func normaliseEnrolmentCode(s string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+// redactProductCost keeps only the selling price on a device product sync; purchasing, freight, duty and wholesale
+// cost stay on the server unless the device is allowed cost data.
+func redactProductCost(cost *models.ProductCost) *models.ProductCost {
+	if cost == nil {
+		return nil
+	}
+	return &models.ProductCost{
+		ID: cost.ID, ProductID: cost.ProductID, UnitWeightG: cost.UnitWeightG,
+		DirectRetailOnlineStorePriceGBP: cost.DirectRetailOnlineStorePriceGBP,
+		EffectiveFrom:                   cost.EffectiveFrom, EffectiveTo: cost.EffectiveTo, CreatedAt: cost.CreatedAt,
+	}
+}
+
+func (h *DeviceHandler) audit(c *gin.Context, action string, deviceID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: "pos_device",
+		EntityID:   &deviceID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+// legacyAccess reports whether tills without a device token may still call the device endpoints by code.
+func (h *DeviceHandler) legacyAccess() bool {
+	return h.cfg != nil && h.cfg.DeviceLegacyAccess
+}
+
+func (h *DeviceHandler) loadManagedDevice(c *gin.Context) (*models.POSDevice, bool) {
+	var device models.POSDevice
+	if err := h.db.Preload("Store").First(&device, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return nil, false
+	}
+	return &device, true
+}
+
+// CreateEnrolmentCode issues a one-time code (valid 15 minutes) that the till exchanges for its device token.
+func (h *DeviceHandler) CreateEnrolmentCode(c *gin.Context) {
+	device, ok := h.loadManagedDevice(c)
+	if !ok {
+		return
+	}
+	code, err := newEnrolmentCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate enrolment code"})
+		return
+	}
+	expires := time.Now().Add(deviceEnrolmentCodeTTL)
+	if err := h.db.Model(device).Updates(map[string]interface{}{
+		"enrolment_code_hash": utils.HashToken(normaliseEnrolmentCode(code)), "enrolment_code_expires_at": expires, "enrolment_attempts": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "pos_device_enrolment_code", device.ID, map[string]interface{}{"expires_at": expires.Format(time.RFC3339)})
+	c.JSON(http.StatusOK, gin.H{"enrolment_code": code, "expires_at": expires})
+}
+
+// EnrolDevice is called by the till with its device code and the one-time enrolment code; it returns the device
+// token, which is not shown again.
+func (h *DeviceHandler) EnrolDevice(c *gin.Context) {
+	var req struct {
+		DeviceCode    string `json:"device_code" binding:"required"`
+		EnrolmentCode string `json:"enrolment_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	code := normalizeDeviceCodeForStorage(normalizeDeviceCodeForLookup(req.DeviceCode))
+	var device models.POSDevice
+	if err := h.db.Where("device_code = ?", code).First(&device).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired enrolment code"})
+		return
+	}
+	if device.EnrolmentCodeHash == "" || device.EnrolmentCodeExpiresAt == nil || time.Now().After(*device.EnrolmentCodeExpiresAt) ||
+		device.EnrolmentAttempts >= deviceEnrolmentMaxAttempts {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired enrolment code"})
+		return
+	}
+	if !utils.TokenMatches(normaliseEnrolmentCode(req.EnrolmentCode), device.EnrolmentCodeHash) {
+		h.db.Model(&models.POSDevice{}).Where("id = ?", device.ID).UpdateColumn("enrolment_attempts", gorm.Expr("enrolment_attempts + 1"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired enrolment code"})
+		return
+	}
+	// Claim the code so two tills racing with the same code cannot both enrol.
+	res := h.db.Model(&models.POSDevice{}).Where("id = ? AND enrolment_code_hash = ?", device.ID, device.EnrolmentCodeHash).
+		Update("enrolment_code_hash", "")
+	if res.Error != nil || res.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired enrolment code"})
+		return
+	}
+	token, err := issueDeviceToken(h.db, &device)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue device token"})
+		return
+	}
+	h.audit(c, "pos_device_enrol", device.ID, map[string]interface{}{"method": "enrolment_code"})
+	c.JSON(http.StatusOK, gin.H{"device": device, "device_token": token})
+}
+
+// RevokeDevice clears the device token; the till is refused on its next call and staff sessions started on it end.
+func (h *DeviceHandler) RevokeDevice(c *gin.Context) {
+	device, ok := h.loadManagedDevice(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if err := h.db.Model(device).Updates(map[string]interface{}{
+		"revoked_at": now, "token_hash": "", "enrolment_code_hash": "", "enrolment_code_expires_at": nil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	revokeDeviceSessions(h.db, device.ID)
+	h.audit(c, "pos_device_revoke", device.ID, map[string]interface{}{"device_code": device.DeviceCode})
+	device.RevokedAt, device.TokenHash = &now, ""
+	c.JSON(http.StatusOK, device)
+}
+
+// UpdateDevice changes a device's name, active flag or cost-data permission.
+func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	device, ok := h.loadManagedDevice(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		DeviceName    *string `json:"device_name"`
+		IsActive      *bool   `json:"is_active"`
+		AllowCostData *bool   `json:"allow_cost_data"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	if req.DeviceName != nil {
+		updates["device_name"] = strings.TrimSpace(*req.DeviceName)
+		changes["device_name"] = map[string]interface{}{"old": device.DeviceName, "new": updates["device_name"]}
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+		changes["is_active"] = map[string]interface{}{"old": device.IsActive, "new": *req.IsActive}
+	}
+	if req.AllowCostData != nil {
+		updates["allow_cost_data"] = *req.AllowCostData
+		changes["allow_cost_data"] = map[string]interface{}{"old": device.AllowCostData, "new": *req.AllowCostData}
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(device).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(c, "pos_device_update", device.ID, changes)
+		if req.IsActive != nil && !*req.IsActive {
+			revokeDeviceSessions(h.db, device.ID)
+		}
+	}
+	h.db.Preload("Store").First(device, device.ID)
+	c.JSON(http.StatusOK, device)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+)
+
+func TestEnrolmentCode(t *testing.T) {
+	code, err := newEnrolmentCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 9 || code[4] != '-' {
+		t.Fatalf("newEnrolmentCode() = %q, want XXXX-XXXX", code)
+	}
+	if got := normaliseEnrolmentCode(" " + strings.ToLower(code) + " "); got != strings.Replace(code, "-", "", 1) {
+		t.Errorf("normaliseEnrolmentCode(%q) = %q", code, got)
+	}
+}
+
+func TestRedactProductCost(t *testing.T) {
+	cost := &models.ProductCost{ID: 3, ProductID: 9, WholesaleCostGBP: 4.2, PurchasingCostGBP: 2.1, FreightGBP: 0.4, DirectRetailOnlineStorePriceGBP: 12.99}
+	got := redactProductCost(cost)
+	if got.DirectRetailOnlineStorePriceGBP != 12.99 || got.ProductID != 9 {
+		t.Errorf("selling price not kept: %+v", got)
+	}
+	if got.WholesaleCostGBP != 0 || got.PurchasingCostGBP != 0 || got.FreightGBP != 0 {
+		t.Errorf("cost data not redacted: %+v", got)
+	}
+	if redactProductCost(nil) != nil {
+		t.Error("redactProductCost(nil) should be nil")
+	}
+}
+
+func TestTokenMatches(t *testing.T) {
+	token, err := utils.GenerateToken("pdt_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "pdt_") {
+		t.Errorf("token %q missing prefix", token)
+	}
+	digest := utils.HashToken(token)
+	if !utils.TokenMatches(token, digest) {
+		t.Error("token should match its own digest")
+	}
+	if utils.TokenMatches(token+"x", digest) || utils.TokenMatches(token, "") {
+		t.Error("token matched the wrong digest")
+	}
+}
+
+func TestDeviceEnrolled(t *testing.T) {
+	if deviceEnrolled(nil) {
+		t.Error("no device counted as enrolled")
+	}
+	if deviceEnrolled(&models.POSDevice{DeviceCode: "TILL-1", IsActive: true}) {
+		t.Error("a till let in by code alone (DEVICE_LEGACY_ACCESS) counted as enrolled, so its PIN logins would skip 2FA")
+	}
+	if !deviceEnrolled(&models.POSDevice{DeviceCode: "TILL-1", IsActive: true, TokenHash: "hash"}) {
+		t.Error("a till with a device token not counted as enrolled")
+	}
+}
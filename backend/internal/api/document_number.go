@@ -0,0 +1,401 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultDocumentNumberSeries apply until management saves their own format.
+var defaultDocumentNumberSeries = map[string]models.DocumentNumberSeries{
+	models.DocumentSeriesInvoice:    {Type: models.DocumentSeriesInvoice, Prefix: "INV-", Padding: 6, ResetYearly: true},
+	models.DocumentSeriesCreditNote: {Type: models.DocumentSeriesCreditNote, Prefix: "CN-", Padding: 6, ResetYearly: true},
+}
+
+var errDocumentNumberClaimed = errors.New("document number already assigned")
+
+// formatDocumentNumber renders prefix + [year-] + zero-padded sequence, e.g. INV-2025-000042.
+func formatDocumentNumber(series models.DocumentNumberSeries, year, seq int) string {
+	padding := series.Padding
+	if padding < 1 {
+		padding = 1
+	}
+	num := fmt.Sprintf("%0*d", padding, seq)
+	if series.ResetYearly && year > 0 {
+		return fmt.Sprintf("%s%d-%s", series.Prefix, year, num)
+	}
+	return series.Prefix + num
+}
+
+func loadDocumentNumberSeries(db *gorm.DB, seriesType string) models.DocumentNumberSeries {
+	var series models.DocumentNumberSeries
+	if err := db.Where("type = ?", seriesType).First(&series).Error; err != nil {
+		return defaultDocumentNumberSeries[seriesType]
+	}
+	return series
+}
+
+// issueDocumentNumber takes the next number in a series inside tx. The counter row is incremented with a single
+// UPDATE, which holds its row lock until tx commits; if tx rolls back the increment is undone, so no gap is left.
+// The first number of a series or year seeds the counter with an insert that ignores the unique key, so two
+// requests starting it together both go on to the UPDATE instead of one failing on the duplicate row.
+func issueDocumentNumber(tx *gorm.DB, seriesType string, now time.Time, orderID, returnID, userID *uint) (models.DocumentNumber, error) {
+	series := loadDocumentNumberSeries(tx, seriesType)
+	year := 0
+	if series.ResetYearly {
+		year = now.Year()
+	}
+	counter := models.DocumentNumberCounter{SeriesType: seriesType, Year: year}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+		return models.DocumentNumber{}, err
+	}
+	if err := tx.Model(&models.DocumentNumberCounter{}).Where("series_type = ? AND year = ?", seriesType, year).
+		Update("last_value", gorm.Expr("last_value + 1")).Error; err != nil {
+		return models.DocumentNumber{}, err
+	}
+	counter = models.DocumentNumberCounter{}
+	if err := tx.Where("series_type = ? AND year = ?", seriesType, year).First(&counter).Error; err != nil {
+		return models.DocumentNumber{}, err
+	}
+	num := models.DocumentNumber{
+		SeriesType:        seriesType,
+		Year:              year,
+		Sequence:          counter.LastValue,
+		Number:            formatDocumentNumber(series, year, counter.LastValue),
+		WholesaleOrderID:  orderID,
+		WholesaleReturnID: returnID,
+		UserID:            userID,
+		IssuedAt:          now,
+	}
+	if err := tx.Create(&num).Error; err != nil {
+		return models.DocumentNumber{}, err
+	}
+	return num, nil
+}
+
+func contextUserID(c *gin.Context) *uint {
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get("user_id"); ok {
+		if uid, ok := v.(uint); ok && uid > 0 {
+			return &uid
+		}
+	}
+	return nil
+}
+
+// documentNumberIsLive reports whether number was issued and has not been voided.
+func documentNumberIsLive(db *gorm.DB, number string) bool {
+	var count int64
+	db.Model(&models.DocumentNumber{}).Where("number = ? AND voided_at IS NULL", number).Count(&count)
+	return count > 0
+}
+
+// ensureInvoiceNumber assigns the next invoice number to wo the first time an invoice is issued. Regenerating the
+// invoice keeps its number; only a voided number is replaced.
+func (h *WholesaleOrderHandler) ensureInvoiceNumber(c *gin.Context, wo *models.WholesaleOrder) error {
+	if wo.InvoiceNumber != "" && documentNumberIsLive(h.db, wo.InvoiceNumber) {
+		return nil
+	}
+	previous := wo.InvoiceNumber
+	var issued models.DocumentNumber
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		orderID := wo.ID
+		issued, err = issueDocumentNumber(tx, models.DocumentSeriesInvoice, time.Now(), &orderID, nil, contextUserID(c))
+		if err != nil {
+			return err
+		}
+		// Claim: only one concurrent request may replace the order's number.
+		res := tx.Model(&models.WholesaleOrder{}).Where("id = ? AND invoice_number = ?", wo.ID, previous).
+			Update("invoice_number", issued.Number)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errDocumentNumberClaimed
+		}
+		return nil
+	})
+	if errors.Is(err, errDocumentNumberClaimed) {
+		var current models.WholesaleOrder
+		if err := h.db.Select("id", "invoice_number").First(&current, wo.ID).Error; err != nil {
+			return err
+		}
+		wo.InvoiceNumber = current.InvoiceNumber
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	wo.InvoiceNumber = issued.Number
+	changes := map[string]interface{}{"invoice_number": issued.Number}
+	if previous != "" {
+		changes["replaces_voided"] = previous
+	}
+	if c != nil {
+		h.audit(c, "wholesale_order_invoice_number_assigned", wo.ID, changes)
+	}
+	emitWebhookEvent(h.db, webhook.EventInvoiceGenerated, gin.H{
+		"wholesale_order_id": wo.ID, "order_number": wo.OrderNumber, "invoice_number": issued.Number,
+		"wholesale_client_id": wo.WholesaleClientID, "total_net": wo.TotalNet, "vat_total": wo.VATTotal,
+		"replaces_voided": previous,
+	})
+	return nil
+}
+
+// ensureCreditNoteNumber assigns the next credit note number to ret the first time its credit note is issued.
+func ensureCreditNoteNumber(db *gorm.DB, c *gin.Context, ret *models.WholesaleReturn) error {
+	if ret.CreditNoteNumber != "" {
+		return nil
+	}
+	var issued models.DocumentNumber
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		orderID, returnID := ret.WholesaleOrderID, ret.ID
+		issued, err = issueDocumentNumber(tx, models.DocumentSeriesCreditNote, time.Now(), &orderID, &returnID, contextUserID(c))
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&models.WholesaleReturn{}).Where("id = ? AND credit_note_number = ?", ret.ID, "").
+			Update("credit_note_number", issued.Number)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errDocumentNumberClaimed
+		}
+		return nil
+	})
+	if errors.Is(err, errDocumentNumberClaimed) {
+		var current models.WholesaleReturn
+		if err := db.Select("id", "credit_note_number").First(&current, ret.ID).Error; err != nil {
+			return err
+		}
+		ret.CreditNoteNumber = current.CreditNoteNumber
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ret.CreditNoteNumber = issued.Number
+	return nil
+}
+
+// voidDocumentNumber voids an issued number with a reason; it stays in the sequence report.
+func voidDocumentNumber(db *gorm.DB, number, reason string, userID *uint) error {
+	return db.Model(&models.DocumentNumber{}).Where("number = ? AND voided_at IS NULL", number).Updates(map[string]interface{}{
+		"voided_at": time.Now(), "voided_by": userID, "void_reason": reason,
+	}).Error
+}
+
+// wholesaleInvoiceNumberLabel is the number printed on the invoice: the series number, or the order ref for
+// invoices issued before numbering was introduced.
+func wholesaleInvoiceNumberLabel(wo *models.WholesaleOrder) string {
+	if n := strings.TrimSpace(wo.InvoiceNumber); n != "" {
+		return n
+	}
+	return wholesaleOrderRefLabel(wo)
+}
+
+type DocumentNumberHandler struct {
+	db *gorm.DB
+}
+
+func NewDocumentNumberHandler(db *gorm.DB) *DocumentNumberHandler {
+	return &DocumentNumberHandler{db: db}
+}
+
+func (h *DocumentNumberHandler) audit(c *gin.Context, action, entityType string, entityID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   &entityID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+// ListSeries returns the configured format of each series with a preview of its next number.
+func (h *DocumentNumberHandler) ListSeries(c *gin.Context) {
+	now := time.Now()
+	out := make([]gin.H, 0, len(defaultDocumentNumberSeries))
+	for _, t := range []string{models.DocumentSeriesInvoice, models.DocumentSeriesCreditNote} {
+		series := loadDocumentNumberSeries(h.db, t)
+		year := 0
+		if series.ResetYearly {
+			year = now.Year()
+		}
+		var counter models.DocumentNumberCounter
+		h.db.Where("series_type = ? AND year = ?", t, year).First(&counter)
+		out = append(out, gin.H{
+			"type": t, "prefix": series.Prefix, "padding": series.Padding, "reset_yearly": series.ResetYearly,
+			"last_issued": counter.LastValue, "next_number": formatDocumentNumber(series, year, counter.LastValue+1),
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// UpdateSeries changes the format of future numbers; numbers already issued keep their text.
+func (h *DocumentNumberHandler) UpdateSeries(c *gin.Context) {
+	seriesType := c.Param("type")
+	if _, ok := defaultDocumentNumberSeries[seriesType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be invoice or credit_note"})
+		return
+	}
+	var req struct {
+		Prefix      *string `json:"prefix"`
+		Padding     *int    `json:"padding"`
+		ResetYearly *bool   `json:"reset_yearly"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	series := loadDocumentNumberSeries(h.db, seriesType)
+	old := series
+	if req.Prefix != nil {
+		prefix := strings.TrimSpace(*req.Prefix)
+		if len(prefix) > 20 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "prefix must be at most 20 characters"})
+			return
+		}
+		series.Prefix = prefix
+	}
+	if req.Padding != nil {
+		if *req.Padding < 1 || *req.Padding > 10 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "padding must be between 1 and 10"})
+			return
+		}
+		series.Padding = *req.Padding
+	}
+	if req.ResetYearly != nil {
+		series.ResetYearly = *req.ResetYearly
+	}
+	// The other series must not be able to produce the same text, or the unique number index would reject it.
+	for t := range defaultDocumentNumberSeries {
+		if t != seriesType && strings.EqualFold(loadDocumentNumberSeries(h.db, t).Prefix, series.Prefix) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "prefix is already used by the " + t + " series"})
+			return
+		}
+	}
+	if err := h.db.Save(&series).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "document_number_series_update", "document_number_series", series.ID, map[string]interface{}{
+		"type":         seriesType,
+		"prefix":       map[string]interface{}{"old": old.Prefix, "new": series.Prefix},
+		"padding":      map[string]interface{}{"old": old.Padding, "new": series.Padding},
+		"reset_yearly": map[string]interface{}{"old": old.ResetYearly, "new": series.ResetYearly},
+	})
+	h.ListSeries(c)
+}
+
+// SequenceReport lists every number issued in a series/year in order, marking voided numbers with their reason and
+// flagging any sequence gaps (which should never occur).
+func (h *DocumentNumberHandler) SequenceReport(c *gin.Context) {
+	seriesType := c.DefaultQuery("type", models.DocumentSeriesInvoice)
+	if _, ok := defaultDocumentNumberSeries[seriesType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be invoice or credit_note"})
+		return
+	}
+	year := time.Now().Year()
+	if !loadDocumentNumberSeries(h.db, seriesType).ResetYearly {
+		year = 0
+	}
+	if y := c.Query("year"); y != "" {
+		v, err := strconv.Atoi(y)
+		if err != nil || v < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+		year = v
+	}
+	var numbers []models.DocumentNumber
+	if err := h.db.Preload("User").Preload("Voider").Where("series_type = ? AND year = ?", seriesType, year).
+		Order("sequence ASC").Find(&numbers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rows := make([]gin.H, 0, len(numbers))
+	var gaps []int
+	expected := 1
+	voided := 0
+	for _, n := range numbers {
+		for ; expected < n.Sequence; expected++ {
+			gaps = append(gaps, expected)
+		}
+		expected = n.Sequence + 1
+		row := gin.H{
+			"id": n.ID, "sequence": n.Sequence, "number": n.Number, "issued_at": n.IssuedAt,
+			"wholesale_order_id": n.WholesaleOrderID, "wholesale_return_id": n.WholesaleReturnID,
+			"status": "issued",
+		}
+		if n.User != nil {
+			row["issued_by"] = userDisplayName(n.User)
+		}
+		if n.VoidedAt != nil {
+			voided++
+			row["status"] = "voided"
+			row["voided_at"] = n.VoidedAt
+			row["void_reason"] = n.VoidReason
+			if n.Voider != nil {
+				row["voided_by"] = userDisplayName(n.Voider)
+			}
+		}
+		rows = append(rows, row)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"type": seriesType, "year": year, "count": len(numbers), "voided": voided, "gaps": gaps, "numbers": rows,
+	})
+}
+
+// VoidNumber voids an issued number (e.g. an invoice cancelled before it was sent). The number is not reused;
+// if its order is invoiced again a new number is issued.
+func (h *DocumentNumberHandler) VoidNumber(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var num models.DocumentNumber
+	if err := h.db.First(&num, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document number not found"})
+		return
+	}
+	if num.VoidedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Number is already voided"})
+		return
+	}
+	if err := voidDocumentNumber(h.db, num.Number, strings.TrimSpace(req.Reason), contextUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if num.WholesaleOrderID != nil {
+		// Logged against the order so it shows in the order's audit trail.
+		h.audit(c, "wholesale_order_document_number_void", "wholesale_order", *num.WholesaleOrderID, map[string]interface{}{
+			"number": num.Number, "series_type": num.SeriesType, "reason": strings.TrimSpace(req.Reason),
+		})
+	}
+	h.db.Preload("User").Preload("Voider").First(&num, num.ID)
+	c.JSON(http.StatusOK, num)
+}
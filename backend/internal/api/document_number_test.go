@@ -0,0 +1,21 @@
+package api
+
+import (
+	"testing"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestFormatDocumentNumber(t *testing.T) {
+	yearly := models.DocumentNumberSeries{Prefix: "INV-", Padding: 6, ResetYearly: true}
+	if got := formatDocumentNumber(yearly, 2025, 42); got != "INV-2025-000042" {
+		t.Fatalf("yearly: got %q", got)
+	}
+	continuous := models.DocumentNumberSeries{Prefix: "CN", Padding: 4}
+	if got := formatDocumentNumber(continuous, 0, 7); got != "CN0007" {
+		t.Fatalf("continuous: got %q", got)
+	}
+	if got := formatDocumentNumber(continuous, 0, 123456); got != "CN123456" {
+		t.Fatalf("overflowing padding: got %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/jobs"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Job types run by the background runner.
+const (
+	jobTypeWholesaleOrderEmail    = "wholesale_order_email"
+	jobTypeWholesaleBulkZipEmail  = "wholesale_bulk_zip_email"
+	jobTypeWholesaleOrderDocument = "wholesale_order_document"
+	jobTypeCurrencySync           = "currency_sync"
+)
+
+// auditActor is who an audit row is attributed to. Jobs carry it from the request that queued them so work done
+// later in the background is still logged against that user, key and address.
+type auditActor struct {
+	UserID    *uint  `json:"user_id,omitempty"`
+	APIKeyID  *uint  `json:"api_key_id,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+func actorFromContext(c *gin.Context) auditActor {
+	if c == nil {
+		return auditActor{}
+	}
+	return auditActor{
+		UserID:    contextUserID(c),
+		APIKeyID:  contextAPIKeyID(c),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+func writeAudit(db *gorm.DB, actor auditActor, action, entityType string, entityID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	db.Create(&models.AuditLog{
+		UserID:     actor.UserID,
+		APIKeyID:   actor.APIKeyID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   &entityID,
+		Changes:    string(changesJSON),
+		IPAddress:  actor.IPAddress,
+		UserAgent:  actor.UserAgent,
+	})
+}
+
+// enqueueJob queues a job on behalf of the caller.
+func enqueueJob(c *gin.Context, db *gorm.DB, jobType string, payload interface{}) (*models.Job, error) {
+	return jobs.Enqueue(db, jobType, payload, jobs.Options{CreatedByID: contextUserID(c)})
+}
+
+// StartJobRunner starts cfg.JobWorkers background workers for the job types below. Queued work is kept in the
+// database, so nothing is lost on restart; on platforms that throttle CPU between requests (Cloud Run's default)
+// allocate CPU always or keep a minimum instance, or jobs only progress while requests are being served.
+func StartJobRunner(db *gorm.DB, cfg *config.Config) *jobs.Runner {
+	r := jobs.NewRunner(db, cfg.JobWorkers, time.Duration(cfg.JobPollSeconds)*time.Second)
+	wholesale := NewWholesaleOrderHandler(db, cfg)
+	currency := NewCurrencyHandler(db)
+	r.Register(jobTypeWholesaleOrderEmail, wholesale.runOrderEmailJob)
+	r.Register(jobTypeWholesaleBulkZipEmail, wholesale.runBulkZipEmailJob)
+	r.Register(jobTypeWholesaleOrderDocument, wholesale.runOrderDocumentJob)
+	r.Register(jobTypeCurrencySync, currency.runSyncJob)
+	scheduler := NewSchedulerHandler(db, cfg)
+	r.Register(jobTypeLowStockAlert, scheduler.runLowStockAlertJob)
+	r.Register(jobTypeDayEndSnapshot, scheduler.runDayEndSnapshotJob)
+	r.Register(jobTypeSalesReportEmail, scheduler.runSalesReportJob)
+	r.Start()
+	return r
+}
+
+type JobHandler struct {
+	db *gorm.DB
+}
+
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{db: db}
+}
+
+// ListJobs returns jobs newest first, optionally filtered by status and type.
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	q := h.db.Model(&models.Job{})
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v := c.Query("type"); v != "" {
+		q = q.Where("type = ?", v)
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	var total int64
+	q.Count(&total)
+	var list []models.Job
+	if err := q.Omit("payload").Order("id DESC").Limit(limit).Offset(offset).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": list, "total": total})
+}
+
+// GetJob lets the user who queued a job poll it; job.manage holders can read any job.
+func (h *JobHandler) GetJob(c *gin.Context) {
+	var job models.Job
+	if err := h.db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	uid := contextUserID(c)
+	if !hasPermission(c, models.PermJobManage) && (uid == nil || job.CreatedByID == nil || *job.CreatedByID != *uid) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob re-queues a dead job (or re-runs a succeeded one) with a fresh attempt budget.
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	var job models.Job
+	if err := h.db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err := jobs.Retry(h.db, job.ID); err != nil {
+		if errors.Is(err, jobs.ErrNotRetryable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Job is " + job.Status + "; only dead or succeeded jobs can be retried"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeAudit(h.db, actorFromContext(c), "job_retry", "job", job.ID, map[string]interface{}{
+		"type": job.Type, "old_status": job.Status, "attempts": job.Attempts, "last_error": job.LastError,
+	})
+	h.db.First(&job, job.ID)
+	c.JSON(http.StatusOK, job)
+}
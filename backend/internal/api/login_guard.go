@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/loginguard"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginThrottle counts failed logins per username and per source (the till for PIN logins, else the client IP).
+// Sources get more slack than users since one till serves a whole shift.
+type loginThrottle struct {
+	users   *loginguard.Limiter
+	sources *loginguard.Limiter
+}
+
+func newLoginThrottle(cfg *config.Config, store loginguard.Store) *loginThrottle {
+	lockout := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+	return &loginThrottle{
+		users: loginguard.New(loginguard.Policy{
+			FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second,
+			LockoutAfter: cfg.LoginLockoutAttempts, LockoutDuration: lockout, ResetAfter: time.Hour,
+		}, store),
+		sources: loginguard.New(loginguard.Policy{
+			FreeAttempts: 10, BaseDelay: time.Second, MaxDelay: 30 * time.Second,
+			LockoutAfter: 3 * cfg.LoginLockoutAttempts, LockoutDuration: lockout, ResetAfter: time.Hour,
+		}, store),
+	}
+}
+
+func loginUserKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func loginSourceKey(c *gin.Context, device *models.POSDevice) string {
+	if device != nil {
+		return fmt.Sprintf("device:%d", device.ID)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// loginAttempt is a login attempt reserved against both the username and the source. It is counted before the
+// credentials are checked so that parallel guesses cannot all slip under the limit; defer release so that
+// attempts that neither fail nor succeed (a database error, a pending second step) are handed back.
+type loginAttempt struct {
+	throttle     *loginThrottle
+	userKey      string
+	user, source *loginguard.Attempt
+}
+
+// loginBlocked reserves an attempt, or answers 429 with Retry-After when the username or source must wait before
+// another one and returns nil.
+func (h *AuthHandler) loginBlocked(c *gin.Context, userKey, sourceKey string) *loginAttempt {
+	user, wait, locked := h.throttle.users.Reserve(userKey)
+	if user != nil {
+		var source *loginguard.Attempt
+		if source, wait, locked = h.throttle.sources.Reserve(sourceKey); source != nil {
+			return &loginAttempt{throttle: h.throttle, userKey: userKey, user: user, source: source}
+		}
+		user.Release()
+	}
+	secs := int((wait + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(secs))
+	msg := fmt.Sprintf("Too many failed attempts, try again in %d seconds", secs)
+	if locked {
+		msg = fmt.Sprintf("Login locked after too many failed attempts; try again in %d minutes or ask a supervisor to unlock", (secs+59)/60)
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": msg, "retry_after": secs, "locked": locked})
+	return nil
+}
+
+// succeeded clears the username's failures and hands the source's reservation back.
+func (a *loginAttempt) succeeded() {
+	a.user.Keep()
+	a.throttle.users.Reset(a.userKey)
+	a.source.Release()
+}
+
+// release hands back whatever has not been settled by loginFailed or succeeded.
+func (a *loginAttempt) release() {
+	a.user.Release()
+	a.source.Release()
+}
+
+// loginFailed keeps the attempt counted and, for a known user, records login_failed (and login_locked when this
+// failure locked the username) in user_activity_events.
+func (h *AuthHandler) loginFailed(c *gin.Context, user *models.User, method string, device *models.POSDevice, attempt *loginAttempt) {
+	attempt.user.Keep()
+	attempt.source.Keep()
+	lockedUser := len(attempt.user.Locked()) > 0
+	if user == nil || h.db == nil {
+		return
+	}
+	detail := method + " from " + c.ClientIP()
+	var storeID *uint
+	if device != nil {
+		detail += ", device " + normalizeDeviceCodeForLookup(device.DeviceCode)
+		storeID = &device.StoreID
+	}
+	now := time.Now()
+	events := []models.UserActivityEvent{{UserID: user.ID, StoreID: storeID, EventType: models.EventLoginFailed, OccurredAt: now, Detail: truncateString(detail, 255)}}
+	if lockedUser {
+		events = append(events, models.UserActivityEvent{UserID: user.ID, StoreID: storeID, EventType: models.EventLoginLocked, OccurredAt: now, Detail: truncateString(detail, 255)})
+	}
+	_ = h.db.Create(&events).Error
+}
+
+// lockoutResponse is a throttled key as shown to supervisors.
+type lockoutResponse struct {
+	loginguard.Entry
+	RetryAfter int `json:"retry_after"`
+}
+
+// ListLoginLockouts lists the usernames, tills and IPs currently delayed or locked out.
+func (h *AuthHandler) ListLoginLockouts(c *gin.Context) {
+	out := []lockoutResponse{}
+	for _, l := range []*loginguard.Limiter{h.throttle.users, h.throttle.sources} {
+		for _, e := range l.Blocked() {
+			out = append(out, lockoutResponse{Entry: e, RetryAfter: int(time.Until(e.BlockedUntil).Seconds()) + 1})
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// UnlockLogin clears the failure count for a user (user_id) or for any listed key (key), so they can try again at once.
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	var req struct {
+		UserID uint   `json:"user_id"`
+		Key    string `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key := strings.TrimSpace(req.Key)
+	var user models.User
+	if req.UserID != 0 {
+		if err := h.db.First(&user, req.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		key = loginUserKey(user.Username)
+	}
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or key is required"})
+		return
+	}
+	h.throttle.users.Reset(key)
+	h.throttle.sources.Reset(key)
+
+	changesJSON, _ := json.Marshal(map[string]interface{}{"key": key})
+	entry := models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     "login_unlock",
+		EntityType: "user",
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+	if user.ID != 0 {
+		entry.EntityID = &user.ID
+		h.db.Create(&models.UserActivityEvent{UserID: user.ID, EventType: models.EventLoginUnlocked, OccurredAt: time.Now()})
+	}
+	h.db.Create(&entry)
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked", "key": key})
+}
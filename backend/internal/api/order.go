@@ -10,6 +10,7 @@ import (
 
 	"pos-system/backend/internal/config"
 	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/webhook"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -196,6 +197,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 			continue
 		}
 
+		oldQuantity := stock.Quantity
 		stock.Quantity -= item.Quantity
 		if stock.Quantity < 0 {
 			stock.Quantity = 0
@@ -204,6 +206,8 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		if err := h.db.Save(&stock).Error; err != nil {
 			fmt.Printf("Warning: Failed to update stock for product %d: %v\n", item.ProductID, err)
 			// Continue with other items even if one fails
+		} else {
+			emitStockLowIfCrossed(h.db, stock, oldQuantity)
 		}
 	}
 
@@ -224,6 +228,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	h.db.Preload("Store").Preload("User").Preload("Sector").Preload("Items.Product").First(&order, order.ID)
 	order.Store = withStoreReceiptDefaults(order.Store)
+	emitWebhookEvent(h.db, webhook.EventOrderCreated, order)
 
 	c.JSON(http.StatusCreated, order)
 }
@@ -309,6 +314,7 @@ func (h *OrderHandler) MarkPaid(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	emitWebhookEvent(h.db, webhook.EventOrderPaid, order)
 
 	c.JSON(http.StatusOK, order)
 }
@@ -512,6 +518,7 @@ func (h *OrderHandler) MarkPickedUp(c *gin.Context) {
 	changesJSON, _ := json.Marshal(changes)
 	auditLog := models.AuditLog{
 		UserID:     userID,
+		APIKeyID:   contextAPIKeyID(c),
 		Action:     "order_pickup",
 		EntityType: "order",
 		EntityID:   &order.ID,
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestPermissionSetAllows(t *testing.T) {
+	p := permissionSet{
+		global: map[string]bool{models.PermProductView: true},
+		stores: map[uint]map[string]bool{3: {models.PermStockAdjust: true, models.PermProductView: true, models.PermRoleManage: true}},
+	}
+	if !p.allows(models.PermProductView, 0) {
+		t.Error("global permission should be allowed without a store")
+	}
+	if p.allows(models.PermStockAdjust, 0) || p.allows(models.PermStockAdjust, 4) {
+		t.Error("store grant must not apply without its store")
+	}
+	if !p.allows(models.PermStockAdjust, 3) {
+		t.Error("store grant should apply to its store")
+	}
+	if p.allows(models.PermRoleManage, 3) {
+		t.Error("store grant of a company-wide permission must not apply")
+	}
+	if got := p.storeLists(); !reflect.DeepEqual(got, map[string][]string{"3": {models.PermStockAdjust}}) {
+		t.Errorf("storeLists() = %v", got)
+	}
+}
+
+func TestStoreGrantNotRaisedByQueryString(t *testing.T) {
+	p := permissionSet{
+		global: map[string]bool{},
+		stores: map[uint]map[string]bool{3: {models.PermStockAdjust: true, models.PermSettingsManage: true}},
+	}
+	request := func(target string, params gin.Params, perm string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, target, nil)
+		c.Params = params
+		c.Set("permissions", p)
+		return hasPermission(c, perm)
+	}
+	if request("/settings?store_id=3", nil, models.PermSettingsManage) {
+		t.Error("?store_id= must not turn a store grant into a global one")
+	}
+	if request("/stock?store_id=3", nil, models.PermStockAdjust) {
+		t.Error("?store_id= must not select the store for a grant")
+	}
+	if !request("/stock/1/3", gin.Params{{Key: "store_id", Value: "3"}}, models.PermStockAdjust) {
+		t.Error("store grant should apply on a :store_id route for that store")
+	}
+	if request("/stock/1/4", gin.Params{{Key: "store_id", Value: "4"}}, models.PermStockAdjust) {
+		t.Error("store grant must not apply to another store")
+	}
+}
+
+func TestNormalisePermissions(t *testing.T) {
+	got, unknown := normalisePermissions([]string{" stock.adjust", models.PermProductView, "stock.adjust", ""})
+	if unknown != "" || !reflect.DeepEqual(got, []string{models.PermProductView, models.PermStockAdjust}) {
+		t.Errorf("normalisePermissions = %v, %q", got, unknown)
+	}
+	if _, unknown = normalisePermissions([]string{"product.fly"}); unknown != "product.fly" {
+		t.Errorf("unknown permission not reported, got %q", unknown)
+	}
+}
+
+func TestDefaultRolePermissionsKnown(t *testing.T) {
+	for role, perms := range models.DefaultRolePermissions {
+		for _, p := range perms {
+			if !models.IsKnownPermission(p) {
+				t.Errorf("role %s has unknown permission %s", role, p)
+			}
+		}
+	}
+	if len(models.DefaultRolePermissions[models.RoleNameManagement]) != len(models.PermissionCatalogue) {
+		t.Error("management should hold every permission")
+	}
+}
+
+func TestPosUserCanAdjustStock(t *testing.T) {
+	p := permissionSet{global: map[string]bool{}, stores: map[uint]map[string]bool{}}
+	for _, perm := range models.DefaultRolePermissions[models.RoleNamePosUser] {
+		p.global[perm] = true
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("permissions", p) })
+	r.PUT("/stock/:product_id/:store_id", requirePermission(models.PermStockAdjust), func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/stock/1/3", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("pos_user PUT /stock = %d, want 200: the till's stocktake and offline sync need it", w.Code)
+	}
+}
@@ -331,9 +331,6 @@ func fillProductLineRequestFields(c *gin.Context, req *CreateProductRequest) {
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	if rejectIfPosUserWrite(c) {
-		return
-	}
 	var req CreateProductRequest
 
 	// Check if it's multipart form data (file upload)
@@ -474,9 +471,6 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
-	if rejectIfPosUserWrite(c) {
-		return
-	}
 	var product models.Product
 	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -608,9 +602,6 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
-	if rejectIfHQStaffProductDelete(c) {
-		return
-	}
 	var product models.Product
 	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -888,9 +879,6 @@ func (h *ProductHandler) ImportProductsFromExcel(c *gin.Context) {
 }
 
 func (h *ProductHandler) SetProductCost(c *gin.Context) {
-	if rejectIfHQStaffProductCostEdit(c) {
-		return
-	}
 	var product models.Product
 	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -963,9 +951,6 @@ func (h *ProductHandler) SetProductCost(c *gin.Context) {
 
 // UpdateProductCostSimple allows updating just wholesale cost and retail price without full recalculation
 func (h *ProductHandler) UpdateProductCostSimple(c *gin.Context) {
-	if rejectIfHQStaffProductCostEdit(c) {
-		return
-	}
 	var product models.Product
 	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	db *gorm.DB
+}
+
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{db: db}
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+type roleResponse struct {
+	models.Role
+	PermissionNames []string `json:"permissions"`
+	UserCount       int64    `json:"user_count"`
+}
+
+type SaveRoleRequest struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Permissions      []string `json:"permissions"`
+	RequireTwoFactor *bool    `json:"require_two_factor"`
+}
+
+func toRoleResponse(db *gorm.DB, role models.Role) roleResponse {
+	out := roleResponse{Role: role, PermissionNames: []string{}}
+	for _, p := range role.Permissions {
+		out.PermissionNames = append(out.PermissionNames, p.Permission)
+	}
+	sort.Strings(out.PermissionNames)
+	db.Model(&models.User{}).Where("role = ?", role.Name).Count(&out.UserCount)
+	return out
+}
+
+// normalisePermissions de-duplicates the list and rejects names not in the catalogue.
+func normalisePermissions(perms []string) ([]string, string) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		if !models.IsKnownPermission(p) {
+			return nil, p
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out, ""
+}
+
+func stringSliceContains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// roleExists reports whether name is a role users can be given.
+func roleExists(db *gorm.DB, name string) bool {
+	var count int64
+	db.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return name != "" && count > 0
+}
+
+// rejectRoleEscalation stops a caller without role.manage from giving a user a role with permissions they lack.
+func rejectRoleEscalation(c *gin.Context, db *gorm.DB, role string) bool {
+	if hasPermission(c, models.PermRoleManage) {
+		return false
+	}
+	names, err := rolePermissionNames(db, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	held := contextPermissions(c)
+	for _, n := range names {
+		if !held.global[n] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot assign a role with permissions you do not hold", "permission": n})
+			return true
+		}
+	}
+	return false
+}
+
+func (h *RoleHandler) audit(c *gin.Context, action string, roleID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: "role",
+		EntityID:   &roleID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+// MyPermissions returns the caller's effective permissions so the UIs can hide what the API would refuse.
+func (h *RoleHandler) MyPermissions(c *gin.Context) {
+	perms := contextPermissions(c)
+	c.JSON(http.StatusOK, gin.H{
+		"role":              currentRole(c),
+		"permissions":       perms.globalList(),
+		"store_permissions": perms.storeLists(),
+	})
+}
+
+// ListPermissions returns the permission catalogue for the role editor.
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.PermissionCatalogue)
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.Preload("Permissions").Order("is_system DESC, name ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]roleResponse, 0, len(roles))
+	for _, r := range roles {
+		out = append(out, toRoleResponse(h.db, r))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req SaveRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 2-50 lowercase letters, digits or underscores"})
+		return
+	}
+	if roleExists(h.db, req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+		return
+	}
+	perms, unknown := normalisePermissions(req.Permissions)
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + unknown})
+		return
+	}
+	role := models.Role{Name: req.Name, Description: strings.TrimSpace(req.Description)}
+	if req.RequireTwoFactor != nil {
+		role.RequireTwoFactor = *req.RequireTwoFactor
+	}
+	for _, p := range perms {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: p})
+	}
+	if err := h.db.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "role_create", role.ID, map[string]interface{}{"name": role.Name, "permissions": perms, "require_two_factor": role.RequireTwoFactor})
+	c.JSON(http.StatusCreated, toRoleResponse(h.db, role))
+}
+
+// UpdateRole replaces a role's description and permissions. System roles keep their name, and callers cannot
+// remove role.manage from their own role.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	var req SaveRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	perms, unknown := normalisePermissions(req.Permissions)
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + unknown})
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if name == "" {
+		name = role.Name
+	}
+	if name != role.Name {
+		if role.IsSystem {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "System roles cannot be renamed"})
+			return
+		}
+		if !roleNamePattern.MatchString(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 2-50 lowercase letters, digits or underscores"})
+			return
+		}
+		if roleExists(h.db, name) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+			return
+		}
+	}
+	if role.Name == currentRole(c) && !stringSliceContains(perms, models.PermRoleManage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove " + models.PermRoleManage + " from your own role"})
+		return
+	}
+
+	before := toRoleResponse(h.db, role).PermissionNames
+	oldName, oldRequire := role.Name, role.RequireTwoFactor
+	updates := map[string]interface{}{"name": name, "description": strings.TrimSpace(req.Description)}
+	if req.RequireTwoFactor != nil {
+		updates["require_two_factor"] = *req.RequireTwoFactor
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Updates(updates).Error; err != nil {
+			return err
+		}
+		if name != oldName {
+			if err := tx.Model(&models.User{}).Where("role = ?", oldName).Update("role", name).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if err := tx.Create(&models.RolePermission{RoleID: role.ID, Permission: p}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "role_update", role.ID, map[string]interface{}{
+		"name": name, "old_name": oldName, "permissions": perms, "old_permissions": before,
+		"require_two_factor": updates["require_two_factor"], "old_require_two_factor": oldRequire,
+	})
+	h.db.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusOK, toRoleResponse(h.db, role))
+}
+
+// DeleteRole removes a custom role that no user holds as their primary role; its per-store grants go with it.
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "System roles cannot be deleted"})
+		return
+	}
+	var users int64
+	h.db.Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is assigned to users; move them to another role first", "user_count": users})
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserStoreRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "role_delete", role.ID, map[string]interface{}{"name": role.Name})
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// ListUserStoreRoles returns a user's per-store role grants.
+func (h *RoleHandler) ListUserStoreRoles(c *gin.Context) {
+	var grants []models.UserStoreRole
+	if err := h.db.Preload("Role").Preload("Store").Where("user_id = ?", c.Param("id")).Order("store_id ASC").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// SetUserStoreRoles replaces a user's per-store role grants.
+func (h *RoleHandler) SetUserStoreRoles(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var req struct {
+		Grants []struct {
+			StoreID uint `json:"store_id" binding:"required"`
+			RoleID  uint `json:"role_id" binding:"required"`
+		} `json:"grants"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	grants := make([]models.UserStoreRole, 0, len(req.Grants))
+	seen := map[[2]uint]bool{}
+	for _, g := range req.Grants {
+		key := [2]uint{g.StoreID, g.RoleID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		var stores, roles int64
+		h.db.Model(&models.Store{}).Where("id = ?", g.StoreID).Count(&stores)
+		h.db.Model(&models.Role{}).Where("id = ?", g.RoleID).Count(&roles)
+		if stores == 0 || roles == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown store or role", "store_id": g.StoreID, "role_id": g.RoleID})
+			return
+		}
+		grants = append(grants, models.UserStoreRole{UserID: user.ID, StoreID: g.StoreID, RoleID: g.RoleID})
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserStoreRole{}).Error; err != nil {
+			return err
+		}
+		if len(grants) == 0 {
+			return nil
+		}
+		return tx.Create(&grants).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	changes := make([]map[string]uint, 0, len(grants))
+	for _, g := range grants {
+		changes = append(changes, map[string]uint{"store_id": g.StoreID, "role_id": g.RoleID})
+	}
+	userID := user.ID
+	changesJSON, _ := json.Marshal(map[string]interface{}{"grants": changes})
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     "user_store_roles_update",
+		EntityType: "user",
+		EntityID:   &userID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+	h.ListUserStoreRoles(c)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -37,7 +38,7 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	stockHandler := NewStockHandler(db)
 	orderHandler := NewOrderHandler(db, cfg)
 	userHandler := NewUserHandler(db, cfg)
-	deviceHandler := NewDeviceHandler(db)
+	deviceHandler := NewDeviceHandler(db, cfg)
 	catalogHandler := NewCatalogHandler(db, cfg)
 	currencyHandler := NewCurrencyHandler(db)
 	auditHandler := NewAuditHandler(db)
@@ -45,6 +46,13 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	wholesaleOrderHandler := NewWholesaleOrderHandler(db, cfg)
 	wholesaleClientHandler := NewWholesaleClientHandler(db)
 	settingsHandler := NewSettingsHandler(db, cfg)
+	accountingExportHandler := NewAccountingExportHandler(db)
+	documentNumberHandler := NewDocumentNumberHandler(db)
+	roleHandler := NewRoleHandler(db)
+	apiKeyHandler := NewAPIKeyHandler(db)
+	webhookHandler := NewWebhookHandler(db)
+	jobHandler := NewJobHandler(db)
+	schedulerHandler := NewSchedulerHandler(db, cfg)
 
 	// Public routes
 	public := router.Group("/api/v1")
@@ -52,192 +60,332 @@ func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		public.POST("/auth/login", authHandler.Login)
 		public.POST("/auth/pin-login", PosModuleEnabledMiddleware(db), authHandler.PINLogin)
 		public.POST("/auth/refresh", authHandler.Refresh)
+		public.POST("/auth/2fa/verify", authHandler.VerifyTwoFactor)
+		public.POST("/auth/2fa/enrol", authHandler.EnrolTwoFactorAtLogin)
+		public.POST("/auth/2fa/enrol/confirm", authHandler.ConfirmTwoFactorAtLogin)
 		public.POST("/device/register", PosModuleEnabledMiddleware(db), deviceHandler.RegisterDevice)
-		public.GET("/device/:device_code/users", deviceHandler.GetUsersForDevice)
-		public.GET("/device/:device_code/products", deviceHandler.GetProductsForDevice)
-		public.GET("/device/:device_code/info", deviceHandler.GetDeviceInfo)
+		public.POST("/device/enrol", PosModuleEnabledMiddleware(db), deviceHandler.EnrolDevice)
+		// Device sync: the till authenticates with its device token, not a user session.
+		deviceAuth := deviceAuthMiddleware(db, cfg)
+		public.GET("/device/:device_code/users", deviceAuth, deviceHandler.GetUsersForDevice)
+		public.GET("/device/:device_code/products", deviceAuth, deviceHandler.GetProductsForDevice)
+		public.GET("/device/:device_code/info", deviceAuth, deviceHandler.GetDeviceInfo)
 		public.GET("/public/company-branding", settingsHandler.GetPublicCompanyBranding)
 	}
 
 	// Protected routes
 	protected := router.Group("/api/v1")
-	protected.Use(authMiddleware(cfg.JWTSecret))
+	protected.Use(apiKeyMiddleware(db), authMiddleware(cfg.JWTSecret), sessionMiddleware(db, cfg.SessionLegacyRefresh), deviceSessionMiddleware(db), permissionsMiddleware(db))
 	{
+		// Every route declares the permission it needs; roles are edited under /roles.
+		perm := requirePermission
+
+		// Own sessions and two-factor settings; these act on a login, so API keys cannot use them
+		interactive := interactiveOnly()
+		protected.POST("/auth/logout", interactive, authHandler.Logout)
+		protected.POST("/auth/logout-all", interactive, authHandler.LogoutAll)
+		protected.GET("/auth/sessions", interactive, authHandler.ListMySessions)
+		protected.DELETE("/auth/sessions/:id", interactive, authHandler.RevokeMySession)
+		protected.GET("/auth/2fa", interactive, authHandler.GetTwoFactorStatus)
+		protected.POST("/auth/2fa/setup", interactive, authHandler.SetupTwoFactor)
+		protected.POST("/auth/2fa/confirm", interactive, authHandler.ConfirmTwoFactor)
+		protected.POST("/auth/2fa/recovery-codes", interactive, authHandler.RegenerateRecoveryCodes)
+		protected.POST("/auth/2fa/disable", interactive, authHandler.DisableTwoFactor)
+
+		// Effective permissions, role and API key administration
+		protected.GET("/auth/permissions", roleHandler.MyPermissions)
+		protected.GET("/permissions", perm(models.PermUserView), roleHandler.ListPermissions)
+		protected.GET("/roles", perm(models.PermUserView), roleHandler.ListRoles)
+		protected.POST("/roles", perm(models.PermRoleManage), roleHandler.CreateRole)
+		protected.PUT("/roles/:id", perm(models.PermRoleManage), roleHandler.UpdateRole)
+		protected.DELETE("/roles/:id", perm(models.PermRoleManage), roleHandler.DeleteRole)
+		protected.GET("/api-keys", interactive, perm(models.PermAPIKeyManage), apiKeyHandler.ListAPIKeys)
+		protected.POST("/api-keys", interactive, perm(models.PermAPIKeyManage), apiKeyHandler.CreateAPIKey)
+		protected.PUT("/api-keys/:id", interactive, perm(models.PermAPIKeyManage), apiKeyHandler.UpdateAPIKey)
+		protected.POST("/api-keys/:id/revoke", interactive, perm(models.PermAPIKeyManage), apiKeyHandler.RevokeAPIKey)
+		protected.GET("/webhooks/events", perm(models.PermWebhookManage), webhookHandler.ListWebhookEvents)
+		protected.GET("/webhooks", perm(models.PermWebhookManage), webhookHandler.ListSubscriptions)
+		protected.POST("/webhooks", perm(models.PermWebhookManage), webhookHandler.CreateSubscription)
+		protected.PUT("/webhooks/:id", perm(models.PermWebhookManage), webhookHandler.UpdateSubscription)
+		protected.DELETE("/webhooks/:id", perm(models.PermWebhookManage), webhookHandler.DeleteSubscription)
+		protected.POST("/webhooks/:id/rotate-secret", perm(models.PermWebhookManage), webhookHandler.RotateSecret)
+		protected.POST("/webhooks/:id/test", perm(models.PermWebhookManage), webhookHandler.TestSubscription)
+		protected.GET("/webhook-deliveries", perm(models.PermWebhookManage), webhookHandler.ListDeliveries)
+		protected.GET("/webhook-deliveries/:id", perm(models.PermWebhookManage), webhookHandler.GetDelivery)
+		protected.POST("/webhook-deliveries/:id/redeliver", perm(models.PermWebhookManage), webhookHandler.Redeliver)
+		protected.GET("/jobs", perm(models.PermJobManage), jobHandler.ListJobs)
+		protected.GET("/jobs/:id", jobHandler.GetJob)
+		protected.POST("/jobs/:id/retry", perm(models.PermJobManage), jobHandler.RetryJob)
+		protected.GET("/scheduled-tasks", perm(models.PermScheduleManage), schedulerHandler.ListTasks)
+		protected.PUT("/scheduled-tasks/:id", perm(models.PermScheduleManage), schedulerHandler.UpdateTask)
+		protected.POST("/scheduled-tasks/:id/run", perm(models.PermScheduleManage), schedulerHandler.RunTask)
+		protected.POST("/scheduled-tasks/:id/pause", perm(models.PermScheduleManage), schedulerHandler.PauseTask)
+		protected.POST("/scheduled-tasks/:id/resume", perm(models.PermScheduleManage), schedulerHandler.ResumeTask)
+		protected.GET("/users/:id/store-roles", perm(models.PermRoleManage), roleHandler.ListUserStoreRoles)
+		protected.PUT("/users/:id/store-roles", perm(models.PermRoleManage), roleHandler.SetUserStoreRoles)
+
 		// Products
-		protected.GET("/products", productHandler.ListProducts)
-		protected.GET("/products/:id", productHandler.GetProduct)
-		protected.POST("/products", productHandler.CreateProduct)
-		protected.PUT("/products/:id", productHandler.UpdateProduct)
-		protected.DELETE("/products/:id", productHandler.DeleteProduct)
-		protected.POST("/products/:id/cost", productHandler.SetProductCost)
-		protected.PUT("/products/:id/cost", productHandler.UpdateProductCostSimple)
-		protected.GET("/products/:id/price-history", productHandler.GetPriceHistory)
-		protected.POST("/products/import-excel", productHandler.ImportProductsFromExcel)
+		protected.GET("/products", perm(models.PermProductView), productHandler.ListProducts)
+		protected.GET("/products/:id", perm(models.PermProductView), productHandler.GetProduct)
+		protected.POST("/products", perm(models.PermProductEdit), productHandler.CreateProduct)
+		protected.PUT("/products/:id", perm(models.PermProductEdit), productHandler.UpdateProduct)
+		protected.DELETE("/products/:id", perm(models.PermProductDelete), productHandler.DeleteProduct)
+		protected.POST("/products/:id/cost", perm(models.PermProductCostEdit), productHandler.SetProductCost)
+		protected.PUT("/products/:id/cost", perm(models.PermProductCostEdit), productHandler.UpdateProductCostSimple)
+		protected.GET("/products/:id/price-history", perm(models.PermProductView), productHandler.GetPriceHistory)
+		protected.POST("/products/import-excel", perm(models.PermProductEdit), productHandler.ImportProductsFromExcel)
 
 		// Product lines (catalog grouping; variants are products)
-		protected.GET("/product-lines", productLineHandler.List)
-		protected.GET("/product-lines/:id", productLineHandler.Get)
-		protected.POST("/product-lines", productLineHandler.Create)
-		protected.PUT("/product-lines/:id", productLineHandler.Update)
-		protected.DELETE("/product-lines/:id", productLineHandler.Delete)
+		protected.GET("/product-lines", perm(models.PermProductView), productLineHandler.List)
+		protected.GET("/product-lines/:id", perm(models.PermProductView), productLineHandler.Get)
+		protected.POST("/product-lines", perm(models.PermProductEdit), productLineHandler.Create)
+		protected.PUT("/product-lines/:id", perm(models.PermProductEdit), productLineHandler.Update)
+		protected.DELETE("/product-lines/:id", perm(models.PermProductEdit), productLineHandler.Delete)
 
 		// Sectors
-		protected.GET("/sectors", sectorHandler.ListSectors)
-		protected.POST("/sectors", sectorHandler.CreateSector)
-		protected.PUT("/sectors/:id", sectorHandler.UpdateSector)
-		protected.DELETE("/sectors/:id", sectorHandler.DeleteSector)
+		protected.GET("/sectors", perm(models.PermProductView), sectorHandler.ListSectors)
+		protected.POST("/sectors", perm(models.PermCatalogManage), sectorHandler.CreateSector)
+		protected.PUT("/sectors/:id", perm(models.PermCatalogManage), sectorHandler.UpdateSector)
+		protected.DELETE("/sectors/:id", perm(models.PermCatalogManage), sectorHandler.DeleteSector)
 
 		// Categories
-		protected.GET("/categories", categoryHandler.ListCategories)
-		protected.POST("/categories", categoryHandler.CreateCategory)
-		protected.POST("/categories/normalize", categoryHandler.NormalizeCategories)
-		protected.DELETE("/categories/:name", categoryHandler.DeleteCategory)
-		protected.PUT("/categories/:name/rename", categoryHandler.RenameCategory)
+		protected.GET("/categories", perm(models.PermProductView), categoryHandler.ListCategories)
+		protected.POST("/categories", perm(models.PermCatalogManage), categoryHandler.CreateCategory)
+		protected.POST("/categories/normalize", perm(models.PermCatalogManage), categoryHandler.NormalizeCategories)
+		protected.DELETE("/categories/:name", perm(models.PermCatalogManage), categoryHandler.DeleteCategory)
+		protected.PUT("/categories/:name/rename", perm(models.PermCatalogManage), categoryHandler.RenameCategory)
 
 		// Product-Sector Discounts
-		protected.POST("/products/:id/discounts/:sector_id", productHandler.SetDiscount)
-		protected.GET("/products/:id/discounts", productHandler.GetDiscounts)
+		protected.POST("/products/:id/discounts/:sector_id", perm(models.PermProductEdit), productHandler.SetDiscount)
+		protected.GET("/products/:id/discounts", perm(models.PermProductView), productHandler.GetDiscounts)
 
 		// Stock
-		protected.GET("/stock", stockHandler.ListStock)
-		protected.GET("/stock/report", stockHandler.GetStockReport)
-		protected.GET("/stock/by-product/:product_id", stockHandler.GetProductStockAssignments)
-		protected.POST("/stock/assign", stockHandler.AssignProductsToStore)
-		protected.POST("/stock/set-assignments", stockHandler.SetStockAssignments)
-		protected.POST("/stock/unassign", stockHandler.UnassignProductsFromStore)
-		protected.GET("/stock/:store_id", stockHandler.GetStoreStock)
-		protected.GET("/stock/low-stock", stockHandler.GetLowStock)
-		protected.GET("/stock/incoming", stockHandler.GetIncomingStock) // Get on-the-way stock
-		protected.PUT("/stock/:product_id/:store_id", stockHandler.UpdateStock)
-		protected.POST("/stock/:product_id/:store_id/convert", stockHandler.ConvertStockInventory)
+		protected.GET("/stock", perm(models.PermStockView), stockHandler.ListStock)
+		protected.GET("/stock/report", perm(models.PermStockView), stockHandler.GetStockReport)
+		protected.GET("/stock/by-product/:product_id", perm(models.PermStockView), stockHandler.GetProductStockAssignments)
+		protected.POST("/stock/assign", perm(models.PermStockAssign), stockHandler.AssignProductsToStore)
+		protected.POST("/stock/set-assignments", perm(models.PermStockAssign), stockHandler.SetStockAssignments)
+		protected.POST("/stock/unassign", perm(models.PermStockAssign), stockHandler.UnassignProductsFromStore)
+		protected.GET("/stock/:store_id", perm(models.PermStockView), stockHandler.GetStoreStock)
+		protected.GET("/stock/low-stock", perm(models.PermStockView), stockHandler.GetLowStock)
+		protected.GET("/stock/incoming", perm(models.PermStockView), stockHandler.GetIncomingStock) // Get on-the-way stock
+		protected.PUT("/stock/:product_id/:store_id", perm(models.PermStockAdjust), stockHandler.UpdateStock)
+		protected.POST("/stock/:product_id/:store_id/convert", perm(models.PermStockAssign), stockHandler.ConvertStockInventory)
 
 		// Audit Logs
-		protected.GET("/audit/stock", auditHandler.GetStockAuditLogs)
-		protected.GET("/audit/order", auditHandler.GetOrderAuditLogs)
+		protected.GET("/audit/stock", perm(models.PermAuditView), auditHandler.GetStockAuditLogs)
+		protected.GET("/audit/order", perm(models.PermAuditView), auditHandler.GetOrderAuditLogs)
 
 		// Stocktake day-start (record first login / done / skipped; list for management)
-		protected.POST("/stocktake-day-start", stocktakeHandler.RecordFirstLoginOrResult)
-		protected.GET("/stocktake-day-start", stocktakeHandler.ListDayStartRecords)
+		protected.POST("/stocktake-day-start", perm(models.PermStockStocktake), stocktakeHandler.RecordFirstLoginOrResult)
+		protected.GET("/stocktake-day-start", perm(models.PermAuditView), stocktakeHandler.ListDayStartRecords)
 		// User activity events (for timetable: login, logout, stocktake)
-		protected.GET("/user-activity-events", stocktakeHandler.ListUserActivityEvents)
+		protected.GET("/user-activity-events", perm(models.PermAuditView), stocktakeHandler.ListUserActivityEvents)
 
 		// Re-stock Orders
-		protected.GET("/restock-orders", stockHandler.ListRestockOrders)
-		protected.POST("/restock-orders", stockHandler.CreateRestockOrder)
-		protected.PUT("/restock-orders/:id/tracking", stockHandler.UpdateTrackingNumber)
-		protected.PUT("/restock-orders/:id/receive", stockHandler.ReceiveRestockOrder)
+		protected.GET("/restock-orders", perm(models.PermStockView), stockHandler.ListRestockOrders)
+		protected.POST("/restock-orders", perm(models.PermStockAdjust), stockHandler.CreateRestockOrder)
+		protected.PUT("/restock-orders/:id/tracking", perm(models.PermStockAdjust), stockHandler.UpdateTrackingNumber)
+		protected.PUT("/restock-orders/:id/receive", perm(models.PermStockReceive), stockHandler.ReceiveRestockOrder)
 
 		// Orders
-		protected.GET("/orders", orderHandler.ListOrders)
-		protected.GET("/orders/stats/revenue", orderHandler.GetDailyRevenueStats)
-		protected.GET("/orders/stats/product-sales", orderHandler.GetDailyProductSalesStats)
-		protected.POST("/orders", orderHandler.CreateOrder)
-		protected.GET("/orders/:id", orderHandler.GetOrder)
-		protected.PUT("/orders/:id/pay", orderHandler.MarkPaid)
-		protected.PUT("/orders/:id/complete", orderHandler.MarkComplete)
-		protected.PUT("/orders/:id/cancel", orderHandler.MarkCancelled)
-		protected.PUT("/orders/pickup/:order_number", orderHandler.MarkPickedUp)
+		protected.GET("/orders", perm(models.PermOrderView), orderHandler.ListOrders)
+		protected.GET("/orders/stats/revenue", perm(models.PermOrderView), orderHandler.GetDailyRevenueStats)
+		protected.GET("/orders/stats/product-sales", perm(models.PermOrderView), orderHandler.GetDailyProductSalesStats)
+		protected.POST("/orders", perm(models.PermOrderManage), orderHandler.CreateOrder)
+		protected.GET("/orders/:id", perm(models.PermOrderView), orderHandler.GetOrder)
+		protected.PUT("/orders/:id/pay", perm(models.PermOrderManage), orderHandler.MarkPaid)
+		protected.PUT("/orders/:id/complete", perm(models.PermOrderManage), orderHandler.MarkComplete)
+		protected.PUT("/orders/:id/cancel", perm(models.PermOrderManage), orderHandler.MarkCancelled)
+		protected.PUT("/orders/pickup/:order_number", perm(models.PermOrderManage), orderHandler.MarkPickedUp)
 
 		// Users
-		protected.GET("/users", userHandler.ListUsers)
-		protected.GET("/users/:id", userHandler.GetUser)
-		protected.POST("/users", userHandler.CreateUser)
-		protected.PUT("/users/:id", userHandler.UpdateUser)
-		protected.PUT("/users/:id/pin", userHandler.UpdatePIN)
-		protected.PUT("/users/:id/icon", userHandler.UpdateIcon)
-		protected.PUT("/users/:id/stores", userHandler.UpdateUserStores)
-		protected.PUT("/users/:id/work-settings", userHandler.UpdateUserWorkSettings)
+		protected.GET("/users", perm(models.PermUserView), userHandler.ListUsers)
+		protected.GET("/users/:id", perm(models.PermUserView), userHandler.GetUser)
+		protected.POST("/users", perm(models.PermUserManage), userHandler.CreateUser)
+		protected.PUT("/users/:id", perm(models.PermUserManage), userHandler.UpdateUser)
+		protected.PUT("/users/:id/pin", perm(models.PermUserView), userHandler.UpdatePIN)
+		protected.PUT("/users/:id/icon", perm(models.PermUserView), userHandler.UpdateIcon)
+		protected.PUT("/users/:id/stores", perm(models.PermUserManage), userHandler.UpdateUserStores)
+		protected.PUT("/users/:id/work-settings", perm(models.PermUserView), userHandler.UpdateUserWorkSettings)
+		protected.GET("/users/:id/sessions", perm(models.PermUserManage), authHandler.ListUserSessions)
+		protected.POST("/users/:id/sessions/revoke", perm(models.PermUserManage), authHandler.RevokeUserSessions)
+		protected.DELETE("/users/:id/two-factor", perm(models.PermUserCredentialsEdit), authHandler.ResetUserTwoFactor)
+		protected.GET("/auth/lockouts", perm(models.PermUserManage), authHandler.ListLoginLockouts)
+		protected.POST("/auth/lockouts/unlock", perm(models.PermUserManage), authHandler.UnlockLogin)
 
 		// Devices (protected endpoints for management)
-		protected.GET("/devices", deviceHandler.ListDevices)
-		protected.GET("/devices/:id", deviceHandler.GetDevice)
-		protected.PUT("/device/configure", deviceHandler.ConfigureDevice) // add or update device store (management only)
-		protected.GET("/stores/:store_id/devices", deviceHandler.ListDevicesByStore)
+		protected.GET("/devices", perm(models.PermDeviceView), deviceHandler.ListDevices)
+		protected.GET("/devices/:id", perm(models.PermDeviceView), deviceHandler.GetDevice)
+		protected.PUT("/devices/:id", perm(models.PermDeviceManage), deviceHandler.UpdateDevice)
+		protected.POST("/devices/:id/enrolment-code", perm(models.PermDeviceManage), deviceHandler.CreateEnrolmentCode)
+		protected.POST("/devices/:id/revoke", perm(models.PermDeviceManage), deviceHandler.RevokeDevice)
+		protected.PUT("/device/configure", perm(models.PermDeviceManage), deviceHandler.ConfigureDevice) // add or update device store
+		protected.GET("/stores/:store_id/devices", perm(models.PermDeviceView), deviceHandler.ListDevicesByStore)
 
 		// Stores
-		protected.GET("/stores", stockHandler.ListStores)
-		protected.GET("/stores/:store_id", stockHandler.GetStore)
-		protected.POST("/stores", stockHandler.CreateStore)
-		protected.PUT("/stores/:store_id", stockHandler.UpdateStore)
+		protected.GET("/stores", perm(models.PermStockView), stockHandler.ListStores)
+		protected.GET("/stores/:store_id", perm(models.PermStockView), stockHandler.GetStore)
+		protected.POST("/stores", perm(models.PermStoreManage), stockHandler.CreateStore)
+		protected.PUT("/stores/:store_id", perm(models.PermStoreManage), stockHandler.UpdateStore)
 
 		// Catalogs
-		protected.GET("/catalogs/:sector_id", catalogHandler.GenerateCatalog)
-		protected.GET("/catalogs/:sector_id/download", catalogHandler.DownloadCatalog)
+		protected.GET("/catalogs/:sector_id", perm(models.PermProductView), catalogHandler.GenerateCatalog)
+		protected.GET("/catalogs/:sector_id/download", perm(models.PermProductView), catalogHandler.DownloadCatalog)
 
 		// Company settings (for PDF/document company address and contact)
-		protected.GET("/settings/company", settingsHandler.GetCompanySettings)
-		protected.PUT("/settings/company", settingsHandler.UpdateCompanySettings)
-		protected.POST("/settings/company/logo", settingsHandler.UploadCompanyLogo)
-		protected.POST("/settings/company/logo/copy", settingsHandler.CopyCompanyLogo)
-		protected.POST("/settings/company/logo/all", settingsHandler.UploadCompanyLogoAll)
-		protected.POST("/settings/company/logo/:type", settingsHandler.UploadCompanyLogoByType)
-		protected.GET("/settings/system-info", settingsHandler.GetSystemInfo)
-		protected.POST("/settings/company/wholesale/toggle", settingsHandler.ToggleWholesaleOrder)
-		protected.POST("/settings/company/pos/toggle", settingsHandler.TogglePosModule)
+		protected.GET("/settings/company", perm(models.PermSettingsView), settingsHandler.GetCompanySettings)
+		protected.PUT("/settings/company", perm(models.PermSettingsManage), settingsHandler.UpdateCompanySettings)
+		protected.POST("/settings/company/logo", perm(models.PermSettingsManage), settingsHandler.UploadCompanyLogo)
+		protected.POST("/settings/company/logo/copy", perm(models.PermSettingsManage), settingsHandler.CopyCompanyLogo)
+		protected.POST("/settings/company/logo/all", perm(models.PermSettingsManage), settingsHandler.UploadCompanyLogoAll)
+		protected.POST("/settings/company/logo/:type", perm(models.PermSettingsManage), settingsHandler.UploadCompanyLogoByType)
+		protected.GET("/settings/system-info", perm(models.PermSettingsView), settingsHandler.GetSystemInfo)
+		protected.POST("/settings/company/wholesale/toggle", perm(models.PermSettingsManage), settingsHandler.ToggleWholesaleOrder)
+		protected.POST("/settings/company/pos/toggle", perm(models.PermSettingsManage), settingsHandler.TogglePosModule)
 
 		wholesale := protected.Group("", WholesaleOrderEnabledMiddleware(db))
 		{
-			wholesale.GET("/stock/wholesale-ship-from", stockHandler.GetWholesaleShipFromMap)
-			wholesale.GET("/wholesale-clients", wholesaleClientHandler.List)
-			wholesale.GET("/wholesale-clients/:id", wholesaleClientHandler.Get)
-			wholesale.POST("/wholesale-clients", wholesaleClientHandler.Create)
-			wholesale.PUT("/wholesale-clients/:id", wholesaleClientHandler.Update)
-			wholesale.DELETE("/wholesale-clients/:id", wholesaleClientHandler.Delete)
-			wholesale.POST("/wholesale-clients/:id/stores", wholesaleClientHandler.CreateStore)
-			wholesale.PUT("/wholesale-clients/:id/stores/:store_id", wholesaleClientHandler.UpdateStore)
-			wholesale.DELETE("/wholesale-clients/:id/stores/:store_id", wholesaleClientHandler.DeleteStore)
-			wholesale.POST("/wholesale-orders", wholesaleOrderHandler.Create)
-			wholesale.GET("/wholesale-orders", wholesaleOrderHandler.List)
-			wholesale.GET("/wholesale-orders/recent-order-channels", wholesaleOrderHandler.RecentOrderChannels)
-			wholesale.GET("/wholesale-orders/stats/revenue-summary", wholesaleOrderHandler.GetWholesaleRevenueSummaryStats)
-			wholesale.GET("/wholesale-orders/stats/product-sales", wholesaleOrderHandler.GetWholesaleProductSalesStats)
-			wholesale.GET("/wholesale-orders/stats/client-sales", wholesaleOrderHandler.GetWholesaleClientSalesStats)
-			wholesale.POST("/wholesale-orders/test-email", wholesaleOrderHandler.SendTestEmail)
-			wholesale.POST("/wholesale-orders/bulk-attachments-zip-email", wholesaleOrderHandler.BulkAttachmentsZipEmail)
-			wholesale.GET("/wholesale-orders/:id", wholesaleOrderHandler.Get)
-			wholesale.PUT("/wholesale-orders/:id", wholesaleOrderHandler.Update)
-			wholesale.PUT("/wholesale-orders/:id/approve", wholesaleOrderHandler.Approve)
-			wholesale.GET("/wholesale-orders/:id/endorse-allocation-preview", wholesaleOrderHandler.EndorseAllocationPreview)
-			wholesale.PUT("/wholesale-orders/:id/reject", wholesaleOrderHandler.Reject)
-			wholesale.PUT("/wholesale-orders/:id/archive", wholesaleOrderHandler.Archive)
-			wholesale.PUT("/wholesale-orders/:id/resubmit", wholesaleOrderHandler.Resubmit)
-			wholesale.PUT("/wholesale-orders/:id/assign", wholesaleOrderHandler.AssignStores)
-			wholesale.PUT("/wholesale-orders/:id/unassign", wholesaleOrderHandler.UnassignStores)
-			wholesale.PUT("/wholesale-orders/:id/assign-by-defaults", wholesaleOrderHandler.AssignByDefaults)
-			wholesale.PUT("/wholesale-orders/:id/complete-assignment", wholesaleOrderHandler.CompleteAssignment)
-			wholesale.POST("/wholesale-orders/:id/regenerate-order-confirmation", wholesaleOrderHandler.RegenerateOrderConfirmation)
-			wholesale.POST("/wholesale-orders/:id/generate-invoice", wholesaleOrderHandler.GenerateInvoice)
-			wholesale.PATCH("/wholesale-orders/:id/invoice-sent", wholesaleOrderHandler.SetInvoiceSentAt)
-			wholesale.GET("/wholesale-orders/:id/audit-logs", wholesaleOrderHandler.GetAuditLogs)
-			wholesale.POST("/wholesale-orders/:id/audit-logs/:auditLogId/restore-document", wholesaleOrderHandler.RestoreDocumentFromAudit)
-			wholesale.POST("/wholesale-orders/:id/email", wholesaleOrderHandler.EmailOrder)
-			wholesale.POST("/wholesale-orders/:id/skip-email", wholesaleOrderHandler.SkipWholesaleOrderEmail)
-			wholesale.POST("/wholesale-orders/:id/email-document", wholesaleOrderHandler.EmailDocument)
-			wholesale.POST("/wholesale-orders/:id/po-attachments", wholesaleOrderHandler.UploadPOAttachments)
-			wholesale.DELETE("/wholesale-orders/:id/documents/:docId", wholesaleOrderHandler.DeletePOAttachment)
-			wholesale.GET("/wholesale-orders/:id/documents/:docId/download", wholesaleOrderHandler.DownloadDocument)
-			wholesale.GET("/wholesale-orders/:id/legacy-payment-proof/download", wholesaleOrderHandler.DownloadLegacyPaymentProof)
-			wholesale.POST("/wholesale-orders/:id/upload-payment-proof", wholesaleOrderHandler.UploadPaymentProof)
-			wholesale.POST("/wholesale-orders/:id/confirm-payment", wholesaleOrderHandler.ConfirmPayment)
-			wholesale.GET("/shipments", wholesaleOrderHandler.ListShipments)
-			wholesale.GET("/shipments/:id", wholesaleOrderHandler.GetShipment)
-			wholesale.PUT("/shipments/:id", wholesaleOrderHandler.UpdateShipment)
-			wholesale.PATCH("/shipments/:id/status", wholesaleOrderHandler.UpdateShipmentStatus)
-			wholesale.POST("/shipments/:id/start-shipment", wholesaleOrderHandler.StartShipment)
-			wholesale.POST("/shipments/:id/upload-signed-delivery-note", wholesaleOrderHandler.UploadSignedDeliveryNote)
-			wholesale.POST("/shipments/:id/complete-packing", wholesaleOrderHandler.CompletePacking)
-			wholesale.POST("/shipments/:id/regenerate-delivery-note", wholesaleOrderHandler.RegenerateDeliveryNote)
-			wholesale.PUT("/shipments/:id/case-qty", wholesaleOrderHandler.UpdateShipmentCaseQty)
+			wholesale.GET("/stock/wholesale-ship-from", perm(models.PermWholesaleView), stockHandler.GetWholesaleShipFromMap)
+			wholesale.GET("/wholesale-clients", perm(models.PermWholesaleView), wholesaleClientHandler.List)
+			wholesale.GET("/wholesale-clients/:id", perm(models.PermWholesaleView), wholesaleClientHandler.Get)
+			wholesale.POST("/wholesale-clients", perm(models.PermWholesaleClientManage), wholesaleClientHandler.Create)
+			wholesale.PUT("/wholesale-clients/:id", perm(models.PermWholesaleClientManage), wholesaleClientHandler.Update)
+			wholesale.DELETE("/wholesale-clients/:id", perm(models.PermWholesaleClientManage), wholesaleClientHandler.Delete)
+			wholesale.POST("/wholesale-clients/:id/stores", perm(models.PermWholesaleClientManage), wholesaleClientHandler.CreateStore)
+			wholesale.PUT("/wholesale-clients/:id/stores/:clientStoreId", perm(models.PermWholesaleClientManage), wholesaleClientHandler.UpdateStore)
+			wholesale.DELETE("/wholesale-clients/:id/stores/:clientStoreId", perm(models.PermWholesaleClientManage), wholesaleClientHandler.DeleteStore)
+			wholesale.POST("/wholesale-orders", perm(models.PermWholesaleCreate), wholesaleOrderHandler.Create)
+			wholesale.GET("/wholesale-orders", perm(models.PermWholesaleView), wholesaleOrderHandler.List)
+			wholesale.GET("/wholesale-orders/recent-order-channels", perm(models.PermWholesaleView), wholesaleOrderHandler.RecentOrderChannels)
+			wholesale.GET("/wholesale-orders/stats/revenue-summary", perm(models.PermWholesaleViewAll), wholesaleOrderHandler.GetWholesaleRevenueSummaryStats)
+			wholesale.GET("/wholesale-orders/stats/product-sales", perm(models.PermWholesaleViewAll), wholesaleOrderHandler.GetWholesaleProductSalesStats)
+			wholesale.GET("/wholesale-orders/stats/client-sales", perm(models.PermWholesaleViewAll), wholesaleOrderHandler.GetWholesaleClientSalesStats)
+			wholesale.POST("/wholesale-orders/test-email", perm(models.PermWholesaleManage), wholesaleOrderHandler.SendTestEmail)
+			wholesale.POST("/wholesale-orders/bulk-attachments-zip-email", perm(models.PermWholesaleManage), wholesaleOrderHandler.BulkAttachmentsZipEmail)
+			wholesale.GET("/wholesale-orders/:id", perm(models.PermWholesaleView), wholesaleOrderHandler.Get)
+			wholesale.PUT("/wholesale-orders/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.Update)
+			wholesale.PUT("/wholesale-orders/:id/approve", perm(models.PermWholesaleApprove), wholesaleOrderHandler.Approve)
+			wholesale.GET("/wholesale-orders/:id/endorse-allocation-preview", perm(models.PermWholesaleManage), wholesaleOrderHandler.EndorseAllocationPreview)
+			wholesale.POST("/wholesale-orders/:id/backorder", perm(models.PermWholesaleManage), wholesaleOrderHandler.SplitBackorder)
+			wholesale.PUT("/wholesale-orders/:id/reject", perm(models.PermWholesaleApprove), wholesaleOrderHandler.Reject)
+			wholesale.PUT("/wholesale-orders/:id/archive", perm(models.PermWholesaleManage), wholesaleOrderHandler.Archive)
+			wholesale.PUT("/wholesale-orders/:id/resubmit", perm(models.PermWholesaleManage), wholesaleOrderHandler.Resubmit)
+			wholesale.PUT("/wholesale-orders/:id/assign", perm(models.PermWholesaleManage), wholesaleOrderHandler.AssignStores)
+			wholesale.PUT("/wholesale-orders/:id/unassign", perm(models.PermWholesaleManage), wholesaleOrderHandler.UnassignStores)
+			wholesale.PUT("/wholesale-orders/:id/assign-by-defaults", perm(models.PermWholesaleManage), wholesaleOrderHandler.AssignByDefaults)
+			wholesale.PUT("/wholesale-orders/:id/complete-assignment", perm(models.PermWholesaleManage), wholesaleOrderHandler.CompleteAssignment)
+			wholesale.POST("/wholesale-orders/:id/regenerate-order-confirmation", perm(models.PermWholesaleManage), wholesaleOrderHandler.RegenerateOrderConfirmation)
+			wholesale.POST("/wholesale-orders/:id/generate-invoice", perm(models.PermWholesaleManage), wholesaleOrderHandler.GenerateInvoice)
+			wholesale.PATCH("/wholesale-orders/:id/invoice-sent", perm(models.PermWholesaleManage), wholesaleOrderHandler.SetInvoiceSentAt)
+			wholesale.GET("/wholesale-orders/:id/audit-logs", perm(models.PermWholesaleView), wholesaleOrderHandler.GetAuditLogs)
+			wholesale.POST("/wholesale-orders/:id/audit-logs/:auditLogId/restore-document", perm(models.PermWholesaleManage), wholesaleOrderHandler.RestoreDocumentFromAudit)
+			wholesale.POST("/wholesale-orders/:id/email", perm(models.PermWholesaleManage), wholesaleOrderHandler.EmailOrder)
+			wholesale.POST("/wholesale-orders/:id/skip-email", perm(models.PermWholesaleManage), wholesaleOrderHandler.SkipWholesaleOrderEmail)
+			wholesale.POST("/wholesale-orders/:id/email-document", perm(models.PermWholesaleManage), wholesaleOrderHandler.EmailDocument)
+			wholesale.POST("/wholesale-orders/:id/po-attachments", perm(models.PermWholesaleCreate), wholesaleOrderHandler.UploadPOAttachments)
+			wholesale.DELETE("/wholesale-orders/:id/documents/:docId", perm(models.PermWholesaleCreate), wholesaleOrderHandler.DeletePOAttachment)
+			wholesale.GET("/wholesale-orders/:id/documents/:docId/download", perm(models.PermWholesaleView), wholesaleOrderHandler.DownloadDocument)
+			wholesale.GET("/wholesale-orders/:id/legacy-payment-proof/download", perm(models.PermWholesaleView), wholesaleOrderHandler.DownloadLegacyPaymentProof)
+			wholesale.POST("/wholesale-orders/:id/upload-payment-proof", perm(models.PermWholesaleManage), wholesaleOrderHandler.UploadPaymentProof)
+			wholesale.POST("/wholesale-orders/:id/confirm-payment", perm(models.PermWholesaleManage), wholesaleOrderHandler.ConfirmPayment)
+			wholesale.POST("/wholesale-quotations", perm(models.PermWholesaleCreate), wholesaleOrderHandler.CreateQuotation)
+			wholesale.GET("/wholesale-quotations", perm(models.PermWholesaleView), wholesaleOrderHandler.ListQuotations)
+			wholesale.GET("/wholesale-quotations/:id", perm(models.PermWholesaleView), wholesaleOrderHandler.GetQuotation)
+			wholesale.PUT("/wholesale-quotations/:id", perm(models.PermWholesaleCreate), wholesaleOrderHandler.UpdateQuotation)
+			wholesale.PUT("/wholesale-quotations/:id/cancel", perm(models.PermWholesaleCreate), wholesaleOrderHandler.CancelQuotation)
+			wholesale.GET("/wholesale-quotations/:id/download", perm(models.PermWholesaleView), wholesaleOrderHandler.DownloadQuotationPDF)
+			wholesale.POST("/wholesale-quotations/:id/email", perm(models.PermWholesaleCreate), wholesaleOrderHandler.EmailQuotation)
+			wholesale.POST("/wholesale-quotations/:id/convert", perm(models.PermWholesaleCreate), wholesaleOrderHandler.ConvertQuotation)
+			wholesale.POST("/wholesale-orders/:id/ubl-invoice", perm(models.PermWholesaleManage), wholesaleOrderHandler.GenerateUBLInvoice)
+			wholesale.GET("/wholesale-orders/:id/ubl-invoice/validate", perm(models.PermWholesaleManage), wholesaleOrderHandler.ValidateUBLInvoice)
+			wholesale.POST("/wholesale-orders/:id/returns", perm(models.PermWholesaleManage), wholesaleOrderHandler.CreateReturn)
+			wholesale.GET("/wholesale-orders/:id/returns", perm(models.PermWholesaleManage), wholesaleOrderHandler.ListOrderReturns)
+			wholesale.GET("/wholesale-returns", perm(models.PermWholesaleManage), wholesaleOrderHandler.ListReturns)
+			wholesale.GET("/wholesale-returns/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.GetReturn)
+			wholesale.PUT("/wholesale-returns/:id/receive", perm(models.PermWholesaleManage), wholesaleOrderHandler.ReceiveReturn)
+			wholesale.PUT("/wholesale-returns/:id/inspect", perm(models.PermWholesaleManage), wholesaleOrderHandler.InspectReturn)
+			wholesale.PUT("/wholesale-returns/:id/cancel", perm(models.PermWholesaleManage), wholesaleOrderHandler.CancelReturn)
+			wholesale.POST("/wholesale-returns/:id/credit-note", perm(models.PermWholesaleManage), wholesaleOrderHandler.GenerateReturnCreditNote)
+			wholesale.POST("/wholesale-returns/:id/ubl-credit-note", perm(models.PermWholesaleManage), wholesaleOrderHandler.GenerateReturnUBLCreditNote)
+			wholesale.GET("/shipping-rules", perm(models.PermWholesaleView), wholesaleOrderHandler.ListShippingRules)
+			wholesale.POST("/shipping-rules", perm(models.PermWholesaleManage), wholesaleOrderHandler.CreateShippingRule)
+			wholesale.POST("/shipping-rules/quote", perm(models.PermWholesaleView), wholesaleOrderHandler.QuoteShipping)
+			wholesale.PUT("/shipping-rules/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateShippingRule)
+			wholesale.DELETE("/shipping-rules/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.DeleteShippingRule)
+			wholesale.GET("/standing-orders", perm(models.PermWholesaleManage), wholesaleOrderHandler.ListStandingOrders)
+			wholesale.POST("/standing-orders", perm(models.PermWholesaleManage), wholesaleOrderHandler.CreateStandingOrder)
+			wholesale.POST("/standing-orders/run", perm(models.PermWholesaleManage), wholesaleOrderHandler.RunStandingOrders)
+			wholesale.GET("/standing-orders/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.GetStandingOrder)
+			wholesale.PUT("/standing-orders/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateStandingOrder)
+			wholesale.DELETE("/standing-orders/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.DeleteStandingOrder)
+			wholesale.GET("/standing-orders/:id/occurrences", perm(models.PermWholesaleManage), wholesaleOrderHandler.ListStandingOrderOccurrences)
+			wholesale.PUT("/standing-orders/:id/occurrences/:date", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateStandingOrderOccurrence)
+			wholesale.GET("/shipments", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ListShipments)
+			wholesale.GET("/shipments/:id", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetShipment)
+			wholesale.PUT("/shipments/:id", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.UpdateShipment)
+			wholesale.PATCH("/shipments/:id/status", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.UpdateShipmentStatus)
+			wholesale.POST("/shipments/:id/start-shipment", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.StartShipment)
+			wholesale.POST("/shipments/:id/upload-signed-delivery-note", perm(models.PermWholesaleManage), wholesaleOrderHandler.UploadSignedDeliveryNote)
+			wholesale.POST("/shipments/:id/epod", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.CaptureDeliveryProof)
+			wholesale.GET("/shipments/:id/epod", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ListDeliveryProofs)
+			wholesale.POST("/shipments/:id/complete-packing", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.CompletePacking)
+			wholesale.POST("/shipments/:id/regenerate-delivery-note", perm(models.PermWholesaleManage), wholesaleOrderHandler.RegenerateDeliveryNote)
+			wholesale.PUT("/shipments/:id/case-qty", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateShipmentCaseQty)
+			wholesale.GET("/pick-list", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetPickList)
+			wholesale.GET("/pick-list/pdf", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetPickListPDF)
+			wholesale.POST("/pick-list/scan", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ScanPickList)
+			wholesale.GET("/delivery-runs", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ListDeliveryRuns)
+			wholesale.POST("/delivery-runs", perm(models.PermWholesaleManage), wholesaleOrderHandler.CreateDeliveryRun)
+			wholesale.GET("/delivery-runs/:id", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetDeliveryRun)
+			wholesale.PUT("/delivery-runs/:id", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateDeliveryRun)
+			wholesale.POST("/delivery-runs/:id/cancel", perm(models.PermWholesaleManage), wholesaleOrderHandler.CancelDeliveryRun)
+			wholesale.GET("/delivery-runs/:id/manifest", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetDeliveryRunManifest)
+			wholesale.POST("/delivery-runs/:id/stops", perm(models.PermWholesaleManage), wholesaleOrderHandler.AddDeliveryRunStops)
+			wholesale.PUT("/delivery-runs/:id/sequence", perm(models.PermWholesaleManage), wholesaleOrderHandler.ReorderDeliveryRunStops)
+			wholesale.POST("/delivery-runs/:id/optimise", perm(models.PermWholesaleManage), wholesaleOrderHandler.OptimiseDeliveryRun)
+			wholesale.PUT("/delivery-runs/:id/stops/:stopId", perm(models.PermWholesaleManage), wholesaleOrderHandler.UpdateDeliveryRunStop)
+			wholesale.DELETE("/delivery-runs/:id/stops/:stopId", perm(models.PermWholesaleManage), wholesaleOrderHandler.RemoveDeliveryRunStop)
+			wholesale.POST("/delivery-runs/:id/stops/:stopId/deliver", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.DeliverDeliveryRunStop)
+			wholesale.POST("/delivery-runs/:id/stops/:stopId/fail", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.FailDeliveryRunStop)
+			wholesale.GET("/couriers", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ListCourierProviders)
+			wholesale.GET("/shipments/:id/labels", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetShipmentLabels)
+			wholesale.POST("/shipments/:id/consignment", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.CreateShipmentConsignment)
+			wholesale.GET("/shipments/:id/courier-label", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetShipmentCourierLabel)
+			wholesale.GET("/shipments/:id/tracking", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ListShipmentTrackingEvents)
+			wholesale.POST("/shipments/:id/tracking/refresh", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.RefreshShipmentTracking)
+			wholesale.POST("/shipments/:id/packing-session", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.StartPackingSession)
+			wholesale.GET("/shipments/:id/packing-session", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.GetPackingSession)
+			wholesale.POST("/packing-sessions/:id/cartons", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.AddPackingCarton)
+			wholesale.POST("/packing-sessions/:id/scan", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.ScanPackingItem)
+			wholesale.DELETE("/packing-sessions/:id/carton-items/:itemId", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.RemovePackingCartonItem)
+			wholesale.POST("/packing-sessions/:id/complete", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.CompletePackingSession)
+			wholesale.POST("/packing-sessions/:id/cancel", perm(models.PermWholesaleDispatch), wholesaleOrderHandler.CancelPackingSession)
 		}
 
 		// Currency Rates
-		protected.GET("/currency-rates", currencyHandler.ListCurrencyRates)
-		protected.GET("/currency-rates/:code", currencyHandler.GetCurrencyRate)
-		protected.POST("/currency-rates", currencyHandler.CreateCurrencyRate)
-		protected.PUT("/currency-rates/:code", currencyHandler.UpdateCurrencyRate)
-		protected.PUT("/currency-rates/:code/pin", currencyHandler.TogglePinCurrencyRate)
-		protected.DELETE("/currency-rates/:code", currencyHandler.DeleteCurrencyRate)
-		protected.POST("/currency-rates/sync", currencyHandler.SyncCurrencyRates)
+		protected.GET("/currency-rates", perm(models.PermSettingsView), currencyHandler.ListCurrencyRates)
+		protected.GET("/currency-rates/:code", perm(models.PermSettingsView), currencyHandler.GetCurrencyRate)
+		protected.POST("/currency-rates", perm(models.PermCurrencyManage), currencyHandler.CreateCurrencyRate)
+		protected.PUT("/currency-rates/:code", perm(models.PermCurrencyManage), currencyHandler.UpdateCurrencyRate)
+		protected.PUT("/currency-rates/:code/pin", perm(models.PermCurrencyManage), currencyHandler.TogglePinCurrencyRate)
+		protected.DELETE("/currency-rates/:code", perm(models.PermCurrencyManage), currencyHandler.DeleteCurrencyRate)
+		protected.POST("/currency-rates/sync", perm(models.PermCurrencyManage), currencyHandler.SyncCurrencyRates)
+
+		// Accounting exports (Xero / Sage CSV)
+		protected.GET("/accounting/nominal-codes", perm(models.PermAccountingExport), accountingExportHandler.ListNominalCodes)
+		protected.PUT("/accounting/nominal-codes", perm(models.PermAccountingExport), accountingExportHandler.UpdateNominalCodes)
+		protected.GET("/accounting/exports/preview", perm(models.PermAccountingExport), accountingExportHandler.PreviewExport)
+		protected.GET("/accounting/exports", perm(models.PermAccountingExport), accountingExportHandler.ListExports)
+		protected.POST("/accounting/exports", perm(models.PermAccountingExport), accountingExportHandler.CreateExport)
+		protected.GET("/accounting/exports/:id/download", perm(models.PermAccountingExport), accountingExportHandler.DownloadExport)
+		protected.PUT("/accounting/exports/:id/void", perm(models.PermAccountingExport), accountingExportHandler.VoidExport)
+
+		// Invoice and credit note number series
+		protected.GET("/document-number-series", perm(models.PermDocumentNumberView), documentNumberHandler.ListSeries)
+		protected.PUT("/document-number-series/:type", perm(models.PermDocumentNumberManage), documentNumberHandler.UpdateSeries)
+		protected.GET("/document-numbers/report", perm(models.PermDocumentNumberView), documentNumberHandler.SequenceReport)
+		protected.PUT("/document-numbers/:id/void", perm(models.PermDocumentNumberManage), documentNumberHandler.VoidNumber)
 	}
 
 	return router
@@ -247,7 +395,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, Pragma, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, Pragma, X-Requested-With, X-Device-Token")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,602 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/cron"
+	"pos-system/backend/internal/jobs"
+	apimail "pos-system/backend/internal/mail"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The scheduler leader renews its lease every tick; another instance takes over once it is older than the TTL.
+// Instance clocks are assumed to agree to well within the TTL.
+const (
+	schedulerLeaseName = "scheduler"
+	schedulerLeaseTTL  = 90 * time.Second
+	schedulerTick      = 30 * time.Second
+)
+
+// Leases for the periodic loops that predate the task scheduler; see holdLoopLease.
+const (
+	standingOrderLeaseName   = "standing_orders"
+	webhookDispatchLeaseName = "webhook_dispatch"
+	courierTrackingLeaseName = "courier_tracking"
+)
+
+// Job types queued by scheduled tasks.
+const (
+	jobTypeLowStockAlert    = "low_stock_alert"
+	jobTypeDayEndSnapshot   = "day_end_snapshot"
+	jobTypeSalesReportEmail = "sales_report_email"
+)
+
+// autoDayEndSnapshotType marks day-end quantities recorded by the scheduler rather than counted by staff.
+// GetStockReport uses them only where no stocktake_day_end exists.
+const autoDayEndSnapshotType = "auto_day_end"
+
+// scheduledTaskDef is a built-in task. Its schedule, zone, recipients and enabled flag live in scheduled_tasks
+// and are editable; what it does is fixed here. A run only queues a job, so the work itself is retried and
+// logged like any other job.
+type scheduledTaskDef struct {
+	Key            string
+	Name           string
+	Description    string
+	DefaultCron    string
+	DefaultEnabled bool
+	enqueue        func(db *gorm.DB, task *models.ScheduledTask, now time.Time, createdBy *uint) (*models.Job, error)
+}
+
+var scheduledTaskDefs = []scheduledTaskDef{
+	{
+		Key: "currency_sync", Name: "Currency rate sync", DefaultCron: "0 6 * * *", DefaultEnabled: true,
+		Description: "Fetches the latest GBP exchange rates; pinned rates are kept.",
+		enqueue: func(db *gorm.DB, _ *models.ScheduledTask, _ time.Time, createdBy *uint) (*models.Job, error) {
+			return jobs.Enqueue(db, jobTypeCurrencySync, struct{}{}, jobs.Options{CreatedByID: createdBy})
+		},
+	},
+	{
+		Key: "low_stock_alert", Name: "Low stock alert email", DefaultCron: "0 7 * * 1-6",
+		Description: "Emails the stock rows at or below their low-stock threshold.",
+		enqueue: func(db *gorm.DB, task *models.ScheduledTask, _ time.Time, createdBy *uint) (*models.Job, error) {
+			return jobs.Enqueue(db, jobTypeLowStockAlert, reportEmailJob{Recipients: taskRecipients(db, task)},
+				jobs.Options{CreatedByID: createdBy})
+		},
+	},
+	{
+		Key: "day_end_snapshot", Name: "Day-end stock snapshot", DefaultCron: "55 23 * * *", DefaultEnabled: true,
+		Description: "Records every store's stock as the day-end quantity where no day-end stocktake was done.",
+		enqueue: func(db *gorm.DB, task *models.ScheduledTask, now time.Time, createdBy *uint) (*models.Job, error) {
+			return jobs.Enqueue(db, jobTypeDayEndSnapshot, reportEmailJob{Date: taskLocalDate(task, now, 0)},
+				jobs.Options{CreatedByID: createdBy})
+		},
+	},
+	{
+		Key: "daily_sales_report", Name: "Daily sales report email", DefaultCron: "0 7 * * *",
+		Description: "Emails the previous day's POS order count and takings per store.",
+		enqueue: func(db *gorm.DB, task *models.ScheduledTask, now time.Time, createdBy *uint) (*models.Job, error) {
+			return jobs.Enqueue(db, jobTypeSalesReportEmail, reportEmailJob{
+				Date: taskLocalDate(task, now, -1), Timezone: task.Timezone, Recipients: taskRecipients(db, task),
+			}, jobs.Options{CreatedByID: createdBy})
+		},
+	},
+}
+
+func findScheduledTaskDef(key string) *scheduledTaskDef {
+	for i := range scheduledTaskDefs {
+		if scheduledTaskDefs[i].Key == key {
+			return &scheduledTaskDefs[i]
+		}
+	}
+	return nil
+}
+
+// reportEmailJob is the payload of the scheduled report jobs. Date is YYYY-MM-DD in Timezone.
+type reportEmailJob struct {
+	Date       string   `json:"date,omitempty"`
+	Timezone   string   `json:"timezone,omitempty"`
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+// taskLocalDate is the task's local calendar date at now, shifted by days.
+func taskLocalDate(task *models.ScheduledTask, now time.Time, days int) string {
+	loc, err := time.LoadLocation(task.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return now.In(loc).AddDate(0, 0, days).Format("2006-01-02")
+}
+
+// taskRecipients returns the task's recipients, or the company email when none are set.
+func taskRecipients(db *gorm.DB, task *models.ScheduledTask) []string {
+	if list := parseEmailList(task.Recipients); len(list) > 0 {
+		return list
+	}
+	var company models.CompanySettings
+	if err := db.Select("email").First(&company, companySettingsID).Error; err == nil && strings.TrimSpace(company.Email) != "" {
+		return []string{strings.TrimSpace(company.Email)}
+	}
+	return nil
+}
+
+// nextTaskRun is the task's next due time after from, or nil when the schedule never fires again.
+func nextTaskRun(task *models.ScheduledTask, from time.Time) (*time.Time, error) {
+	s, err := cron.Parse(task.CronExpr, task.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	next := s.Next(from)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// ensureScheduledTasks inserts a row for each built-in task that has none yet. Existing rows keep their edits.
+func ensureScheduledTasks(db *gorm.DB, timezone string) error {
+	for _, def := range scheduledTaskDefs {
+		task := models.ScheduledTask{
+			Key: def.Key, Name: def.Name, Description: def.Description, CronExpr: def.DefaultCron,
+			Timezone: timezone, Enabled: def.DefaultEnabled,
+		}
+		if def.DefaultEnabled {
+			next, err := nextTaskRun(&task, time.Now())
+			if err != nil {
+				return fmt.Errorf("task %s: %w", def.Key, err)
+			}
+			task.NextRunAt = next
+		}
+		if err := db.Where(models.ScheduledTask{Key: def.Key}).Attrs(task).FirstOrCreate(&task).Error; err != nil {
+			return err
+		}
+		if task.Name != def.Name || task.Description != def.Description {
+			db.Model(&task).Updates(map[string]interface{}{"name": def.Name, "description": def.Description})
+		}
+	}
+	return nil
+}
+
+// acquireLease takes or renews the named lease for holder. It returns false while another holder's lease is live.
+func acquireLease(db *gorm.DB, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res := db.Model(&models.SchedulerLease{}).Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+	var count int64
+	if err := db.Model(&models.SchedulerLease{}).Where("name = ?", name).Count(&count).Error; err != nil || count > 0 {
+		return false, err
+	}
+	// First run: the primary key lets only one instance create the row.
+	if err := db.Create(&models.SchedulerLease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}).Error; err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// holdLoopLease takes or renews the named lease for holder ahead of a periodic loop's run and reports whether this
+// instance should do the run, so that loops started on every instance do their work on one at a time. The lease
+// outlasts the interval so the holder keeps it between runs; another instance takes over about a tick after the
+// holder stops.
+func holdLoopLease(db *gorm.DB, name, holder string, interval time.Duration) bool {
+	ok, err := acquireLease(db, name, holder, interval+schedulerLeaseTTL)
+	if err != nil {
+		log.Printf("[scheduler] %s lease: %v", name, err)
+	}
+	return ok
+}
+
+// StartTaskScheduler seeds the built-in tasks and, unless cfg.SchedulerEnabled is off, runs due tasks on
+// whichever instance holds the scheduler lease.
+func StartTaskScheduler(db *gorm.DB, cfg *config.Config) {
+	if err := ensureScheduledTasks(db, cfg.SchedulerTimezone); err != nil {
+		log.Printf("[scheduler] seed tasks: %v", err)
+	}
+	if !cfg.SchedulerEnabled {
+		log.Printf("[scheduler] disabled on this instance (SCHEDULER_ENABLED=false)")
+		return
+	}
+	holder := jobs.InstanceName()
+	go func() {
+		leader := false
+		for {
+			ok, err := acquireLease(db, schedulerLeaseName, holder, schedulerLeaseTTL)
+			if err != nil {
+				log.Printf("[scheduler] lease: %v", err)
+			}
+			if ok && !leader {
+				log.Printf("[scheduler] %s is now running scheduled tasks", holder)
+			} else if !ok && leader {
+				log.Printf("[scheduler] %s lost the scheduler lease", holder)
+			}
+			leader = ok
+			if leader {
+				refreshTaskResults(db)
+				if n, err := runDueTasks(db); err != nil {
+					log.Printf("[scheduler] run due tasks: %v", err)
+				} else if n > 0 {
+					log.Printf("[scheduler] started %d task(s)", n)
+				}
+			}
+			time.Sleep(schedulerTick)
+		}
+	}()
+}
+
+func runDueTasks(db *gorm.DB) (int, error) {
+	now := time.Now()
+	var due []models.ScheduledTask
+	if err := db.Where("enabled = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range due {
+		task := &due[i]
+		next, err := nextTaskRun(task, now)
+		if err != nil {
+			db.Model(task).Updates(map[string]interface{}{"enabled": false, "last_error": "invalid schedule: " + err.Error()})
+			continue
+		}
+		// Claim this run by moving next_run_at on; a run already claimed elsewhere is skipped.
+		claim := db.Model(&models.ScheduledTask{}).Where("id = ? AND next_run_at = ?", task.ID, task.NextRunAt).
+			Update("next_run_at", next)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+		startScheduledTask(db, task, "schedule", nil)
+		n++
+	}
+	return n, nil
+}
+
+// startScheduledTask queues one run of task and records it as the task's last run.
+func startScheduledTask(db *gorm.DB, task *models.ScheduledTask, trigger string, createdBy *uint) (*models.Job, error) {
+	now := time.Now()
+	def := findScheduledTaskDef(task.Key)
+	var job *models.Job
+	err := errors.New("no built-in task with this key")
+	if def != nil {
+		job, err = def.enqueue(db, task, now, createdBy)
+	}
+	updates := map[string]interface{}{"last_run_at": now, "last_trigger": trigger, "last_result": ""}
+	if err != nil {
+		updates["last_status"], updates["last_error"], updates["last_finished_at"], updates["last_job_id"] =
+			models.TaskRunFailed, err.Error(), now, nil
+	} else {
+		updates["last_status"], updates["last_error"], updates["last_finished_at"], updates["last_job_id"] =
+			models.TaskRunQueued, "", nil, job.ID
+	}
+	db.Model(&models.ScheduledTask{}).Where("id = ?", task.ID).Updates(updates)
+	return job, err
+}
+
+// refreshTaskResults copies the outcome of finished jobs onto the tasks that queued them.
+func refreshTaskResults(db *gorm.DB) {
+	var pending []models.ScheduledTask
+	if err := db.Where("last_status = ? AND last_job_id IS NOT NULL", models.TaskRunQueued).Find(&pending).Error; err != nil || len(pending) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(pending))
+	for _, t := range pending {
+		ids = append(ids, *t.LastJobID)
+	}
+	var list []models.Job
+	db.Select("id", "status", "result", "last_error", "finished_at").Where("id IN ?", ids).Find(&list)
+	byID := make(map[uint]models.Job, len(list))
+	for _, j := range list {
+		byID[j.ID] = j
+	}
+	for _, t := range pending {
+		j, ok := byID[*t.LastJobID]
+		var updates map[string]interface{}
+		switch {
+		case !ok:
+			updates = map[string]interface{}{"last_status": models.TaskRunFailed, "last_error": "job no longer exists"}
+		case j.Status == models.JobStatusSucceeded:
+			updates = map[string]interface{}{"last_status": models.TaskRunSucceeded, "last_result": j.Result, "last_error": "", "last_finished_at": j.FinishedAt}
+		case j.Status == models.JobStatusDead:
+			updates = map[string]interface{}{"last_status": models.TaskRunFailed, "last_error": j.LastError, "last_finished_at": j.FinishedAt}
+		default:
+			continue
+		}
+		db.Model(&models.ScheduledTask{}).Where("id = ? AND last_job_id = ?", t.ID, *t.LastJobID).Updates(updates)
+	}
+}
+
+type SchedulerHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewSchedulerHandler(db *gorm.DB, cfg *config.Config) *SchedulerHandler {
+	return &SchedulerHandler{db: db, cfg: cfg}
+}
+
+func (h *SchedulerHandler) audit(c *gin.Context, action string, task *models.ScheduledTask, changes map[string]interface{}) {
+	changes["key"] = task.Key
+	writeAudit(h.db, actorFromContext(c), action, "scheduled_task", task.ID, changes)
+}
+
+func (h *SchedulerHandler) ListTasks(c *gin.Context) {
+	refreshTaskResults(h.db)
+	var tasks []models.ScheduledTask
+	if err := h.db.Order("name").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var lease models.SchedulerLease
+	h.db.First(&lease, "name = ?", schedulerLeaseName)
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks, "leader": lease.Holder, "lease_expires_at": lease.ExpiresAt})
+}
+
+// UpdateTask changes a task's schedule, zone, recipients or enabled flag. Fields left out are unchanged.
+func (h *SchedulerHandler) UpdateTask(c *gin.Context) {
+	var task models.ScheduledTask
+	if err := h.db.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled task not found"})
+		return
+	}
+	var req struct {
+		CronExpr   *string `json:"cron_expr"`
+		Timezone   *string `json:"timezone"`
+		Recipients *string `json:"recipients"`
+		Enabled    *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	changes := map[string]interface{}{}
+	if req.CronExpr != nil && strings.TrimSpace(*req.CronExpr) != task.CronExpr {
+		changes["cron_expr"] = map[string]interface{}{"old": task.CronExpr, "new": strings.TrimSpace(*req.CronExpr)}
+		task.CronExpr = strings.TrimSpace(*req.CronExpr)
+	}
+	if req.Timezone != nil && strings.TrimSpace(*req.Timezone) != task.Timezone {
+		changes["timezone"] = map[string]interface{}{"old": task.Timezone, "new": strings.TrimSpace(*req.Timezone)}
+		task.Timezone = strings.TrimSpace(*req.Timezone)
+	}
+	if req.Recipients != nil {
+		list := parseEmailList(*req.Recipients)
+		for _, addr := range list {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient: " + addr})
+				return
+			}
+		}
+		if joined := strings.Join(list, ", "); joined != task.Recipients {
+			changes["recipients"] = map[string]interface{}{"old": task.Recipients, "new": joined}
+			task.Recipients = joined
+		}
+	}
+	if req.Enabled != nil && *req.Enabled != task.Enabled {
+		changes["enabled"] = map[string]interface{}{"old": task.Enabled, "new": *req.Enabled}
+		task.Enabled = *req.Enabled
+	}
+	next, err := nextTaskRun(&task, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+		return
+	}
+	if len(changes) > 0 {
+		if !task.Enabled {
+			next = nil
+		}
+		if err := h.db.Model(&task).Updates(map[string]interface{}{
+			"cron_expr": task.CronExpr, "timezone": task.Timezone, "recipients": task.Recipients,
+			"enabled": task.Enabled, "next_run_at": next,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(c, "scheduled_task_update", &task, changes)
+	}
+	h.db.First(&task, task.ID)
+	c.JSON(http.StatusOK, task)
+}
+
+// PauseTask stops a task from running on schedule; it can still be run by hand.
+func (h *SchedulerHandler) PauseTask(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+// ResumeTask re-enables a task from its next scheduled time; runs missed while paused are not made up.
+func (h *SchedulerHandler) ResumeTask(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+func (h *SchedulerHandler) setEnabled(c *gin.Context, enabled bool) {
+	var task models.ScheduledTask
+	if err := h.db.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled task not found"})
+		return
+	}
+	var next *time.Time
+	if enabled {
+		var err error
+		if next, err = nextTaskRun(&task, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+			return
+		}
+	}
+	if task.Enabled != enabled {
+		if err := h.db.Model(&task).Updates(map[string]interface{}{"enabled": enabled, "next_run_at": next}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		action := "scheduled_task_pause"
+		if enabled {
+			action = "scheduled_task_resume"
+		}
+		h.audit(c, action, &task, map[string]interface{}{})
+	}
+	h.db.First(&task, task.ID)
+	c.JSON(http.StatusOK, task)
+}
+
+// RunTask queues a run now, whether or not the task is enabled, and returns the job id to poll.
+func (h *SchedulerHandler) RunTask(c *gin.Context) {
+	var task models.ScheduledTask
+	if err := h.db.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled task not found"})
+		return
+	}
+	job, err := startScheduledTask(h.db, &task, "manual", contextUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start task: " + err.Error()})
+		return
+	}
+	h.audit(c, "scheduled_task_run", &task, map[string]interface{}{"job_id": job.ID})
+	h.db.First(&task, task.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "task": task})
+}
+
+func (h *SchedulerHandler) sendReport(recipients []string, subject, body string) error {
+	if len(recipients) == 0 {
+		return jobs.Permanent(errors.New("no recipients: set them on the task or set the company email"))
+	}
+	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
+		return jobs.Permanent(errors.New("email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"))
+	}
+	return apimail.SendPlain(h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, h.cfg.EffectiveSMTPFrom(),
+		recipients, subject, body)
+}
+
+func (h *SchedulerHandler) runLowStockAlertJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p reportEmailJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	var low []models.Stock
+	if err := h.db.WithContext(ctx).Where("low_stock_threshold > 0 AND quantity <= low_stock_threshold").
+		Preload("Product").Preload("Store").Find(&low).Error; err != nil {
+		return nil, err
+	}
+	if len(low) == 0 {
+		return gin.H{"items": 0, "emailed": false}, nil
+	}
+	sort.Slice(low, func(i, j int) bool {
+		if low[i].Store.Name != low[j].Store.Name {
+			return low[i].Store.Name < low[j].Store.Name
+		}
+		return low[i].Product.Name < low[j].Product.Name
+	})
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d stock line(s) are at or below their low-stock threshold:\n\n", len(low))
+	store := ""
+	for _, s := range low {
+		if s.Store.Name != store {
+			store = s.Store.Name
+			fmt.Fprintf(&b, "%s\n", store)
+		}
+		fmt.Fprintf(&b, "  %s (%s): %g left, threshold %g\n", s.Product.Name, s.Product.SKU, s.Quantity, s.LowStockThreshold)
+	}
+	if err := h.sendReport(p.Recipients, fmt.Sprintf("Low stock: %d item(s)", len(low)), b.String()); err != nil {
+		return nil, err
+	}
+	return gin.H{"items": len(low), "emailed": true, "recipients": p.Recipients}, nil
+}
+
+func (h *SchedulerHandler) runDayEndSnapshotJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p reportEmailJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse("2006-01-02", p.Date); err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("bad date %q", p.Date))
+	}
+	type key struct{ storeID, productID uint }
+	var existing []models.StocktakeInventorySnapshot
+	if err := h.db.WithContext(ctx).Select("store_id", "product_id").
+		Where("snapshot_date = ? AND snapshot_type IN ?", p.Date, []string{"stocktake_day_end", autoDayEndSnapshotType}).
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[key]bool, len(existing))
+	for _, s := range existing {
+		done[key{s.StoreID, s.ProductID}] = true
+	}
+	var stock []models.Stock
+	if err := h.db.WithContext(ctx).Select("store_id", "product_id", "quantity").Find(&stock).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var rows []models.StocktakeInventorySnapshot
+	for _, s := range stock {
+		if done[key{s.StoreID, s.ProductID}] {
+			continue
+		}
+		rows = append(rows, models.StocktakeInventorySnapshot{
+			StoreID: s.StoreID, ProductID: s.ProductID, Quantity: s.Quantity,
+			SnapshotDate: p.Date, SnapshotType: autoDayEndSnapshotType, CreatedAt: now,
+		})
+	}
+	if len(rows) > 0 {
+		if err := h.db.WithContext(ctx).CreateInBatches(rows, 500).Error; err != nil {
+			return nil, err
+		}
+	}
+	return gin.H{"date": p.Date, "recorded": len(rows), "already_recorded": len(done)}, nil
+}
+
+func (h *SchedulerHandler) runSalesReportJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p reportEmailJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	day, err := time.ParseInLocation("2006-01-02", p.Date, loc)
+	if err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("bad date %q", p.Date))
+	}
+	type storeTotal struct {
+		StoreID uint
+		Name    string
+		Orders  int64
+		Total   float64
+	}
+	var totals []storeTotal
+	if err := h.db.WithContext(ctx).Table("orders").
+		Select("orders.store_id, stores.name, COUNT(*) AS orders, COALESCE(SUM(orders.total_amount), 0) AS total").
+		Joins("JOIN stores ON stores.id = orders.store_id").
+		Where("orders.created_at >= ? AND orders.created_at < ? AND orders.status IN ?", day, day.AddDate(0, 0, 1),
+			[]string{"paid", "completed", "picked_up"}).
+		Group("orders.store_id, stores.name").Order("stores.name").Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "POS sales for %s (%s):\n\n", p.Date, loc)
+	var orders int64
+	var total float64
+	for _, t := range totals {
+		fmt.Fprintf(&b, "  %s: %d order(s), £%s\n", t.Name, t.Orders, formatNumberWithCommas(t.Total, 2))
+		orders += t.Orders
+		total += t.Total
+	}
+	if len(totals) == 0 {
+		b.WriteString("  No paid orders.\n")
+	}
+	fmt.Fprintf(&b, "\nTotal: %d order(s), £%s\n", orders, formatNumberWithCommas(total, 2))
+	if err := h.sendReport(p.Recipients, "Daily sales report "+p.Date, b.String()); err != nil {
+		return nil, err
+	}
+	return gin.H{"date": p.Date, "orders": orders, "total": total, "recipients": p.Recipients}, nil
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestScheduledTaskDefsParse(t *testing.T) {
+	seen := map[string]bool{}
+	for _, def := range scheduledTaskDefs {
+		if seen[def.Key] {
+			t.Errorf("duplicate task key %q", def.Key)
+		}
+		seen[def.Key] = true
+		next, err := nextTaskRun(&models.ScheduledTask{CronExpr: def.DefaultCron, Timezone: "Europe/London"}, time.Now())
+		if err != nil || next == nil {
+			t.Errorf("%s: default schedule %q: next=%v err=%v", def.Key, def.DefaultCron, next, err)
+		}
+	}
+}
+
+func TestTaskLocalDate(t *testing.T) {
+	// 23:30 UTC on 30 June is already 1 July in London (BST).
+	now := time.Date(2026, 6, 30, 23, 30, 0, 0, time.UTC)
+	task := &models.ScheduledTask{Timezone: "Europe/London"}
+	if got := taskLocalDate(task, now, 0); got != "2026-07-01" {
+		t.Errorf("today = %s, want 2026-07-01", got)
+	}
+	if got := taskLocalDate(task, now, -1); got != "2026-06-30" {
+		t.Errorf("yesterday = %s, want 2026-06-30", got)
+	}
+	if got := taskLocalDate(&models.ScheduledTask{Timezone: "Nowhere/Else"}, now, 0); got != "2026-06-30" {
+		t.Errorf("unknown zone = %s, want UTC date 2026-06-30", got)
+	}
+}
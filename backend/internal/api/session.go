@@ -0,0 +1,343 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	refreshTokenPrefix = "prt_"
+	// refreshReuseGrace tolerates two tabs refreshing with the same token at once: the loser gets 409 and retries
+	// with the token the winner stored, instead of the session being revoked as a replay.
+	refreshReuseGrace = 30 * time.Second
+)
+
+// sessionTokens is what a login or refresh hands back to the client.
+type sessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	SessionID    uint
+}
+
+// newRefreshToken adds a fresh token to the session's rotation chain.
+func newRefreshToken(tx *gorm.DB, sessionID uint) (string, error) {
+	token, err := utils.GenerateToken(refreshTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Create(&models.SessionRefreshToken{SessionID: sessionID, TokenHash: utils.HashToken(token)}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// startSession records a login and returns its first access and refresh tokens.
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, device *models.POSDevice, method string) (sessionTokens, error) {
+	now := time.Now()
+	session := models.UserSession{
+		UserID:      user.ID,
+		LoginMethod: method,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   truncateString(c.GetHeader("User-Agent"), 255),
+		LastSeenAt:  now,
+		ExpiresAt:   now.AddDate(0, 0, h.cfg.RefreshTokenDays),
+	}
+	if device != nil {
+		session.DeviceID = &device.ID
+	}
+	var out sessionTokens
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		refresh, err := newRefreshToken(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		out.RefreshToken = refresh
+		return nil
+	})
+	if err != nil {
+		return out, err
+	}
+	out.SessionID = session.ID
+	out.AccessToken, out.ExpiresAt, err = h.generateJWT(user, device, session.ID)
+	return out, err
+}
+
+// loginResponse builds the login/refresh payload with sensitive user fields cleared.
+func loginResponse(user models.User, tokens sessionTokens) LoginResponse {
+	user.PasswordHash = ""
+	user.PINHash = ""
+	expiresAt := tokens.ExpiresAt
+	return LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    &expiresAt,
+		SessionID:    tokens.SessionID,
+		User:         user,
+	}
+}
+
+// revokeUserSessions ends every active session of a user, except keepID when non-zero, and returns how many ended.
+func revokeUserSessions(db *gorm.DB, userID uint, reason string, keepID uint) int64 {
+	q := db.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepID != 0 {
+		q = q.Where("id <> ?", keepID)
+	}
+	res := q.Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return res.RowsAffected
+}
+
+// revokeDeviceSessions ends the staff sessions started on a till, when it is revoked or re-enrolled.
+func revokeDeviceSessions(db *gorm.DB, deviceID uint) {
+	db.Model(&models.UserSession{}).Where("device_id = ? AND revoked_at IS NULL", deviceID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedDevice})
+}
+
+func auditSession(db *gorm.DB, c *gin.Context, action string, userID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: "user",
+		EntityID:   &userID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+func contextSessionID(c *gin.Context) uint {
+	v, _ := c.Get("session_id")
+	id, _ := v.(uint)
+	return id
+}
+
+// sessionMiddleware rejects access tokens whose session has been revoked or has lapsed, or whose user has been
+// deactivated, and takes the role from the user record so role changes apply at once. last_seen_at is written at
+// most once a minute. With allowLegacy, tokens issued before sessions existed (no sid) are accepted until they
+// expire, as long as the user is active. Must run after authMiddleware.
+func sessionMiddleware(db *gorm.DB, allowLegacy bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if db == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database connection not available. Please try again later."})
+			c.Abort()
+			return
+		}
+		if contextAPIKeyID(c) != nil {
+			c.Next()
+			return
+		}
+		sid := contextSessionID(c)
+		if sid == 0 && allowLegacy {
+			var user models.User
+			uid := contextUserID(c)
+			if uid == nil || db.Select("id", "role", "is_active").First(&user, *uid).Error != nil || !user.IsActive {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+				c.Abort()
+				return
+			}
+			c.Set("role", user.Role)
+			c.Next()
+			return
+		}
+		if sid == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+			c.Abort()
+			return
+		}
+		var row struct {
+			UserID     uint
+			RevokedAt  *time.Time
+			ExpiresAt  time.Time
+			LastSeenAt time.Time
+			Role       string
+			IsActive   bool
+		}
+		err := db.Table("user_sessions").
+			Select("user_sessions.user_id, user_sessions.revoked_at, user_sessions.expires_at, user_sessions.last_seen_at, users.role, users.is_active").
+			Joins("JOIN users ON users.id = user_sessions.user_id").
+			Where("user_sessions.id = ?", sid).
+			Take(&row).Error
+		uid := contextUserID(c)
+		if err != nil || uid == nil || row.UserID != *uid || row.RevokedAt != nil || !row.IsActive || time.Now().After(row.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+			c.Abort()
+			return
+		}
+		c.Set("role", row.Role)
+		if time.Since(row.LastSeenAt) > time.Minute {
+			db.Model(&models.UserSession{}).Where("id = ?", sid).
+				Updates(map[string]interface{}{"last_seen_at": time.Now(), "ip_address": c.ClientIP()})
+		}
+		c.Next()
+	}
+}
+
+// Refresh failures. errRefreshReplayed and errRefreshRaced are a rotated token presented again, outside and inside
+// refreshReuseGrace respectively.
+var (
+	errRefreshInvalid  = errors.New("invalid refresh token")
+	errRefreshReplayed = errors.New("refresh token reused")
+	errRefreshRaced    = errors.New("refresh token already rotated")
+)
+
+// checkRefreshToken decides whether a presented refresh token may be rotated: its session must be live and its
+// user active, and it must not have been rotated already (errRefreshRaced inside refreshReuseGrace,
+// errRefreshReplayed after it).
+func checkRefreshToken(rt *models.SessionRefreshToken, session *models.UserSession, userActive bool, now time.Time) error {
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) || !userActive {
+		return errRefreshInvalid
+	}
+	if rt.RotatedAt != nil {
+		if now.Sub(*rt.RotatedAt) < refreshReuseGrace {
+			return errRefreshRaced
+		}
+		return errRefreshReplayed
+	}
+	return nil
+}
+
+// rotateRefreshToken swaps a refresh token for a new one in the same session. A replayed token revokes the session.
+func (h *AuthHandler) rotateRefreshToken(c *gin.Context, presented string) (*models.UserSession, string, error) {
+	var session models.UserSession
+	var next string
+	replayed := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var rt models.SessionRefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(presented)).First(&rt).Error; err != nil {
+			return errRefreshInvalid
+		}
+		if err := tx.First(&session, rt.SessionID).Error; err != nil {
+			return errRefreshInvalid
+		}
+		var user models.User
+		if err := tx.Select("id", "is_active").First(&user, session.UserID).Error; err != nil {
+			return errRefreshInvalid
+		}
+		if err := checkRefreshToken(&rt, &session, user.IsActive, time.Now()); err != nil {
+			replayed = errors.Is(err, errRefreshReplayed)
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&rt).Update("rotated_at", now).Error; err != nil {
+			return err
+		}
+		token, err := newRefreshToken(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		next = token
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   c.ClientIP(),
+			"expires_at":   now.AddDate(0, 0, h.cfg.RefreshTokenDays),
+		}).Error
+	})
+	if replayed {
+		// Outside the transaction so the revocation is not rolled back with it.
+		h.db.Model(&models.UserSession{}).Where("id = ?", session.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedReuse})
+		auditSession(h.db, c, "session_refresh_reuse", session.UserID, map[string]interface{}{
+			"session_id": session.ID, "ip_address": c.ClientIP(),
+		})
+	}
+	return &session, next, err
+}
+
+type sessionResponse struct {
+	models.UserSession
+	Current bool `json:"current"`
+}
+
+func (h *AuthHandler) listSessions(c *gin.Context, userID uint) {
+	var sessions []models.UserSession
+	if err := h.db.Preload("Device").
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current := contextSessionID(c)
+	out := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionResponse{UserSession: s, Current: s.ID == current})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// Logout ends the caller's current session.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	h.db.Model(&models.UserSession{}).Where("id = ? AND revoked_at IS NULL", contextSessionID(c)).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedLogout})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll ends every session of the caller; keep_current leaves the one making the request signed in.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	var req struct {
+		KeepCurrent bool `json:"keep_current"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	uid := contextUserID(c)
+	var keep uint
+	if req.KeepCurrent {
+		keep = contextSessionID(c)
+	}
+	n := revokeUserSessions(h.db, *uid, models.SessionRevokedLogoutAll, keep)
+	auditSession(h.db, c, "session_logout_all", *uid, map[string]interface{}{"sessions": n, "keep_current": req.KeepCurrent})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions ended", "sessions": n})
+}
+
+// ListMySessions lists the caller's active sessions with device, IP and last seen.
+func (h *AuthHandler) ListMySessions(c *gin.Context) {
+	h.listSessions(c, *contextUserID(c))
+}
+
+// RevokeMySession ends one of the caller's own sessions.
+func (h *AuthHandler) RevokeMySession(c *gin.Context) {
+	res := h.db.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), *contextUserID(c)).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": models.SessionRevokedLogout})
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
+
+// ListUserSessions lists another user's active sessions.
+func (h *AuthHandler) ListUserSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	h.listSessions(c, uint(userID))
+}
+
+// RevokeUserSessions logs a user out everywhere, e.g. when a device is lost or they leave.
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	n := revokeUserSessions(h.db, user.ID, models.SessionRevokedByAdmin, 0)
+	auditSession(h.db, c, "session_revoke_all", user.ID, map[string]interface{}{"sessions": n})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions ended", "sessions": n})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoginResponse(t *testing.T) {
+	exp := time.Now().Add(15 * time.Minute)
+	user := models.User{ID: 7, Username: "ana", PasswordHash: "x", PINHash: "y"}
+	resp := loginResponse(user, sessionTokens{AccessToken: "a", RefreshToken: "prt_r", ExpiresAt: exp, SessionID: 3})
+	if resp.User.PasswordHash != "" || resp.User.PINHash != "" {
+		t.Error("loginResponse must clear password and PIN hashes")
+	}
+	if resp.Token != "a" || resp.RefreshToken != "prt_r" || resp.SessionID != 3 || resp.ExpiresAt == nil || !resp.ExpiresAt.Equal(exp) {
+		t.Errorf("loginResponse = %+v", resp)
+	}
+	if user.PasswordHash != "x" {
+		t.Error("loginResponse must not modify the caller's user")
+	}
+}
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Now()
+	live := models.UserSession{ID: 1, UserID: 7, ExpiresAt: now.Add(24 * time.Hour)}
+	fresh := models.SessionRefreshToken{SessionID: 1}
+	justRotated := now.Add(-5 * time.Second)
+	longRotated := now.Add(-refreshReuseGrace - time.Minute)
+	revokedAt := now.Add(-time.Minute)
+
+	cases := []struct {
+		name    string
+		rt      models.SessionRefreshToken
+		session models.UserSession
+		active  bool
+		want    error
+	}{
+		{"normal rotation", fresh, live, true, nil},
+		{"race inside the grace window", models.SessionRefreshToken{SessionID: 1, RotatedAt: &justRotated}, live, true, errRefreshRaced},
+		{"replay outside the grace window", models.SessionRefreshToken{SessionID: 1, RotatedAt: &longRotated}, live, true, errRefreshReplayed},
+		{"deactivated user", fresh, live, false, errRefreshInvalid},
+		{"revoked session", fresh, models.UserSession{ID: 1, ExpiresAt: live.ExpiresAt, RevokedAt: &revokedAt}, true, errRefreshInvalid},
+		{"lapsed session", fresh, models.UserSession{ID: 1, ExpiresAt: now.Add(-time.Second)}, true, errRefreshInvalid},
+	}
+	for _, tc := range cases {
+		if got := checkRefreshToken(&tc.rt, &tc.session, tc.active, now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLegacyTokenConvertible(t *testing.T) {
+	now := time.Now()
+	rollout := now.Add(-48 * time.Hour)
+	token := func(iat, exp time.Time, extra map[string]interface{}) jwt.MapClaims {
+		c := jwt.MapClaims{"user_id": float64(7), "role": "pos_user", "iat": float64(iat.Unix()), "exp": float64(exp.Unix())}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	preSession := now.Add(-72 * time.Hour)
+	cases := []struct {
+		name   string
+		claims jwt.MapClaims
+		since  *time.Time
+		want   bool
+	}{
+		{"pre-session token, still valid", token(preSession, now.Add(time.Hour), nil), &rollout, true},
+		{"pre-session token, just expired", token(preSession, now.Add(-30*time.Minute), nil), &rollout, true},
+		{"no sessions yet", token(now.Add(-time.Hour), now.Add(time.Hour), nil), nil, true},
+		{"expired past the leeway", token(preSession, now.Add(-legacyTokenLeeway-time.Minute), nil), &rollout, false},
+		{"issued after sessions went live", token(now.Add(-time.Hour), now.Add(time.Hour), nil), &rollout, false},
+		{"challenge token", token(preSession, now.Add(time.Hour), map[string]interface{}{"purpose": "2fa_verify", "role": nil}), &rollout, false},
+		{"purpose claim with a role", token(preSession, now.Add(time.Hour), map[string]interface{}{"purpose": "x"}), &rollout, false},
+		{"no issued-at", jwt.MapClaims{"user_id": float64(7), "role": "pos_user", "exp": float64(now.Add(time.Hour).Unix())}, &rollout, false},
+	}
+	for _, tc := range cases {
+		if got := legacyTokenConvertible(tc.claims, tc.since, now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRefreshRefusesTwoFactorChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// No database: a token that got past the claim checks would answer 503, not 401.
+	h := &AuthHandler{cfg: &config.Config{JWTSecret: "secret", JWTExpiration: 24, SessionLegacyRefresh: true}}
+	r := gin.New()
+	r.POST("/auth/refresh", h.Refresh)
+	refresh := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":""}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	challenge, err := h.issueChallenge(7, challengeTwoFactorVerify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := refresh(challenge); code != http.StatusUnauthorized {
+		t.Errorf("refresh with a 2FA challenge = %d, want 401", code)
+	}
+	// A challenge signed the way they used to be, with the access-token secret.
+	old, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7, "purpose": challengeTwoFactorVerify, "exp": time.Now().Add(time.Minute).Unix(), "iat": time.Now().Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := refresh(old); code != http.StatusUnauthorized {
+		t.Errorf("refresh with a challenge signed by JWT_SECRET = %d, want 401", code)
+	}
+	if _, ok := h.challengeUser(old, challengeTwoFactorVerify); ok {
+		t.Error("challengeUser accepted a challenge not signed with the challenge key")
+	}
+}
@@ -96,6 +96,7 @@ func (h *SettingsHandler) UpdateCompanySettings(c *gin.Context) {
 		Postcode          *string `json:"postcode"`
 		Telephone         *string `json:"telephone"`
 		Email             *string `json:"email"`
+		VATNumber         *string `json:"vat_number"`
 		BankAccountName   *string `json:"bank_account_name"`
 		BankAccountNumber *string `json:"bank_account_number"`
 		BankSortCode      *string `json:"bank_sort_code"`
@@ -169,6 +170,9 @@ func (h *SettingsHandler) UpdateCompanySettings(c *gin.Context) {
 	if body.Email != nil {
 		s.Email = *body.Email
 	}
+	if body.VATNumber != nil {
+		s.VATNumber = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(*body.VATNumber), " ", ""))
+	}
 	if body.BankAccountName != nil {
 		s.BankAccountName = *body.BankAccountName
 	}
@@ -227,6 +231,7 @@ func (h *SettingsHandler) companySettingsResponse(s models.CompanySettings) gin.
 		"postcode":                                s.Postcode,
 		"telephone":                               s.Telephone,
 		"email":                                   s.Email,
+		"vat_number":                              s.VATNumber,
 		"bank_account_name":                       s.BankAccountName,
 		"bank_account_number":                     s.BankAccountNumber,
 		"bank_sort_code":                          s.BankSortCode,
@@ -0,0 +1,510 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// A password login by a user with TOTP (or whose role requires it) returns a challenge token instead of a session;
+// the second step exchanges it, with a code, for the session.
+const (
+	challengeTwoFactorVerify = "2fa_verify"
+	challengeTwoFactorEnrol  = "2fa_enrol"
+	twoFactorChallengeTTL    = 5 * time.Minute
+	challengeAudience        = "login-challenge"
+	recoveryCodeCount        = 10
+)
+
+var (
+	errTwoFactorEnrolled    = errors.New("two-factor authentication is already enabled")
+	errTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	errTwoFactorCode        = errors.New("invalid authentication code")
+)
+
+// twoFactorLoginResponse is a completed login; RecoveryCodes is set when the login also finished enrolment.
+type twoFactorLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// confirmedTwoFactor returns the user's confirmed authenticator, or nil.
+func confirmedTwoFactor(db *gorm.DB, userID uint) *models.UserTwoFactor {
+	var tf models.UserTwoFactor
+	if err := db.Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&tf).Error; err != nil {
+		return nil
+	}
+	return &tf
+}
+
+func roleRequiresTwoFactor(db *gorm.DB, role string) bool {
+	var count int64
+	db.Model(&models.Role{}).Where("name = ? AND require_two_factor = ?", role, true).Count(&count)
+	return count > 0
+}
+
+// challengeSigningKey derives the key login challenges are signed with from JWT_SECRET, so no parser of access
+// tokens can ever accept a challenge.
+func challengeSigningKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(challengeAudience))
+	return mac.Sum(nil)
+}
+
+func (h *AuthHandler) issueChallenge(userID uint, purpose string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"purpose": purpose,
+		"aud":     challengeAudience,
+		"exp":     time.Now().Add(twoFactorChallengeTTL).Unix(),
+		"iat":     time.Now().Unix(),
+	})
+	return token.SignedString(challengeSigningKey(h.cfg.JWTSecret))
+}
+
+// challengeUser resolves a challenge token of the given purpose to its active user. Challenge tokens are signed
+// with their own key and carry no role or session, so authMiddleware and Refresh never accept them.
+func (h *AuthHandler) challengeUser(tokenString, purpose string) (*models.User, bool) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return challengeSigningKey(h.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(challengeAudience))
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if p, _ := jwtClaimString(claims, "purpose"); p != purpose {
+		return nil, false
+	}
+	uid, ok := jwtClaimUint(claims, "user_id")
+	if !ok {
+		return nil, false
+	}
+	var user models.User
+	if err := h.db.Where("id = ? AND is_active = ?", uid, true).First(&user).Error; err != nil {
+		return nil, false
+	}
+	return &user, true
+}
+
+// passwordLoginChallenge answers a correct password with a challenge when the user has TOTP, or must enrol it.
+// It reports whether it responded.
+func (h *AuthHandler) passwordLoginChallenge(c *gin.Context, user *models.User) bool {
+	purpose := ""
+	switch {
+	case confirmedTwoFactor(h.db, user.ID) != nil:
+		purpose = challengeTwoFactorVerify
+	case roleRequiresTwoFactor(h.db, user.Role):
+		purpose = challengeTwoFactorEnrol
+	default:
+		return false
+	}
+	token, err := h.issueChallenge(user.ID, purpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required":           purpose == challengeTwoFactorVerify,
+		"two_factor_enrolment_required": purpose == challengeTwoFactorEnrol,
+		"challenge_token":               token,
+		"expires_in":                    int(twoFactorChallengeTTL.Seconds()),
+	})
+	return true
+}
+
+// useTOTP accepts a code for the user's confirmed authenticator, once per time step.
+func (h *AuthHandler) useTOTP(tf *models.UserTwoFactor, code string) bool {
+	secret, err := utils.OpenSecret(h.cfg.TwoFactorKey, tf.SecretSealed)
+	if err != nil {
+		return false
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok || step <= tf.LastUsedStep {
+		return false
+	}
+	res := h.db.Model(&models.UserTwoFactor{}).Where("id = ? AND last_used_step < ?", tf.ID, step).
+		Updates(map[string]interface{}{"last_used_step": step, "last_used_at": time.Now()})
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// useRecoveryCode spends one of the user's unused recovery codes.
+func (h *AuthHandler) useRecoveryCode(userID uint, code string) bool {
+	code = normaliseEnrolmentCode(code)
+	if code == "" {
+		return false
+	}
+	res := h.db.Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(code)).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns the new ones; only their hashes are kept.
+func newRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newEnrolmentCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.UserRecoveryCode{UserID: userID, CodeHash: utils.HashToken(normaliseEnrolmentCode(code))}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (h *AuthHandler) totpIssuer() string {
+	var s models.CompanySettings
+	if err := h.db.Select("company_name").First(&s, companySettingsID).Error; err == nil && strings.TrimSpace(s.CompanyName) != "" {
+		return strings.TrimSpace(s.CompanyName)
+	}
+	return defaultCompanySettings.CompanyName
+}
+
+// beginEnrolment stores a new unconfirmed secret for the user, replacing any earlier unconfirmed one.
+func (h *AuthHandler) beginEnrolment(user *models.User) (gin.H, error) {
+	if confirmedTwoFactor(h.db, user.ID) != nil {
+		return nil, errTwoFactorEnrolled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := utils.SealSecret(h.cfg.TwoFactorKey, secret)
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserTwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserTwoFactor{UserID: user.ID, SecretSealed: sealed}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"secret": secret, "provisioning_uri": utils.TOTPProvisioningURI(h.totpIssuer(), user.Username, secret)}, nil
+}
+
+// confirmEnrolment turns on TOTP once the user proves their app produces codes, and issues recovery codes.
+func (h *AuthHandler) confirmEnrolment(c *gin.Context, user *models.User, code string) ([]string, error) {
+	var tf models.UserTwoFactor
+	if err := h.db.Where("user_id = ? AND confirmed_at IS NULL", user.ID).First(&tf).Error; err != nil {
+		if confirmedTwoFactor(h.db, user.ID) != nil {
+			return nil, errTwoFactorEnrolled
+		}
+		return nil, errTwoFactorNotEnrolled
+	}
+	secret, err := utils.OpenSecret(h.cfg.TwoFactorKey, tf.SecretSealed)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, errTwoFactorCode
+	}
+	var codes []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&tf).Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step, "last_used_at": now}).Error; err != nil {
+			return err
+		}
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	auditSession(h.db, c, "two_factor_enable", user.ID, map[string]interface{}{})
+	return codes, nil
+}
+
+// twoFactorError maps enrolment errors to responses.
+func twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTwoFactorEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.Is(err, errTwoFactorNotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+	case errors.Is(err, errTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// completeLogin starts the session for a password login that passed its second step.
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, method, deviceCode string, recoveryCodes []string) {
+	tokens, err := h.startSession(c, user, nil, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	resp := twoFactorLoginResponse{LoginResponse: loginResponse(*user, tokens), RecoveryCodes: recoveryCodes}
+	if deviceCode != "" {
+		resp.LastStocktakeAt = h.lastStocktakeAtForDevice(deviceCode)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type twoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+	DeviceCode     string `json:"device_code"`
+}
+
+// VerifyTwoFactor is the second step of a password login: a TOTP code, or a recovery code, for the challenge.
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req twoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.challengeUser(req.ChallengeToken, challengeTwoFactorVerify)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
+	userKey, sourceKey := loginUserKey(user.Username), loginSourceKey(c, nil)
+	attempt := h.loginBlocked(c, userKey, sourceKey)
+	if attempt == nil {
+		return
+	}
+	defer attempt.release()
+	method := "password+totp"
+	if req.RecoveryCode != "" {
+		method = "password+recovery"
+		ok = h.useRecoveryCode(user.ID, req.RecoveryCode)
+	} else if tf := confirmedTwoFactor(h.db, user.ID); tf != nil {
+		ok = h.useTOTP(tf, req.Code)
+	} else {
+		ok = false
+	}
+	if !ok {
+		h.loginFailed(c, user, "totp", nil, attempt)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+	attempt.succeeded()
+	if req.RecoveryCode != "" {
+		var left int64
+		h.db.Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&left)
+		auditSession(h.db, c, "two_factor_recovery_code_used", user.ID, map[string]interface{}{"remaining": left})
+	}
+	h.completeLogin(c, user, method, req.DeviceCode, nil)
+}
+
+// EnrolTwoFactorAtLogin starts TOTP setup for a user whose role requires it, using their login challenge.
+func (h *AuthHandler) EnrolTwoFactorAtLogin(c *gin.Context) {
+	var req twoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.challengeUser(req.ChallengeToken, challengeTwoFactorEnrol)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
+	out, err := h.beginEnrolment(user)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ConfirmTwoFactorAtLogin finishes enforced setup with a first code and completes the login, returning the
+// recovery codes alongside the session.
+func (h *AuthHandler) ConfirmTwoFactorAtLogin(c *gin.Context) {
+	var req twoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := h.challengeUser(req.ChallengeToken, challengeTwoFactorEnrol)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please sign in again"})
+		return
+	}
+	userKey, sourceKey := loginUserKey(user.Username), loginSourceKey(c, nil)
+	attempt := h.loginBlocked(c, userKey, sourceKey)
+	if attempt == nil {
+		return
+	}
+	defer attempt.release()
+	codes, err := h.confirmEnrolment(c, user, req.Code)
+	if errors.Is(err, errTwoFactorCode) {
+		h.loginFailed(c, user, "totp", nil, attempt)
+	}
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	attempt.succeeded()
+	h.completeLogin(c, user, "password+totp", req.DeviceCode, codes)
+}
+
+// GetTwoFactorStatus reports whether the caller has TOTP on, whether their role requires it and how many recovery
+// codes remain.
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, *contextUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	resp := gin.H{"enabled": false, "required": roleRequiresTwoFactor(h.db, user.Role)}
+	if tf := confirmedTwoFactor(h.db, user.ID); tf != nil {
+		var left int64
+		h.db.Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&left)
+		resp["enabled"] = true
+		resp["confirmed_at"] = tf.ConfirmedAt
+		resp["last_used_at"] = tf.LastUsedAt
+		resp["recovery_codes_remaining"] = left
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetupTwoFactor starts optional TOTP setup for the caller; the response holds the secret and otpauth:// URI
+// for the QR code.
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, *contextUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	out, err := h.beginEnrolment(&user)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ConfirmTwoFactor turns TOTP on for the caller with a first code and returns their recovery codes.
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var user models.User
+	if err := h.db.First(&user, *contextUserID(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	codes, err := h.confirmEnrolment(c, &user, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes; a current TOTP code is required.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := *contextUserID(c)
+	tf := confirmedTwoFactor(h.db, uid)
+	if tf == nil {
+		twoFactorError(c, errTwoFactorNotEnrolled)
+		return
+	}
+	if !h.useTOTP(tf, req.Code) {
+		twoFactorError(c, errTwoFactorCode)
+		return
+	}
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = newRecoveryCodes(tx, uid)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auditSession(h.db, c, "two_factor_recovery_codes_regenerate", uid, map[string]interface{}{})
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns TOTP off for the caller with a current code, unless their role requires it.
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if roleRequiresTwoFactor(h.db, currentRole(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+		return
+	}
+	uid := *contextUserID(c)
+	tf := confirmedTwoFactor(h.db, uid)
+	if tf == nil {
+		twoFactorError(c, errTwoFactorNotEnrolled)
+		return
+	}
+	if !h.useTOTP(tf, req.Code) {
+		twoFactorError(c, errTwoFactorCode)
+		return
+	}
+	if err := removeTwoFactor(h.db, uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auditSession(h.db, c, "two_factor_disable", uid, map[string]interface{}{})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ResetUserTwoFactor removes another user's authenticator and recovery codes, e.g. after a lost phone, and ends
+// their sessions. If their role requires TOTP they set it up again at next login.
+func (h *AuthHandler) ResetUserTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := removeTwoFactor(h.db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	n := revokeUserSessions(h.db, user.ID, models.SessionRevokedByAdmin, 0)
+	auditSession(h.db, c, "two_factor_reset", user.ID, map[string]interface{}{"sessions": n})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+func removeTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserTwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error
+	})
+}
@@ -127,10 +127,13 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if !isValidUserRole(req.Role) {
+	if !roleExists(h.db, req.Role) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
 		return
 	}
+	if rejectRoleEscalation(c, h.db, req.Role) {
+		return
+	}
 
 	// Hash password
 	passwordHash, err := utils.HashPassword(req.Password)
@@ -142,6 +145,10 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	// Hash PIN if provided
 	var pinHash string
 	if req.PIN != "" {
+		if err := utils.ValidatePIN(req.PIN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		pinHash, err = utils.HashPIN(req.PIN)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN"})
@@ -216,10 +223,13 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		updateData["email"] = *req.Email
 	}
 	if req.Role != nil {
-		if !isValidUserRole(*req.Role) {
+		if !roleExists(h.db, *req.Role) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
 			return
 		}
+		if *req.Role != user.Role && rejectRoleEscalation(c, h.db, *req.Role) {
+			return
+		}
 		updateData["role"] = *req.Role
 	}
 	if req.IsActive != nil {
@@ -232,6 +242,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			return
 		}
 	}
+	if req.IsActive != nil && !*req.IsActive {
+		revokeUserSessions(h.db, user.ID, models.SessionRevokedUserDeactivated, 0)
+	}
 
 	if err := h.db.First(&user, user.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -288,10 +301,6 @@ func (h *UserHandler) UpdateUserStores(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func canManageUserWorkAssignments(role string) bool {
-	return role == RoleManagement || role == RoleSupervisor
-}
-
 func uintSliceContains(ids []uint, id uint) bool {
 	for _, v := range ids {
 		if v == id {
@@ -311,9 +320,8 @@ func (h *UserHandler) UpdateUserWorkSettings(c *gin.Context) {
 
 	currentUserIDInterface, _ := c.Get("user_id")
 	currentUserID := currentUserIDInterface.(uint)
-	role := currentRole(c)
 	isSelf := uint(userID) == currentUserID
-	canManageAssignments := canManageUserWorkAssignments(role)
+	canManageAssignments := hasPermission(c, models.PermUserAssign)
 
 	if !isSelf && !canManageAssignments {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
@@ -354,7 +362,7 @@ func (h *UserHandler) UpdateUserWorkSettings(c *gin.Context) {
 		}
 		_ = h.db.Preload("Stores").Preload("WholesaleClients").First(&user, userID)
 	} else if req.StoreIDs != nil || req.WholesaleClientIDs != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only management can change store and client assignments"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Changing store and client assignments requires " + models.PermUserAssign})
 		return
 	}
 
@@ -416,13 +424,10 @@ func (h *UserHandler) UpdatePIN(c *gin.Context) {
 	currentUserIDInterface, _ := c.Get("user_id")
 	currentUserID := currentUserIDInterface.(uint)
 
-	// Users can only update their own PIN, or management can update any
-	if uint(userID) != currentUserID {
-		role, _ := c.Get("role")
-		if role != "management" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
-			return
-		}
+	// Users can only update their own PIN; user.credentials.edit can update any
+	if uint(userID) != currentUserID && !hasPermission(c, models.PermUserCredentialsEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
+		return
 	}
 
 	var req struct {
@@ -433,8 +438,12 @@ func (h *UserHandler) UpdatePIN(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := utils.ValidatePIN(req.PIN); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// If updating own PIN, verify current PIN first (unless user is management updating another user)
+	// If updating own PIN, verify current PIN first (not when another user's PIN is being reset)
 	if uint(userID) == currentUserID && req.CurrentPIN != "" {
 		var user models.User
 		if err := h.db.First(&user, userID).Error; err != nil {
@@ -468,13 +477,10 @@ func (h *UserHandler) UpdateIcon(c *gin.Context) {
 	currentUserIDInterface, _ := c.Get("user_id")
 	currentUserID := currentUserIDInterface.(uint)
 
-	// Users can only update their own icon, or management can update any
-	if uint(userID) != currentUserID {
-		role, _ := c.Get("role")
-		if role != "management" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
-			return
-		}
+	// Users can only update their own icon; user.credentials.edit can update any
+	if uint(userID) != currentUserID && !hasPermission(c, models.PermUserCredentialsEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized"})
+		return
 	}
 
 	// Check if it's multipart form data (file upload) or JSON (icon URL or color generation)
@@ -0,0 +1,586 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/jobs"
+	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/utils"
+	"pos-system/backend/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// webhookSendTimeout bounds one delivery attempt; a slow receiver counts as a failure and is retried.
+const webhookSendTimeout = 10 * time.Second
+
+// webhookStuckAfter is how long a delivery may stay "sending" before the dispatcher assumes the process died
+// mid-attempt and queues it again.
+const webhookStuckAfter = 5 * time.Minute
+
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+// webhookEnvelope is the JSON body of every delivery.
+type webhookEnvelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookSubscribed reports whether a subscription's event list includes event; "*" matches everything.
+func webhookSubscribed(events, event string) bool {
+	for _, e := range splitList(events) {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// emitWebhookEvent queues event for every active subscription that wants it. Callers emit after their change has
+// been committed, so an event is never sent for a change that rolled back; a crash between the two can lose the
+// event. Failures are logged and never fail the caller.
+func emitWebhookEvent(db *gorm.DB, event string, data interface{}) {
+	if db == nil {
+		return
+	}
+	var subs []models.WebhookSubscription
+	if err := db.Where("is_active = ?", true).Find(&subs).Error; err != nil {
+		log.Printf("[webhook] load subscriptions for %s: %v", event, err)
+		return
+	}
+	var matched []models.WebhookSubscription
+	for _, s := range subs {
+		if webhookSubscribed(s.Events, event) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	eventID, err := utils.GenerateToken("evt_")
+	if err != nil {
+		log.Printf("[webhook] event id for %s: %v", event, err)
+		return
+	}
+	payload, err := json.Marshal(webhookEnvelope{ID: eventID, Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("[webhook] encode %s: %v", event, err)
+		return
+	}
+	now := time.Now()
+	for _, s := range matched {
+		d := models.WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        eventID,
+			EventType:      event,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+		}
+		if err := db.Create(&d).Error; err != nil {
+			log.Printf("[webhook] queue %s for subscription %d: %v", event, s.ID, err)
+		}
+	}
+}
+
+// stockCrossedLow reports whether a quantity change took stock from above threshold to at or below it. A zero
+// threshold means low-stock alerts are off for that row.
+func stockCrossedLow(oldQuantity, newQuantity, threshold float64) bool {
+	return threshold > 0 && oldQuantity > threshold && newQuantity <= threshold
+}
+
+// emitStockLowIfCrossed queues stock.low once per drop below the threshold, not on every later sale.
+func emitStockLowIfCrossed(db *gorm.DB, stock models.Stock, oldQuantity float64) {
+	if !stockCrossedLow(oldQuantity, stock.Quantity, stock.LowStockThreshold) {
+		return
+	}
+	emitWebhookEvent(db, webhook.EventStockLow, gin.H{
+		"product_id":          stock.ProductID,
+		"store_id":            stock.StoreID,
+		"quantity":            stock.Quantity,
+		"previous_quantity":   oldQuantity,
+		"low_stock_threshold": stock.LowStockThreshold,
+	})
+}
+
+// emitShipmentShipped queues shipment.shipped for a shipment that has just moved to shipped.
+func emitShipmentShipped(db *gorm.DB, s models.Shipment) {
+	emitWebhookEvent(db, webhook.EventShipmentShipped, gin.H{
+		"shipment_id":        s.ID,
+		"wholesale_order_id": s.WholesaleOrderID,
+		"store_id":           s.StoreID,
+		"courier":            s.Courier,
+		"tracking_number":    s.TrackingNumber,
+		"delivery_date":      s.DeliveryDate,
+	})
+}
+
+// StartWebhookDispatcher sends due deliveries from the outbox every cfg.WebhookWorkerSeconds on whichever instance
+// holds the dispatch lease. Each row is also claimed with a conditional update, so a lease handover cannot send
+// a delivery twice.
+func StartWebhookDispatcher(db *gorm.DB, cfg *config.Config) {
+	interval := time.Duration(cfg.WebhookWorkerSeconds) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	client := webhook.NewClient(webhookSendTimeout)
+	holder := jobs.InstanceName()
+	go func() {
+		for {
+			time.Sleep(interval)
+			if !holdLoopLease(db, webhookDispatchLeaseName, holder, interval) {
+				continue
+			}
+			if n, err := dispatchWebhooks(db, client); err != nil {
+				log.Printf("[webhook] dispatch failed: %v", err)
+			} else if n > 0 {
+				log.Printf("[webhook] made %d delivery attempt(s)", n)
+			}
+		}
+	}()
+}
+
+func dispatchWebhooks(db *gorm.DB, client *http.Client) (int, error) {
+	db.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND last_attempt_at < ?", models.WebhookDeliverySending, time.Now().Add(-webhookStuckAfter)).
+		Update("status", models.WebhookDeliveryPending)
+
+	var due []models.WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+		Order("next_attempt_at").Limit(100).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range due {
+		now := time.Now()
+		claim := db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ?", d.ID, models.WebhookDeliveryPending).
+			Updates(map[string]interface{}{"status": models.WebhookDeliverySending, "last_attempt_at": now})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+		attemptWebhookDelivery(db, client, d, true)
+		n++
+	}
+	return n, nil
+}
+
+// attemptWebhookDelivery makes one attempt at a claimed delivery and records the outcome. keepBody records the
+// start of the receiver's response in the delivery log.
+func attemptWebhookDelivery(db *gorm.DB, client *http.Client, d models.WebhookDelivery, keepBody bool) models.WebhookDelivery {
+	now := time.Now()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	updates := map[string]interface{}{"attempts": d.Attempts, "last_attempt_at": now}
+
+	var sub models.WebhookSubscription
+	if err := db.First(&sub, d.SubscriptionID).Error; err != nil || !sub.IsActive {
+		d.Status, d.LastError = models.WebhookDeliveryFailed, "subscription is disabled or deleted"
+		updates["status"], updates["last_error"] = d.Status, d.LastError
+		db.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates)
+		return d
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookSendTimeout)
+	res, err := webhook.Send(ctx, client, sub.URL, sub.Secret, d.EventType, strconv.FormatUint(uint64(d.ID), 10), []byte(d.Payload))
+	cancel()
+	d.ResponseStatus = res.StatusCode
+	updates["response_status"] = res.StatusCode
+	if keepBody {
+		d.ResponseBody = res.Body
+		updates["response_body"] = res.Body
+	}
+	switch {
+	case err == nil && res.OK():
+		d.Status, d.DeliveredAt, d.LastError = models.WebhookDeliveryDelivered, &now, ""
+		updates["delivered_at"] = now
+	case d.Attempts >= webhook.MaxAttempts:
+		d.Status = models.WebhookDeliveryFailed
+	default:
+		d.Status, d.NextAttemptAt = models.WebhookDeliveryPending, now.Add(webhook.RetryDelay(d.Attempts))
+		updates["next_attempt_at"] = d.NextAttemptAt
+	}
+	if d.Status != models.WebhookDeliveryDelivered {
+		if err != nil {
+			d.LastError = err.Error()
+		} else {
+			d.LastError = "receiver returned HTTP " + strconv.Itoa(res.StatusCode)
+		}
+	}
+	updates["status"], updates["last_error"] = d.Status, d.LastError
+	db.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates)
+	return d
+}
+
+type webhookSubscriptionResponse struct {
+	models.WebhookSubscription
+	EventList []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"` // only in the create and rotate-secret responses
+}
+
+func toWebhookSubscriptionResponse(s models.WebhookSubscription) webhookSubscriptionResponse {
+	events := splitList(s.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return webhookSubscriptionResponse{WebhookSubscription: s, EventList: events}
+}
+
+func (h *WebhookHandler) audit(c *gin.Context, action string, subID uint, changes map[string]interface{}) {
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     contextUserID(c),
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: "webhook",
+		EntityID:   &subID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+// checkWebhookURL requires an absolute http(s) URL whose host is not an internal address or localhost. Hostnames
+// are checked again after resolution when each delivery is sent (see webhook.NewClient).
+func checkWebhookURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(raw) > 500 || u.User != nil {
+		return "", false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", false
+	}
+	if ip := net.ParseIP(host); ip != nil && webhook.IsBlockedIP(ip) {
+		return "", false
+	}
+	return raw, true
+}
+
+// checkWebhookEvents validates requested event types and returns them de-duplicated, or the first unknown one.
+func checkWebhookEvents(requested []string) ([]string, string) {
+	var out []string
+	for _, e := range requested {
+		e = strings.TrimSpace(e)
+		if e == "" || stringSliceContains(out, e) {
+			continue
+		}
+		if e != "*" && !webhook.IsKnownEvent(e) {
+			return nil, e
+		}
+		out = append(out, e)
+	}
+	return out, ""
+}
+
+// ListWebhookEvents returns the event types a subscription can ask for.
+func (h *WebhookHandler) ListWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, webhook.Events)
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	var subs []models.WebhookSubscription
+	if err := h.db.Order("name").Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]webhookSubscriptionResponse, 0, len(subs))
+	for _, s := range subs {
+		out = append(out, toWebhookSubscriptionResponse(s))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+type SaveWebhookRequest struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// CreateSubscription adds a subscription with a fresh signing secret. The secret is returned once; use
+// rotate-secret to issue a new one.
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req SaveWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	target, ok := checkWebhookURL(req.URL)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL on a public host"})
+		return
+	}
+	events, unknown := checkWebhookEvents(req.Events)
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event: " + unknown})
+		return
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one event is required"})
+		return
+	}
+	secret, err := utils.GenerateToken("whsec_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	sub := models.WebhookSubscription{
+		Name:        name,
+		URL:         target,
+		Secret:      secret,
+		Events:      strings.Join(events, ","),
+		IsActive:    req.IsActive == nil || *req.IsActive,
+		CreatedByID: contextUserID(c),
+	}
+	if err := h.db.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "webhook_create", sub.ID, map[string]interface{}{
+		"name": sub.Name, "url": sub.URL, "events": events, "is_active": sub.IsActive,
+	})
+	resp := toWebhookSubscriptionResponse(sub)
+	resp.Secret = secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateSubscription changes a subscription's name, URL, events or active flag. Fields left out are unchanged.
+func (h *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	var req struct {
+		Name     *string  `json:"name"`
+		URL      *string  `json:"url"`
+		Events   []string `json:"events"`
+		IsActive *bool    `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates := map[string]interface{}{}
+	changes := map[string]interface{}{}
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		updates["name"] = strings.TrimSpace(*req.Name)
+		changes["name"] = map[string]interface{}{"old": sub.Name, "new": updates["name"]}
+	}
+	if req.URL != nil {
+		target, ok := checkWebhookURL(*req.URL)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL on a public host"})
+			return
+		}
+		updates["url"] = target
+		changes["url"] = map[string]interface{}{"old": sub.URL, "new": target}
+	}
+	if req.Events != nil {
+		events, unknown := checkWebhookEvents(req.Events)
+		if unknown != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event: " + unknown})
+			return
+		}
+		if len(events) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one event is required"})
+			return
+		}
+		updates["events"] = strings.Join(events, ",")
+		changes["events"] = map[string]interface{}{"old": splitList(sub.Events), "new": events}
+	}
+	if req.IsActive != nil && *req.IsActive != sub.IsActive {
+		updates["is_active"] = *req.IsActive
+		changes["is_active"] = map[string]interface{}{"old": sub.IsActive, "new": *req.IsActive}
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&sub).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.audit(c, "webhook_update", sub.ID, changes)
+	}
+	h.db.First(&sub, sub.ID)
+	c.JSON(http.StatusOK, toWebhookSubscriptionResponse(sub))
+}
+
+// DeleteSubscription removes a subscription. Its delivery log is kept; queued deliveries are marked failed.
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.WebhookDelivery{}).
+			Where("subscription_id = ? AND status = ?", sub.ID, models.WebhookDeliveryPending).
+			Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "last_error": "subscription deleted"}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&sub).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "webhook_delete", sub.ID, map[string]interface{}{"name": sub.Name, "url": sub.URL})
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// RotateSecret issues a new signing secret; requests signed with the old one stop immediately.
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	secret, err := utils.GenerateToken("whsec_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := h.db.Model(&sub).Update("secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "webhook_rotate_secret", sub.ID, map[string]interface{}{"name": sub.Name})
+	resp := toWebhookSubscriptionResponse(sub)
+	resp.Secret = secret
+	c.JSON(http.StatusOK, resp)
+}
+
+// TestSubscription sends a ping to the subscription right away and returns the logged delivery.
+func (h *WebhookHandler) TestSubscription(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	eventID, err := utils.GenerateToken("evt_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate event id"})
+		return
+	}
+	payload, _ := json.Marshal(webhookEnvelope{
+		ID: eventID, Event: webhook.EventPing, CreatedAt: time.Now().UTC(),
+		Data: gin.H{"subscription_id": sub.ID, "name": sub.Name},
+	})
+	now := time.Now()
+	d := models.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        eventID,
+		EventType:      webhook.EventPing,
+		Payload:        string(payload),
+		Status:         models.WebhookDeliverySending,
+		NextAttemptAt:  now,
+		LastAttemptAt:  &now,
+	}
+	if err := h.db.Create(&d).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// A failed ping is not retried; it only reports whether the receiver is reachable. The response body is not
+	// kept or returned, so the ping cannot be used to read whatever answers at the URL.
+	d = attemptWebhookDelivery(h.db, webhook.NewClient(webhookSendTimeout), d, false)
+	if d.Status == models.WebhookDeliveryPending {
+		d.Status = models.WebhookDeliveryFailed
+		h.db.Model(&d).Update("status", d.Status)
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// ListDeliveries returns the delivery log, newest first, optionally filtered by subscription_id, status and
+// event.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	q := h.db.Model(&models.WebhookDelivery{})
+	if v := c.Query("subscription_id"); v != "" {
+		q = q.Where("subscription_id = ?", v)
+	}
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v := c.Query("event"); v != "" {
+		q = q.Where("event_type = ?", v)
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	var total int64
+	q.Count(&total)
+	var deliveries []models.WebhookDelivery
+	if err := q.Omit("payload", "response_body").Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "total": total})
+}
+
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	var d models.WebhookDelivery
+	if err := h.db.Preload("Subscription").First(&d, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// Redeliver queues a copy of a delivery with the same payload and event id, to be sent on the next dispatcher
+// pass. The original row is left as it was.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	var orig models.WebhookDelivery
+	if err := h.db.First(&orig, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	var sub models.WebhookSubscription
+	if err := h.db.First(&sub, orig.SubscriptionID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook has been deleted"})
+		return
+	}
+	if !sub.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook is disabled"})
+		return
+	}
+	d := models.WebhookDelivery{
+		SubscriptionID: orig.SubscriptionID,
+		EventID:        orig.EventID,
+		EventType:      orig.EventType,
+		Payload:        orig.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  time.Now(),
+		RedeliveryOfID: &orig.ID,
+	}
+	if err := h.db.Create(&d).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "webhook_redeliver", sub.ID, map[string]interface{}{"delivery_id": orig.ID, "new_delivery_id": d.ID, "event": orig.EventType})
+	c.JSON(http.StatusCreated, d)
+}
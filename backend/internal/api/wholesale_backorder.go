@@ -0,0 +1,325 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recalcWholesaleOrderTotals recomputes subtotal/net/amount due from the order's lines, clamping the order discount.
+func recalcWholesaleOrderTotals(tx *gorm.DB, orderID uint) error {
+	var wo models.WholesaleOrder
+	if err := tx.First(&wo, orderID).Error; err != nil {
+		return err
+	}
+	var items []models.WholesaleOrderItem
+	if err := tx.Where("wholesale_order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	var subtotal float64
+	for _, it := range items {
+		subtotal += it.LineTotal
+	}
+	discount := wo.DiscountAmount
+	if discount > subtotal {
+		discount = subtotal
+	}
+	totalNet := subtotal - discount
+	return tx.Model(&wo).Updates(map[string]interface{}{
+		"subtotal": subtotal, "discount_amount": discount, "total_net": totalNet, "amount_due": totalNet + wo.VATTotal,
+	}).Error
+}
+
+// SplitBackorder moves the quantities that current stock cannot cover into a linked backorder, so the
+// original order can be endorsed with what is in stock. The backorder is allocated on restock receipt.
+func (h *WholesaleOrderHandler) SplitBackorder(c *gin.Context) {
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("Items").First(&wo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, wo.Status) {
+		return
+	}
+	if wo.Status != models.WholesaleOrderStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is not pending approval"})
+		return
+	}
+	preview, err := h.endorseAllocationPreviewForOrder(&wo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if preview.Outcome != endorseOutcomeInsufficientStock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock covers every line; no backorder needed"})
+		return
+	}
+	var totalAllocated float64
+	for _, line := range preview.Lines {
+		totalAllocated += line.Allocated
+	}
+	if totalAllocated <= 0.0001 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No line has stock available; keep the order pending instead"})
+		return
+	}
+	itemsByID := make(map[uint]models.WholesaleOrderItem, len(wo.Items))
+	for _, it := range wo.Items {
+		itemsByID[it.ID] = it
+	}
+
+	var existing int64
+	h.db.Model(&models.WholesaleOrder{}).Where("backorder_of_id = ?", wo.ID).Count(&existing)
+	suffix := fmt.Sprintf("-BO%d", existing+1)
+	now := time.Now()
+	parentID := wo.ID
+	bo := models.WholesaleOrder{
+		OrderNumber:            wo.OrderNumber + suffix,
+		RefNo:                  wo.RefNo + suffix,
+		WholesaleClientID:      wo.WholesaleClientID,
+		WholesaleClientStoreID: wo.WholesaleClientStoreID,
+		StoreID:                wo.StoreID,
+		UserID:                 wo.UserID,
+		SectorID:               wo.SectorID,
+		PONumber:               wo.PONumber,
+		OrderChannel:           wo.OrderChannel,
+		PODate:                 wo.PODate,
+		OrderDate:              wo.OrderDate,
+		PaymentTerms:           wo.PaymentTerms,
+		Status:                 models.WholesaleOrderStatusBackorder,
+		Notes:                  fmt.Sprintf("Backorder of %s", wholesaleOrderRefLabel(&wo)),
+		WholesaleQuotationID:   wo.WholesaleQuotationID,
+		BackorderOfID:          &parentID,
+		CreatedAt:              now,
+	}
+	backordered := []map[string]interface{}{}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bo).Error; err != nil {
+			return err
+		}
+		for _, line := range preview.Lines {
+			if line.Shortfall <= 0.0001 {
+				continue
+			}
+			item := itemsByID[line.WholesaleOrderItemID]
+			// Split the line discount in proportion to quantity.
+			boDiscount := 0.0
+			if item.Quantity > 0 {
+				boDiscount = math.Round(item.LineDiscountAmount*line.Shortfall/item.Quantity*100) / 100
+			}
+			boTotal := math.Max(0, item.UnitPrice*line.Shortfall-boDiscount)
+			if err := tx.Create(&models.WholesaleOrderItem{
+				WholesaleOrderID:   bo.ID,
+				ProductID:          item.ProductID,
+				Quantity:           line.Shortfall,
+				UnitPrice:          item.UnitPrice,
+				LineDiscountType:   item.LineDiscountType,
+				LineDiscountUnit:   item.LineDiscountUnit,
+				LineDiscountAmount: boDiscount,
+				LineTotal:          boTotal,
+			}).Error; err != nil {
+				return err
+			}
+			keepQty := item.Quantity - line.Shortfall
+			if keepQty <= 0.0001 {
+				if err := tx.Delete(&models.WholesaleOrderItem{}, item.ID).Error; err != nil {
+					return err
+				}
+			} else {
+				keepDiscount := item.LineDiscountAmount - boDiscount
+				if err := tx.Model(&models.WholesaleOrderItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+					"quantity": keepQty, "line_discount_amount": keepDiscount,
+					"line_total": math.Max(0, item.UnitPrice*keepQty-keepDiscount),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			backordered = append(backordered, map[string]interface{}{
+				"product_id": item.ProductID, "ordered": item.Quantity, "backordered": line.Shortfall,
+			})
+		}
+		if err := recalcWholesaleOrderTotals(tx, wo.ID); err != nil {
+			return err
+		}
+		return recalcWholesaleOrderTotals(tx, bo.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "wholesale_order_backorder_split", wo.ID, map[string]interface{}{
+		"backorder_id": bo.ID, "backorder_ref_no": bo.RefNo, "lines": backordered,
+	})
+	h.audit(c, "wholesale_order_create", bo.ID, map[string]interface{}{
+		"order_number": bo.OrderNumber, "client_id": bo.WholesaleClientID, "item_count": len(backordered),
+		"source": "backorder", "backorder_of_id": wo.ID,
+	})
+
+	var updated models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("Store").Preload("User").Preload("Sector").
+		Preload("Backorders.Items.Product").
+		First(&updated, wo.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// allocateBackordersForStore assigns storeID's open backorders (oldest first) to it when its stock covers every
+// remaining line, creating the follow-up shipment; the invoice follows once that shipment is completed. A backorder
+// is only allocated once its original order has been endorsed, and it goes through the credit check again since
+// it was not part of the exposure when that order was approved; a credit hold leaves it on backorder.
+// Returns the number of backorders allocated.
+func (h *WholesaleOrderHandler) allocateBackordersForStore(c *gin.Context, storeID uint) (int, error) {
+	var backorders []models.WholesaleOrder
+	if err := h.db.Preload("Items").
+		Joins("INNER JOIN wholesale_orders parent ON parent.id = wholesale_orders.backorder_of_id AND parent.status IN ?",
+			[]string{models.WholesaleOrderStatusAssignShipment, models.WholesaleOrderStatusApproved}).
+		Where("wholesale_orders.status = ? AND wholesale_orders.store_id = ?", models.WholesaleOrderStatusBackorder, storeID).
+		Order("wholesale_orders.created_at ASC, wholesale_orders.id ASC").Find(&backorders).Error; err != nil {
+		return 0, err
+	}
+	if len(backorders) == 0 {
+		return 0, nil
+	}
+	var stockRows []models.Stock
+	if err := h.db.Where("store_id = ?", storeID).Find(&stockRows).Error; err != nil {
+		return 0, err
+	}
+	available := make(map[uint]float64, len(stockRows))
+	for _, row := range stockRows {
+		available[row.ProductID] = row.Quantity
+	}
+
+	allocated := 0
+	for i := range backorders {
+		bo := &backorders[i]
+		req := AssignStoresRequest{}
+		need := make(map[uint]float64)
+		for _, item := range bo.Items {
+			assigned, err := h.assignedQtyForOrderItem(item.ID)
+			if err != nil {
+				return allocated, err
+			}
+			pending := item.Quantity - assigned
+			if pending <= 0.0001 {
+				continue
+			}
+			need[item.ProductID] += pending
+			sid := storeID
+			qty := pending
+			req.Assignments = append(req.Assignments, struct {
+				WholesaleOrderItemID uint     `json:"wholesale_order_item_id" binding:"required"`
+				StoreID              *uint    `json:"store_id"`
+				Quantity             *float64 `json:"quantity"`
+				CaseQty              *float64 `json:"case_qty"`
+			}{
+				WholesaleOrderItemID: item.ID,
+				StoreID:              &sid,
+				Quantity:             &qty,
+			})
+		}
+		if len(req.Assignments) == 0 {
+			continue
+		}
+		coverable := true
+		for productID, qty := range need {
+			if available[productID] < qty-0.0001 {
+				coverable = false
+				break
+			}
+		}
+		if !coverable {
+			continue
+		}
+		var client models.WholesaleClient
+		if err := h.db.First(&client, bo.WholesaleClientID).Error; err != nil {
+			return allocated, err
+		}
+		credit, err := checkWholesaleOrderCredit(h.db, bo, &client)
+		if err != nil {
+			return allocated, err
+		}
+		if credit.blocking() {
+			h.audit(c, "wholesale_order_backorder_credit_hold", bo.ID, map[string]interface{}{
+				"store_id": storeID, "reason": credit.message(), "credit": credit,
+			})
+			continue
+		}
+		oldStatus := bo.Status
+		bo.Status = models.WholesaleOrderStatusAssignShipment
+		if err := h.db.Model(bo).Update("status", bo.Status).Error; err != nil {
+			return allocated, err
+		}
+		if _, err := h.applyAssignStoresBatch(c, bo, req); err != nil {
+			h.db.Model(bo).Update("status", oldStatus)
+			return allocated, err
+		}
+		for productID, qty := range need {
+			available[productID] -= qty
+		}
+		allocateAudit := map[string]interface{}{
+			"store_id": storeID, "old_status": oldStatus, "trigger": "restock_received",
+		}
+		if credit.OverLimit {
+			allocateAudit["credit_warning"] = credit.message()
+		}
+		h.audit(c, "wholesale_order_backorder_allocate", bo.ID, allocateAudit)
+		allocated++
+	}
+	return allocated, nil
+}
+
+// cascadeBackorderStatus moves the backorders split from parentID that are in one of the from statuses to status,
+// so that rejecting, resubmitting or deleting an order carries its unallocated backorders with it. Backorders
+// already allocated to a shipment are left alone.
+func (h *WholesaleOrderHandler) cascadeBackorderStatus(c *gin.Context, parentID uint, from []string, status string, reason string) error {
+	var children []models.WholesaleOrder
+	if err := h.db.Where("backorder_of_id = ? AND status IN ?", parentID, from).Find(&children).Error; err != nil {
+		return err
+	}
+	for _, bo := range children {
+		updates := map[string]interface{}{"status": status}
+		if status == models.WholesaleOrderStatusRejected {
+			updates["rejection_reason"] = reason
+		} else if bo.Status == models.WholesaleOrderStatusRejected {
+			updates["rejection_reason"] = ""
+		}
+		if err := h.db.Model(&models.WholesaleOrder{}).Where("id = ? AND status = ?", bo.ID, bo.Status).Updates(updates).Error; err != nil {
+			return err
+		}
+		h.audit(c, "wholesale_order_backorder_cascade", bo.ID, map[string]interface{}{
+			"old_status": bo.Status, "new_status": status, "backorder_of_id": parentID, "reason": reason,
+		})
+	}
+	return nil
+}
+
+// wholesaleBackorderEmailSection lists backordered lines for the client email; empty when there are none.
+func (h *WholesaleOrderHandler) wholesaleBackorderEmailSection(orderID uint) string {
+	var backorders []models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").
+		Where("backorder_of_id = ? AND status NOT IN ?", orderID, []string{models.WholesaleOrderStatusRejected, models.WholesaleOrderStatusDeleted}).
+		Order("id ASC").Find(&backorders).Error; err != nil || len(backorders) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nThe following items are on backorder and will be delivered and invoiced separately once in stock:\n")
+	for _, bo := range backorders {
+		for _, it := range bo.Items {
+			name := strings.TrimSpace(it.Product.Name)
+			if name == "" {
+				name = fmt.Sprintf("Product %d", it.ProductID)
+			}
+			fmt.Fprintf(&b, "- %s x %s (%s)\n", name, strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", it.Quantity), "0"), "."), wholesaleOrderRefLabel(&bo))
+		}
+	}
+	return b.String()
+}
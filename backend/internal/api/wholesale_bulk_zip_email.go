@@ -19,11 +19,13 @@ import (
 	"strings"
 	"time"
 
+	"pos-system/backend/internal/jobs"
 	apimail "pos-system/backend/internal/mail"
 	"pos-system/backend/internal/models"
 
 	"cloud.google.com/go/storage"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const bulkAttachmentEmailMaxOrders = 200
@@ -144,7 +146,7 @@ func (h *WholesaleOrderHandler) buildBulkAttachmentZipBytes(orders []models.Whol
 		inv = "invoice"
 		pp  = "payment_proof"
 	)
-	standardTypes := []string{oc, poa, dn, inv, pp}
+	standardTypes := []string{oc, poa, dn, inv, ublInvoiceDocType, creditNoteDocType, ublCreditNoteDocType, pp}
 
 	for _, order := range orders {
 		ref := order.RefNo
@@ -246,6 +248,7 @@ var validEmailAttachmentKinds = map[string]bool{
 	"po_attachment":        true,
 	"payment_proof":        true,
 	"invoice":              true,
+	"ubl_invoice":          true,
 	"order_confirmation":   true,
 	"delivery_note":        true,
 	"signed_delivery_note": true,
@@ -435,11 +438,9 @@ func (h *WholesaleOrderHandler) collectOrderEmailAttachments(order *models.Whole
 	return out, nil
 }
 
-// EmailOrder sends an email with selected attachments for a wholesale order.
+// EmailOrder queues an email with selected attachments for a wholesale order and returns the job id to poll.
+// Attachments are collected and the email sent by runOrderEmailJob.
 func (h *WholesaleOrderHandler) EmailOrder(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"})
 		return
@@ -517,16 +518,6 @@ func (h *WholesaleOrderHandler) EmailOrder(c *gin.Context) {
 		}
 	}
 
-	mailAttachments, err := h.collectOrderEmailAttachments(&wo, req.Attachments, req.SignedDeliveryShipmentID, req.ShipmentIDs)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if len(mailAttachments) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No files found for the selected attachments"})
-		return
-	}
-
 	var company models.CompanySettings
 	_ = h.db.First(&company, companySettingsID).Error
 
@@ -572,22 +563,116 @@ func (h *WholesaleOrderHandler) EmailOrder(c *gin.Context) {
 		default:
 			body = wholesaleOrderEmailDefaultBody(&wo, "the attached documents")
 		}
+		body += h.wholesaleBackorderEmailSection(wo.ID)
+	}
+
+	job := orderEmailJob{
+		OrderID:                  wo.ID,
+		To:                       toList,
+		CC:                       ccList,
+		BCC:                      bccList,
+		Subject:                  subject,
+		Body:                     body,
+		Attachments:              req.Attachments,
+		SignedDeliveryShipmentID: req.SignedDeliveryShipmentID,
+		ShipmentIDs:              req.ShipmentIDs,
+		Message:                  strings.TrimSpace(req.Message),
+		EmailType:                strings.TrimSpace(req.EmailType),
+		InitiatedBy:              initiatorName,
+		Actor:                    actorFromContext(c),
+	}
+	queued, err := enqueueJob(c, h.db, jobTypeWholesaleOrderEmail, job)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue email: " + err.Error()})
+		return
+	}
+
+	h.db.Preload("Items.Product").Preload("WholesaleClient.Stores").Preload("WholesaleClientStore").Preload("Store").Preload("User").Preload("Sector").
+		Preload("Reviewer").Preload("Documents").Preload("Shipments.Store").
+		Preload("Shipments.Items.WholesaleOrderItem.Product").
+		First(&wo, wo.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":      "Email queued",
+		"job_id":       queued.ID,
+		"recipient":    strings.Join(toList, ", "),
+		"to":           toList,
+		"cc":           strings.Join(ccList, ", "),
+		"cc_list":      ccList,
+		"bcc":          strings.Join(bccList, ", "),
+		"bcc_list":     bccList,
+		"initiated_by": initiatorName,
+		"order":        wo,
+	})
+}
+
+// orderEmailJob is an EmailOrder request after recipients, subject and body have been resolved.
+type orderEmailJob struct {
+	OrderID                  uint       `json:"order_id"`
+	To                       []string   `json:"to"`
+	CC                       []string   `json:"cc,omitempty"`
+	BCC                      []string   `json:"bcc,omitempty"`
+	Subject                  string     `json:"subject"`
+	Body                     string     `json:"body"`
+	Attachments              []string   `json:"attachments"`
+	SignedDeliveryShipmentID *uint      `json:"signed_delivery_shipment_id,omitempty"`
+	ShipmentIDs              []uint     `json:"shipment_ids,omitempty"`
+	Message                  string     `json:"message,omitempty"`
+	EmailType                string     `json:"email_type,omitempty"`
+	InitiatedBy              string     `json:"initiated_by,omitempty"`
+	Actor                    auditActor `json:"actor"`
+}
+
+// runOrderEmailJob collects the attachments, sends the email and writes the "wholesale_order_email" audit row the
+// order email steps are tracked by.
+func (h *WholesaleOrderHandler) runOrderEmailJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p orderEmailJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
+		return nil, jobs.Permanent(fmt.Errorf("email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"))
+	}
+	var wo models.WholesaleOrder
+	if err := h.db.WithContext(ctx).Preload("WholesaleClient").Preload("Documents").Preload("Shipments").
+		First(&wo, p.OrderID).Error; err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("wholesale order %d not found", p.OrderID))
+	}
+	if wo.Status == models.WholesaleOrderStatusDeleted {
+		return nil, jobs.Permanent(fmt.Errorf("wholesale order %d is deleted", p.OrderID))
+	}
+	// An invoice or confirmation queued for (re)generation before this email is what the sender means to attach,
+	// not the PDF it is about to replace.
+	if pending, err := pendingOrderDocumentJob(h.db.WithContext(ctx), wo.ID, p.Attachments, job.ID); err != nil {
+		return nil, err
+	} else if pending != 0 {
+		return nil, jobs.Later(15*time.Second, fmt.Sprintf("waiting for document job %d", pending))
+	}
+
+	mailAttachments, err := h.collectOrderEmailAttachments(&wo, p.Attachments, p.SignedDeliveryShipmentID, p.ShipmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(mailAttachments) == 0 {
+		return nil, jobs.Permanent(fmt.Errorf("no files found for the selected attachments"))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	from := h.cfg.EffectiveSMTPFrom()
 	if err := apimail.SendWithAttachments(
-		h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from,
-		toList, ccList, bccList, subject, body, mailAttachments,
+		ctx, h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from,
+		p.To, p.CC, p.BCC, p.Subject, p.Body, mailAttachments,
 	); err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send email: " + err.Error()})
-		return
+		return nil, fmt.Errorf("send email: %w", err)
 	}
 
 	sentAt := time.Now().UTC()
 	hasInvoice := false
-	attachmentKinds := make([]string, 0, len(req.Attachments))
+	attachmentKinds := make([]string, 0, len(p.Attachments))
 	seenKind := make(map[string]struct{})
-	for _, k := range req.Attachments {
+	for _, k := range p.Attachments {
 		k = strings.TrimSpace(k)
 		if k == "invoice" {
 			hasInvoice = true
@@ -608,64 +693,67 @@ func (h *WholesaleOrderHandler) EmailOrder(c *gin.Context) {
 		filenames[i] = a.Filename
 	}
 	changes := map[string]interface{}{
-		"recipient":        strings.Join(toList, ", "),
-		"to":               toList,
-		"subject":          subject,
+		"recipient":        strings.Join(p.To, ", "),
+		"to":               p.To,
+		"subject":          p.Subject,
 		"sent_at":          sentAt.Format(time.RFC3339),
-		"initiated_by":     initiatorName,
+		"initiated_by":     p.InitiatedBy,
 		"attachment_kinds": attachmentKinds,
 		"attachment_count": len(mailAttachments),
 		"filenames":        filenames,
+		"job_id":           job.ID,
 	}
-	if req.SignedDeliveryShipmentID != nil && *req.SignedDeliveryShipmentID > 0 {
-		changes["signed_delivery_shipment_id"] = *req.SignedDeliveryShipmentID
+	if p.SignedDeliveryShipmentID != nil && *p.SignedDeliveryShipmentID > 0 {
+		changes["signed_delivery_shipment_id"] = *p.SignedDeliveryShipmentID
 	}
-	if len(req.ShipmentIDs) > 0 {
-		changes["shipment_ids"] = req.ShipmentIDs
+	if len(p.ShipmentIDs) > 0 {
+		changes["shipment_ids"] = p.ShipmentIDs
 	}
-	if len(ccList) > 0 {
-		changes["cc"] = strings.Join(ccList, ", ")
-		changes["cc_list"] = ccList
+	if len(p.CC) > 0 {
+		changes["cc"] = strings.Join(p.CC, ", ")
+		changes["cc_list"] = p.CC
 	}
-	if len(bccList) > 0 {
-		changes["bcc"] = strings.Join(bccList, ", ")
-		changes["bcc_list"] = bccList
+	if len(p.BCC) > 0 {
+		changes["bcc"] = strings.Join(p.BCC, ", ")
+		changes["bcc_list"] = p.BCC
 	}
-	if strings.TrimSpace(req.Message) != "" {
-		changes["message"] = strings.TrimSpace(req.Message)
+	if p.Message != "" {
+		changes["message"] = p.Message
 	}
-	if emailType := strings.TrimSpace(req.EmailType); emailType != "" {
-		changes["email_type"] = emailType
+	if p.EmailType != "" {
+		changes["email_type"] = p.EmailType
 	}
+	h.auditAs(p.Actor, "wholesale_order_email", wo.ID, changes)
 
-	h.audit(c, "wholesale_order_email", wo.ID, changes)
-
-	h.db.Preload("Items.Product").Preload("WholesaleClient.Stores").Preload("WholesaleClientStore").Preload("Store").Preload("User").Preload("Sector").
-		Preload("Reviewer").Preload("Documents").Preload("Shipments.Store").
-		Preload("Shipments.Items.WholesaleOrderItem.Product").
-		First(&wo, wo.ID)
+	return gin.H{"sent_at": sentAt, "to": p.To, "attachment_count": len(mailAttachments), "filenames": filenames}, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":          "Email sent",
-		"recipient":        strings.Join(toList, ", "),
-		"to":               toList,
-		"cc":               strings.Join(ccList, ", "),
-		"cc_list":          ccList,
-		"bcc":              strings.Join(bccList, ", "),
-		"bcc_list":         bccList,
-		"sent_at":          sentAt.Format(time.RFC3339),
-		"initiated_by":     initiatorName,
-		"attachment_count": len(mailAttachments),
-		"order":            wo,
-	})
+// pendingOrderDocumentJob returns the id of a document job queued before beforeJobID that is still to produce one
+// of the attachment kinds for orderID, or 0 when there is none.
+func pendingOrderDocumentJob(db *gorm.DB, orderID uint, attachments []string, beforeJobID uint) (uint, error) {
+	var docTypes []string
+	for _, k := range attachments {
+		if k = strings.TrimSpace(k); k == "invoice" || k == "order_confirmation" {
+			docTypes = append(docTypes, k)
+		}
+	}
+	if len(docTypes) == 0 {
+		return 0, nil
+	}
+	var ids []uint
+	err := db.Model(&models.Job{}).
+		Where("type = ? AND status IN ? AND id < ?", jobTypeWholesaleOrderDocument,
+			[]string{models.JobStatusQueued, models.JobStatusRunning}, beforeJobID).
+		Where("JSON_EXTRACT(payload, '$.order_id') = ? AND JSON_UNQUOTE(JSON_EXTRACT(payload, '$.doc_type')) IN ?", orderID, docTypes).
+		Order("id").Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
 }
 
 // SkipWholesaleOrderEmail records that a structured wholesale order email step was skipped.
 func (h *WholesaleOrderHandler) SkipWholesaleOrderEmail(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -855,9 +943,6 @@ func randomZipFilename() string {
 
 // SendTestEmail sends a simple test SMTP email for connectivity/auth verification.
 func (h *WholesaleOrderHandler) SendTestEmail(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"})
 		return
@@ -909,12 +994,9 @@ func (h *WholesaleOrderHandler) SendTestEmail(c *gin.Context) {
 	})
 }
 
-// BulkAttachmentsZipEmail builds a ZIP server-side and emails a download link.
-// Runs synchronously so platforms like Cloud Run keep CPU until the request finishes (background work after 202 often never runs).
+// BulkAttachmentsZipEmail queues a job that builds a ZIP server-side and emails a download link, and returns the
+// job id to poll.
 func (h *WholesaleOrderHandler) BulkAttachmentsZipEmail(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"})
 		return
@@ -958,63 +1040,80 @@ func (h *WholesaleOrderHandler) BulkAttachmentsZipEmail(c *gin.Context) {
 		return
 	}
 
-	orderIDs := req.OrderIDs
+	var count int64
+	if err := h.db.Model(&models.WholesaleOrder{}).Where("id IN ? AND status != ?", req.OrderIDs, models.WholesaleOrderStatusDeleted).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load orders: " + err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No orders found for the given IDs"})
+		return
+	}
+	queued, err := enqueueJob(c, h.db, jobTypeWholesaleBulkZipEmail, bulkZipEmailJob{OrderIDs: req.OrderIDs, Kind: kind, Recipient: recipientAddr})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue export: " + err.Error()})
+		return
+	}
+	log.Printf("[bulk-zip-email] queued job=%d orders=%d kind=%q recipient=%q", queued.ID, len(req.OrderIDs), kind, recipientAddr)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "The export is being prepared; an email with the download link will follow.",
+		"job_id":  queued.ID,
+	})
+}
+
+type bulkZipEmailJob struct {
+	OrderIDs  []uint `json:"order_ids"`
+	Kind      string `json:"kind"`
+	Recipient string `json:"recipient"`
+}
+
+// runBulkZipEmailJob builds and uploads the ZIP, then emails the link. A failed email is retried with a rebuilt ZIP.
+func (h *WholesaleOrderHandler) runBulkZipEmailJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p bulkZipEmailJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
+		return nil, jobs.Permanent(fmt.Errorf("email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"))
+	}
 	from := h.cfg.EffectiveSMTPFrom()
 
-	log.Printf("[bulk-zip-email] started orders=%d kind=%q recipient=%q smtp_host=%q smtp_port=%d smtp_user=%q smtp_from=%q", len(orderIDs), kind, recipientAddr, h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, from)
+	log.Printf("[bulk-zip-email] job=%d started orders=%d kind=%q recipient=%q smtp_host=%q smtp_port=%d smtp_user=%q smtp_from=%q", job.ID, len(p.OrderIDs), p.Kind, p.Recipient, h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, from)
 
 	var orders []models.WholesaleOrder
-	if err := h.db.Where("id IN ? AND status != ?", orderIDs, models.WholesaleOrderStatusDeleted).
+	if err := h.db.WithContext(ctx).Where("id IN ? AND status != ?", p.OrderIDs, models.WholesaleOrderStatusDeleted).
 		Preload("Documents").
 		Preload("Shipments").
 		Find(&orders).Error; err != nil {
-		log.Printf("[bulk-zip-email] db load error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load orders: " + err.Error()})
-		return
+		return nil, fmt.Errorf("load orders: %w", err)
 	}
 	if len(orders) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No orders found for the given IDs"})
-		return
+		return nil, jobs.Permanent(fmt.Errorf("no orders found for the given IDs"))
 	}
 
-	zipData, n, err := h.buildBulkAttachmentZipBytes(orders, kind)
+	zipData, n, err := h.buildBulkAttachmentZipBytes(orders, p.Kind)
 	if err != nil {
-		log.Printf("[bulk-zip-email] zip build error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build ZIP: " + err.Error()})
-		return
+		return nil, fmt.Errorf("build ZIP: %w", err)
 	}
 	if n == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No matching attachments were found for the selected orders."})
-		return
+		return nil, jobs.Permanent(fmt.Errorf("no matching attachments were found for the selected orders"))
 	}
-	log.Printf("[bulk-zip-email] zip ok files=%d bytes=%d", n, len(zipData))
+	log.Printf("[bulk-zip-email] job=%d zip ok files=%d bytes=%d", job.ID, n, len(zipData))
 
-	fileName := "bulk-zips/" + randomZipFilename()
-	publicURL, err := h.uploadWholesaleFile(fileName, zipData)
+	publicURL, err := h.uploadWholesaleFile("bulk-zips/"+randomZipFilename(), zipData)
 	if err != nil {
-		log.Printf("[bulk-zip-email] upload error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ZIP was built but upload failed: " + err.Error()})
-		return
+		return nil, fmt.Errorf("upload ZIP: %w", err)
 	}
-	log.Printf("[bulk-zip-email] uploaded %s", publicURL)
+	log.Printf("[bulk-zip-email] job=%d uploaded %s", job.ID, publicURL)
 
 	body := fmt.Sprintf(
 		"Your wholesale attachment export is ready (%d file(s), type: %s).\n\nDownload:\n%s\n\nLink points to stored file; download it whenever ready; your organisation may delete old bulk exports over time.\n",
-		n, kind, publicURL,
+		n, p.Kind, publicURL,
 	)
-	subjOK := "Wholesale attachments ready"
-	log.Printf("[bulk-zip-email] smtp sending to=%q from=%q", recipientAddr, from)
-	if err := apimail.SendPlain(h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from, []string{recipientAddr}, subjOK, body); err != nil {
-		log.Printf("[bulk-zip-email] FAILED success email to %q: %v", recipientAddr, err)
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error":        "The ZIP was uploaded but the email could not be sent: " + err.Error(),
-			"download_url": publicURL,
-		})
-		return
+	if err := apimail.SendPlain(h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from, []string{p.Recipient}, "Wholesale attachments ready", body); err != nil {
+		return nil, fmt.Errorf("send download link (ZIP at %s): %w", publicURL, err)
 	}
-	log.Printf("[bulk-zip-email] sent download link to %q (check spam if inbox empty)", recipientAddr)
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "An email with the download link was sent.",
-		"download_url": publicURL,
-	})
+	log.Printf("[bulk-zip-email] job=%d sent download link to %q (check spam if inbox empty)", job.ID, p.Recipient)
+	return gin.H{"download_url": publicURL, "files": n}, nil
 }
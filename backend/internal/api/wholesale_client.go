@@ -37,7 +37,7 @@ func (h *WholesaleClientHandler) List(c *gin.Context) {
 	if c.Query("active_only") == "1" {
 		query = query.Where("is_active = ?", true)
 	}
-	if role, _ := c.Get("role"); role == RolePosUser {
+	if !hasPermission(c, models.PermWholesaleViewAll) {
 		userIDInterface, _ := c.Get("user_id")
 		userID := userIDInterface.(uint)
 		clientIDs := posUserWholesaleClientIDs(h.db, userID)
@@ -54,6 +54,10 @@ func (h *WholesaleClientHandler) List(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := applyWholesaleClientExposure(h.db, list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load client credit: " + err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, list)
 }
 
@@ -63,7 +67,12 @@ func (h *WholesaleClientHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale client not found"})
 		return
 	}
-	c.JSON(http.StatusOK, client)
+	clients := []models.WholesaleClient{client}
+	if err := applyWholesaleClientExposure(h.db, clients); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load client credit: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, clients[0])
 }
 
 type CreateWholesaleClientRequest struct {
@@ -80,12 +89,49 @@ type CreateWholesaleClientRequest struct {
 	Terms         string `json:"terms"`
 	AccountCode   string `json:"account_code"`
 	SectorID      *uint  `json:"sector_id"`
+	CreditLimit   *float64 `json:"credit_limit"`  // 0 = no limit
+	CreditAction  string   `json:"credit_action"` // "block" (default) or "warn"
+	OnStop        *bool    `json:"on_stop"`
+	OnStopReason  *string  `json:"on_stop_reason"`
 }
 
-func (h *WholesaleClientHandler) Create(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
+// applyCreditSettings copies credit fields from the request; changing them requires wholesale.credit.override.
+func applyCreditSettings(c *gin.Context, client *models.WholesaleClient, req *CreateWholesaleClientRequest) bool {
+	if req.CreditLimit == nil && req.CreditAction == "" && req.OnStop == nil && req.OnStopReason == nil {
+		return true
+	}
+	if !hasPermission(c, models.PermWholesaleCreditOverride) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Changing credit settings requires " + models.PermWholesaleCreditOverride})
+		return false
+	}
+	if req.CreditLimit != nil {
+		if *req.CreditLimit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "credit_limit must not be negative"})
+			return false
+		}
+		client.CreditLimit = *req.CreditLimit
 	}
+	if req.CreditAction != "" {
+		action := strings.ToLower(strings.TrimSpace(req.CreditAction))
+		if action != models.WholesaleClientCreditActionBlock && action != models.WholesaleClientCreditActionWarn {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "credit_action must be block or warn"})
+			return false
+		}
+		client.CreditAction = action
+	}
+	if req.OnStop != nil {
+		client.OnStop = *req.OnStop
+		if !client.OnStop {
+			client.OnStopReason = ""
+		}
+	}
+	if req.OnStopReason != nil && client.OnStop {
+		client.OnStopReason = strings.TrimSpace(*req.OnStopReason)
+	}
+	return true
+}
+
+func (h *WholesaleClientHandler) Create(c *gin.Context) {
 	var req CreateWholesaleClientRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -109,6 +155,10 @@ func (h *WholesaleClientHandler) Create(c *gin.Context) {
 		AccountCode:   req.AccountCode,
 		SectorID:     req.SectorID,
 		IsActive:     true,
+		CreditAction: models.WholesaleClientCreditActionBlock,
+	}
+	if !applyCreditSettings(c, &client, &req) {
+		return
 	}
 	client.Address = buildAddress(client.AddressLine1, client.AddressLine2, client.Postcode)
 	if req.Address != "" && client.Address == "" {
@@ -123,9 +173,6 @@ func (h *WholesaleClientHandler) Create(c *gin.Context) {
 }
 
 func (h *WholesaleClientHandler) Update(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var client models.WholesaleClient
 	if err := h.db.First(&client, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale client not found"})
@@ -173,6 +220,9 @@ func (h *WholesaleClientHandler) Update(c *gin.Context) {
 	if req.SectorID != nil {
 		client.SectorID = req.SectorID
 	}
+	if !applyCreditSettings(c, &client, &req) {
+		return
+	}
 	client.Address = buildAddress(client.AddressLine1, client.AddressLine2, client.Postcode)
 	if req.Address != "" && client.Address == "" {
 		client.Address = req.Address
@@ -186,9 +236,6 @@ func (h *WholesaleClientHandler) Update(c *gin.Context) {
 }
 
 func (h *WholesaleClientHandler) Delete(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var client models.WholesaleClient
 	if err := h.db.First(&client, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale client not found"})
@@ -216,9 +263,6 @@ type ClientStoreRequest struct {
 }
 
 func (h *WholesaleClientHandler) CreateStore(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var client models.WholesaleClient
 	if err := h.db.First(&client, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale client not found"})
@@ -260,11 +304,8 @@ type UpdateStoreRequest struct {
 }
 
 func (h *WholesaleClientHandler) UpdateStore(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var store models.WholesaleClientStore
-	if err := h.db.First(&store, c.Param("store_id")).Error; err != nil {
+	if err := h.db.First(&store, c.Param("clientStoreId")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Store not found"})
 		return
 	}
@@ -305,11 +346,8 @@ func (h *WholesaleClientHandler) UpdateStore(c *gin.Context) {
 }
 
 func (h *WholesaleClientHandler) DeleteStore(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var store models.WholesaleClientStore
-	if err := h.db.First(&store, c.Param("store_id")).Error; err != nil {
+	if err := h.db.First(&store, c.Param("clientStoreId")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Store not found"})
 		return
 	}
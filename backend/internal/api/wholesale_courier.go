@@ -0,0 +1,371 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/courier"
+	"pos-system/backend/internal/jobs"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const courierLabelDocType = "courier_label"
+
+func (h *WholesaleOrderHandler) courierProviders() map[string]courier.Provider {
+	cfg := courier.Config{}
+	if h.cfg != nil {
+		cfg = courier.Config{
+			MockDir:                 h.cfg.CourierMockDir,
+			RoyalMailAPIKey:         h.cfg.RoyalMailAPIKey,
+			RoyalMailTrackingID:     h.cfg.RoyalMailTrackingClientID,
+			RoyalMailTrackingSecret: h.cfg.RoyalMailTrackingClientSecret,
+		}
+	}
+	return courier.Registry(cfg)
+}
+
+// ListCourierProviders returns the courier integrations that can book consignments.
+func (h *WholesaleOrderHandler) ListCourierProviders(c *gin.Context) {
+	type providerInfo struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	}
+	var out []providerInfo
+	for _, p := range h.courierProviders() {
+		out = append(out, providerInfo{Key: p.Key(), Name: p.Name()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	c.JSON(http.StatusOK, out)
+}
+
+// shipmentRecipient is the ship-to address: the order's delivery location, else the client's own address.
+func shipmentRecipient(wo *models.WholesaleOrder) courier.Address {
+	client := wo.WholesaleClient
+	addr := courier.Address{
+		Name: client.ContactName, Company: client.Name, AddressLine1: client.AddressLine1, AddressLine2: client.AddressLine2,
+		Postcode: client.Postcode, Phone: client.Phone, Email: client.Email,
+	}
+	if st := wo.WholesaleClientStore; st != nil && strings.TrimSpace(st.AddressLine1) != "" {
+		addr.AddressLine1, addr.AddressLine2, addr.City, addr.Postcode = st.AddressLine1, st.AddressLine2, st.City, st.Postcode
+		if st.ContactName != "" {
+			addr.Name = st.ContactName
+		}
+		if st.Phone != "" {
+			addr.Phone = st.Phone
+		}
+		if st.Email != "" {
+			addr.Email = st.Email
+		}
+		if st.Name != "" && st.Name != client.Name {
+			addr.Company = client.Name + " - " + st.Name
+		}
+	}
+	return addr
+}
+
+// CreateShipmentConsignment books the shipment with a courier provider, storing the tracking number and label.
+func (h *WholesaleOrderHandler) CreateShipmentConsignment(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
+		Preload("Items.WholesaleOrderItem").First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, s.WholesaleOrder.Status) {
+		return
+	}
+	if models.ShipmentStatusIsCompleted(s.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment already completed"})
+		return
+	}
+	if s.ConsignmentID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment is already booked with " + s.Courier})
+		return
+	}
+	var req struct {
+		Provider    string `json:"provider" binding:"required"`
+		ServiceCode string `json:"service_code"`
+		Parcels     []struct {
+			WeightKg float64 `json:"weight_kg"`
+		} `json:"parcels"` // optional; defaults to one parcel per packed carton
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	provider, err := courier.Lookup(h.courierProviders(), req.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	company := h.loadCompanySettings()
+	creq := courier.ConsignmentRequest{
+		Reference:   wholesaleDeliveryNoteRefLabel(&s.WholesaleOrder, &s),
+		ServiceCode: strings.TrimSpace(req.ServiceCode),
+		ShipDate:    time.Now(),
+		Sender: courier.Address{
+			Company: company.CompanyName, AddressLine1: company.AddressLine1, AddressLine2: company.AddressLine2,
+			City: company.City, Postcode: company.Postcode, Phone: company.Telephone, Email: company.Email,
+		},
+		Recipient: shipmentRecipient(&s.WholesaleOrder),
+	}
+	for _, p := range req.Parcels {
+		creq.Parcels = append(creq.Parcels, courier.Parcel{WeightKg: p.WeightKg})
+	}
+	if len(creq.Parcels) == 0 {
+		for range h.deliveryNoteCartons(s.ID) {
+			creq.Parcels = append(creq.Parcels, courier.Parcel{})
+		}
+	}
+	for _, si := range s.Items {
+		creq.Value += effectiveShipmentItemQty(&si, si.WholesaleOrderItem.Quantity) * si.WholesaleOrderItem.UnitPrice
+	}
+	if strings.TrimSpace(creq.Recipient.AddressLine1) == "" || strings.TrimSpace(creq.Recipient.Postcode) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery address and postcode are required to book a courier"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	consignment, err := provider.CreateConsignment(ctx, creq)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, courier.ErrNotConfigured) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": "Failed to book consignment: " + err.Error()})
+		return
+	}
+	labelURL := ""
+	if len(consignment.Label) > 0 {
+		labelURL, err = h.storeCourierLabel(&s, provider, consignment.Label)
+		if err != nil {
+			// The booking stands; the label can be fetched again from the provider.
+			log.Printf("[courier] shipment %d: failed to store label: %v", s.ID, err)
+		}
+	}
+	updates := map[string]interface{}{
+		"courier": provider.Name(), "tracking_number": consignment.TrackingNumber, "courier_provider": provider.Key(),
+		"consignment_id": consignment.ID, "courier_label_url": labelURL, "tracking_status": "", "tracking_error": "",
+	}
+	if err := h.db.Model(&s).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "wholesale_shipment_courier_booked", s.WholesaleOrderID, map[string]interface{}{
+		"shipment_id": s.ID, "provider": provider.Key(), "consignment_id": consignment.ID,
+		"tracking_number": consignment.TrackingNumber, "service_code": consignment.ServiceCode, "label_url": labelURL,
+	})
+	h.db.Preload("Store").Preload("WholesaleOrder").Preload("Items.WholesaleOrderItem.Product").First(&s, s.ID)
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *WholesaleOrderHandler) storeCourierLabel(s *models.Shipment, provider courier.Provider, label []byte) (string, error) {
+	filename := fmt.Sprintf("%s-courier-label-%d-%d.pdf", s.WholesaleOrder.OrderNumber, s.ID, time.Now().UnixNano())
+	url, err := h.uploadWholesalePDF(filename, label)
+	if err != nil {
+		return "", err
+	}
+	h.db.Create(&models.WholesaleOrderDocument{
+		WholesaleOrderID: s.WholesaleOrderID,
+		Type:             courierLabelDocType,
+		FileURL:          url,
+		OriginalFilename: fmt.Sprintf("%s label %s.pdf", provider.Name(), s.TrackingNumber),
+		CreatedAt:        time.Now(),
+	})
+	return url, nil
+}
+
+// GetShipmentCourierLabel returns the courier label URL, fetching it from the provider when not stored yet.
+func (h *WholesaleOrderHandler) GetShipmentCourierLabel(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.Preload("WholesaleOrder").First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if s.CourierLabelURL != "" {
+		c.JSON(http.StatusOK, gin.H{"label_url": s.CourierLabelURL})
+		return
+	}
+	if s.CourierProvider == "" || s.ConsignmentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment was not booked through a courier integration"})
+		return
+	}
+	provider, err := courier.Lookup(h.courierProviders(), s.CourierProvider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	label, err := provider.Label(ctx, s.ConsignmentID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch label: " + err.Error()})
+		return
+	}
+	url, err := h.storeCourierLabel(&s, provider, label)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.db.Model(&s).Update("courier_label_url", url)
+	c.JSON(http.StatusOK, gin.H{"label_url": url})
+}
+
+// ListShipmentTrackingEvents returns stored tracking events, newest first.
+func (h *WholesaleOrderHandler) ListShipmentTrackingEvents(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	var events []models.ShipmentTrackingEvent
+	h.db.Where("shipment_id = ?", s.ID).Order("occurred_at DESC, id DESC").Find(&events)
+	c.JSON(http.StatusOK, gin.H{
+		"shipment_id": s.ID, "courier": s.Courier, "tracking_number": s.TrackingNumber, "tracking_status": s.TrackingStatus,
+		"tracking_checked_at": s.TrackingCheckedAt, "tracking_error": s.TrackingError, "events": events,
+	})
+}
+
+// RefreshShipmentTracking polls the provider now instead of waiting for the background poller.
+func (h *WholesaleOrderHandler) RefreshShipmentTracking(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if strings.TrimSpace(s.TrackingNumber) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment has no tracking number"})
+		return
+	}
+	if err := h.pollShipmentTracking(c, &s); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch tracking: " + err.Error()})
+		return
+	}
+	h.ListShipmentTrackingEvents(c)
+}
+
+// pollShipmentTracking stores new tracking events for s. When the carrier reports delivery the shipment is
+// completed, which in turn may generate the invoice. c is nil when called from the background poller.
+func (h *WholesaleOrderHandler) pollShipmentTracking(c *gin.Context, s *models.Shipment) error {
+	key := s.CourierProvider
+	if key == "" {
+		key = s.Courier // typed by hand in StartShipment; resolves when it names a known carrier
+	}
+	provider, err := courier.Lookup(h.courierProviders(), key)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	now := time.Now()
+	events, err := provider.TrackingEvents(ctx, strings.TrimSpace(s.TrackingNumber))
+	if err != nil {
+		msg := err.Error()
+		if len(msg) > 500 {
+			msg = msg[:500]
+		}
+		h.db.Model(s).Updates(map[string]interface{}{"tracking_checked_at": now, "tracking_error": msg})
+		return err
+	}
+	added := 0
+	for _, e := range events {
+		row := models.ShipmentTrackingEvent{
+			ShipmentID: s.ID, Provider: provider.Key(), Code: e.Code, Status: e.Status,
+			Description: e.Description, Location: e.Location, OccurredAt: e.OccurredAt, CreatedAt: now,
+		}
+		res := h.db.Where(models.ShipmentTrackingEvent{ShipmentID: s.ID, Code: e.Code, OccurredAt: e.OccurredAt}).FirstOrCreate(&row)
+		if res.Error == nil && res.RowsAffected > 0 {
+			added++
+		}
+	}
+	latest := s.TrackingStatus
+	if len(events) > 0 {
+		latest = events[len(events)-1].Status
+	}
+	h.db.Model(s).Updates(map[string]interface{}{"tracking_status": latest, "tracking_checked_at": now, "tracking_error": ""})
+	s.TrackingStatus = latest
+
+	delivered, ok := courier.Delivered(events)
+	if !ok || !models.ShipmentStatusAllowsDeliveryProofUpload(s.Status) {
+		return nil
+	}
+	// Claim packed/shipped -> completed so a manual completion and the poller cannot both fire.
+	updates := map[string]interface{}{"status": models.ShipmentStatusCompleted}
+	if s.DeliveryDate == nil {
+		d := dateOnly(delivered.OccurredAt)
+		updates["delivery_date"] = &d
+	}
+	res := h.db.Model(&models.Shipment{}).Where("id = ? AND status IN ?", s.ID, []string{models.ShipmentStatusPacked, models.ShipmentStatusShipped}).Updates(updates)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	oldStatus := s.Status
+	s.Status = models.ShipmentStatusCompleted
+	h.audit(c, "wholesale_shipment_status", s.WholesaleOrderID, map[string]interface{}{
+		"shipment_id": s.ID, "old_status": oldStatus, "new_status": models.ShipmentStatusCompleted,
+		"trigger": "courier_tracking", "provider": provider.Key(), "delivered_at": delivered.OccurredAt, "new_events": added,
+	})
+	h.maybeGenerateInvoiceWhenAllShipmentsComplete(c, s.WholesaleOrderID)
+	return nil
+}
+
+// pollOpenShipmentTracking polls every dispatched shipment that has a tracking number.
+func (h *WholesaleOrderHandler) pollOpenShipmentTracking() (int, error) {
+	var shipments []models.Shipment
+	if err := h.db.Model(&models.Shipment{}).
+		Joins("INNER JOIN wholesale_orders wo ON wo.id = shipments.wholesale_order_id AND wo.status != ?", models.WholesaleOrderStatusDeleted).
+		Where("shipments.status IN ? AND shipments.tracking_number != ''", []string{models.ShipmentStatusPacked, models.ShipmentStatusShipped}).
+		Find(&shipments).Error; err != nil {
+		return 0, err
+	}
+	polled := 0
+	for i := range shipments {
+		s := &shipments[i]
+		if s.CourierProvider == "" {
+			if _, err := courier.Lookup(h.courierProviders(), s.Courier); err != nil {
+				continue // hand-typed courier we have no integration for
+			}
+		}
+		if err := h.pollShipmentTracking(nil, s); err != nil && !errors.Is(err, courier.ErrNotConfigured) {
+			log.Printf("[courier] shipment %d tracking %s: %v", s.ID, s.TrackingNumber, err)
+		}
+		polled++
+	}
+	return polled, nil
+}
+
+// StartCourierTrackingScheduler polls courier tracking for dispatched shipments every COURIER_TRACKING_POLL_MINUTES,
+// on whichever instance holds the courier tracking lease.
+func StartCourierTrackingScheduler(db *gorm.DB, cfg *config.Config) {
+	h := NewWholesaleOrderHandler(db, cfg)
+	interval := time.Duration(cfg.CourierTrackingPollMinutes) * time.Minute
+	holder := jobs.InstanceName()
+	go func() {
+		for {
+			time.Sleep(interval)
+			if !holdLoopLease(db, courierTrackingLeaseName, holder, interval) {
+				continue
+			}
+			var s models.CompanySettings
+			if err := db.Select("wholesale_order_enabled").First(&s, companySettingsID).Error; err != nil || !s.WholesaleOrderEnabled {
+				continue
+			}
+			if n, err := h.pollOpenShipmentTracking(); err != nil {
+				log.Printf("[courier] tracking poll failed: %v", err)
+			} else if n > 0 {
+				log.Printf("[courier] polled tracking for %d shipment(s)", n)
+			}
+		}
+	}()
+}
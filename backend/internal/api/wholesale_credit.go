@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+
+	"pos-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type wholesaleClientExposure struct {
+	WholesaleClientID uint
+	InvoicedUnpaid    float64
+	OpenOrders        float64
+}
+
+// wholesaleOrderInvoicedSQL matches orders that have been invoiced (invoice PDF generated or invoice sent).
+const wholesaleOrderInvoicedSQL = `(wholesale_orders.invoice_sent_at IS NOT NULL OR EXISTS (
+	SELECT 1 FROM wholesale_order_documents d WHERE d.wholesale_order_id = wholesale_orders.id AND d.type = 'invoice'))`
+
+// wholesaleClientExposures sums unpaid order totals (net + shipping) per client. Orders still pending approval,
+// rejected or deleted do not count; excludeOrderID leaves one order out (the one being approved). A query error is
+// returned rather than an empty map, which would read as no exposure and wave orders through the credit check.
+func wholesaleClientExposures(db *gorm.DB, clientIDs []uint, excludeOrderID uint) (map[uint]wholesaleClientExposure, error) {
+	out := make(map[uint]wholesaleClientExposure, len(clientIDs))
+	if len(clientIDs) == 0 {
+		return out, nil
+	}
+	var rows []wholesaleClientExposure
+	q := db.Model(&models.WholesaleOrder{}).
+		Select(fmt.Sprintf(`wholesale_client_id,
+			COALESCE(SUM(CASE WHEN %[1]s THEN total_net + COALESCE(shipping_fee, 0) ELSE 0 END), 0) AS invoiced_unpaid,
+			COALESCE(SUM(CASE WHEN %[1]s THEN 0 ELSE total_net + COALESCE(shipping_fee, 0) END), 0) AS open_orders`, wholesaleOrderInvoicedSQL)).
+		Where("wholesale_client_id IN ?", clientIDs).
+		Where("status IN ?", []string{models.WholesaleOrderStatusAssignShipment, models.WholesaleOrderStatusApproved}).
+		Where("payment_confirmed_at IS NULL")
+	if excludeOrderID != 0 {
+		q = q.Where("wholesale_orders.id <> ?", excludeOrderID)
+	}
+	if err := q.Group("wholesale_client_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.WholesaleClientID] = r
+	}
+	return out, nil
+}
+
+// applyWholesaleClientExposure fills the computed credit fields on each client.
+func applyWholesaleClientExposure(db *gorm.DB, clients []models.WholesaleClient) error {
+	ids := make([]uint, len(clients))
+	for i := range clients {
+		ids[i] = clients[i].ID
+	}
+	exposures, err := wholesaleClientExposures(db, ids, 0)
+	if err != nil {
+		return err
+	}
+	for i := range clients {
+		e := exposures[clients[i].ID]
+		clients[i].InvoicedUnpaid = e.InvoicedUnpaid
+		clients[i].OpenOrders = e.OpenOrders
+		clients[i].Exposure = e.InvoicedUnpaid + e.OpenOrders
+	}
+	return nil
+}
+
+// creditCheck is the result of checking an order against the client's on-stop flag and credit limit.
+type creditCheck struct {
+	OnStop         bool    `json:"on_stop"`
+	OnStopReason   string  `json:"on_stop_reason,omitempty"`
+	CreditLimit    float64 `json:"credit_limit"`
+	CreditAction   string  `json:"credit_action"`
+	InvoicedUnpaid float64 `json:"invoiced_unpaid"`
+	OpenOrders     float64 `json:"open_orders"`
+	OrderTotal     float64 `json:"order_total"`
+	Exposure       float64 `json:"exposure"` // including this order
+	OverLimit      bool    `json:"over_limit"`
+}
+
+// blocking reports whether approval must be refused without a management override.
+func (cc creditCheck) blocking() bool {
+	return cc.OnStop || (cc.OverLimit && cc.CreditAction != models.WholesaleClientCreditActionWarn)
+}
+
+func (cc creditCheck) message() string {
+	if cc.OnStop {
+		if cc.OnStopReason != "" {
+			return "Client account is on stop: " + cc.OnStopReason
+		}
+		return "Client account is on stop"
+	}
+	return fmt.Sprintf("Order takes client exposure to £%.2f, over the credit limit of £%.2f", cc.Exposure, cc.CreditLimit)
+}
+
+// checkWholesaleOrderCredit evaluates approving wo against its client's credit settings. Callers must refuse the
+// order when it returns an error.
+func checkWholesaleOrderCredit(db *gorm.DB, wo *models.WholesaleOrder, client *models.WholesaleClient) (creditCheck, error) {
+	exposures, err := wholesaleClientExposures(db, []uint{client.ID}, wo.ID)
+	if err != nil {
+		return creditCheck{}, err
+	}
+	e := exposures[client.ID]
+	cc := creditCheck{
+		OnStop:         client.OnStop,
+		OnStopReason:   client.OnStopReason,
+		CreditLimit:    client.CreditLimit,
+		CreditAction:   client.CreditAction,
+		InvoicedUnpaid: e.InvoicedUnpaid,
+		OpenOrders:     e.OpenOrders,
+		OrderTotal:     wholesaleOrderGrandTotal(wo),
+	}
+	cc.Exposure = cc.InvoicedUnpaid + cc.OpenOrders + cc.OrderTotal
+	cc.OverLimit = cc.CreditLimit > 0 && cc.Exposure > cc.CreditLimit+0.005
+	return cc, nil
+}
@@ -0,0 +1,888 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ukPostcode is a postcode split into the parts used to estimate how close two addresses are.
+type ukPostcode struct {
+	Area     string // "SW"
+	District int    // 1 in SW1A
+	Sector   int    // 1 in SW1A 1AA; -1 when only the outward code is known
+	Unit     string // "AA"
+}
+
+var ukOutwardRe = regexp.MustCompile(`^([A-Z]{1,2})([0-9]{1,2})[A-Z]?$`)
+
+func parseUKPostcode(pc string) (ukPostcode, bool) {
+	s := strings.ToUpper(strings.Join(strings.Fields(pc), ""))
+	out := ukPostcode{Sector: -1}
+	outward := s
+	if len(s) >= 5 && unicode.IsDigit(rune(s[len(s)-3])) {
+		outward = s[:len(s)-3]
+		out.Sector = int(s[len(s)-3] - '0')
+		out.Unit = s[len(s)-2:]
+	}
+	m := ukOutwardRe.FindStringSubmatch(outward)
+	if m == nil {
+		return ukPostcode{}, false
+	}
+	out.Area = m[1]
+	out.District, _ = strconv.Atoi(m[2])
+	return out, true
+}
+
+// postcodeDistance is a rough, unitless distance between two UK postcodes: same unit < same sector < same
+// district < neighbouring districts < different postcode area. Unparseable postcodes sort last.
+func postcodeDistance(a, b string) float64 {
+	pa, okA := parseUKPostcode(a)
+	pb, okB := parseUKPostcode(b)
+	switch {
+	case !okA || !okB:
+		return 1000
+	case pa.Area != pb.Area:
+		return 100
+	case pa.District != pb.District:
+		return 10 + math.Min(float64(absInt(pa.District-pb.District)), 50)
+	case pa.Sector != pb.Sector:
+		return 1 + float64(absInt(pa.Sector-pb.Sector))/10
+	case pa.Unit != pb.Unit:
+		return 0.5
+	}
+	return 0
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// nearestNeighbourOrder returns indexes into postcodes in visiting order, starting from start and always going
+// to the closest unvisited postcode next. Ties keep the original order.
+func nearestNeighbourOrder(start string, postcodes []string) []int {
+	visited := make([]bool, len(postcodes))
+	order := make([]int, 0, len(postcodes))
+	current := start
+	for len(order) < len(postcodes) {
+		best, bestDist := -1, 0.0
+		for i, pc := range postcodes {
+			if visited[i] {
+				continue
+			}
+			d := postcodeDistance(current, pc)
+			if strings.TrimSpace(current) == "" {
+				d = 0 // no depot postcode: start from the first stop
+			}
+			if best < 0 || d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		visited[best] = true
+		order = append(order, best)
+		current = postcodes[best]
+	}
+	return order
+}
+
+var paymentOnDeliveryRe = regexp.MustCompile(`(?i)\b(cod|c\.o\.d\.?|cash on delivery|payment on delivery|pay on delivery|cash)\b`)
+
+// paymentDueOnDelivery reports whether the order's payment terms ask the driver to collect payment.
+func paymentDueOnDelivery(terms string) bool {
+	return paymentOnDeliveryRe.MatchString(terms)
+}
+
+func (h *WholesaleOrderHandler) loadDeliveryRun(id interface{}) (*models.DeliveryRun, error) {
+	var run models.DeliveryRun
+	err := h.db.Preload("Driver").
+		Preload("Stops", func(db *gorm.DB) *gorm.DB { return db.Order("sequence ASC, id ASC") }).
+		Preload("Stops.Shipment.WholesaleOrder.WholesaleClient").Preload("Stops.Shipment.WholesaleOrder.WholesaleClientStore").
+		Preload("Stops.Shipment.WholesaleOrder.Shipments").Preload("Stops.Shipment.Items").
+		First(&run, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func deliveryRunIsClosed(run *models.DeliveryRun) bool {
+	return run.Status == models.DeliveryRunStatusCompleted || run.Status == models.DeliveryRunStatusCancelled
+}
+
+// canActOnDeliveryRun allows wholesale managers, and the run's own driver for stop updates.
+func canActOnDeliveryRun(c *gin.Context, run *models.DeliveryRun) bool {
+	if hasPermission(c, models.PermWholesaleManage) {
+		return true
+	}
+	uid := contextUserID(c)
+	return uid != nil && run.DriverUserID != nil && *uid == *run.DriverUserID
+}
+
+func deliveryStopPostcode(s *models.Shipment) string {
+	return shipmentRecipient(&s.WholesaleOrder).Postcode
+}
+
+// shipmentCaseCount is the number of cases for the manifest: packed cartons, else the delivery note case qty.
+func (h *WholesaleOrderHandler) shipmentCaseCount(s *models.Shipment) float64 {
+	if n := len(h.deliveryNoteCartons(s.ID)); n > 0 {
+		return float64(n)
+	}
+	total := 0.0
+	for _, si := range s.Items {
+		total += si.CaseQty
+	}
+	return total
+}
+
+type deliveryRunRequest struct {
+	RunDate       string  `json:"run_date"` // YYYY-MM-DD
+	DriverUserID  *uint   `json:"driver_user_id"`
+	DriverName    *string `json:"driver_name"`
+	VehicleReg    *string `json:"vehicle_reg"`
+	StartPostcode *string `json:"start_postcode"`
+	Notes         *string `json:"notes"`
+	ShipmentIDs   []uint  `json:"shipment_ids"`
+}
+
+// ListDeliveryRuns lists runs, newest first. Query: date=YYYY-MM-DD, driver_user_id, status.
+func (h *WholesaleOrderHandler) ListDeliveryRuns(c *gin.Context) {
+	q := h.db.Model(&models.DeliveryRun{}).Preload("Driver").
+		Preload("Stops", func(db *gorm.DB) *gorm.DB { return db.Order("sequence ASC, id ASC") })
+	if d := strings.TrimSpace(c.Query("date")); d != "" {
+		t, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		q = q.Where("run_date = ?", t)
+	}
+	if v := strings.TrimSpace(c.Query("driver_user_id")); v != "" {
+		q = q.Where("driver_user_id = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("status")); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	var runs []models.DeliveryRun
+	if err := q.Order("run_date DESC, id DESC").Limit(200).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetDeliveryRun returns a run with its stops in visiting order.
+func (h *WholesaleOrderHandler) GetDeliveryRun(c *gin.Context) {
+	run, err := h.loadDeliveryRun(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// CreateDeliveryRun plans a run for a date and driver, optionally with its first shipments.
+func (h *WholesaleOrderHandler) CreateDeliveryRun(c *gin.Context) {
+	var req deliveryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	runDate, err := time.Parse("2006-01-02", strings.TrimSpace(req.RunDate))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "run_date is required (YYYY-MM-DD)"})
+		return
+	}
+	run := models.DeliveryRun{RunDate: runDate, Status: models.DeliveryRunStatusPlanned, CreatedBy: contextUserID(c)}
+	if status, err := h.applyDeliveryRunFields(&run, &req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if run.StartPostcode == "" {
+		run.StartPostcode = strings.TrimSpace(h.loadCompanySettings().Postcode)
+	}
+	var added []models.DeliveryRunStop
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		var err error
+		added, err = h.addDeliveryRunStops(tx, &run, req.ShipmentIDs)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditDeliveryRunStopsAdded(c, &run, added)
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *WholesaleOrderHandler) applyDeliveryRunFields(run *models.DeliveryRun, req *deliveryRunRequest) (int, error) {
+	if req.DriverUserID != nil {
+		if *req.DriverUserID == 0 {
+			run.DriverUserID = nil
+		} else {
+			var u models.User
+			if err := h.db.Select("id", "is_active").First(&u, *req.DriverUserID).Error; err != nil || !u.IsActive {
+				return http.StatusBadRequest, fmt.Errorf("driver user not found")
+			}
+			run.DriverUserID = req.DriverUserID
+		}
+	}
+	if req.DriverName != nil {
+		run.DriverName = strings.TrimSpace(*req.DriverName)
+	}
+	if req.VehicleReg != nil {
+		run.VehicleReg = strings.ToUpper(strings.TrimSpace(*req.VehicleReg))
+	}
+	if req.StartPostcode != nil {
+		run.StartPostcode = strings.ToUpper(strings.TrimSpace(*req.StartPostcode))
+	}
+	if req.Notes != nil {
+		run.Notes = strings.TrimSpace(*req.Notes)
+	}
+	return 0, nil
+}
+
+// addDeliveryRunStops appends shipments to the end of the run. A shipment can only be on one open run at a time.
+// Returned stops carry their Shipment for auditing once the transaction commits.
+func (h *WholesaleOrderHandler) addDeliveryRunStops(tx *gorm.DB, run *models.DeliveryRun, shipmentIDs []uint) ([]models.DeliveryRunStop, error) {
+	if len(shipmentIDs) == 0 {
+		return nil, nil
+	}
+	var existing []models.DeliveryRunStop
+	tx.Where("delivery_run_id = ?", run.ID).Order("sequence ASC").Find(&existing)
+	seq := len(existing)
+	collecting := map[uint]bool{} // orders already collecting payment on this run
+	for _, st := range existing {
+		if st.CollectAmount > 0 {
+			var s models.Shipment
+			if tx.Select("wholesale_order_id").First(&s, st.ShipmentID).Error == nil {
+				collecting[s.WholesaleOrderID] = true
+			}
+		}
+	}
+	var added []models.DeliveryRunStop
+	seen := map[uint]bool{}
+	for _, sid := range shipmentIDs {
+		if seen[sid] {
+			continue
+		}
+		seen[sid] = true
+		var s models.Shipment
+		if err := tx.Preload("WholesaleOrder").First(&s, sid).Error; err != nil {
+			return nil, fmt.Errorf("shipment %d not found", sid)
+		}
+		if s.WholesaleOrder.Status == models.WholesaleOrderStatusDeleted {
+			return nil, fmt.Errorf("shipment %d belongs to a deleted order", sid)
+		}
+		if models.ShipmentStatusIsCompleted(s.Status) {
+			return nil, fmt.Errorf("shipment %d is already completed", sid)
+		}
+		var onRun int64
+		tx.Model(&models.DeliveryRunStop{}).
+			Joins("INNER JOIN delivery_runs dr ON dr.id = delivery_run_stops.delivery_run_id").
+			Where("delivery_run_stops.shipment_id = ? AND delivery_run_stops.status = ? AND dr.status IN ?",
+				sid, models.DeliveryStopStatusPending, []string{models.DeliveryRunStatusPlanned, models.DeliveryRunStatusInProgress}).
+			Count(&onRun)
+		if onRun > 0 {
+			return nil, fmt.Errorf("shipment %d is already on an open delivery run", sid)
+		}
+		stop := models.DeliveryRunStop{DeliveryRunID: run.ID, ShipmentID: s.ID, Sequence: seq + 1, Status: models.DeliveryStopStatusPending}
+		wo := &s.WholesaleOrder
+		if wo.PaymentConfirmedAt == nil && !collecting[wo.ID] && paymentDueOnDelivery(wo.PaymentTerms) {
+			stop.CollectAmount = math.Round(wholesaleOrderGrandTotal(wo)*100) / 100
+			collecting[wo.ID] = true
+		}
+		if err := tx.Create(&stop).Error; err != nil {
+			return nil, err
+		}
+		seq++
+		stop.Shipment = s
+		added = append(added, stop)
+	}
+	return added, nil
+}
+
+func (h *WholesaleOrderHandler) auditDeliveryRunStopsAdded(c *gin.Context, run *models.DeliveryRun, stops []models.DeliveryRunStop) {
+	for _, stop := range stops {
+		h.audit(c, "wholesale_delivery_run_stop_added", stop.Shipment.WholesaleOrderID, map[string]interface{}{
+			"delivery_run_id": run.ID, "run_date": run.RunDate.Format("2006-01-02"), "shipment_id": stop.ShipmentID,
+			"sequence": stop.Sequence, "collect_amount": stop.CollectAmount,
+		})
+	}
+}
+
+// UpdateDeliveryRun edits run date, driver, vehicle, depot postcode and notes.
+func (h *WholesaleOrderHandler) UpdateDeliveryRun(c *gin.Context) {
+	var run models.DeliveryRun
+	if err := h.db.First(&run, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(&run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	var req deliveryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if d := strings.TrimSpace(req.RunDate); d != "" {
+		t, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "run_date must be YYYY-MM-DD"})
+			return
+		}
+		run.RunDate = t
+	}
+	if status, err := h.applyDeliveryRunFields(&run, &req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Save(&run).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+// CancelDeliveryRun cancels a run; its pending shipments become free to plan on another run.
+func (h *WholesaleOrderHandler) CancelDeliveryRun(c *gin.Context) {
+	res := h.db.Model(&models.DeliveryRun{}).Where("id = ? AND status IN ?", c.Param("id"),
+		[]string{models.DeliveryRunStatusPlanned, models.DeliveryRunStatusInProgress}).
+		Update("status", models.DeliveryRunStatusCancelled)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run not found or already closed"})
+		return
+	}
+	out, _ := h.loadDeliveryRun(c.Param("id"))
+	c.JSON(http.StatusOK, out)
+}
+
+// AddDeliveryRunStops appends shipments to a run. Body: {"shipment_ids": [..]}.
+func (h *WholesaleOrderHandler) AddDeliveryRunStops(c *gin.Context) {
+	var run models.DeliveryRun
+	if err := h.db.First(&run, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(&run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	var req struct {
+		ShipmentIDs []uint `json:"shipment_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var added []models.DeliveryRunStop
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		added, err = h.addDeliveryRunStops(tx, &run, req.ShipmentIDs)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditDeliveryRunStopsAdded(c, &run, added)
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+// UpdateDeliveryRunStop edits the amount to collect and driver notes for a stop.
+func (h *WholesaleOrderHandler) UpdateDeliveryRunStop(c *gin.Context) {
+	var stop models.DeliveryRunStop
+	if err := h.db.Where("id = ? AND delivery_run_id = ?", c.Param("stopId"), c.Param("id")).First(&stop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stop not found"})
+		return
+	}
+	var run models.DeliveryRun
+	if err := h.db.First(&run, stop.DeliveryRunID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(&run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	var req struct {
+		CollectAmount *float64 `json:"collect_amount"`
+		Notes         *string  `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.CollectAmount != nil {
+		if *req.CollectAmount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collect_amount cannot be negative"})
+			return
+		}
+		updates["collect_amount"] = math.Round(*req.CollectAmount*100) / 100
+	}
+	if req.Notes != nil {
+		updates["notes"] = strings.TrimSpace(*req.Notes)
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&stop).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	h.db.First(&stop, stop.ID)
+	c.JSON(http.StatusOK, stop)
+}
+
+// RemoveDeliveryRunStop takes a pending shipment off the run and closes the gap in the sequence.
+func (h *WholesaleOrderHandler) RemoveDeliveryRunStop(c *gin.Context) {
+	var stop models.DeliveryRunStop
+	if err := h.db.Where("id = ? AND delivery_run_id = ?", c.Param("stopId"), c.Param("id")).First(&stop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stop not found"})
+		return
+	}
+	var run models.DeliveryRun
+	if err := h.db.First(&run, stop.DeliveryRunID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(&run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	if stop.Status != models.DeliveryStopStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending stops can be removed"})
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&stop).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.DeliveryRunStop{}).Where("delivery_run_id = ? AND sequence > ?", stop.DeliveryRunID, stop.Sequence).
+			Update("sequence", gorm.Expr("sequence - 1")).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.refreshDeliveryRunStatus(stop.DeliveryRunID)
+	out, _ := h.loadDeliveryRun(stop.DeliveryRunID)
+	c.JSON(http.StatusOK, out)
+}
+
+// ReorderDeliveryRunStops sets the visiting order manually. Body: {"stop_ids": [..]} listing every stop once.
+func (h *WholesaleOrderHandler) ReorderDeliveryRunStops(c *gin.Context) {
+	run, err := h.loadDeliveryRun(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	var req struct {
+		StopIDs []uint `json:"stop_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	onRun := map[uint]bool{}
+	for _, st := range run.Stops {
+		onRun[st.ID] = true
+	}
+	seen := map[uint]bool{}
+	for _, id := range req.StopIDs {
+		if !onRun[id] || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("stop %d is not on this run or is listed twice", id)})
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(run.Stops) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stop_ids must list every stop on the run"})
+		return
+	}
+	if err := h.saveDeliveryRunSequence(run.ID, req.StopIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WholesaleOrderHandler) saveDeliveryRunSequence(runID uint, stopIDs []uint) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range stopIDs {
+			if err := tx.Model(&models.DeliveryRunStop{}).Where("id = ? AND delivery_run_id = ?", id, runID).
+				Update("sequence", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OptimiseDeliveryRun reorders pending stops by nearest postcode, starting from the last visited stop (or the
+// depot). Stops already delivered or failed keep their place at the front.
+func (h *WholesaleOrderHandler) OptimiseDeliveryRun(c *gin.Context) {
+	run, err := h.loadDeliveryRun(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	if deliveryRunIsClosed(run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return
+	}
+	var ids []uint
+	var pending []models.DeliveryRunStop
+	start := run.StartPostcode
+	for _, st := range run.Stops {
+		if st.Status == models.DeliveryStopStatusPending {
+			pending = append(pending, st)
+			continue
+		}
+		ids = append(ids, st.ID)
+		start = deliveryStopPostcode(&st.Shipment)
+	}
+	postcodes := make([]string, len(pending))
+	for i := range pending {
+		postcodes[i] = deliveryStopPostcode(&pending[i].Shipment)
+	}
+	for _, i := range nearestNeighbourOrder(start, postcodes) {
+		ids = append(ids, pending[i].ID)
+	}
+	if err := h.saveDeliveryRunSequence(run.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WholesaleOrderHandler) loadDeliveryRunStopForUpdate(c *gin.Context) (*models.DeliveryRun, *models.DeliveryRunStop, bool) {
+	var run models.DeliveryRun
+	if err := h.db.First(&run, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return nil, nil, false
+	}
+	if !canActOnDeliveryRun(c, &run) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the run's driver or a manager can update stops"})
+		return nil, nil, false
+	}
+	if deliveryRunIsClosed(&run) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery run is " + run.Status})
+		return nil, nil, false
+	}
+	var stop models.DeliveryRunStop
+	if err := h.db.Where("id = ? AND delivery_run_id = ?", c.Param("stopId"), run.ID).First(&stop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stop not found"})
+		return nil, nil, false
+	}
+	if stop.Status != models.DeliveryStopStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stop is already " + stop.Status})
+		return nil, nil, false
+	}
+	return &run, &stop, true
+}
+
+// DeliverDeliveryRunStop marks a stop delivered. Multipart form: signed_delivery_note (file, required), notes.
+// The file goes through the same signed delivery note upload as the shipment page, which completes the shipment.
+func (h *WholesaleOrderHandler) DeliverDeliveryRunStop(c *gin.Context) {
+	run, stop, ok := h.loadDeliveryRunStopForUpdate(c)
+	if !ok {
+		return
+	}
+	var s models.Shipment
+	if err := h.db.First(&s, stop.ShipmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if !h.saveSignedDeliveryNote(c, &s) {
+		return
+	}
+	h.markDeliveryRunStopDelivered(c, run, stop, s.WholesaleOrderID, s.SignedDeliveryNotePDFURL, strings.TrimSpace(c.PostForm("notes")))
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+// markDeliveryRunStopDelivered closes a pending stop once its shipment has delivery proof, then updates the run.
+func (h *WholesaleOrderHandler) markDeliveryRunStopDelivered(c *gin.Context, run *models.DeliveryRun, stop *models.DeliveryRunStop, orderID uint, proofURL, notes string) {
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.DeliveryStopStatusDelivered, "delivered_at": &now}
+	if notes != "" {
+		updates["notes"] = notes
+	}
+	res := h.db.Model(&models.DeliveryRunStop{}).Where("id = ? AND status = ?", stop.ID, models.DeliveryStopStatusPending).Updates(updates)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	h.audit(c, "wholesale_delivery_run_stop_delivered", orderID, map[string]interface{}{
+		"delivery_run_id": run.ID, "stop_id": stop.ID, "shipment_id": stop.ShipmentID, "file_url": proofURL,
+		"collect_amount": stop.CollectAmount,
+	})
+	h.refreshDeliveryRunStatus(run.ID)
+}
+
+// FailDeliveryRunStop records a failed delivery; the shipment stays open so it can go on another run.
+func (h *WholesaleOrderHandler) FailDeliveryRunStop(c *gin.Context) {
+	run, stop, ok := h.loadDeliveryRunStopForUpdate(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+	res := h.db.Model(&models.DeliveryRunStop{}).Where("id = ? AND status = ?", stop.ID, models.DeliveryStopStatusPending).
+		Updates(map[string]interface{}{"status": models.DeliveryStopStatusFailed, "failure_reason": reason})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	var s models.Shipment
+	if h.db.Select("id", "wholesale_order_id").First(&s, stop.ShipmentID).Error == nil {
+		h.audit(c, "wholesale_delivery_run_stop_failed", s.WholesaleOrderID, map[string]interface{}{
+			"delivery_run_id": run.ID, "stop_id": stop.ID, "shipment_id": s.ID, "reason": reason,
+		})
+	}
+	h.refreshDeliveryRunStatus(run.ID)
+	out, _ := h.loadDeliveryRun(run.ID)
+	c.JSON(http.StatusOK, out)
+}
+
+// refreshDeliveryRunStatus moves an open run to in_progress once a stop is closed and to completed once all are.
+func (h *WholesaleOrderHandler) refreshDeliveryRunStatus(runID uint) {
+	var run models.DeliveryRun
+	if err := h.db.Preload("Stops").First(&run, runID).Error; err != nil || deliveryRunIsClosed(&run) {
+		return
+	}
+	closed := 0
+	for _, st := range run.Stops {
+		if st.Status != models.DeliveryStopStatusPending {
+			closed++
+		}
+	}
+	status := models.DeliveryRunStatusPlanned
+	switch {
+	case closed > 0 && closed == len(run.Stops):
+		status = models.DeliveryRunStatusCompleted
+	case closed > 0:
+		status = models.DeliveryRunStatusInProgress
+	}
+	if status != run.Status {
+		h.db.Model(&run).Update("status", status)
+	}
+}
+
+// GetDeliveryRunManifest downloads the driver manifest PDF.
+func (h *WholesaleOrderHandler) GetDeliveryRunManifest(c *gin.Context) {
+	run, err := h.loadDeliveryRun(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery run not found"})
+		return
+	}
+	data, err := h.renderDeliveryManifestPDF(run)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate manifest: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", fmt.Sprintf("delivery-manifest-%d-%s.pdf", run.ID, run.RunDate.Format("2006-01-02"))))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+func deliveryRunDriverLabel(run *models.DeliveryRun) string {
+	if run.Driver != nil {
+		if name := userDisplayName(run.Driver); name != "" {
+			return name
+		}
+	}
+	if run.DriverName != "" {
+		return run.DriverName
+	}
+	return "-"
+}
+
+func (h *WholesaleOrderHandler) renderDeliveryManifestPDF(run *models.DeliveryRun) ([]byte, error) {
+	pdf, fontName, fontBold := h.newWholesalePDF("P")
+	margin := 12.0
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fontName, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	contentW := 210.0 - 2*margin
+	pageBottom := 297.0 - 18
+
+	type stopRow struct {
+		stop    models.DeliveryRunStop
+		lines   []string
+		contact []string
+		ref     string
+		cases   float64
+	}
+	var rows []stopRow
+	totalCases, totalCollect := 0.0, 0.0
+	for _, st := range run.Stops {
+		s := st.Shipment
+		to := shipmentRecipient(&s.WholesaleOrder)
+		r := stopRow{stop: st, cases: h.shipmentCaseCount(&s)}
+		r.lines = nonEmptyStrings(to.Company, to.AddressLine1, to.AddressLine2, strings.TrimSpace(to.City+" "+strings.ToUpper(to.Postcode)))
+		r.contact = nonEmptyStrings(to.Name, to.Phone)
+		r.ref = wholesaleDeliveryNoteRefLabel(&s.WholesaleOrder, &s)
+		if r.ref == "" {
+			r.ref = wholesaleOrderRefLabel(&s.WholesaleOrder)
+		}
+		totalCases += r.cases
+		totalCollect += st.CollectAmount
+		rows = append(rows, r)
+	}
+
+	cols := []struct {
+		title string
+		w     float64
+		align string
+	}{
+		{"#", 8, "C"}, {"Deliver to", 64, "L"}, {"Contact", 34, "L"}, {"Ref", 30, "L"}, {"Cases", 14, "R"}, {"Collect", 18, "R"}, {"Signed", 18, "C"},
+	}
+	header := func() {
+		pdf.SetFont(fontBold, "B", 8)
+		pdf.SetFillColor(230, 230, 230)
+		for _, col := range cols {
+			pdf.CellFormat(col.w, 6, col.title, "1", 0, col.align, true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	pdf.AddPage()
+	pdf.SetFont(fontBold, "B", 16)
+	pdf.CellFormat(contentW, 8, "Delivery Manifest", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontName, "", 10)
+	vehicle := run.VehicleReg
+	if vehicle == "" {
+		vehicle = "-"
+	}
+	info := [][2]string{
+		{"Date:", ordinalDay(run.RunDate.Day()) + " " + run.RunDate.Format("January 2006")},
+		{"Driver:", deliveryRunDriverLabel(run)},
+		{"Vehicle:", vehicle},
+		{"Stops:", strconv.Itoa(len(rows))},
+		{"Total cases:", strconv.FormatFloat(totalCases, 'f', -1, 64)},
+		{"Total to collect:", "£" + formatNumberWithCommas(totalCollect, 2)},
+	}
+	for _, kv := range info {
+		pdf.SetFont(fontBold, "B", 10)
+		pdf.CellFormat(32, 5.5, kv[0], "", 0, "L", false, 0, "")
+		pdf.SetFont(fontName, "", 10)
+		pdf.CellFormat(contentW-32, 5.5, kv[1], "", 1, "L", false, 0, "")
+	}
+	if run.Notes != "" {
+		pdf.SetFont(fontName, "", 9)
+		pdf.MultiCell(contentW, 4.5, "Notes: "+run.Notes, "", "L", false)
+	}
+	pdf.Ln(3)
+	header()
+
+	lineH := 4.2
+	for _, r := range rows {
+		pdf.SetFont(fontName, "", 8)
+		var addrLines, contactLines, refLines [][]byte
+		for _, l := range r.lines {
+			addrLines = append(addrLines, pdf.SplitLines([]byte(l), cols[1].w-2)...)
+		}
+		for _, l := range r.contact {
+			contactLines = append(contactLines, pdf.SplitLines([]byte(l), cols[2].w-2)...)
+		}
+		refLines = pdf.SplitLines([]byte(r.ref), cols[3].w-2)
+		if r.stop.Notes != "" {
+			addrLines = append(addrLines, pdf.SplitLines([]byte("Note: "+r.stop.Notes), cols[1].w-2)...)
+		}
+		n := len(addrLines)
+		if len(contactLines) > n {
+			n = len(contactLines)
+		}
+		if len(refLines) > n {
+			n = len(refLines)
+		}
+		rowH := math.Max(float64(n)*lineH+2, 12)
+		if pdf.GetY()+rowH > pageBottom {
+			pdf.AddPage()
+			header()
+		}
+		x, y := margin, pdf.GetY()
+		cell := func(i int, lines [][]byte) {
+			pdf.Rect(x, y, cols[i].w, rowH, "D")
+			for j, l := range lines {
+				pdf.SetXY(x+1, y+1+float64(j)*lineH)
+				pdf.CellFormat(cols[i].w-2, lineH, string(l), "", 0, cols[i].align, false, 0, "")
+			}
+			x += cols[i].w
+		}
+		pdf.SetFont(fontBold, "B", 10)
+		cell(0, [][]byte{[]byte(strconv.Itoa(r.stop.Sequence))})
+		pdf.SetFont(fontName, "", 8)
+		cell(1, addrLines)
+		cell(2, contactLines)
+		cell(3, refLines)
+		cases := "-"
+		if r.cases > 0 {
+			cases = strconv.FormatFloat(r.cases, 'f', -1, 64)
+		}
+		cell(4, [][]byte{[]byte(cases)})
+		collect := "-"
+		if r.stop.CollectAmount > 0 {
+			collect = "£" + formatNumberWithCommas(r.stop.CollectAmount, 2)
+		}
+		pdf.SetFont(fontBold, "B", 8)
+		cell(5, [][]byte{[]byte(collect)})
+		pdf.SetFont(fontName, "", 7)
+		status := ""
+		if r.stop.Status != models.DeliveryStopStatusPending {
+			status = r.stop.Status
+		}
+		cell(6, [][]byte{[]byte(status)})
+		pdf.SetXY(margin, y+rowH)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUKPostcode(t *testing.T) {
+	pc, ok := parseUKPostcode("sw1a 1aa")
+	if !ok || pc.Area != "SW" || pc.District != 1 || pc.Sector != 1 || pc.Unit != "AA" {
+		t.Errorf("parseUKPostcode(sw1a 1aa) = %+v, %v", pc, ok)
+	}
+	pc, ok = parseUKPostcode("KT19")
+	if !ok || pc.Area != "KT" || pc.District != 19 || pc.Sector != -1 {
+		t.Errorf("parseUKPostcode(KT19) = %+v, %v", pc, ok)
+	}
+	if _, ok := parseUKPostcode("not a postcode"); ok {
+		t.Error("expected invalid postcode")
+	}
+}
+
+func TestPostcodeDistanceOrdering(t *testing.T) {
+	from := "KT19 0SR"
+	sameSector := postcodeDistance(from, "KT19 0AB")
+	sameDistrict := postcodeDistance(from, "KT19 8QQ")
+	nearDistrict := postcodeDistance(from, "KT17 1AA")
+	otherArea := postcodeDistance(from, "NW1 2AB")
+	invalid := postcodeDistance(from, "")
+	if !(sameSector < sameDistrict && sameDistrict < nearDistrict && nearDistrict < otherArea && otherArea < invalid) {
+		t.Errorf("unexpected ordering: %v %v %v %v %v", sameSector, sameDistrict, nearDistrict, otherArea, invalid)
+	}
+}
+
+func TestNearestNeighbourOrder(t *testing.T) {
+	postcodes := []string{"NW1 2AB", "KT17 1AA", "", "KT19 0AB", "NW1 2AD"}
+	got := nearestNeighbourOrder("KT19 0SR", postcodes)
+	want := []int{3, 1, 0, 4, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nearestNeighbourOrder = %v, want %v", got, want)
+	}
+	if got := nearestNeighbourOrder("", []string{"NW1 2AB", "KT19 0AB", "NW1 2AD"}); !reflect.DeepEqual(got, []int{0, 2, 1}) {
+		t.Errorf("without depot = %v", got)
+	}
+}
+
+func TestPaymentDueOnDelivery(t *testing.T) {
+	for terms, want := range map[string]bool{
+		"COD":                      true,
+		"Cash on delivery":         true,
+		"Payment on delivery only": true,
+		"30 days from invoice":     false,
+		"":                         false,
+		"Encoded terms":            false,
+	} {
+		if got := paymentDueOnDelivery(terms); got != want {
+			t.Errorf("paymentDueOnDelivery(%q) = %v, want %v", terms, got, want)
+		}
+	}
+}
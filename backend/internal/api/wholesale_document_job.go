@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pos-system/backend/internal/jobs"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// orderDocumentJob renders an order confirmation or invoice PDF and stores it as an order document.
+type orderDocumentJob struct {
+	OrderID uint   `json:"order_id"`
+	DocType string `json:"doc_type"` // "order_confirmation" or "invoice"
+	// Replace drops existing documents of DocType once the new PDF is stored; otherwise the job does nothing
+	// when one already exists.
+	Replace          bool       `json:"replace"`
+	AuditAction      string     `json:"audit_action"`
+	Trigger          string     `json:"trigger,omitempty"`
+	UnlockAfterEmail bool       `json:"unlock_after_email,omitempty"`
+	Actor            auditActor `json:"actor"`
+}
+
+// queueOrderDocument queues PDF generation for an order and returns the job id, or nil when queueing failed
+// (logged; the document can be regenerated by hand).
+func (h *WholesaleOrderHandler) queueOrderDocument(c *gin.Context, p orderDocumentJob) *uint {
+	p.Actor = actorFromContext(c)
+	job, err := jobs.Enqueue(h.db, jobTypeWholesaleOrderDocument, p, jobs.Options{CreatedByID: p.Actor.UserID})
+	if err != nil {
+		fmt.Printf("Failed to queue %s PDF for wholesale order %d: %v\n", p.DocType, p.OrderID, err)
+		return nil
+	}
+	return &job.ID
+}
+
+func (h *WholesaleOrderHandler) runOrderDocumentJob(ctx context.Context, job *models.Job) (interface{}, error) {
+	var p orderDocumentJob
+	if err := jobs.Decode(job, &p); err != nil {
+		return nil, err
+	}
+	if p.DocType != "order_confirmation" && p.DocType != "invoice" {
+		return nil, jobs.Permanent(fmt.Errorf("unknown document type %q", p.DocType))
+	}
+	var wo models.WholesaleOrder
+	if err := h.db.WithContext(ctx).Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").
+		Preload("Store").Preload("User").Preload("Reviewer").Preload("Documents").First(&wo, p.OrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, jobs.Permanent(fmt.Errorf("wholesale order %d not found", p.OrderID))
+		}
+		return nil, err
+	}
+	if wo.Status == models.WholesaleOrderStatusDeleted {
+		return nil, jobs.Permanent(fmt.Errorf("wholesale order %d is deleted", p.OrderID))
+	}
+	if !p.Replace {
+		for _, d := range wo.Documents {
+			if d.Type == p.DocType {
+				return gin.H{"skipped": "document already exists", "document_id": d.ID, "file_url": d.FileURL}, nil
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var url string
+	var err error
+	if p.DocType == "invoice" {
+		url, err = h.generateInvoicePDF(&wo)
+	} else {
+		url, err = h.generateOrderConfirmationPDF(&wo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, fmt.Errorf("%s PDF was not stored", p.DocType)
+	}
+	// Past the timeout the job may already have been handed to another worker; leave the documents to that run.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	doc := models.WholesaleOrderDocument{WholesaleOrderID: wo.ID, Type: p.DocType, FileURL: url, CreatedAt: time.Now()}
+	err = h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if p.Replace {
+			if err := tx.Where("wholesale_order_id = ? AND type = ?", wo.ID, p.DocType).Delete(&models.WholesaleOrderDocument{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&doc).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{"document_type": p.DocType, "file_url": url}
+	if p.Trigger != "" {
+		changes["trigger"] = p.Trigger
+	}
+	if p.DocType == "invoice" && wo.InvoiceNumber != "" {
+		changes["invoice_number"] = wo.InvoiceNumber
+	}
+	if p.UnlockAfterEmail {
+		changes["unlock_after_email"] = true
+	}
+	h.auditAs(p.Actor, p.AuditAction, wo.ID, changes)
+	if p.DocType == "invoice" {
+		h.syncUBLInvoice(p.Actor, wo.ID)
+	}
+	return gin.H{"document_id": doc.ID, "file_url": url}, nil
+}
@@ -292,10 +292,6 @@ func computeEndorseAllocationPreview(in endorseAllocationInput) EndorseAllocatio
 }
 
 func (h *WholesaleOrderHandler) EndorseAllocationPreview(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.Preload("Items").First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -313,6 +309,16 @@ func (h *WholesaleOrderHandler) EndorseAllocationPreview(c *gin.Context) {
 		return
 	}
 
+	preview, err := h.endorseAllocationPreviewForOrder(&wo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// endorseAllocationPreviewForOrder loads current stock and computes the allocation preview for the order's unassigned quantities.
+func (h *WholesaleOrderHandler) endorseAllocationPreviewForOrder(wo *models.WholesaleOrder) (EndorseAllocationPreview, error) {
 	productIDs := make([]uint, 0, len(wo.Items))
 	for _, item := range wo.Items {
 		productIDs = append(productIDs, item.ProductID)
@@ -320,8 +326,7 @@ func (h *WholesaleOrderHandler) EndorseAllocationPreview(c *gin.Context) {
 
 	var stockRows []models.Stock
 	if err := h.db.Where("product_id IN ?", productIDs).Preload("Store").Find(&stockRows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return EndorseAllocationPreview{}, err
 	}
 
 	defaultStoreByProd := make(map[uint]uint)
@@ -346,8 +351,7 @@ func (h *WholesaleOrderHandler) EndorseAllocationPreview(c *gin.Context) {
 	for _, item := range wo.Items {
 		assigned, err := h.assignedQtyForOrderItem(item.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return EndorseAllocationPreview{}, err
 		}
 		pending := item.Quantity - assigned
 		if pending <= 0.0001 {
@@ -360,11 +364,10 @@ func (h *WholesaleOrderHandler) EndorseAllocationPreview(c *gin.Context) {
 		})
 	}
 
-	preview := computeEndorseAllocationPreview(endorseAllocationInput{
+	return computeEndorseAllocationPreview(endorseAllocationInput{
 		Items:              items,
 		DefaultStoreByProd: defaultStoreByProd,
 		StockByStoreProd:   stockByStoreProd,
 		StoreNames:         storeNames,
-	})
-	c.JSON(http.StatusOK, preview)
+	}), nil
 }
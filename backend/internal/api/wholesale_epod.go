@@ -0,0 +1,409 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/disintegration/imaging"
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	epodMaxPhotos      = 6
+	epodMaxImageBytes  = 15 << 20
+	epodPhotoMaxPx     = 1600
+	epodSignatureMaxPx = 1000
+	// epodMaxSourcePixels caps width x height before decoding: a small compressed file can declare a huge
+	// canvas, and decoding allocates it in full. 50 MP covers any phone camera.
+	epodMaxSourcePixels = 50_000_000
+)
+
+// deliveryProof is what renderDeliveryNotePDF needs to draw the ePOD section; images are already normalised
+// (signature PNG, photos JPEG).
+type deliveryProof struct {
+	RecipientName string
+	DeliveredAt   time.Time
+	Latitude      *float64
+	Longitude     *float64
+	AccuracyM     *float64
+	CapturedBy    string
+	Signature     []byte
+	Photos        [][]byte
+}
+
+// normaliseProofImage decodes an uploaded image, shrinks it to maxPx and re-encodes it in a format gofpdf can
+// embed: PNG flattened onto white for signatures (canvas exports are often transparent), JPEG for photos.
+func normaliseProofImage(data []byte, maxPx int, asPNG bool) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image (use PNG, JPEG, GIF or WebP): %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > epodMaxSourcePixels {
+		return nil, fmt.Errorf("image is %dx%d pixels; the most accepted is %d megapixels", cfg.Width, cfg.Height, epodMaxSourcePixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image (use PNG, JPEG, GIF or WebP): %w", err)
+	}
+	b := img.Bounds()
+	if b.Dx() > maxPx || b.Dy() > maxPx {
+		img = imaging.Fit(img, maxPx, maxPx, imaging.Lanczos)
+		b = img.Bounds()
+	}
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	var buf bytes.Buffer
+	if asPNG {
+		err = png.Encode(&buf, flat)
+	} else {
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeDataURL accepts "data:image/png;base64,...." (as produced by a signature canvas) or bare base64.
+func decodeDataURL(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ","); strings.HasPrefix(s, "data:") && i > 0 {
+		s = s[i+1:]
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// parseEPODCoordinates reads latitude/longitude/accuracy form values; coordinates are optional but must come as a pair.
+func parseEPODCoordinates(latStr, lngStr, accStr string) (lat, lng, acc *float64, err error) {
+	latStr, lngStr, accStr = strings.TrimSpace(latStr), strings.TrimSpace(lngStr), strings.TrimSpace(accStr)
+	if latStr == "" && lngStr == "" {
+		return nil, nil, nil, nil
+	}
+	la, errLat := strconv.ParseFloat(latStr, 64)
+	lo, errLng := strconv.ParseFloat(lngStr, 64)
+	if errLat != nil || errLng != nil || la < -90 || la > 90 || lo < -180 || lo > 180 {
+		return nil, nil, nil, fmt.Errorf("latitude and longitude must be given together as valid coordinates")
+	}
+	lat, lng = &la, &lo
+	if accStr != "" {
+		a, err := strconv.ParseFloat(accStr, 64)
+		if err != nil || a < 0 {
+			return nil, nil, nil, fmt.Errorf("accuracy must be a non-negative number of metres")
+		}
+		acc = &a
+	}
+	return lat, lng, acc, nil
+}
+
+func formatEPODLocation(lat, lng, acc *float64) string {
+	if lat == nil || lng == nil {
+		return "Not captured"
+	}
+	out := fmt.Sprintf("%.5f, %.5f", *lat, *lng)
+	if acc != nil {
+		out += fmt.Sprintf(" (accuracy %.0f m)", *acc)
+	}
+	return out
+}
+
+func deliveryProofSectionHeight(p *deliveryProof) float64 {
+	rows := (len(p.Photos) + 3) / 4
+	return 10 + 36 + float64(rows)*34
+}
+
+// fitImageOnPDF registers data and draws it centred inside the box, keeping its aspect ratio.
+func fitImageOnPDF(pdf *gofpdf.Fpdf, name, imageType string, data []byte, x, y, w, h float64) {
+	opts := gofpdf.ImageOptions{ImageType: imageType}
+	info := pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(data))
+	if info == nil || info.Width() <= 0 || info.Height() <= 0 {
+		return
+	}
+	scale := math.Min(w/info.Width(), h/info.Height())
+	dw, dh := info.Width()*scale, info.Height()*scale
+	pdf.ImageOptions(name, x+(w-dw)/2, y+(h-dh)/2, dw, dh, false, opts, 0, "")
+}
+
+// drawDeliveryProofSection appends the ePOD block (details, signature and photos) to the delivery note.
+func drawDeliveryProofSection(pdf *gofpdf.Fpdf, fontName, fontBold string, margin, contentW float64, p *deliveryProof) error {
+	pdf.Ln(5)
+	pdf.SetFillColor(0, 51, 102)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont(fontBold, "B", 10)
+	pdf.CellFormat(contentW, 7, "Electronic Proof of Delivery", "", 1, "L", true, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	top := pdf.GetY() + 2
+
+	sigW, sigH := 70.0, 32.0
+	infoW := contentW - sigW - 4
+	rows := [][2]string{
+		{"Received by:", p.RecipientName},
+		{"Delivered at:", p.DeliveredAt.Format("2 Jan 2006 15:04 MST")},
+		{"Location:", formatEPODLocation(p.Latitude, p.Longitude, p.AccuracyM)},
+	}
+	if p.CapturedBy != "" {
+		rows = append(rows, [2]string{"Driver:", p.CapturedBy})
+	}
+	pdf.SetY(top)
+	for _, r := range rows {
+		pdf.SetX(margin)
+		pdf.SetFont(fontBold, "B", 9)
+		pdf.CellFormat(26, 6, r[0], "", 0, "L", false, 0, "")
+		pdf.SetFont(fontName, "", 9)
+		pdf.CellFormat(infoW-26, 6, r[1], "", 1, "L", false, 0, "")
+	}
+
+	sigX := margin + contentW - sigW
+	pdf.Rect(sigX, top, sigW, sigH, "D")
+	fitImageOnPDF(pdf, fmt.Sprintf("epod-signature-%d", time.Now().UnixNano()), "PNG", p.Signature, sigX+1, top+1, sigW-2, sigH-6)
+	pdf.SetXY(sigX, top+sigH-5)
+	pdf.SetFont(fontName, "", 7)
+	pdf.CellFormat(sigW, 4, "Signature", "T", 0, "C", false, 0, "")
+
+	y := top + sigH + 4
+	photoW, photoH, gap := (contentW-9)/4, 30.0, 3.0
+	for i, photo := range p.Photos {
+		x := margin + float64(i%4)*(photoW+gap)
+		py := y + float64(i/4)*(photoH+4)
+		pdf.Rect(x, py, photoW, photoH, "D")
+		fitImageOnPDF(pdf, fmt.Sprintf("epod-photo-%d-%d", i, time.Now().UnixNano()), "JPG", photo, x+0.5, py+0.5, photoW-1, photoH-1)
+	}
+	if len(p.Photos) > 0 {
+		y += float64((len(p.Photos)+3)/4) * (photoH + 4)
+	}
+	pdf.SetXY(margin, y)
+	return pdf.Error()
+}
+
+// openDeliveryRunStopForShipment returns the pending stop for the shipment on a planned or in-progress run.
+func (h *WholesaleOrderHandler) openDeliveryRunStopForShipment(shipmentID uint) (*models.DeliveryRun, *models.DeliveryRunStop) {
+	var stop models.DeliveryRunStop
+	err := h.db.Joins("INNER JOIN delivery_runs dr ON dr.id = delivery_run_stops.delivery_run_id").
+		Where("delivery_run_stops.shipment_id = ? AND delivery_run_stops.status = ? AND dr.status IN ?",
+			shipmentID, models.DeliveryStopStatusPending, []string{models.DeliveryRunStatusPlanned, models.DeliveryRunStatusInProgress}).
+		First(&stop).Error
+	if err != nil {
+		return nil, nil
+	}
+	var run models.DeliveryRun
+	if err := h.db.First(&run, stop.DeliveryRunID).Error; err != nil {
+		return nil, nil
+	}
+	return &run, &stop
+}
+
+// CaptureDeliveryProof records an electronic proof of delivery and completes the shipment.
+// Multipart form: recipient_name (required); signature (image file) or signature_data (data URL); photos (up to 6
+// images); latitude, longitude, accuracy (metres); captured_at (RFC3339, device time; default now); notes.
+// Allowed for wholesale managers and the driver of an open delivery run carrying the shipment.
+func (h *WholesaleOrderHandler) CaptureDeliveryProof(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.Preload("Store").
+		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
+		Preload("Items.WholesaleOrderItem.Product").First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	run, stop := h.openDeliveryRunStopForShipment(s.ID)
+	if !hasPermission(c, models.PermWholesaleManage) && (run == nil || !canActOnDeliveryRun(c, run)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the delivery run's driver or a manager can record delivery"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, s.WholesaleOrder.Status) {
+		return
+	}
+	if strings.TrimSpace(s.DeliveryNotePDFURL) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Generate a delivery note (Start shipment) before recording delivery"})
+		return
+	}
+	if !models.ShipmentStatusAllowsDeliveryProofUpload(s.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment must be packed or shipped before recording delivery"})
+		return
+	}
+
+	recipient := strings.TrimSpace(c.PostForm("recipient_name"))
+	if recipient == "" || len(recipient) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recipient_name is required (max 200 characters)"})
+		return
+	}
+	lat, lng, acc, err := parseEPODCoordinates(c.PostForm("latitude"), c.PostForm("longitude"), c.PostForm("accuracy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	capturedAt := time.Now()
+	if v := strings.TrimSpace(c.PostForm("captured_at")); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "captured_at must be RFC3339"})
+			return
+		}
+		if t.After(time.Now().Add(10 * time.Minute)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "captured_at is in the future"})
+			return
+		}
+		capturedAt = t
+	}
+
+	var rawSignature []byte
+	if fh, err := c.FormFile("signature"); err == nil {
+		if fh.Size > epodMaxImageBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Signature image is too large"})
+			return
+		}
+		rawSignature, err = readMultipartFileBytes(fh)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read signature: " + err.Error()})
+			return
+		}
+	} else if v := c.PostForm("signature_data"); strings.TrimSpace(v) != "" {
+		rawSignature, err = decodeDataURL(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "signature_data is not valid base64"})
+			return
+		}
+	}
+	if len(rawSignature) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A signature is required (form file 'signature' or 'signature_data')"})
+		return
+	}
+	signature, err := normaliseProofImage(rawSignature, epodSignatureMaxPx, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Signature: " + err.Error()})
+		return
+	}
+	var photos [][]byte
+	if form, err := c.MultipartForm(); err == nil && form != nil {
+		files := form.File["photos"]
+		if len(files) > epodMaxPhotos {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d photos", epodMaxPhotos)})
+			return
+		}
+		for i, fh := range files {
+			if fh.Size > epodMaxImageBytes {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Photo %d is too large", i+1)})
+				return
+			}
+			data, err := readMultipartFileBytes(fh)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read photo: " + err.Error()})
+				return
+			}
+			photo, err := normaliseProofImage(data, epodPhotoMaxPx, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Photo %d: %s", i+1, err.Error())})
+				return
+			}
+			photos = append(photos, photo)
+		}
+	}
+
+	proof := &deliveryProof{
+		RecipientName: recipient, DeliveredAt: capturedAt, Latitude: lat, Longitude: lng, AccuracyM: acc,
+		Signature: signature, Photos: photos,
+	}
+	if uid := contextUserID(c); uid != nil {
+		var u models.User
+		if h.db.Select("id", "username", "first_name", "last_name").First(&u, *uid).Error == nil {
+			proof.CapturedBy = userDisplayName(&u)
+		}
+	}
+	if s.DeliveryDate == nil {
+		d := dateOnly(capturedAt)
+		s.DeliveryDate = &d
+	}
+	pdfData, err := h.renderDeliveryNotePDF(&s, proof)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render signed delivery note: " + err.Error()})
+		return
+	}
+
+	stamp := time.Now().UnixNano()
+	sigURL, err := h.uploadWholesaleFile(fmt.Sprintf("epod/epod-%d-%d-signature.png", s.ID, stamp), signature)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save signature: " + err.Error()})
+		return
+	}
+	var photoURLs []string
+	for i, photo := range photos {
+		url, err := h.uploadWholesaleFile(fmt.Sprintf("epod/epod-%d-%d-photo-%d.jpg", s.ID, stamp, i+1), photo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo: " + err.Error()})
+			return
+		}
+		photoURLs = append(photoURLs, url)
+	}
+	signedURL, err := h.uploadWholesaleFile(fmt.Sprintf("signed-dn/signed-dn-%d-%d.pdf", s.ID, stamp), pdfData)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save signed delivery note: " + err.Error()})
+		return
+	}
+
+	record := models.DeliveryProof{
+		ShipmentID: s.ID, RecipientName: recipient, SignatureURL: sigURL, PhotoURLs: photoURLs,
+		Latitude: lat, Longitude: lng, AccuracyM: acc, CapturedAt: capturedAt, SignedPDFURL: signedURL,
+		UserID: contextUserID(c), CreatedAt: time.Now(),
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Claim packed/shipped -> completed so a parallel upload or courier poll cannot complete it twice.
+		res := tx.Model(&models.Shipment{}).Where("id = ? AND status IN ?", s.ID, []string{models.ShipmentStatusPacked, models.ShipmentStatusShipped}).
+			Updates(map[string]interface{}{
+				"status": models.ShipmentStatusCompleted, "signed_delivery_note_pdf_url": signedURL, "delivery_date": s.DeliveryDate,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errShipmentAlreadyDelivered
+		}
+		return tx.Create(&record).Error
+	})
+	if err == errShipmentAlreadyDelivered {
+		c.JSON(http.StatusConflict, gin.H{"error": "Shipment was completed in the meantime"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	oldStatus := s.Status
+	h.audit(c, "wholesale_shipment_epod", s.WholesaleOrderID, map[string]interface{}{
+		"shipment_id": s.ID, "old_status": oldStatus, "new_status": models.ShipmentStatusCompleted, "delivery_proof_id": record.ID,
+		"recipient_name": recipient, "captured_at": capturedAt.Format(time.RFC3339), "latitude": lat, "longitude": lng,
+		"photos": len(photoURLs), "file_url": signedURL,
+	})
+	if stop != nil {
+		h.markDeliveryRunStopDelivered(c, run, stop, s.WholesaleOrderID, signedURL, strings.TrimSpace(c.PostForm("notes")))
+	}
+	h.maybeGenerateInvoiceWhenAllShipmentsComplete(c, s.WholesaleOrderID)
+	c.JSON(http.StatusOK, gin.H{"delivery_proof": record, "signed_delivery_note_pdf_url": signedURL})
+}
+
+var errShipmentAlreadyDelivered = fmt.Errorf("shipment already delivered")
+
+// ListDeliveryProofs returns the ePOD records for a shipment, newest first.
+func (h *WholesaleOrderHandler) ListDeliveryProofs(c *gin.Context) {
+	var proofs []models.DeliveryProof
+	if err := h.db.Where("shipment_id = ?", c.Param("id")).Order("id DESC").Find(&proofs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, proofs)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func transparentSignaturePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	img.Set(1, 1, color.NRGBA{A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormaliseProofImage(t *testing.T) {
+	out, err := normaliseProofImage(transparentSignaturePNG(t, 2000, 500), 1000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(out))
+	if err != nil || format != "png" {
+		t.Fatalf("decode: %v %s", err, format)
+	}
+	if b := img.Bounds(); b.Dx() != 1000 || b.Dy() != 250 {
+		t.Errorf("resized to %v, want 1000x250", b)
+	}
+	if r, g, b, a := img.At(900, 200).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff || a != 0xffff {
+		t.Errorf("transparent area not flattened to white: %v %v %v %v", r, g, b, a)
+	}
+	if _, err := normaliseProofImage([]byte("not an image"), 100, false); err == nil {
+		t.Error("expected error for invalid image")
+	}
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	raw := []byte{1, 2, 3}
+	enc := base64.StdEncoding.EncodeToString(raw)
+	for _, in := range []string{"data:image/png;base64," + enc, enc} {
+		got, err := decodeDataURL(in)
+		if err != nil || !bytes.Equal(got, raw) {
+			t.Errorf("decodeDataURL(%q) = %v, %v", in, got, err)
+		}
+	}
+}
+
+func TestParseEPODCoordinates(t *testing.T) {
+	lat, lng, acc, err := parseEPODCoordinates("51.5", "-0.12", "8")
+	if err != nil || *lat != 51.5 || *lng != -0.12 || *acc != 8 {
+		t.Errorf("got %v %v %v %v", lat, lng, acc, err)
+	}
+	if lat, lng, _, err := parseEPODCoordinates("", "", ""); err != nil || lat != nil || lng != nil {
+		t.Error("empty coordinates should be accepted as not captured")
+	}
+	for _, in := range [][2]string{{"51.5", ""}, {"91", "0"}, {"0", "181"}, {"x", "1"}} {
+		if _, _, _, err := parseEPODCoordinates(in[0], in[1], ""); err == nil {
+			t.Errorf("expected error for %v", in)
+		}
+	}
+	if got := formatEPODLocation(nil, nil, nil); got != "Not captured" {
+		t.Errorf("formatEPODLocation(nil) = %q", got)
+	}
+}
+
+func TestDrawDeliveryProofSection(t *testing.T) {
+	sig, err := normaliseProofImage(transparentSignaturePNG(t, 300, 100), 1000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo, err := normaliseProofImage(transparentSignaturePNG(t, 40, 30), 1600, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &deliveryProof{RecipientName: "J Smith", DeliveredAt: time.Now(), Signature: sig, Photos: [][]byte{photo, photo, photo, photo, photo}}
+	if h := deliveryProofSectionHeight(p); h <= deliveryProofSectionHeight(&deliveryProof{}) {
+		t.Errorf("photos should add height, got %v", h)
+	}
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	if err := drawDeliveryProofSection(pdf, "Helvetica", "Helvetica", 15, 180, p); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestNormaliseProofImageRefusesHugeCanvas declares a 100000x100000 canvas in an otherwise tiny PNG; it must be
+// refused from the header alone, before anything is decoded.
+func TestNormaliseProofImageRefusesHugeCanvas(t *testing.T) {
+	data := transparentSignaturePNG(t, 4, 4)
+	// IHDR data starts after the 8-byte signature and the chunk's length and type.
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], 100000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 100000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+
+	if _, err := normaliseProofImage(data, epodSignatureMaxPx, true); err == nil || !strings.Contains(err.Error(), "megapixels") {
+		t.Fatalf("normaliseProofImage(huge canvas) err = %v, want a megapixel limit error", err)
+	}
+}
@@ -0,0 +1,374 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pos-system/backend/internal/courier"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Shipping label formats: one 4x6 inch label per page (thermal printers) or four A6 labels per A4 sheet.
+const (
+	labelFormat4x6 = "4x6"
+	labelFormatA4  = "a4"
+)
+
+// code128Patterns holds bar/space module widths for Code 128 symbol values 0-106 (106 = stop).
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128CodeC  = 99
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// code128Symbols encodes text as Code 128 symbol values, including start, check and stop symbols. All-digit
+// text uses code set C (two digits per symbol); anything else uses code set B (printable ASCII).
+func code128Symbols(text string) ([]int, error) {
+	if text == "" {
+		return nil, fmt.Errorf("barcode text is empty")
+	}
+	digits := true
+	for _, r := range text {
+		if r < 32 || r > 126 {
+			return nil, fmt.Errorf("barcode text contains unsupported character %q", r)
+		}
+		if r < '0' || r > '9' {
+			digits = false
+		}
+	}
+	var symbols []int
+	switch {
+	case digits && len(text)%2 == 0:
+		symbols = append(symbols, code128StartC)
+	case digits:
+		// Odd length: first digit in set B, then switch to C for the pairs.
+		symbols = append(symbols, code128StartB, int(text[0])-32, code128CodeC)
+		text = text[1:]
+	default:
+		symbols = append(symbols, code128StartB)
+		for _, r := range text {
+			symbols = append(symbols, int(r)-32)
+		}
+		text = ""
+	}
+	for i := 0; i+1 < len(text); i += 2 {
+		symbols = append(symbols, int(text[i]-'0')*10+int(text[i+1]-'0'))
+	}
+	check := symbols[0]
+	for i := 1; i < len(symbols); i++ {
+		check += i * symbols[i]
+	}
+	return append(symbols, check%103, code128Stop), nil
+}
+
+// drawCode128 draws the barcode centred in a box of width w starting at (x, y), keeping a 10-module quiet zone.
+func drawCode128(pdf *gofpdf.Fpdf, text string, x, y, w, h float64) error {
+	symbols, err := code128Symbols(text)
+	if err != nil {
+		return err
+	}
+	modules := 20
+	for _, sym := range symbols {
+		for _, ch := range code128Patterns[sym] {
+			modules += int(ch - '0')
+		}
+	}
+	moduleW := math.Min(w/float64(modules), 0.5)
+	cx := x + (w-moduleW*float64(modules))/2 + 10*moduleW
+	pdf.SetFillColor(0, 0, 0)
+	for _, sym := range symbols {
+		for i, ch := range code128Patterns[sym] {
+			bw := float64(ch-'0') * moduleW
+			if i%2 == 0 {
+				pdf.Rect(cx, y, bw, h, "F")
+			}
+			cx += bw
+		}
+	}
+	return nil
+}
+
+// shippingLabel is one carton's label.
+type shippingLabel struct {
+	Number   int
+	Total    int
+	Contents string
+}
+
+// shipmentLabelCartons decides how many carton labels to print: the override when given, else the cartons of
+// the completed packing session, else the shipment's case quantity (at least one label).
+func (h *WholesaleOrderHandler) shipmentLabelCartons(s *models.Shipment, override int) []shippingLabel {
+	var labels []shippingLabel
+	if override <= 0 {
+		for _, carton := range h.deliveryNoteCartons(s.ID) {
+			units := 0.0
+			for _, it := range carton.Items {
+				units += it.Quantity
+			}
+			labels = append(labels, shippingLabel{Contents: fmt.Sprintf("%d line(s), %s unit(s)", len(carton.Items), strconv.FormatFloat(math.Round(units*1000)/1000, 'f', -1, 64))})
+		}
+	}
+	if len(labels) == 0 {
+		n := override
+		if n <= 0 {
+			cases := 0.0
+			for _, si := range s.Items {
+				cases += si.CaseQty
+			}
+			n = int(math.Ceil(cases - qtyEpsilon))
+		}
+		if n <= 0 {
+			n = 1
+		}
+		labels = make([]shippingLabel, n)
+	}
+	for i := range labels {
+		labels[i].Number, labels[i].Total = i+1, len(labels)
+	}
+	return labels
+}
+
+func (h *WholesaleOrderHandler) loadShipmentForLabels(id interface{}) (*models.Shipment, error) {
+	var s models.Shipment
+	err := h.db.Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
+		Preload("WholesaleOrder.Shipments").Preload("Items").First(&s, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// renderShippingLabelsPDF renders one label per carton in the given format.
+func (h *WholesaleOrderHandler) renderShippingLabelsPDF(s *models.Shipment, format string, cartons int) ([]byte, error) {
+	labels := h.shipmentLabelCartons(s, cartons)
+	company := h.loadCompanySettings()
+	uploadDir := "uploads"
+	if h.cfg != nil && strings.TrimSuffix(h.cfg.UploadDir, "/") != "" {
+		uploadDir = strings.TrimSuffix(h.cfg.UploadDir, "/")
+	}
+
+	var pdf *gofpdf.Fpdf
+	var fontName, fontBold string
+	var slotW, slotH float64
+	perPage := 1
+	if format == labelFormatA4 {
+		pdf, fontName, fontBold = h.newWholesalePDF("P")
+		slotW, slotH, perPage = 105, 148.5, 4
+	} else {
+		pdf, fontName, fontBold = h.newWholesalePDFCustom(&gofpdf.InitType{UnitStr: "mm", Size: gofpdf.SizeType{Wd: 101.6, Ht: 152.4}})
+		slotW, slotH = 101.6, 152.4
+	}
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	wo := &s.WholesaleOrder
+	to := shipmentRecipient(wo)
+	ref := wholesaleDeliveryNoteRefLabel(wo, s)
+	if ref == "" {
+		ref = wholesaleOrderRefLabel(wo)
+	}
+	barcodeText := strconv.FormatUint(uint64(s.ID), 10)
+
+	for i, label := range labels {
+		slot := i % perPage
+		if slot == 0 {
+			pdf.AddPage()
+			if perPage > 1 {
+				// Cut lines between the four A6 labels.
+				pdf.SetDrawColor(180, 180, 180)
+				pdf.SetDashPattern([]float64{1, 1}, 0)
+				pdf.Line(slotW, 0, slotW, 2*slotH)
+				pdf.Line(0, slotH, 2*slotW, slotH)
+				pdf.SetDashPattern([]float64{}, 0)
+				pdf.SetDrawColor(0, 0, 0)
+			}
+		}
+		x := float64(slot%2) * slotW
+		y := float64(slot/2) * slotH
+		if err := h.drawShippingLabel(pdf, fontName, fontBold, company, uploadDir, x+5, y+5, slotW-10, slotH-10, s, to, ref, barcodeText, label); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *WholesaleOrderHandler) drawShippingLabel(pdf *gofpdf.Fpdf, fontName, fontBold string, company models.CompanySettings, uploadDir string,
+	x, y, w, ht float64, s *models.Shipment, to courier.Address, ref, barcodeText string, label shippingLabel) error {
+	pad := 3.0
+	innerW := w - 2*pad
+	pdf.SetLineWidth(0.4)
+	pdf.Rect(x, y, w, ht, "D")
+
+	// Header: logo (or company name) on the left, carton n of m on the right.
+	logoH := drawCompanyLogoOnPDF(pdf, company, h.cfg, uploadDir, pad, x+pad, y+pad, 50, 15)
+	boxW := 34.0
+	pdf.SetFillColor(0, 0, 0)
+	pdf.Rect(x+w-pad-boxW, y+pad, boxW, 15, "F")
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont(fontBold, "B", 7)
+	pdf.SetXY(x+w-pad-boxW, y+pad+1)
+	pdf.CellFormat(boxW, 3.5, "CARTON", "", 2, "C", false, 0, "")
+	pdf.SetFont(fontBold, "B", 15)
+	pdf.CellFormat(boxW, 8, fmt.Sprintf("%d of %d", label.Number, label.Total), "", 0, "C", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	if logoH <= 0 {
+		pdf.SetFont(fontBold, "B", 11)
+		pdf.SetXY(x+pad, y+pad+4)
+		pdf.CellFormat(innerW-boxW-2, 6, company.CompanyName, "", 0, "L", false, 0, "")
+	}
+	cy := y + pad + 17
+	pdf.Line(x, cy, x+w, cy)
+
+	// Ship to.
+	pdf.SetXY(x+pad, cy+2)
+	pdf.SetFont(fontBold, "B", 8)
+	pdf.CellFormat(innerW, 4, "SHIP TO", "", 2, "L", false, 0, "")
+	pdf.SetFont(fontBold, "B", 13)
+	for _, line := range []string{to.Company, to.Name} {
+		if strings.TrimSpace(line) != "" {
+			pdf.MultiCell(innerW, 5.5, line, "", "L", false)
+			pdf.SetX(x + pad)
+		}
+	}
+	pdf.SetFont(fontName, "", 12)
+	for _, line := range []string{to.AddressLine1, to.AddressLine2, to.City} {
+		if strings.TrimSpace(line) != "" {
+			pdf.MultiCell(innerW, 5.5, line, "", "L", false)
+			pdf.SetX(x + pad)
+		}
+	}
+	if strings.TrimSpace(to.Postcode) != "" {
+		pdf.SetFont(fontBold, "B", 18)
+		pdf.CellFormat(innerW, 9, strings.ToUpper(to.Postcode), "", 2, "L", false, 0, "")
+	}
+	if strings.TrimSpace(to.Phone) != "" {
+		pdf.SetFont(fontName, "", 9)
+		pdf.CellFormat(innerW, 4.5, "Tel: "+to.Phone, "", 2, "L", false, 0, "")
+	}
+	cy = pdf.GetY() + 2
+	pdf.Line(x, cy, x+w, cy)
+
+	// Order details.
+	pdf.SetXY(x+pad, cy+2)
+	rows := [][2]string{{"Ref:", ref}}
+	if s.DeliveryDate != nil {
+		rows = append(rows, [2]string{"Delivery:", ordinalDay(s.DeliveryDate.Day()) + " " + s.DeliveryDate.Format("January 2006")})
+	}
+	if s.Courier != "" {
+		rows = append(rows, [2]string{"Courier:", s.Courier})
+	}
+	if s.TrackingNumber != "" {
+		rows = append(rows, [2]string{"Tracking:", s.TrackingNumber})
+	}
+	if label.Contents != "" {
+		rows = append(rows, [2]string{"Contents:", label.Contents})
+	}
+	for _, r := range rows {
+		pdf.SetX(x + pad)
+		pdf.SetFont(fontBold, "B", 9)
+		pdf.CellFormat(20, 5, r[0], "", 0, "L", false, 0, "")
+		pdf.SetFont(fontName, "", 9)
+		pdf.CellFormat(innerW-20, 5, r[1], "", 1, "L", false, 0, "")
+	}
+
+	// Sender line and shipment barcode at the foot of the label.
+	barH := 16.0
+	footY := y + ht - pad - barH - 5 - 6
+	pdf.Line(x, footY-2, x+w, footY-2)
+	pdf.SetXY(x+pad, footY)
+	pdf.SetFont(fontName, "", 7)
+	from := strings.Join(nonEmptyStrings(company.CompanyName, company.AddressLine1, company.City, company.Postcode), ", ")
+	pdf.CellFormat(innerW, 4, "From: "+from, "", 0, "L", false, 0, "")
+	if err := drawCode128(pdf, barcodeText, x+pad, footY+5, innerW, barH); err != nil {
+		return err
+	}
+	pdf.SetXY(x+pad, footY+5+barH)
+	pdf.SetFont(fontName, "", 8)
+	pdf.CellFormat(innerW, 4, "Shipment "+barcodeText, "", 0, "C", false, 0, "")
+	return nil
+}
+
+func nonEmptyStrings(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func normaliseLabelFormat(format string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", labelFormat4x6, "4x6in", "thermal":
+		return labelFormat4x6, true
+	case labelFormatA4, "a4-multi":
+		return labelFormatA4, true
+	}
+	return "", false
+}
+
+// GetShipmentLabels downloads carton labels for a shipment.
+// Query: format=4x6|a4 (default 4x6), cartons=n to override the carton count.
+func (h *WholesaleOrderHandler) GetShipmentLabels(c *gin.Context) {
+	format, ok := normaliseLabelFormat(c.Query("format"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 4x6 or a4"})
+		return
+	}
+	cartons := 0
+	if v := strings.TrimSpace(c.Query("cartons")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cartons must be between 1 and 500"})
+			return
+		}
+		cartons = n
+	}
+	s, err := h.loadShipmentForLabels(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, s.WholesaleOrder.Status) {
+		return
+	}
+	data, err := h.renderShippingLabelsPDF(s, format, cartons)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate labels: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", shipmentLabelsFilename(s, format)))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+func shipmentLabelsFilename(s *models.Shipment, format string) string {
+	ref := strings.NewReplacer("/", "_", "\\", "_", " ", "").Replace(wholesaleOrderRefLabel(&s.WholesaleOrder))
+	return fmt.Sprintf("%s_labels_shipment_%d_%s.pdf", ref, s.ID, format)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestCode128Patterns(t *testing.T) {
+	seen := map[string]bool{}
+	for i, p := range code128Patterns {
+		sum := 0
+		for _, ch := range p {
+			sum += int(ch - '0')
+		}
+		want := 11
+		if i == code128Stop {
+			want = 13
+		}
+		if sum != want {
+			t.Errorf("pattern %d (%s) has %d modules, want %d", i, p, sum, want)
+		}
+		if seen[p] {
+			t.Errorf("pattern %d (%s) is duplicated", i, p)
+		}
+		seen[p] = true
+	}
+}
+
+func TestCode128Symbols(t *testing.T) {
+	cases := []struct {
+		text string
+		want []int
+	}{
+		// Set B: start B, P J J 1 2 3 C, check 55, stop.
+		{"PJJ123C", []int{104, 48, 42, 42, 17, 18, 19, 35, 55, 106}},
+		// Even digits: start C, 12 34, check (105+12+68)%103 = 82.
+		{"1234", []int{105, 12, 34, 82, 106}},
+		// Odd digits: B "1", Code C, 23; check (104+17+2*99+3*23)%103 = 79.
+		{"123", []int{104, 17, 99, 23, 79, 106}},
+	}
+	for _, tc := range cases {
+		got, err := code128Symbols(tc.text)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.text, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("code128Symbols(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+	if _, err := code128Symbols("café"); err == nil {
+		t.Error("expected error for non-ASCII text")
+	}
+}
+
+func TestDrawCode128(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	if err := drawCode128(pdf, "42", 10, 10, 80, 15); err != nil {
+		t.Fatal(err)
+	}
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
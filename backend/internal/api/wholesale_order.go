@@ -24,6 +24,7 @@ import (
 	"pos-system/backend/internal/config"
 	apimail "pos-system/backend/internal/mail"
 	"pos-system/backend/internal/models"
+	"pos-system/backend/internal/webhook"
 )
 
 type WholesaleOrderHandler struct {
@@ -51,43 +52,14 @@ func isOrderCompleted(wo *models.WholesaleOrder) bool {
 	return true
 }
 
+// audit records a wholesale order audit entry. c may be nil for background jobs (logged without user or client).
 func (h *WholesaleOrderHandler) audit(c *gin.Context, action string, orderID uint, changes map[string]interface{}) {
-	userIDVal, _ := c.Get("user_id")
-	var uid *uint
-	if id, ok := userIDVal.(uint); ok {
-		uid = &id
-	}
-	changesJSON, _ := json.Marshal(changes)
-	h.db.Create(&models.AuditLog{
-		UserID:     uid,
-		Action:     action,
-		EntityType: "wholesale_order",
-		EntityID:   &orderID,
-		Changes:    string(changesJSON),
-		IPAddress:  c.ClientIP(),
-		UserAgent:  c.GetHeader("User-Agent"),
-	})
+	h.auditAs(actorFromContext(c), action, orderID, changes)
 }
 
-func wholesaleMgmtRolesOk(r string) bool {
-	return r == "management" || r == "supervisor" || r == "admin" || r == "system_admin" || r == RoleHQStaff
-}
-
-// requireManagementOrSupervisor aborts with 403 unless role is management, supervisor, admin, or system_admin.
-func requireManagementOrSupervisor(c *gin.Context) bool {
-	role, _ := c.Get("role")
-	r, _ := role.(string)
-	if !wholesaleMgmtRolesOk(r) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Admin or manager role required"})
-		c.Abort()
-		return false
-	}
-	return true
-}
-
-// requireManagementSupervisorOrAdmin allows the same wholesale management roles as requireManagementOrSupervisor.
-func requireManagementSupervisorOrAdmin(c *gin.Context) bool {
-	return requireManagementOrSupervisor(c)
+// auditAs records a wholesale order audit row for work done outside the request, such as a background job.
+func (h *WholesaleOrderHandler) auditAs(actor auditActor, action string, orderID uint, changes map[string]interface{}) {
+	writeAudit(h.db, actor, action, "wholesale_order", orderID, changes)
 }
 
 func parseUnlockAfterCompletion(c *gin.Context) bool {
@@ -168,31 +140,19 @@ func (h *WholesaleOrderHandler) abortIfWholesaleOrderDeletedByID(c *gin.Context,
 	return abortIfWholesaleOrderDeleted(c, wo.Status)
 }
 
-// requirePosUserSupervisorOrManagement allows create for pos_user, supervisor, management, or admin.
-func requirePosUserSupervisorOrManagement(c *gin.Context) bool {
-	role, _ := c.Get("role")
-	r, _ := role.(string)
-	if r != "pos_user" && r != "supervisor" && r != "management" && r != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "POS user, supervisor, or admin role required"})
-		c.Abort()
-		return false
-	}
-	return true
-}
-
 type CreateWholesaleOrderRequest struct {
-	WholesaleClientID      uint    `json:"wholesale_client_id" binding:"required"`
-	WholesaleClientStoreID *uint   `json:"wholesale_client_store_id"` // shipping address
-	StoreID                uint    `json:"store_id" binding:"required"`
-	SectorID               *uint   `json:"sector_id"`
-	PONumber               string  `json:"po_number"`
-	OrderChannel           string  `json:"order_channel"` // "po" = client provided PO, "whatsapp" = we generate PO (delivery note shows "Whatsapp")
-	PODate                 string  `json:"po_date"`
-	OrderDate              string  `json:"order_date"`
-	PaymentTerms           string  `json:"payment_terms"` // defaults from client.Terms if empty
-	Notes                  string  `json:"notes"`
-	TotalDiscount          float64 `json:"total_discount"`
-	ShippingFee            float64 `json:"shipping_fee"` // order-level shipping fee
+	WholesaleClientID      uint     `json:"wholesale_client_id" binding:"required"`
+	WholesaleClientStoreID *uint    `json:"wholesale_client_store_id"` // shipping address
+	StoreID                uint     `json:"store_id" binding:"required"`
+	SectorID               *uint    `json:"sector_id"`
+	PONumber               string   `json:"po_number"`
+	OrderChannel           string   `json:"order_channel"` // "po" = client provided PO, "whatsapp" = we generate PO (delivery note shows "Whatsapp")
+	PODate                 string   `json:"po_date"`
+	OrderDate              string   `json:"order_date"`
+	PaymentTerms           string   `json:"payment_terms"` // defaults from client.Terms if empty
+	Notes                  string   `json:"notes"`
+	TotalDiscount          float64  `json:"total_discount"`
+	ShippingFee            *float64 `json:"shipping_fee"` // order-level shipping fee; calculated from the shipping rule when omitted
 	Items                  []struct {
 		ProductID          uint    `json:"product_id" binding:"required"`
 		Quantity           float64 `json:"quantity" binding:"required"`
@@ -200,10 +160,53 @@ type CreateWholesaleOrderRequest struct {
 	} `json:"items" binding:"required,min=1"`
 }
 
-func (h *WholesaleOrderHandler) Create(c *gin.Context) {
-	if !requirePosUserSupervisorOrManagement(c) {
-		return
+// wholesaleUnitPrice resolves the unit price for a wholesale line: PO-date retail price, falling back to the
+// latest configured price, then sector pricing when a sector is set.
+func (h *WholesaleOrderHandler) wholesaleUnitPrice(productID uint, sectorID *uint, priceDate, now time.Time) float64 {
+	var unitPrice float64
+	var cost models.ProductCost
+	if err := h.db.Where("product_id = ? AND (effective_from IS NULL OR effective_from <= ?) AND (effective_to IS NULL OR effective_to >= ?)",
+		productID, priceDate, priceDate).
+		Order("effective_from DESC").First(&cost).Error; err == nil {
+		// Base price for wholesale order (PO-date "price"):
+		// use the retail online store price for that season.
+		unitPrice = cost.DirectRetailOnlineStorePriceGBP
+		// If retail isn't set for that season, fallback later to current retail.
+	}
+	// If the PO-date retail price isn't set, fall back to current/latest retail.
+	if unitPrice <= 0 {
+		var currentCost models.ProductCost
+		// "Current/latest price" should be the latest configured cost up to now.
+		// Do not require effective_to > now, otherwise future/unset PO dates could yield 0.
+		if err := h.db.Where("product_id = ? AND (effective_from IS NULL OR effective_from <= ?)",
+			productID, now).
+			Order("effective_from DESC").First(&currentCost).Error; err == nil {
+			unitPrice = currentCost.DirectRetailOnlineStorePriceGBP
+			if unitPrice <= 0 {
+				// Last resort: if retail is still unset, use wholesale cost.
+				unitPrice = currentCost.WholesaleCostGBP
+			}
+		} else {
+			unitPrice = 0
+		}
+	}
+	// Apply sector pricing if sector is set
+	if sectorID != nil && unitPrice > 0 {
+		var psd models.ProductSectorDiscount
+		if err := h.db.Where("product_id = ? AND sector_id = ? AND (effective_from IS NULL OR effective_from <= ?) AND (effective_to IS NULL OR effective_to >= ?)",
+			productID, *sectorID, priceDate, priceDate).Order("effective_from DESC").First(&psd).Error; err == nil {
+			if psd.SectorPriceGBP > 0 {
+				unitPrice = psd.SectorPriceGBP
+			} else if psd.DiscountPercent > 0 {
+				unitPrice = unitPrice * (1 - psd.DiscountPercent/100)
+				unitPrice = math.Round(unitPrice*100) / 100
+			}
+		}
 	}
+	return unitPrice
+}
+
+func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 	userIDInterface, _ := c.Get("user_id")
 	userID := userIDInterface.(uint)
 
@@ -236,7 +239,7 @@ func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 		sectorID = client.SectorID
 	}
 
-	var subtotal float64
+	var subtotal, cases float64
 	var items []models.WholesaleOrderItem
 	for _, it := range req.Items {
 		var product models.Product
@@ -244,46 +247,8 @@ func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product %d not found", it.ProductID)})
 			return
 		}
-		var unitPrice float64
-		var cost models.ProductCost
-		if err := h.db.Where("product_id = ? AND (effective_from IS NULL OR effective_from <= ?) AND (effective_to IS NULL OR effective_to >= ?)",
-			product.ID, priceDate, priceDate).
-			Order("effective_from DESC").First(&cost).Error; err == nil {
-			// Base price for wholesale order (PO-date "price"):
-			// use the retail online store price for that season.
-			unitPrice = cost.DirectRetailOnlineStorePriceGBP
-			// If retail isn't set for that season, fallback later to current retail.
-		}
-		// If the PO-date retail price isn't set, fall back to current/latest retail.
-		if unitPrice <= 0 {
-			var currentCost models.ProductCost
-			// "Current/latest price" should be the latest configured cost up to now.
-			// Do not require effective_to > now, otherwise future/unset PO dates could yield 0.
-			if err := h.db.Where("product_id = ? AND (effective_from IS NULL OR effective_from <= ?)",
-				product.ID, now).
-				Order("effective_from DESC").First(&currentCost).Error; err == nil {
-				unitPrice = currentCost.DirectRetailOnlineStorePriceGBP
-				if unitPrice <= 0 {
-					// Last resort: if retail is still unset, use wholesale cost.
-					unitPrice = currentCost.WholesaleCostGBP
-				}
-			} else {
-				unitPrice = 0
-			}
-		}
-		// Apply sector pricing if sector is set
-		if sectorID != nil && unitPrice > 0 {
-			var psd models.ProductSectorDiscount
-			if err := h.db.Where("product_id = ? AND sector_id = ? AND (effective_from IS NULL OR effective_from <= ?) AND (effective_to IS NULL OR effective_to >= ?)",
-				product.ID, *sectorID, priceDate, priceDate).Order("effective_from DESC").First(&psd).Error; err == nil {
-				if psd.SectorPriceGBP > 0 {
-					unitPrice = psd.SectorPriceGBP
-				} else if psd.DiscountPercent > 0 {
-					unitPrice = unitPrice * (1 - psd.DiscountPercent/100)
-					unitPrice = math.Round(unitPrice*100) / 100
-				}
-			}
-		}
+		cases += orderLineCases(it.Quantity, product.WholesaleUnitsPerBox)
+		unitPrice := h.wholesaleUnitPrice(product.ID, sectorID, priceDate, now)
 		lineDiscount := it.LineDiscountAmount
 		if lineDiscount < 0 {
 			lineDiscount = 0
@@ -322,9 +287,18 @@ func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 	if orderChannel == "whatsapp" {
 		poNumber = "" // force no client PO when channel is WhatsApp
 	}
-	shippingFee := req.ShippingFee
-	if shippingFee < 0 {
-		shippingFee = 0
+	var quote *shippingQuote
+	if rule := h.shippingRuleFor(client.ID, sectorID); rule != nil {
+		q := quoteShipping(rule, totalNet, cases, h.deliveryPostcode(&client, req.WholesaleClientStoreID))
+		if q.blocking() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": q.minimumMessage(), "shipping_quote": q})
+			return
+		}
+		quote = &q
+	}
+	shippingFee := 0.0
+	if req.ShippingFee != nil && *req.ShippingFee > 0 {
+		shippingFee = *req.ShippingFee
 	}
 	wo := models.WholesaleOrder{
 		OrderNumber:            orderNumber,
@@ -347,13 +321,56 @@ func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 		Notes:                  req.Notes,
 		CreatedAt:              now,
 	}
+	shippingOverridden := false
+	if quote != nil {
+		applyShippingQuote(&wo, *quote)
+		if req.ShippingFee != nil && math.Abs(shippingFee-quote.Fee) > 0.005 {
+			wo.ShippingFee, wo.ShippingFeeBasis, wo.ShippingFeeOverridden = shippingFee, "", true
+			shippingOverridden = true
+		}
+	}
 	if err := h.db.Create(&wo).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.assignOrderRefAndPONumber(&wo, orderChannel)
+
+	for i := range items {
+		items[i].WholesaleOrderID = wo.ID
+	}
+	if err := h.db.Create(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.audit(c, "wholesale_order_create", wo.ID, map[string]interface{}{
+		"order_number": orderNumber, "client_id": req.WholesaleClientID,
+		"po_number": req.PONumber, "item_count": len(items), "subtotal": subtotal,
+	})
+	if shippingOverridden {
+		h.audit(c, "wholesale_order_shipping_fee_override", wo.ID, map[string]interface{}{
+			"calculated_fee": quote.Fee, "basis": quote.Basis, "shipping_fee": shippingFee, "rule_id": quote.RuleID,
+		})
+	}
+
+	var created models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("Store").Preload("User").Preload("Sector").
+		First(&created, wo.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if quote != nil && quote.BelowMinimum {
+		created.MinimumOrderWarning = quote.minimumMessage()
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// assignOrderRefAndPONumber sets the default OC Number (D<id>) on a newly created order and, when no PO was
+// supplied, generates the daily running PO number and order channel.
+func (h *WholesaleOrderHandler) assignOrderRefAndPONumber(wo *models.WholesaleOrder, orderChannel string) {
 	// Default OC Number: D + order_id (user can manually edit to D123.1 etc.)
 	wo.RefNo = fmt.Sprintf("D%d", wo.ID)
-	h.db.Model(&wo).Update("ref_no", wo.RefNo)
+	h.db.Model(wo).Update("ref_no", wo.RefNo)
 
 	// If no PO provided, always generate PO [003][dd][mm][yy] and store channel
 	if wo.PONumber == "" {
@@ -368,48 +385,25 @@ func (h *WholesaleOrderHandler) Create(c *gin.Context) {
 			orderChannel = "whatsapp"
 		}
 		wo.OrderChannel = orderChannel
-		h.db.Model(&wo).Updates(map[string]interface{}{"po_number": wo.PONumber, "order_channel": wo.OrderChannel})
+		h.db.Model(wo).Updates(map[string]interface{}{"po_number": wo.PONumber, "order_channel": wo.OrderChannel})
 	} else {
 		// If client supplied a PO and channel indicates client PO (or blank), normalise to "po"
 		if orderChannel == "po" || (orderChannel == "" && wo.PONumber != "") {
 			wo.OrderChannel = "po"
-			h.db.Model(&wo).Update("order_channel", "po")
+			h.db.Model(wo).Update("order_channel", "po")
 		} else if orderChannel != "" {
 			wo.OrderChannel = orderChannel
-			h.db.Model(&wo).Update("order_channel", orderChannel)
+			h.db.Model(wo).Update("order_channel", orderChannel)
 		}
 	}
-
-	for i := range items {
-		items[i].WholesaleOrderID = wo.ID
-	}
-	if err := h.db.Create(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	h.audit(c, "wholesale_order_create", wo.ID, map[string]interface{}{
-		"order_number": orderNumber, "client_id": req.WholesaleClientID,
-		"po_number": req.PONumber, "item_count": len(items), "subtotal": subtotal,
-	})
-
-	var created models.WholesaleOrder
-	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("Store").Preload("User").Preload("Sector").
-		First(&created, wo.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusCreated, created)
 }
 
 const poAttachmentDocType = "po_attachment"
+const creditNoteDocType = "credit_note"
 const paymentProofDocType = "payment_proof"
 
 // UploadPOAttachments accepts multipart form with files (key "po_attachments"). Saves each to wholesale-docs/po/ and creates a WholesaleOrderDocument with type po_attachment.
 func (h *WholesaleOrderHandler) UploadPOAttachments(c *gin.Context) {
-	if !requirePosUserSupervisorOrManagement(c) {
-		return
-	}
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
@@ -519,6 +513,8 @@ func wholesaleFileContentType(subpath string) string {
 		return "image/heic"
 	case ".zip":
 		return "application/zip"
+	case ".xml":
+		return "application/xml"
 	default:
 		return "application/octet-stream"
 	}
@@ -576,9 +572,6 @@ func (h *WholesaleOrderHandler) uploadWholesaleFile(subpath string, data []byte)
 
 // DeletePOAttachment deletes a document by ID if it belongs to the order and type is po_attachment.
 func (h *WholesaleOrderHandler) DeletePOAttachment(c *gin.Context) {
-	if !requirePosUserSupervisorOrManagement(c) {
-		return
-	}
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
@@ -613,9 +606,6 @@ func (h *WholesaleOrderHandler) DeletePOAttachment(c *gin.Context) {
 
 // DownloadDocument streams a document file with Content-Disposition: attachment for download.
 func (h *WholesaleOrderHandler) DownloadDocument(c *gin.Context) {
-	if !requirePosUserSupervisorOrManagement(c) {
-		return
-	}
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
@@ -635,7 +625,7 @@ func (h *WholesaleOrderHandler) DownloadDocument(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access this document"})
 		return
 	}
-	// Any POS/supervisor/management user authenticated for wholesale routes may download documents
+	// Anyone with wholesale.view may download documents
 	// linked to the order (metadata is already exposed on order payloads; bulk ZIP needs OC/DN/invoice).
 	filename := doc.OriginalFilename
 	if filename == "" {
@@ -723,6 +713,8 @@ func (h *WholesaleOrderHandler) DownloadDocument(c *gin.Context) {
 			contentType = "image/webp"
 		case ".pdf":
 			contentType = "application/pdf"
+		case ".xml":
+			contentType = "application/xml"
 		}
 		c.Header("Content-Type", contentType)
 		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
@@ -735,9 +727,6 @@ func (h *WholesaleOrderHandler) DownloadDocument(c *gin.Context) {
 
 // DownloadLegacyPaymentProof streams the order's payment_proof_url with Content-Disposition for backward compatibility.
 func (h *WholesaleOrderHandler) DownloadLegacyPaymentProof(c *gin.Context) {
-	if !requirePosUserSupervisorOrManagement(c) {
-		return
-	}
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
@@ -806,9 +795,6 @@ func (h *WholesaleOrderHandler) DownloadLegacyPaymentProof(c *gin.Context) {
 
 // UploadPaymentProof accepts multipart form with files (key "payment_proofs"). Saves each as WholesaleOrderDocument type payment_proof. Confirms payment on first upload.
 func (h *WholesaleOrderHandler) UploadPaymentProof(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
@@ -992,9 +978,6 @@ func (h *WholesaleOrderHandler) UploadPaymentProof(c *gin.Context) {
 
 // ConfirmPayment confirms payment received (with or without proof).
 func (h *WholesaleOrderHandler) ConfirmPayment(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var req struct {
 		Amount        *float64 `json:"amount"`
 		TransferDate  *string  `json:"transfer_date"`  // YYYY-MM-DD
@@ -1101,9 +1084,6 @@ func (h *WholesaleOrderHandler) RecentOrderChannels(c *gin.Context) {
 }
 
 func (h *WholesaleOrderHandler) List(c *gin.Context) {
-	role, _ := c.Get("role")
-	r, _ := role.(string)
-
 	status := c.Query("status")
 	storeID := c.Query("store_id")
 	clientFilter := c.Query("client")
@@ -1114,8 +1094,8 @@ func (h *WholesaleOrderHandler) List(c *gin.Context) {
 	orderDateFrom := c.Query("order_date_from")
 	orderDateTo := c.Query("order_date_to")
 
-	// POS user: approved+store_id = packing list; otherwise list orders created by this user (with filters).
-	if r == "pos_user" {
+	// Without wholesale.view_all (POS users): approved+store_id = packing list; otherwise list orders created by this user (with filters).
+	if !hasPermission(c, models.PermWholesaleViewAll) {
 		userIDInterface, _ := c.Get("user_id")
 		userID := userIDInterface.(uint)
 
@@ -1203,10 +1183,6 @@ func (h *WholesaleOrderHandler) List(c *gin.Context) {
 		return
 	}
 
-	if !requireManagementSupervisorOrAdmin(c) {
-		return
-	}
-
 	query := h.db.Model(&models.WholesaleOrder{}).
 		Preload("WholesaleClient").
 		Preload("WholesaleClientStore").
@@ -1288,8 +1264,7 @@ func (h *WholesaleOrderHandler) List(c *gin.Context) {
 }
 
 func (h *WholesaleOrderHandler) Get(c *gin.Context) {
-	role, _ := c.Get("role")
-	// pos_user can only get their own; management/supervisor can get any
+	// Without wholesale.view_all only orders in the user's own scope; otherwise any
 	var wo models.WholesaleOrder
 	q := h.db.Preload("Items.Product").
 		Preload("Items.AssignedStore").
@@ -1301,15 +1276,15 @@ func (h *WholesaleOrderHandler) Get(c *gin.Context) {
 		Preload("Reviewer").
 		Preload("Documents").
 		Preload("Shipments.Store").
-		Preload("Shipments.Items.WholesaleOrderItem.Product")
+		Preload("Shipments.Items.WholesaleOrderItem.Product").
+		Preload("Backorders.Items.Product")
 	if err := q.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
 		return
 	}
-	r, _ := role.(string)
 	userIDInterface, _ := c.Get("user_id")
 	userID := userIDInterface.(uint)
-	if r == "pos_user" && !posUserCanViewWholesaleOrder(h.db, userID, &wo) {
+	if !hasPermission(c, models.PermWholesaleViewAll) && !posUserCanViewWholesaleOrder(h.db, userID, &wo) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to view this order"})
 		return
 	}
@@ -1318,9 +1293,6 @@ func (h *WholesaleOrderHandler) Get(c *gin.Context) {
 }
 
 func (h *WholesaleOrderHandler) Update(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -1338,8 +1310,9 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		RefNo                     *string  `json:"ref_no"`
 		PODate                    *string  `json:"po_date"`
 		OrderDate                 *string  `json:"order_date"`
-		InvoiceDate               *string  `json:"invoice_date"` // YYYY-MM-DD; used on invoice PDF "Date:"
-		ShippingFee               *float64 `json:"shipping_fee"`
+		InvoiceDate               *string  `json:"invoice_date"`                    // YYYY-MM-DD; used on invoice PDF "Date:"
+		ShippingFee               *float64 `json:"shipping_fee"`                    // typed fee; overrides the shipping rule
+		RecalculateShipping       bool     `json:"recalculate_shipping"`            // drop a manual override and use the shipping rule
 		DiscountAmount            *float64 `json:"discount_amount"`                 // order-level discount in £
 		WholesaleClientStore      *uint    `json:"wholesale_client_store_id"`       // shipping address
 		ClearWholesaleClientStore *bool    `json:"clear_wholesale_client_store_id"` // when true, set to nil (use company address)
@@ -1353,6 +1326,14 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if wo.WholesaleQuotationID != nil {
+		for _, ri := range req.Items {
+			if ri.UnitPrice != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unit prices are locked to the accepted quotation"})
+				return
+			}
+		}
+	}
 	changes := map[string]interface{}{}
 	poWasSetEmpty := false
 	if req.PONumber != nil {
@@ -1413,14 +1394,6 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		changes["invoice_date"] = map[string]interface{}{"old": oldDate, "new": *req.InvoiceDate}
 		wo.InvoiceDate = parsePODate(*req.InvoiceDate)
 	}
-	if req.ShippingFee != nil {
-		fee := *req.ShippingFee
-		if fee < 0 {
-			fee = 0
-		}
-		changes["shipping_fee"] = map[string]interface{}{"old": wo.ShippingFee, "new": fee}
-		wo.ShippingFee = fee
-	}
 	if req.DiscountAmount != nil {
 		disc := *req.DiscountAmount
 		if disc < 0 {
@@ -1437,13 +1410,11 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		}
 		wo.AmountDue = wo.TotalNet + wo.VATTotal
 	}
-	if err := h.db.Save(&wo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	// Line changes are applied in memory first so the minimum order check can refuse them before anything is saved.
+	var changedItems []*models.WholesaleOrderItem
+	var items []models.WholesaleOrderItem // the order's lines with the changes applied, when any were sent
 	if len(req.Items) > 0 {
 		var subtotal float64
-		var items []models.WholesaleOrderItem
 		h.db.Where("wholesale_order_id = ?", wo.ID).Find(&items)
 		itemMap := map[uint]*models.WholesaleOrderItem{}
 		for i := range items {
@@ -1477,7 +1448,7 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 					if it.LineTotal < 0 {
 						it.LineTotal = 0
 					}
-					h.db.Save(it)
+					changedItems = append(changedItems, it)
 					itemChanges = append(itemChanges, change)
 				}
 			}
@@ -1485,7 +1456,6 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		if len(itemChanges) > 0 {
 			changes["items"] = itemChanges
 		}
-		h.db.Where("wholesale_order_id = ?", wo.ID).Find(&items)
 		for _, it := range items {
 			subtotal += it.LineTotal
 		}
@@ -1495,10 +1465,60 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 			wo.TotalNet = 0
 		}
 		wo.AmountDue = wo.TotalNet + wo.VATTotal
-		h.db.Save(&wo)
+	}
+	// A typed fee overrides the shipping rule; a rule-calculated fee follows total and delivery address changes.
+	totalsChanged := req.DiscountAmount != nil || len(changedItems) > 0
+	addressChanged := req.WholesaleClientStore != nil || (req.ClearWholesaleClientStore != nil && *req.ClearWholesaleClientStore)
+	var quote *shippingQuote
+	if totalsChanged || addressChanged || req.ShippingFee != nil || req.RecalculateShipping {
+		// Quote from the changed lines, not the saved ones, which are only written below.
+		if q, _, ok := h.quoteOrderShipping(&wo, items); ok {
+			quote = &q
+		}
+	}
+	if quote != nil && totalsChanged && quote.blocking() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": quote.minimumMessage(), "shipping_quote": *quote})
+		return
+	}
+	shippingOverridden := false
+	if req.ShippingFee != nil {
+		fee := *req.ShippingFee
+		if fee < 0 {
+			fee = 0
+		}
+		changes["shipping_fee"] = map[string]interface{}{"old": wo.ShippingFee, "new": fee}
+		switch {
+		case quote == nil:
+			wo.ShippingRuleID, wo.ShippingFeeBasis, wo.ShippingFeeOverridden = nil, "", false
+		case math.Abs(fee-quote.Fee) > 0.005:
+			ruleID := quote.RuleID
+			wo.ShippingRuleID, wo.ShippingFeeBasis, wo.ShippingFeeOverridden = &ruleID, "", true
+			shippingOverridden = true
+		default:
+			applyShippingQuote(&wo, *quote)
+		}
+		wo.ShippingFee = fee
+	} else if quote != nil && (req.RecalculateShipping || (wo.ShippingRuleID != nil && !wo.ShippingFeeOverridden)) {
+		if math.Abs(wo.ShippingFee-quote.Fee) > 0.005 || wo.ShippingFeeOverridden {
+			changes["shipping_fee"] = map[string]interface{}{"old": wo.ShippingFee, "new": quote.Fee, "basis": quote.Basis}
+		}
+		applyShippingQuote(&wo, *quote)
+	}
+	if err := h.db.Save(&wo).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, it := range changedItems {
+		h.db.Save(it)
+	}
+	if shippingOverridden {
+		h.audit(c, "wholesale_order_shipping_fee_override", wo.ID, map[string]interface{}{
+			"calculated_fee": quote.Fee, "basis": quote.Basis, "shipping_fee": wo.ShippingFee, "rule_id": quote.RuleID,
+		})
 	}
 	// If shipping fee or discount was updated and all shipments are completed, re-generate invoice.
-	regenerateInvoice := req.ShippingFee != nil || req.DiscountAmount != nil
+	_, shippingFeeChanged := changes["shipping_fee"]
+	regenerateInvoice := shippingFeeChanged || req.DiscountAmount != nil
 	if regenerateInvoice {
 		var shipments []models.Shipment
 		if err := h.db.Where("wholesale_order_id = ?", wo.ID).Find(&shipments).Error; err == nil {
@@ -1514,33 +1534,19 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 				if invoiceLocked {
 					// Invoice was emailed; skip silent auto-regen on fee/discount update.
 				} else {
-				h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, "invoice").Delete(&models.WholesaleOrderDocument{})
 				var woReload models.WholesaleOrder
-				if err := h.db.Preload("Items.Product").
-					Preload("WholesaleClient").
-					Preload("Store").
-					Preload("User").
-					Preload("Reviewer").
-					Preload("Documents").
-					First(&woReload, wo.ID).Error; err == nil {
-					if invURL, err := h.generateInvoicePDF(&woReload); err == nil && invURL != "" {
-						doc := models.WholesaleOrderDocument{
-							WholesaleOrderID: wo.ID,
-							Type:             "invoice",
-							FileURL:          invURL,
-							CreatedAt:        time.Now(),
-						}
-						if err := h.db.Create(&doc).Error; err != nil {
-							fmt.Printf("Failed to save wholesale invoice after shipping fee update for order %d: %v\n", wo.ID, err)
-						} else {
-							trigger := "shipping_fee_updated"
-							if req.DiscountAmount != nil {
-								trigger = "discount_updated"
-							}
-							h.audit(c, "wholesale_order_generate_invoice", wo.ID, map[string]interface{}{
-								"document_type": "invoice", "trigger": trigger, "file_url": invURL,
-							})
+				if err := h.db.First(&woReload, wo.ID).Error; err == nil {
+					if err := h.ensureInvoiceNumber(c, &woReload); err != nil {
+						fmt.Printf("Failed to assign invoice number for wholesale order %d: %v\n", wo.ID, err)
+					} else {
+						trigger := "shipping_fee_updated"
+						if req.DiscountAmount != nil {
+							trigger = "discount_updated"
 						}
+						h.queueOrderDocument(c, orderDocumentJob{
+							OrderID: wo.ID, DocType: "invoice", Replace: true, AuditAction: "wholesale_order_generate_invoice",
+							Trigger: trigger,
+						})
 					}
 				}
 				}
@@ -1568,14 +1574,14 @@ func (h *WholesaleOrderHandler) Update(c *gin.Context) {
 		Preload("Reviewer").Preload("Documents").Preload("Shipments.Store").
 		Preload("Shipments.Items.WholesaleOrderItem.Product").
 		First(&wo, wo.ID)
+	if quote != nil && totalsChanged && quote.BelowMinimum {
+		wo.MinimumOrderWarning = quote.minimumMessage()
+	}
 	c.JSON(http.StatusOK, wo)
 }
 
 // SetInvoiceSentAt records or clears the date the invoice was sent to the client (allowed even when payment is confirmed).
 func (h *WholesaleOrderHandler) SetInvoiceSentAt(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -1637,9 +1643,6 @@ func (h *WholesaleOrderHandler) SetInvoiceSentAt(c *gin.Context) {
 }
 
 func (h *WholesaleOrderHandler) Approve(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	reviewerIDInterface, _ := c.Get("user_id")
 	reviewerID := reviewerIDInterface.(uint)
 
@@ -1655,6 +1658,44 @@ func (h *WholesaleOrderHandler) Approve(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Order is not pending approval"})
 		return
 	}
+	var req struct {
+		OverrideCredit bool   `json:"override_credit"`
+		OverrideReason string `json:"override_reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Credit control: on-stop clients and orders over the credit limit need a management override with a reason.
+	var client models.WholesaleClient
+	if err := h.db.First(&client, wo.WholesaleClientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wholesale client not found"})
+		return
+	}
+	credit, err := checkWholesaleOrderCredit(h.db, &wo, &client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check client credit, order not approved: " + err.Error()})
+		return
+	}
+	creditOverridden := false
+	if credit.blocking() {
+		if !req.OverrideCredit {
+			c.JSON(http.StatusConflict, gin.H{"error": credit.message(), "credit": credit, "override_allowed": hasPermission(c, models.PermWholesaleCreditOverride)})
+			return
+		}
+		if !hasPermission(c, models.PermWholesaleCreditOverride) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Overriding a credit hold requires " + models.PermWholesaleCreditOverride})
+			return
+		}
+		if strings.TrimSpace(req.OverrideReason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "override_reason is required to override a credit hold"})
+			return
+		}
+		creditOverridden = true
+	}
 
 	oldStatus := string(wo.Status)
 	now := time.Now()
@@ -1668,34 +1709,31 @@ func (h *WholesaleOrderHandler) Approve(c *gin.Context) {
 	h.audit(c, "wholesale_order_approve", wo.ID, map[string]interface{}{
 		"old_status": oldStatus, "new_status": string(wo.Status),
 	})
+	if credit.OverLimit && !creditOverridden {
+		h.audit(c, "wholesale_order_credit_warning", wo.ID, map[string]interface{}{
+			"credit_limit": credit.CreditLimit, "exposure": credit.Exposure, "order_total": credit.OrderTotal,
+		})
+	}
+	if creditOverridden {
+		h.audit(c, "wholesale_order_credit_override", wo.ID, map[string]interface{}{
+			"reason": strings.TrimSpace(req.OverrideReason), "on_stop": credit.OnStop, "credit_limit": credit.CreditLimit,
+			"exposure": credit.Exposure, "order_total": credit.OrderTotal,
+		})
+	}
 	h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Store").Preload("User").Preload("Reviewer").Preload("Documents").First(&wo, wo.ID)
+	emitWebhookEvent(h.db, webhook.EventWholesaleOrderApproved, wo)
 
-	// Best-effort: generate order confirmation PDF and store document record.
-	if url, err := h.generateOrderConfirmationPDF(&wo); err != nil {
-		fmt.Printf("Failed to generate wholesale order confirmation PDF for order %d: %v\n", wo.ID, err)
-	} else if url != "" {
-		doc := models.WholesaleOrderDocument{
-			WholesaleOrderID: wo.ID,
-			Type:             "order_confirmation",
-			FileURL:          url,
-			CreatedAt:        time.Now(),
-		}
-		if err := h.db.Create(&doc).Error; err != nil {
-			fmt.Printf("Failed to save wholesale order document record for order %d: %v\n", wo.ID, err)
-		} else {
-			h.audit(c, "wholesale_order_generate_oc", wo.ID, map[string]interface{}{
-				"document_type": "order_confirmation", "trigger": "approve", "file_url": url,
-			})
-			h.db.Preload("Documents").First(&wo, wo.ID)
-		}
+	// The order confirmation PDF is rendered in the background; poll document_job_id for it.
+	wo.DocumentJobID = h.queueOrderDocument(c, orderDocumentJob{
+		OrderID: wo.ID, DocType: "order_confirmation", AuditAction: "wholesale_order_generate_oc", Trigger: "approve",
+	})
+	if credit.OverLimit && !creditOverridden {
+		wo.CreditWarning = credit.message()
 	}
 	c.JSON(http.StatusOK, wo)
 }
 
 func (h *WholesaleOrderHandler) CompleteAssignment(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -1729,9 +1767,6 @@ type RejectRequest struct {
 }
 
 func (h *WholesaleOrderHandler) Reject(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	reviewerIDInterface, _ := c.Get("user_id")
 	reviewerID := reviewerIDInterface.(uint)
 
@@ -1764,6 +1799,11 @@ func (h *WholesaleOrderHandler) Reject(c *gin.Context) {
 	h.audit(c, "wholesale_order_reject", wo.ID, map[string]interface{}{
 		"old_status": oldStatus, "new_status": string(wo.Status), "reason": req.Reason,
 	})
+	if err := h.cascadeBackorderStatus(c, wo.ID, []string{models.WholesaleOrderStatusBackorder}, models.WholesaleOrderStatusRejected,
+		"Original order "+wholesaleOrderRefLabel(&wo)+" rejected"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Order rejected but its backorders were not: " + err.Error()})
+		return
+	}
 	h.db.Preload("Items.Product").
 		Preload("Items.AssignedStore").
 		Preload("WholesaleClient").
@@ -1781,9 +1821,6 @@ func (h *WholesaleOrderHandler) Reject(c *gin.Context) {
 
 // Resubmit changes a rejected order back to pending_approval so it can be endorsed again.
 func (h *WholesaleOrderHandler) Resubmit(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -1805,6 +1842,12 @@ func (h *WholesaleOrderHandler) Resubmit(c *gin.Context) {
 	h.audit(c, "wholesale_order_resubmit", wo.ID, map[string]interface{}{
 		"old_status": oldStatus, "new_status": string(wo.Status),
 	})
+	// Backorders can only have been rejected along with this order, so they come back with it.
+	if err := h.cascadeBackorderStatus(c, wo.ID, []string{models.WholesaleOrderStatusRejected}, models.WholesaleOrderStatusBackorder,
+		"Original order "+wholesaleOrderRefLabel(&wo)+" resubmitted"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Order resubmitted but its backorders were not: " + err.Error()})
+		return
+	}
 	h.db.Preload("Items.Product").
 		Preload("WholesaleClient").
 		Preload("Store").
@@ -1816,10 +1859,6 @@ func (h *WholesaleOrderHandler) Resubmit(c *gin.Context) {
 
 // Archive soft-deletes an order by setting status to deleted. Completed orders cannot be deleted.
 func (h *WholesaleOrderHandler) Archive(c *gin.Context) {
-	if !requireManagementSupervisorOrAdmin(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.Preload("Documents").Preload("Shipments").First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -1845,9 +1884,21 @@ func (h *WholesaleOrderHandler) Archive(c *gin.Context) {
 		return
 	}
 	wo.Status = models.WholesaleOrderStatusDeleted
-	h.audit(c, "wholesale_order_delete", wo.ID, map[string]interface{}{
+	deleteAudit := map[string]interface{}{
 		"status": map[string]interface{}{"old": oldStatus, "new": models.WholesaleOrderStatusDeleted},
-	})
+	}
+	if wo.InvoiceNumber != "" {
+		// The number stays in the sequence, voided, so the invoice register has no unexplained gap.
+		if err := voidDocumentNumber(h.db, wo.InvoiceNumber, "Order "+wholesaleOrderRefLabel(&wo)+" deleted", contextUserID(c)); err == nil {
+			deleteAudit["invoice_number_voided"] = wo.InvoiceNumber
+		}
+	}
+	h.audit(c, "wholesale_order_delete", wo.ID, deleteAudit)
+	if err := h.cascadeBackorderStatus(c, wo.ID, []string{models.WholesaleOrderStatusBackorder, models.WholesaleOrderStatusRejected},
+		models.WholesaleOrderStatusDeleted, "Original order "+wholesaleOrderRefLabel(&wo)+" deleted"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Order deleted but its backorders were not: " + err.Error()})
+		return
+	}
 
 	h.db.Preload("Items.Product").
 		Preload("WholesaleClient").
@@ -1865,9 +1916,6 @@ func (h *WholesaleOrderHandler) Archive(c *gin.Context) {
 
 // RegenerateOrderConfirmation re-generates the order confirmation PDF and replaces the existing one.
 func (h *WholesaleOrderHandler) RegenerateOrderConfirmation(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Store").Preload("User").Preload("Reviewer").Preload("Documents").
 		First(&wo, c.Param("id")).Error; err != nil {
@@ -1888,41 +1936,22 @@ func (h *WholesaleOrderHandler) RegenerateOrderConfirmation(c *gin.Context) {
 	if h.rejectDocumentRegenUnlessUnlocked(c, wo.ID, "order_confirmation", nil, regenBody.UnlockAfterEmail) {
 		return
 	}
-	// Remove existing order_confirmation documents
-	h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, "order_confirmation").Delete(&models.WholesaleOrderDocument{})
-	// Generate new PDF
-	url, err := h.generateOrderConfirmationPDF(&wo)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	doc := models.WholesaleOrderDocument{
-		WholesaleOrderID: wo.ID,
-		Type:             "order_confirmation",
-		FileURL:          url,
-		CreatedAt:        time.Now(),
-	}
-	if err := h.db.Create(&doc).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// The new PDF replaces the existing order confirmation when the job finishes.
+	jobID := h.queueOrderDocument(c, orderDocumentJob{
+		OrderID: wo.ID, DocType: "order_confirmation", Replace: true, AuditAction: "wholesale_order_regenerate_oc",
+		UnlockAfterEmail: regenBody.UnlockAfterEmail,
+	})
+	if jobID == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue order confirmation"})
 		return
 	}
-	ocAudit := map[string]interface{}{
-		"document_type": "order_confirmation", "file_url": url,
-	}
-	if regenBody.UnlockAfterEmail {
-		ocAudit["unlock_after_email"] = true
-	}
-	h.audit(c, "wholesale_order_regenerate_oc", wo.ID, ocAudit)
-	h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Store").Preload("User").Preload("Documents").First(&wo, wo.ID)
-	c.JSON(http.StatusOK, wo)
+	wo.DocumentJobID = jobID
+	c.JSON(http.StatusAccepted, wo)
 }
 
 // GenerateInvoice generates (or regenerates) the invoice PDF for a wholesale order,
 // but only if all shipments for the order have been completed.
 func (h *WholesaleOrderHandler) GenerateInvoice(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var wo models.WholesaleOrder
 	if err := h.db.Preload("Items.Product").
 		Preload("WholesaleClient").
@@ -1974,30 +2003,19 @@ func (h *WholesaleOrderHandler) GenerateInvoice(c *gin.Context) {
 			})
 		}
 	}
-	// Remove existing invoice documents, then generate a fresh one
-	h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, "invoice").Delete(&models.WholesaleOrderDocument{})
-	url, err := h.generateInvoicePDF(&wo)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.ensureInvoiceNumber(c, &wo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign invoice number: " + err.Error()})
 		return
 	}
-	doc := models.WholesaleOrderDocument{
-		WholesaleOrderID: wo.ID,
-		Type:             "invoice",
-		FileURL:          url,
-		CreatedAt:        time.Now(),
-	}
-	if err := h.db.Create(&doc).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// The fresh invoice PDF (and UBL e-invoice, if one exists) replaces the current one when the job finishes.
+	jobID := h.queueOrderDocument(c, orderDocumentJob{
+		OrderID: wo.ID, DocType: "invoice", Replace: true, AuditAction: "wholesale_order_generate_invoice",
+		UnlockAfterEmail: invRegenBody.UnlockAfterEmail,
+	})
+	if jobID == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue invoice"})
 		return
 	}
-	invAudit := map[string]interface{}{
-		"document_type": "invoice", "file_url": url,
-	}
-	if invRegenBody.UnlockAfterEmail {
-		invAudit["unlock_after_email"] = true
-	}
-	h.audit(c, "wholesale_order_generate_invoice", wo.ID, invAudit)
 	h.db.Preload("Items.Product").
 		Preload("WholesaleClient").
 		Preload("WholesaleClientStore").
@@ -2005,7 +2023,8 @@ func (h *WholesaleOrderHandler) GenerateInvoice(c *gin.Context) {
 		Preload("User").
 		Preload("Documents").
 		First(&wo, wo.ID)
-	c.JSON(http.StatusOK, wo)
+	wo.DocumentJobID = jobID
+	c.JSON(http.StatusAccepted, wo)
 }
 
 // ordinalDay returns "1st", "2nd", "3rd", "18th", etc.
@@ -2090,10 +2109,19 @@ func (h *WholesaleOrderHandler) generateInvoicePDF(wo *models.WholesaleOrder) (s
 
 // generateWholesaleOrderPDF builds either an order confirmation or invoice PDF. docType is "order_confirmation" or "invoice".
 func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOrder, docType string) (string, error) {
+	return h.renderWholesaleOrderPDF(wo, docType, nil)
+}
+
+// renderWholesaleOrderPDF draws the shared OC/invoice layout. docType may also be "quotation" or "proforma"
+// (wo is then built from a WholesaleQuotation); validUntil is printed under the date when set.
+func (h *WholesaleOrderHandler) renderWholesaleOrderPDF(wo *models.WholesaleOrder, docType string, validUntil *time.Time) (string, error) {
 	if h.cfg == nil {
 		return "", fmt.Errorf("missing config for PDF generation")
 	}
 	isInvoice := docType == "invoice"
+	isQuotation := docType == models.WholesaleQuotationTypeQuotation
+	isProforma := docType == models.WholesaleQuotationTypeProforma
+	isCreditNote := docType == creditNoteDocType
 	if wo.RefNo == "" {
 		wo.RefNo = fmt.Sprintf("%d", wo.ID)
 	}
@@ -2203,7 +2231,7 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 	pdf.SetMargins(margin, margin, margin)
 	pdf.SetAutoPageBreak(true, 22)
 	itemsPerPage := 10
-	if isInvoice {
+	if isInvoice || isProforma {
 		itemsPerPage = 9
 	}
 
@@ -2300,18 +2328,32 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 		barW := 75.0
 		barX := pageW - margin - barW
 		barY := 15.0
-		if isInvoice {
+		switch {
+		case isInvoice:
 			pdf.SetFillColor(0, 128, 0)
-		} else {
+		case isQuotation:
+			pdf.SetFillColor(0, 51, 102)
+		case isProforma:
+			pdf.SetFillColor(204, 102, 0)
+		case isCreditNote:
+			pdf.SetFillColor(178, 34, 34)
+		default:
 			pdf.SetFillColor(0, 0, 0)
 		}
 		pdf.Rect(barX, barY, barW, 9, "F")
 		pdf.SetTextColor(255, 255, 255)
 		pdf.SetFont(fontBold, "B", 12)
 		pdf.SetXY(barX, barY+2)
-		if isInvoice {
+		switch {
+		case isInvoice:
 			pdf.CellFormat(barW, 6, "INVOICE", "", 1, "C", false, 0, "")
-		} else {
+		case isQuotation:
+			pdf.CellFormat(barW, 6, "QUOTATION", "", 1, "C", false, 0, "")
+		case isProforma:
+			pdf.CellFormat(barW, 6, "PRO-FORMA INVOICE", "", 1, "C", false, 0, "")
+		case isCreditNote:
+			pdf.CellFormat(barW, 6, "CREDIT NOTE", "", 1, "C", false, 0, "")
+		default:
 			pdf.CellFormat(barW, 6, "ORDER CONFIRMATION", "", 1, "C", false, 0, "")
 		}
 		pdf.SetTextColor(0, 0, 0)
@@ -2320,9 +2362,24 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 		pdf.SetXY(barX, poY)
 		pdf.SetFont(fontBold, "B", 10)
 		docRef := wo.PONumber + " / " + wo.RefNo
-		if isInvoice {
+		if wo.PONumber == "" {
+			docRef = wo.RefNo
+		}
+		// Invoices from the number series show the order ref on its own row below the date.
+		orderRef := ""
+		if isInvoice && wo.InvoiceNumber != "" {
+			orderRef, docRef = docRef, wo.InvoiceNumber
+		}
+		switch {
+		case isInvoice:
 			pdf.CellFormat(keyW, 5, "Invoice No:", "", 0, "L", false, 0, "")
-		} else {
+		case isQuotation:
+			pdf.CellFormat(keyW, 5, "Quote No:", "", 0, "L", false, 0, "")
+		case isProforma:
+			pdf.CellFormat(keyW, 5, "Pro-forma No:", "", 0, "L", false, 0, "")
+		case isCreditNote:
+			pdf.CellFormat(keyW, 5, "Credit Note No:", "", 0, "L", false, 0, "")
+		default:
 			pdf.CellFormat(keyW, 5, "PO/OC No:", "", 0, "L", false, 0, "")
 		}
 		pdf.SetFont(fontName, "", 10)
@@ -2332,6 +2389,20 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 		pdf.CellFormat(keyW, 5, "Date:", "", 0, "L", false, 0, "")
 		pdf.SetFont(fontName, "", 10)
 		pdf.CellFormat(barW-keyW, 5, dateStr, "", 1, "L", false, 0, "")
+		if orderRef != "" {
+			pdf.SetX(barX)
+			pdf.SetFont(fontBold, "B", 10)
+			pdf.CellFormat(keyW, 5, "PO/OC No:", "", 0, "L", false, 0, "")
+			pdf.SetFont(fontName, "", 10)
+			pdf.CellFormat(barW-keyW, 5, orderRef, "", 1, "L", false, 0, "")
+		}
+		if validUntil != nil {
+			pdf.SetX(barX)
+			pdf.SetFont(fontBold, "B", 10)
+			pdf.CellFormat(keyW, 5, "Valid Until:", "", 0, "L", false, 0, "")
+			pdf.SetFont(fontName, "", 10)
+			pdf.CellFormat(barW-keyW, 5, ordinalDay(validUntil.Day())+" "+validUntil.Format("January 2006"), "", 1, "L", false, 0, "")
+		}
 		companyY := 15.0 + logoH + 6
 		if logoH <= 0 {
 			companyY = 15
@@ -2753,8 +2824,39 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 
 	yBottomRow := pdf.GetY()
 
-	// Left: Internal Use box for order confirmation; Bank + THANK YOU section for invoice (same position)
-	if !isInvoice {
+	// Left: Internal Use box for order confirmation; Bank + THANK YOU section for invoice/pro-forma (same position);
+	// validity note for quotation.
+	if isQuotation {
+		pdf.SetXY(internalBoxX, yBottomRow+3)
+		pdf.SetTextColor(100, 100, 100)
+		pdf.SetFont(fontName, "", 7)
+		pdf.CellFormat(internalBoxW, 4, "Quotation terms:", "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont(fontName, "", 8)
+		validLine := "Prices are subject to change without notice."
+		if validUntil != nil {
+			validLine = "Prices are valid until " + ordinalDay(validUntil.Day()) + " " + validUntil.Format("January 2006") + "."
+		}
+		pdf.CellFormat(internalBoxW, 4, validLine, "", 1, "L", false, 0, "")
+		pdf.CellFormat(internalBoxW, 4, "Prices are quoted in GBP and subject to stock availability.", "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 180)
+		pdf.SetFont(fontBold, "B", 10)
+		pdf.CellFormat(internalBoxW, 6, "THANK YOU", "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	} else if isCreditNote {
+		pdf.SetXY(internalBoxX, yBottomRow+3)
+		pdf.SetTextColor(100, 100, 100)
+		pdf.SetFont(fontName, "", 7)
+		pdf.CellFormat(internalBoxW, 4, "Credit note:", "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont(fontName, "", 8)
+		for _, line := range strings.Split(wo.Notes, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				pdf.CellFormat(internalBoxW, 4, line, "", 1, "L", false, 0, "")
+			}
+		}
+		pdf.CellFormat(internalBoxW, 4, "This amount will be offset against your account.", "", 1, "L", false, 0, "")
+	} else if !isInvoice && !isProforma {
 		pdf.SetXY(internalBoxX, yBottomRow+3)
 		pdf.SetFillColor(0, 0, 0)
 		pdf.SetTextColor(255, 255, 255)
@@ -2770,6 +2872,13 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 		pdf.SetFont(fontName, "", 8)
 		pdf.CellFormat(internalColW, rowHInternal, reviewerName, "1", 0, "C", false, 0, "")
 		pdf.CellFormat(internalColW, rowHInternal, reviewDate, "1", 1, "C", false, 0, "")
+		// How the shipping fee was worked out, when it came from the client's or sector's shipping rule.
+		if basis := strings.TrimSpace(wo.ShippingFeeBasis); basis != "" && !wo.ShippingFeeOverridden {
+			pdf.SetXY(internalBoxX, pdf.GetY()+2)
+			pdf.SetTextColor(100, 100, 100)
+			pdf.SetFont(fontName, "", 7)
+			pdf.MultiCell(internalBoxW, 3.5, "Shipping: "+basis, "", "L", false)
+		}
 		pdf.SetTextColor(0, 0, 0)
 	} else {
 		// Invoice: payment info (free text up to 5 lines) or legacy bank fields + THANK YOU
@@ -2842,7 +2951,11 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 	pdf.CellFormat(totLabelW, orderTotalH, "Shipping Fee :", "1", 0, "R", false, 0, "")
 	drawGBP(totValueW, orderTotalH, "1", orderShippingFee, 1)
 	pdf.SetXY(totX, yBottomRow+5*orderTotalH)
-	pdf.CellFormat(totLabelW, orderTotalH, "Amount Due :", "1", 0, "R", false, 0, "")
+	if isCreditNote {
+		pdf.CellFormat(totLabelW, orderTotalH, "Total Credit :", "1", 0, "R", false, 0, "")
+	} else {
+		pdf.CellFormat(totLabelW, orderTotalH, "Amount Due :", "1", 0, "R", false, 0, "")
+	}
 	drawGBP(totValueW, orderTotalH, "1", grandTotal, 1)
 
 	var buf bytes.Buffer
@@ -2852,6 +2965,8 @@ func (h *WholesaleOrderHandler) generateWholesaleOrderPDF(wo *models.WholesaleOr
 	var filename string
 	if isInvoice {
 		filename = fmt.Sprintf("%s-invoice-%d.pdf", wo.OrderNumber, time.Now().UnixNano())
+	} else if isQuotation || isProforma || isCreditNote {
+		filename = fmt.Sprintf("%s-%s-%d.pdf", wo.OrderNumber, docType, time.Now().UnixNano())
 	} else {
 		filename = fmt.Sprintf("%s-order-confirmation-%d.pdf", wo.OrderNumber, time.Now().UnixNano())
 	}
@@ -2968,7 +3083,7 @@ type UpdateShipmentRequest struct {
 	DeliveryDate   *string `json:"delivery_date"` // optional YYYY-MM-DD; if empty string, clears the date
 }
 
-// UpdateShipment updates courier and/or tracking number (management/supervisor or POS for their store).
+// UpdateShipment updates courier and/or tracking number (wholesale.dispatch).
 func (h *WholesaleOrderHandler) UpdateShipment(c *gin.Context) {
 	var s models.Shipment
 	if err := h.db.First(&s, c.Param("id")).Error; err != nil {
@@ -3024,6 +3139,7 @@ func (h *WholesaleOrderHandler) UpdateShipment(c *gin.Context) {
 		s.Status = models.ShipmentStatusShipped
 		if err := h.db.Model(&s).Update("status", s.Status).Error; err == nil {
 			changes["status"] = map[string]interface{}{"old": oldStatus, "new": s.Status}
+			emitShipmentShipped(h.db, s)
 		}
 	}
 	if len(changes) > 0 {
@@ -3088,6 +3204,9 @@ func (h *WholesaleOrderHandler) UpdateShipmentStatus(c *gin.Context) {
 		"old_status":  oldStatus,
 		"new_status":  newStatus,
 	})
+	if newStatus == models.ShipmentStatusShipped {
+		emitShipmentShipped(h.db, s)
+	}
 	if newStatus == models.ShipmentStatusCompleted {
 		h.maybeGenerateInvoiceWhenAllShipmentsComplete(c, s.WholesaleOrderID)
 	}
@@ -3247,55 +3366,56 @@ func (h *WholesaleOrderHandler) maybeGenerateInvoiceWhenAllShipmentsComplete(c *
 	if err := h.db.Where("wholesale_order_id = ? AND type = ?", orderID, "invoice").First(&existing).Error; err == nil {
 		return // already has invoice
 	}
-	if invURL, err := h.generateInvoicePDF(&wo); err == nil && invURL != "" {
-		doc := models.WholesaleOrderDocument{
-			WholesaleOrderID: wo.ID,
-			Type:             "invoice",
-			FileURL:          invURL,
-			CreatedAt:        time.Now(),
-		}
-		if err := h.db.Create(&doc).Error; err == nil {
-			h.audit(c, "wholesale_order_generate_invoice", wo.ID, map[string]interface{}{
-				"document_type": "invoice", "trigger": "all_shipments_completed", "file_url": invURL,
-			})
-		}
+	if err := h.ensureInvoiceNumber(c, &wo); err != nil {
+		fmt.Printf("Failed to assign invoice number for wholesale order %d: %v\n", wo.ID, err)
+		return
 	}
+	h.queueOrderDocument(c, orderDocumentJob{
+		OrderID: wo.ID, DocType: "invoice", AuditAction: "wholesale_order_generate_invoice", Trigger: "all_shipments_completed",
+	})
 }
 
 // UploadSignedDeliveryNote accepts multipart form with file (key "signed_delivery_note"). Completes the shipment.
 func (h *WholesaleOrderHandler) UploadSignedDeliveryNote(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var s models.Shipment
 	if err := h.db.First(&s, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
 		return
 	}
-	if h.abortIfWholesaleOrderDeletedByID(c, s.WholesaleOrderID) {
+	if !h.saveSignedDeliveryNote(c, &s) {
 		return
 	}
+	h.db.Preload("Store").Preload("WholesaleOrder").Preload("Items.WholesaleOrderItem.Product").First(&s, s.ID)
+	c.JSON(http.StatusOK, s)
+}
+
+// saveSignedDeliveryNote stores the uploaded signed delivery note (form key signed_delivery_note) on s and
+// completes the shipment. It writes the error response and returns false on failure.
+func (h *WholesaleOrderHandler) saveSignedDeliveryNote(c *gin.Context, s *models.Shipment) bool {
+	if h.abortIfWholesaleOrderDeletedByID(c, s.WholesaleOrderID) {
+		return false
+	}
 	if h.rejectOrderUploadUnlessUnlocked(c, s.WholesaleOrderID, parseUnlockAfterCompletion(c)) {
-		return
+		return false
 	}
 	if strings.TrimSpace(s.DeliveryNotePDFURL) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Generate a delivery note (Start shipment) before uploading a signed copy"})
-		return
+		return false
 	}
 	isReplace := strings.TrimSpace(s.SignedDeliveryNotePDFURL) != ""
 	if isReplace {
 		if !models.ShipmentStatusIsCompleted(s.Status) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Can only replace delivery proof on a completed shipment"})
-			return
+			return false
 		}
 	} else if !models.ShipmentStatusAllowsDeliveryProofUpload(s.Status) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment must be packed or shipped before uploading delivery proof"})
-		return
+		return false
 	}
 	fh, err := c.FormFile("signed_delivery_note")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided (use form key 'signed_delivery_note'): " + err.Error()})
-		return
+		return false
 	}
 	ext := strings.ToLower(filepath.Ext(fh.Filename))
 	allowedExt := map[string]bool{
@@ -3303,31 +3423,31 @@ func (h *WholesaleOrderHandler) UploadSignedDeliveryNote(c *gin.Context) {
 	}
 	if ext == "" || !allowedExt[ext] {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be PDF or image (.pdf, .png, .jpg, .jpeg, .gif, .webp, .heic)"})
-		return
+		return false
 	}
 	data, err := readMultipartFileBytes(fh)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file: " + err.Error()})
-		return
+		return false
 	}
 	if len(data) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file is empty"})
-		return
+		return false
 	}
 	safeName := fmt.Sprintf("signed-dn-%d-%d%s", s.ID, time.Now().UnixNano(), ext)
 	url, err := h.uploadWholesaleFile("signed-dn/"+safeName, data)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file: " + err.Error()})
-		return
+		return false
 	}
 	oldSignedURL := strings.TrimSpace(s.SignedDeliveryNotePDFURL)
 	s.SignedDeliveryNotePDFURL = url
 	if !isReplace {
 		s.Status = models.ShipmentStatusCompleted
 	}
-	if err := h.db.Save(&s).Error; err != nil {
+	if err := h.db.Save(s).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return false
 	}
 	if isReplace {
 		h.audit(c, "wholesale_shipment_replace_signed_dn", s.WholesaleOrderID, map[string]interface{}{
@@ -3339,8 +3459,7 @@ func (h *WholesaleOrderHandler) UploadSignedDeliveryNote(c *gin.Context) {
 		})
 		h.maybeGenerateInvoiceWhenAllShipmentsComplete(c, s.WholesaleOrderID)
 	}
-	h.db.Preload("Store").Preload("WholesaleOrder").Preload("Items.WholesaleOrderItem.Product").First(&s, s.ID)
-	c.JSON(http.StatusOK, s)
+	return true
 }
 
 // CompletePacking generates the delivery note PDF. POS packing sets status to packed;
@@ -3373,6 +3492,12 @@ func (h *WholesaleOrderHandler) CompletePacking(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment is already packed"})
 		return
 	}
+	var openSessionIDs []uint
+	h.db.Model(&models.PackingSession{}).Where("shipment_id = ? AND status = ?", s.ID, models.PackingSessionStatusOpen).Pluck("id", &openSessionIDs)
+	if len(openSessionIDs) > 0 && !body.ForceComplete {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Complete or cancel the barcode packing session for this shipment first"})
+		return
+	}
 	// Optional delivery date (when completing shipment)
 	if body.DeliveryDate != "" {
 		if t, err := time.Parse("2006-01-02", body.DeliveryDate); err == nil {
@@ -3387,6 +3512,32 @@ func (h *WholesaleOrderHandler) CompletePacking(c *gin.Context) {
 		}
 		itemCaseQty[e.WholesaleOrderItemID] = e.CaseQty
 	}
+	if err := h.packShipment(c, &s, itemCaseQty, body.ForceComplete, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Force-completing skips barcode verification, so a session left open is cancelled rather than completed.
+	for _, id := range openSessionIDs {
+		res := h.db.Model(&models.PackingSession{}).Where("id = ? AND status = ?", id, models.PackingSessionStatusOpen).
+			Update("status", models.PackingSessionStatusCancelled)
+		if res.Error != nil {
+			fmt.Printf("Failed to cancel packing session %d on force complete of shipment %d: %v\n", id, s.ID, res.Error)
+			continue
+		}
+		if res.RowsAffected > 0 {
+			h.audit(c, "wholesale_packing_session_cancel", s.WholesaleOrderID, map[string]interface{}{
+				"shipment_id": s.ID, "packing_session_id": id, "reason": "force_complete",
+			})
+		}
+	}
+	h.db.Preload("Store").Preload("WholesaleOrder").Preload("Items.WholesaleOrderItem.Product").First(&s, s.ID)
+	c.JSON(http.StatusOK, s)
+}
+
+// packShipment applies per-line case quantities, generates the delivery note and marks the shipment packed (or
+// completed when forceComplete). s must be loaded with Store, WholesaleOrder (client and client store) and
+// Items.WholesaleOrderItem. auditExtra is merged into the audit entry.
+func (h *WholesaleOrderHandler) packShipment(c *gin.Context, s *models.Shipment, itemCaseQty map[uint]float64, forceComplete bool, auditExtra map[string]interface{}) error {
 	for i := range s.Items {
 		if cq, ok := itemCaseQty[s.Items[i].WholesaleOrderItemID]; ok {
 			s.Items[i].CaseQty = cq
@@ -3397,37 +3548,35 @@ func (h *WholesaleOrderHandler) CompletePacking(c *gin.Context) {
 	for i := range s.Items {
 		h.db.Model(&s.Items[i].WholesaleOrderItem).Association("Product").Find(&s.Items[i].WholesaleOrderItem.Product)
 	}
-	url, err := h.generateDeliveryNotePDF(&s)
+	url, err := h.generateDeliveryNotePDF(s)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate delivery note: " + err.Error()})
-		return
+		return fmt.Errorf("Failed to generate delivery note: %w", err)
 	}
 	oldStatus := string(s.Status)
 	s.DeliveryNotePDFURL = url
-	if body.ForceComplete {
+	if forceComplete {
 		s.Status = models.ShipmentStatusCompleted
 	} else {
 		s.Status = models.ShipmentStatusPacked
 	}
-	if err := h.db.Save(&s).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if err := h.db.Save(s).Error; err != nil {
+		return err
 	}
-	h.audit(c, "wholesale_shipment_complete_packing", s.WholesaleOrderID, map[string]interface{}{
-		"shipment_id": s.ID, "old_status": oldStatus, "new_status": string(s.Status), "file_url": url, "force_complete": body.ForceComplete,
-	})
-	if body.ForceComplete {
+	changes := map[string]interface{}{
+		"shipment_id": s.ID, "old_status": oldStatus, "new_status": string(s.Status), "file_url": url, "force_complete": forceComplete,
+	}
+	for k, v := range auditExtra {
+		changes[k] = v
+	}
+	h.audit(c, "wholesale_shipment_complete_packing", s.WholesaleOrderID, changes)
+	if forceComplete {
 		h.maybeGenerateInvoiceWhenAllShipmentsComplete(c, s.WholesaleOrderID)
 	}
-	h.db.Preload("Store").Preload("WholesaleOrder").Preload("Items.WholesaleOrderItem.Product").First(&s, s.ID)
-	c.JSON(http.StatusOK, s)
+	return nil
 }
 
 // RegenerateDeliveryNote re-generates the delivery note PDF for a shipment and updates delivery_note_pdf_url.
 func (h *WholesaleOrderHandler) RegenerateDeliveryNote(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var s models.Shipment
 	if err := h.db.Preload("Store").
 		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
@@ -3483,9 +3632,6 @@ func (h *WholesaleOrderHandler) RegenerateDeliveryNote(c *gin.Context) {
 
 // UpdateShipmentCaseQty updates case/box qty per item and regenerates the delivery note PDF for completed shipments.
 func (h *WholesaleOrderHandler) UpdateShipmentCaseQty(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	var s models.Shipment
 	if err := h.db.Preload("Store").
 		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
@@ -3568,11 +3714,22 @@ func (h *WholesaleOrderHandler) UpdateShipmentCaseQty(c *gin.Context) {
 	c.JSON(http.StatusOK, s)
 }
 
-// generateDeliveryNotePDF builds a delivery note PDF (same style as order confirmation): company header, client/delivery block,
-// "Delivery Note" title, row with Account, PO number, Delivery Date, Delivery Channel, Received by; item table (Item Description, Item Qty, Case Qty).
+// generateDeliveryNotePDF renders the delivery note and uploads it, returning the file URL.
 func (h *WholesaleOrderHandler) generateDeliveryNotePDF(s *models.Shipment) (string, error) {
+	data, err := h.renderDeliveryNotePDF(s, nil)
+	if err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("%s-delivery-note-%d-%d.pdf", s.WholesaleOrder.OrderNumber, s.ID, time.Now().UnixNano())
+	return h.uploadWholesalePDF(filename, data)
+}
+
+// renderDeliveryNotePDF builds a delivery note PDF (same style as order confirmation): company header, client/delivery block,
+// "Delivery Note" title, row with Account, PO number, Delivery Date, Delivery Channel, Received by; item table (Item Description, Item Qty, Case Qty).
+// When proof is set the note is the signed copy: Received by shows the recipient and the ePOD section is appended.
+func (h *WholesaleOrderHandler) renderDeliveryNotePDF(s *models.Shipment, proof *deliveryProof) ([]byte, error) {
 	if h.cfg == nil {
-		return "", fmt.Errorf("missing config for PDF generation")
+		return nil, fmt.Errorf("missing config for PDF generation")
 	}
 	wo := &s.WholesaleOrder
 	client := &wo.WholesaleClient
@@ -3896,7 +4053,11 @@ func (h *WholesaleOrderHandler) generateDeliveryNotePDF(s *models.Shipment) (str
 		}
 		pdf.CellFormat(w2, 5, deliveryDateStr, "1", 0, "L", false, 0, "")
 		pdf.CellFormat(w3, 5, courier, "1", 0, "L", false, 0, "")
-		pdf.CellFormat(w3, 5, s.TrackingNumber, "1", 1, "L", false, 0, "")
+		receivedBy := s.TrackingNumber
+		if proof != nil {
+			receivedBy = proof.RecipientName
+		}
+		pdf.CellFormat(w3, 5, receivedBy, "1", 1, "L", false, 0, "")
 		pdf.Ln(4)
 	})
 
@@ -4012,16 +4173,55 @@ func (h *WholesaleOrderHandler) generateDeliveryNotePDF(s *models.Shipment) (str
 	}
 	pdf.CellFormat(wCaseQty, 6, totalCaseStr, "LRB", 1, "R", false, 0, "")
 
+	// Carton breakdown from the barcode packing session, when the shipment was packed through one.
+	if cartons := h.deliveryNoteCartons(s.ID); len(cartons) > 0 {
+		cartonRowH := 4.5
+		pdf.Ln(4)
+		for i, carton := range cartons {
+			needed := cartonRowH * float64(len(carton.Items)+1)
+			if i == 0 {
+				needed += 6
+			}
+			if pdf.GetY()+needed > 297-25 {
+				totalPages++
+				pdf.AddPage()
+			}
+			if i == 0 {
+				pdf.SetFont(fontBold, "B", 9)
+				pdf.CellFormat(contentW, 6, fmt.Sprintf("Carton Breakdown (%d cartons)", len(cartons)), "", 1, "L", false, 0, "")
+			}
+			pdf.SetFont(fontBold, "B", 8)
+			pdf.CellFormat(contentW, cartonRowH, fmt.Sprintf("Carton %d", carton.Number), "B", 1, "L", false, 0, "")
+			pdf.SetFont(fontName, "", 8)
+			for _, it := range carton.Items {
+				name := it.Product.NameChinese
+				if name == "" {
+					name = it.Product.Name
+				} else if it.Product.Name != "" {
+					name += " " + it.Product.Name
+				}
+				pdf.CellFormat(wDesc, cartonRowH, "   "+name, "", 0, "L", false, 0, "")
+				pdf.CellFormat(wItemQty, cartonRowH, formatNumberWithCommas(it.Quantity, 2), "", 1, "R", false, 0, "")
+			}
+		}
+	}
+
+	// Electronic proof of delivery on the signed copy.
+	if proof != nil {
+		if pdf.GetY()+deliveryProofSectionHeight(proof) > 297-25 {
+			totalPages++
+			pdf.AddPage()
+		}
+		if err := drawDeliveryProofSection(pdf, fontName, fontBold, margin, contentW, proof); err != nil {
+			return nil, err
+		}
+	}
+
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
-		return "", fmt.Errorf("failed to render PDF: %w", err)
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
 	}
-	filename := fmt.Sprintf("%s-delivery-note-%d-%d.pdf", wo.OrderNumber, s.ID, time.Now().UnixNano())
-	url, err := h.uploadWholesalePDF(filename, buf.Bytes())
-	if err != nil {
-		return "", err
-	}
-	return url, nil
+	return buf.Bytes(), nil
 }
 
 func effectiveShipmentItemQty(si *models.ShipmentItem, orderQty float64) float64 {
@@ -4155,10 +4355,6 @@ type AssignStoresRequest struct {
 }
 
 func (h *WholesaleOrderHandler) AssignStores(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -4188,10 +4384,6 @@ func (h *WholesaleOrderHandler) AssignStores(c *gin.Context) {
 
 // AssignByDefaults assigns pending order lines to each product's wholesale_ship_from store.
 func (h *WholesaleOrderHandler) AssignByDefaults(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.Preload("Items").First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -4425,10 +4617,6 @@ type UnassignStoresRequest struct {
 }
 
 func (h *WholesaleOrderHandler) UnassignStores(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
-
 	var wo models.WholesaleOrder
 	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
@@ -4798,9 +4986,6 @@ func inferDocumentRestoreTarget(action string, changes map[string]interface{}) (
 
 // RestoreDocumentFromAudit sets the active document for an order/shipment to a file_url recorded in an audit log entry.
 func (h *WholesaleOrderHandler) RestoreDocumentFromAudit(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	orderID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil || orderID64 == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order id"})
@@ -5020,6 +5205,8 @@ func wholesaleOrderEmailStatusLabel(wo *models.WholesaleOrder) string {
 		return "Rejected"
 	case models.WholesaleOrderStatusDeleted:
 		return "Deleted"
+	case models.WholesaleOrderStatusBackorder:
+		return "Backorder"
 	default:
 		return strings.ReplaceAll(wo.Status, "_", " ")
 	}
@@ -5253,11 +5440,8 @@ func userDisplayName(u *models.User) string {
 	return strings.TrimSpace(u.Username)
 }
 
-// EmailDocument sends a document (OC, invoice, or DN) with PDF attachment and records an audit log.
+// EmailDocument sends a document (OC, invoice, DN or shipping labels) with PDF attachment and records an audit log.
 func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
-	if !requireManagementOrSupervisor(c) {
-		return
-	}
 	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"})
 		return
@@ -5279,6 +5463,9 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 		BCC          string   `json:"bcc"`
 		Bcc          []string `json:"bcc_list"`
 		ShipmentID   *uint    `json:"shipment_id"`
+		AttachUBL    bool     `json:"attach_ubl"`   // invoice only: also attach the UBL e-invoice XML
+		LabelFormat  string   `json:"label_format"` // shipping_labels only: 4x6 (default) or a4
+		Cartons      int      `json:"cartons"`      // shipping_labels only: override the carton count
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -5289,6 +5476,7 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 		"order_confirmation": "wholesale_order_email_oc",
 		"invoice":            "wholesale_order_email_invoice",
 		"delivery_note":      "wholesale_order_email_dn",
+		"shipping_labels":    "wholesale_order_email_labels",
 	}
 	action, ok := actionMap[req.DocumentType]
 	if !ok {
@@ -5344,6 +5532,7 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 	var attachFilename string
 	var pdfBytes []byte
 	var docLabel string
+	var extraAttachments []apimail.Attachment
 
 	switch req.DocumentType {
 	case "invoice":
@@ -5361,6 +5550,23 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 		docLabel = "Invoice"
 		refSafe := strings.ReplaceAll(strings.ReplaceAll(refLabel, "/", "_"), "\\", "_")
 		attachFilename = fmt.Sprintf("%s_invoice.pdf", refSafe)
+		if req.AttachUBL {
+			var ublDoc models.WholesaleOrderDocument
+			if err := h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, ublInvoiceDocType).First(&ublDoc).Error; err != nil {
+				generated, genErr := h.generateUBLInvoice(wo.ID)
+				if genErr != nil {
+					respondUBLError(c, genErr)
+					return
+				}
+				ublDoc = *generated
+			}
+			xmlData, err := h.readBytesFromFileURL(ublDoc.FileURL)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read UBL invoice: " + err.Error()})
+				return
+			}
+			extraAttachments = append(extraAttachments, apimail.Attachment{Filename: fmt.Sprintf("%s_invoice.xml", refSafe), ContentType: "application/xml", Data: xmlData})
+		}
 
 	case "delivery_note":
 		if req.ShipmentID == nil {
@@ -5386,6 +5592,30 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 		refSafe := strings.ReplaceAll(strings.ReplaceAll(refLabel, "/", "_"), "\\", "_")
 		attachFilename = fmt.Sprintf("%s_delivery_note_shipment_%d.pdf", refSafe, sh.ID)
 
+	case "shipping_labels":
+		if req.ShipmentID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shipment_id is required for shipping labels"})
+			return
+		}
+		format, ok := normaliseLabelFormat(req.LabelFormat)
+		if !ok || req.Cartons < 0 || req.Cartons > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "label_format must be 4x6 or a4 and cartons between 1 and 500"})
+			return
+		}
+		sh, err := h.loadShipmentForLabels(*req.ShipmentID)
+		if err != nil || sh.WholesaleOrderID != wo.ID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+			return
+		}
+		data, err := h.renderShippingLabelsPDF(sh, format, req.Cartons)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate labels: " + err.Error()})
+			return
+		}
+		pdfBytes = data
+		docLabel = "Shipping labels"
+		attachFilename = shipmentLabelsFilename(sh, format)
+
 	case "order_confirmation":
 		var doc models.WholesaleOrderDocument
 		if err := h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, "order_confirmation").First(&doc).Error; err != nil {
@@ -5407,9 +5637,9 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 	body := wholesaleOrderEmailDefaultBody(&wo, fmt.Sprintf("the attached %s", strings.ToLower(docLabel)))
 	from := h.cfg.EffectiveSMTPFrom()
 	if err := apimail.SendWithAttachments(
-		h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from,
+		c.Request.Context(), h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, from,
 		toList, ccList, bccList, subject, body,
-		[]apimail.Attachment{{Filename: attachFilename, ContentType: "application/pdf", Data: pdfBytes}},
+		append([]apimail.Attachment{{Filename: attachFilename, ContentType: "application/pdf", Data: pdfBytes}}, extraAttachments...),
 	); err != nil {
 		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send email: " + err.Error()})
 		return
@@ -5429,6 +5659,9 @@ func (h *WholesaleOrderHandler) EmailDocument(c *gin.Context) {
 		"initiated_by":  initiatorName,
 		"attachment":    attachFilename,
 	}
+	if len(extraAttachments) > 0 {
+		changes["ubl_attachment"] = extraAttachments[0].Filename
+	}
 	if len(ccList) > 0 {
 		changes["cc"] = strings.Join(ccList, ", ")
 		changes["cc_list"] = ccList
@@ -0,0 +1,447 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errPackingSessionChanged = errors.New("packing session changed while scanning; scan again")
+
+// packingLineProgress compares what has been scanned into cartons against one shipment line.
+type packingLineProgress struct {
+	ShipmentItemID uint    `json:"shipment_item_id"`
+	ProductID      uint    `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	Barcode        string  `json:"barcode,omitempty"`
+	Required       float64 `json:"required"`
+	Packed         float64 `json:"packed"`
+	Remaining      float64 `json:"remaining"`
+	Status         string  `json:"status"` // pending, complete, over
+}
+
+// packingProgress builds per-line progress from the shipment lines and the packed quantity per shipment item.
+func packingProgress(items []models.ShipmentItem, packed map[uint]float64) []packingLineProgress {
+	out := make([]packingLineProgress, 0, len(items))
+	for i := range items {
+		si := &items[i]
+		required := effectiveShipmentItemQty(si, si.WholesaleOrderItem.Quantity)
+		p := packingLineProgress{
+			ShipmentItemID: si.ID, ProductID: si.WholesaleOrderItem.ProductID, ProductName: si.WholesaleOrderItem.Product.Name,
+			Barcode: si.WholesaleOrderItem.Product.Barcode, Required: required, Packed: packed[si.ID],
+		}
+		p.Remaining = math.Round((p.Required-p.Packed)*1000) / 1000
+		switch {
+		case p.Remaining > qtyEpsilon:
+			p.Status = "pending"
+		case p.Remaining < -qtyEpsilon:
+			p.Status = "over"
+		default:
+			p.Status = "complete"
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// packingMatches reports whether every line is packed exactly.
+func packingMatches(progress []packingLineProgress) bool {
+	for _, p := range progress {
+		if p.Status != "complete" {
+			return false
+		}
+	}
+	return true
+}
+
+func packedBySessionItem(session *models.PackingSession) map[uint]float64 {
+	packed := map[uint]float64{}
+	for _, carton := range session.Cartons {
+		for _, it := range carton.Items {
+			packed[it.ShipmentItemID] += it.Quantity
+		}
+	}
+	return packed
+}
+
+func (h *WholesaleOrderHandler) loadPackingSession(id interface{}) (models.PackingSession, error) {
+	var session models.PackingSession
+	err := h.db.Preload("Cartons", func(db *gorm.DB) *gorm.DB { return db.Order("number ASC") }).
+		Preload("Cartons.Items.Product").
+		Preload("Shipment.WholesaleOrder").
+		Preload("Shipment.Items.WholesaleOrderItem.Product").
+		First(&session, id).Error
+	return session, err
+}
+
+func packingSessionResponse(session *models.PackingSession) gin.H {
+	progress := packingProgress(session.Shipment.Items, packedBySessionItem(session))
+	return gin.H{"session": session, "lines": progress, "matches": packingMatches(progress)}
+}
+
+// loadOpenPackingSession loads a session for a write and rejects it unless it is still open.
+func (h *WholesaleOrderHandler) loadOpenPackingSession(c *gin.Context) (models.PackingSession, bool) {
+	session, err := h.loadPackingSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing session not found"})
+		return session, false
+	}
+	if session.Status != models.PackingSessionStatusOpen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Packing session is " + session.Status})
+		return session, false
+	}
+	if abortIfWholesaleOrderDeleted(c, session.Shipment.WholesaleOrder.Status) {
+		return session, false
+	}
+	return session, true
+}
+
+// StartPackingSession opens a barcode-verified packing session for a shipment, or returns the one already open.
+func (h *WholesaleOrderHandler) StartPackingSession(c *gin.Context) {
+	var s models.Shipment
+	if err := h.db.Preload("WholesaleOrder").Preload("Items").First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, s.WholesaleOrder.Status) {
+		return
+	}
+	if !models.ShipmentStatusAllowsPacking(s.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment is already packed"})
+		return
+	}
+	if len(s.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment has no items"})
+		return
+	}
+	var session models.PackingSession
+	err := h.db.Where("shipment_id = ? AND status = ?", s.ID, models.PackingSessionStatusOpen).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session = models.PackingSession{ShipmentID: s.ID, Status: models.PackingSessionStatusOpen, StartedBy: contextUserID(c)}
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&session).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.PackingCarton{PackingSessionID: session.ID, Number: 1, CreatedAt: time.Now()}).Error
+		})
+		if err == nil {
+			h.audit(c, "wholesale_packing_session_start", s.WholesaleOrderID, map[string]interface{}{
+				"shipment_id": s.ID, "packing_session_id": session.ID,
+			})
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, err = h.loadPackingSession(session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, packingSessionResponse(&session))
+}
+
+// GetPackingSession returns the shipment's latest packing session with per-line progress.
+func (h *WholesaleOrderHandler) GetPackingSession(c *gin.Context) {
+	var session models.PackingSession
+	if err := h.db.Where("shipment_id = ? AND status != ?", c.Param("id"), models.PackingSessionStatusCancelled).
+		Order("id DESC").First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No packing session for this shipment"})
+		return
+	}
+	session, err := h.loadPackingSession(session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, packingSessionResponse(&session))
+}
+
+// AddPackingCarton starts the next carton; later scans go into it unless a carton_id is given.
+func (h *WholesaleOrderHandler) AddPackingCarton(c *gin.Context) {
+	session, ok := h.loadOpenPackingSession(c)
+	if !ok {
+		return
+	}
+	next := 1
+	for _, carton := range session.Cartons {
+		if carton.Number >= next {
+			next = carton.Number + 1
+		}
+	}
+	if err := h.db.Create(&models.PackingCarton{PackingSessionID: session.ID, Number: next, CreatedAt: time.Now()}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, _ = h.loadPackingSession(session.ID)
+	c.JSON(http.StatusOK, packingSessionResponse(&session))
+}
+
+// ScanPackingItem matches a scanned barcode against the shipment lines and records it in a carton. Products not on
+// the shipment and quantities beyond what the line requires are rejected.
+func (h *WholesaleOrderHandler) ScanPackingItem(c *gin.Context) {
+	session, ok := h.loadOpenPackingSession(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Barcode  string  `json:"barcode" binding:"required"`
+		Quantity float64 `json:"quantity"`  // optional; defaults to one unit, or the weight embedded in the barcode
+		CartonID uint    `json:"carton_id"` // optional; defaults to the highest-numbered carton
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity cannot be negative"})
+		return
+	}
+	scan, err := resolveScannedBarcode(h.db, req.Barcode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "wrong_product": true})
+		return
+	}
+	qty := scan.Quantity
+	if req.Quantity > 0 {
+		qty = req.Quantity
+	}
+	var carton *models.PackingCarton
+	for i := range session.Cartons {
+		if (req.CartonID != 0 && session.Cartons[i].ID == req.CartonID) || (req.CartonID == 0 && (carton == nil || session.Cartons[i].Number > carton.Number)) {
+			carton = &session.Cartons[i]
+		}
+	}
+	if carton == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Carton not found in this packing session"})
+		return
+	}
+	// First line for this product that still needs units; a product split over several lines fills them in order.
+	progress := packingProgress(session.Shipment.Items, packedBySessionItem(&session))
+	var line *packingLineProgress
+	onShipment := false
+	for i := range progress {
+		if progress[i].ProductID != scan.Product.ID {
+			continue
+		}
+		onShipment = true
+		if progress[i].Remaining+qtyEpsilon >= qty {
+			line = &progress[i]
+			break
+		}
+	}
+	if !onShipment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is not on this shipment", scan.Product.Name), "wrong_product": true})
+		return
+	}
+	if line == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many %s: scanned quantity exceeds what the shipment requires", scan.Product.Name)})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Claim on the scan counter so two scanners cannot both take the last units of a line.
+		res := tx.Model(&models.PackingSession{}).Where("id = ? AND status = ? AND scan_count = ?", session.ID, models.PackingSessionStatusOpen, session.ScanCount).
+			Updates(map[string]interface{}{"scan_count": gorm.Expr("scan_count + 1"), "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errPackingSessionChanged
+		}
+		var item models.PackingCartonItem
+		err := tx.Where("packing_carton_id = ? AND shipment_item_id = ?", carton.ID, line.ShipmentItemID).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.PackingCartonItem{
+				PackingCartonID: carton.ID, ShipmentItemID: line.ShipmentItemID, ProductID: line.ProductID, Quantity: qty, UpdatedAt: time.Now(),
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&item).Updates(map[string]interface{}{"quantity": gorm.Expr("quantity + ?", qty), "updated_at": time.Now()}).Error
+	})
+	if errors.Is(err, errPackingSessionChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, _ = h.loadPackingSession(session.ID)
+	resp := packingSessionResponse(&session)
+	resp["scanned"] = gin.H{"product_id": scan.Product.ID, "shipment_item_id": line.ShipmentItemID, "carton_id": carton.ID, "quantity": qty, "scan_type": scan.Kind}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RemovePackingCartonItem takes a mis-scanned line back out of a carton.
+func (h *WholesaleOrderHandler) RemovePackingCartonItem(c *gin.Context) {
+	session, ok := h.loadOpenPackingSession(c)
+	if !ok {
+		return
+	}
+	itemID, _ := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	var found *models.PackingCartonItem
+	for _, carton := range session.Cartons {
+		for i := range carton.Items {
+			if carton.Items[i].ID == uint(itemID) {
+				found = &carton.Items[i]
+			}
+		}
+	}
+	if found == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Carton item not found in this packing session"})
+		return
+	}
+	if err := h.db.Delete(&models.PackingCartonItem{}, found.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.db.Model(&models.PackingSession{}).Where("id = ?", session.ID).Update("scan_count", gorm.Expr("scan_count + 1"))
+	session, _ = h.loadPackingSession(session.ID)
+	c.JSON(http.StatusOK, packingSessionResponse(&session))
+}
+
+// CompletePackingSession packs the shipment once the scanned contents match every line. A mismatch can only be
+// accepted with wholesale.manage and a reason. Case qty defaults to the number of cartons holding each line.
+func (h *WholesaleOrderHandler) CompletePackingSession(c *gin.Context) {
+	session, ok := h.loadOpenPackingSession(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		AcceptDiscrepancy bool   `json:"accept_discrepancy"`
+		Reason            string `json:"reason"`
+		DeliveryDate      string `json:"delivery_date"` // optional YYYY-MM-DD, as for CompletePacking
+	}
+	_ = c.ShouldBindJSON(&body)
+	progress := packingProgress(session.Shipment.Items, packedBySessionItem(&session))
+	matches := packingMatches(progress)
+	if !matches {
+		if !body.AcceptDiscrepancy {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Packed contents do not match the shipment", "lines": progress})
+			return
+		}
+		if !hasPermission(c, models.PermWholesaleManage) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Accepting a packing discrepancy requires " + models.PermWholesaleManage})
+			return
+		}
+		if strings.TrimSpace(body.Reason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required to accept a packing discrepancy"})
+			return
+		}
+	}
+
+	var s models.Shipment
+	if err := h.db.Preload("Store").
+		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
+		Preload("Items.WholesaleOrderItem").First(&s, session.ShipmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if !models.ShipmentStatusAllowsPacking(s.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipment is already packed"})
+		return
+	}
+	if body.DeliveryDate != "" {
+		if t, err := time.Parse("2006-01-02", body.DeliveryDate); err == nil {
+			s.DeliveryDate = &t
+		}
+	}
+
+	// Close the session first so the delivery note picks up its cartons; reopen it if packing fails.
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.PackingSessionStatusCompleted, "completed_by": contextUserID(c), "completed_at": now}
+	if !matches {
+		updates["discrepancy_accepted"] = true
+		updates["discrepancy_accepted_by"] = contextUserID(c)
+		updates["discrepancy_reason"] = strings.TrimSpace(body.Reason)
+	}
+	res := h.db.Model(&models.PackingSession{}).Where("id = ? AND status = ?", session.ID, models.PackingSessionStatusOpen).Updates(updates)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Packing session was already closed"})
+		return
+	}
+
+	cartonsPerItem := map[uint]float64{}
+	for _, carton := range session.Cartons {
+		for _, it := range carton.Items {
+			if it.Quantity > 0 {
+				cartonsPerItem[it.ShipmentItemID]++
+			}
+		}
+	}
+	caseQty := map[uint]float64{}
+	for _, si := range s.Items {
+		if si.CaseQty <= 0 && cartonsPerItem[si.ID] > 0 {
+			caseQty[si.WholesaleOrderItemID] = cartonsPerItem[si.ID]
+		}
+	}
+	extra := map[string]interface{}{"trigger": "packing_session", "packing_session_id": session.ID, "cartons": len(session.Cartons)}
+	if !matches {
+		extra["discrepancy_reason"] = strings.TrimSpace(body.Reason)
+		extra["lines"] = progress
+	}
+	if err := h.packShipment(c, &s, caseQty, false, extra); err != nil {
+		h.db.Model(&models.PackingSession{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"status": models.PackingSessionStatusOpen, "completed_by": nil, "completed_at": nil,
+			"discrepancy_accepted": false, "discrepancy_accepted_by": nil, "discrepancy_reason": "",
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, _ = h.loadPackingSession(session.ID)
+	resp := packingSessionResponse(&session)
+	resp["delivery_note_pdf_url"] = s.DeliveryNotePDFURL
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelPackingSession abandons an open session; its cartons are kept for reference but no longer print.
+func (h *WholesaleOrderHandler) CancelPackingSession(c *gin.Context) {
+	session, ok := h.loadOpenPackingSession(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Model(&models.PackingSession{}).Where("id = ? AND status = ?", session.ID, models.PackingSessionStatusOpen).
+		Update("status", models.PackingSessionStatusCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "wholesale_packing_session_cancel", session.Shipment.WholesaleOrderID, map[string]interface{}{
+		"shipment_id": session.ShipmentID, "packing_session_id": session.ID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Packing session cancelled"})
+}
+
+// deliveryNoteCartons returns the cartons of the shipment's completed packing session, for the delivery note.
+func (h *WholesaleOrderHandler) deliveryNoteCartons(shipmentID uint) []models.PackingCarton {
+	var session models.PackingSession
+	if err := h.db.Where("shipment_id = ? AND status = ?", shipmentID, models.PackingSessionStatusCompleted).
+		Order("id DESC").First(&session).Error; err != nil {
+		return nil
+	}
+	var cartons []models.PackingCarton
+	h.db.Where("packing_session_id = ?", session.ID).Preload("Items.Product").Order("number ASC").Find(&cartons)
+	out := cartons[:0]
+	for _, carton := range cartons {
+		if len(carton.Items) > 0 {
+			out = append(out, carton)
+		}
+	}
+	return out
+}
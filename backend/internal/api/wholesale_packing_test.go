@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestPackingProgress(t *testing.T) {
+	items := []models.ShipmentItem{
+		{ID: 1, Quantity: 6, WholesaleOrderItem: models.WholesaleOrderItem{ProductID: 10, Quantity: 12}},
+		{ID: 2, WholesaleOrderItem: models.WholesaleOrderItem{ProductID: 11, Quantity: 4}},
+		{ID: 3, Quantity: 2, WholesaleOrderItem: models.WholesaleOrderItem{ProductID: 12, Quantity: 2}},
+	}
+	progress := packingProgress(items, map[uint]float64{1: 6, 2: 3, 3: 2.5})
+	want := []string{"complete", "pending", "over"}
+	for i, p := range progress {
+		if p.Status != want[i] {
+			t.Fatalf("line %d: status %q, want %q", i, p.Status, want[i])
+		}
+	}
+	if progress[1].Remaining != 1 {
+		t.Fatalf("legacy line remaining = %v, want 1", progress[1].Remaining)
+	}
+	if packingMatches(progress) {
+		t.Fatal("mismatched packing reported as matching")
+	}
+	if !packingMatches(progress[:1]) {
+		t.Fatal("fully packed line reported as mismatched")
+	}
+}
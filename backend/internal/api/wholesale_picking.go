@@ -0,0 +1,570 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// qtyEpsilon absorbs decimal(10,3) rounding when comparing picked and required quantities.
+const qtyEpsilon = 0.0005
+
+var errPickListConflict = errors.New("pick list changed while scanning; scan again")
+
+// pickListOrderRef is one shipment line contributing to a consolidated pick list line.
+type pickListOrderRef struct {
+	WholesaleOrderID uint    `json:"wholesale_order_id"`
+	OrderRef         string  `json:"order_ref"`
+	ClientName       string  `json:"client_name"`
+	ShipmentID       uint    `json:"shipment_id"`
+	ShipmentItemID   uint    `json:"shipment_item_id"`
+	Quantity         float64 `json:"quantity"`
+	PickedQty        float64 `json:"picked_qty"`
+	Cases            float64 `json:"cases"`
+	LooseUnits       float64 `json:"loose_units"`
+}
+
+// pickListLine aggregates one product across every shipment on the pick list.
+type pickListLine struct {
+	ProductID          uint               `json:"product_id"`
+	ProductName        string             `json:"product_name"`
+	ProductNameChinese string             `json:"product_name_chinese,omitempty"`
+	SKU                string             `json:"sku,omitempty"`
+	Barcode            string             `json:"barcode,omitempty"`
+	UnitType           string             `json:"unit_type"`
+	BinLocation        string             `json:"bin_location,omitempty"`
+	UnitsPerBox        float64            `json:"units_per_box,omitempty"`
+	Quantity           float64            `json:"quantity"`
+	PickedQty          float64            `json:"picked_qty"`
+	Cases              float64            `json:"cases"`
+	LooseUnits         float64            `json:"loose_units"`
+	Orders             []pickListOrderRef `json:"orders"`
+}
+
+type pickList struct {
+	StoreID       uint           `json:"store_id"`
+	StoreName     string         `json:"store_name"`
+	Date          string         `json:"date"`
+	ShipmentIDs   []uint         `json:"shipment_ids"`
+	Lines         []pickListLine `json:"lines"`
+	TotalQuantity float64        `json:"total_quantity"`
+	TotalPicked   float64        `json:"total_picked"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+}
+
+// pickCaseBreakdown splits qty into full cases and loose units. A case count already entered on the shipment line
+// wins; otherwise unitsPerBox is used. Without either, everything is loose.
+func pickCaseBreakdown(qty, caseQty, unitsPerBox float64) (cases, loose float64) {
+	if caseQty > 0 {
+		return caseQty, 0
+	}
+	if unitsPerBox <= 0 || qty <= 0 {
+		return 0, qty
+	}
+	cases = math.Floor(qty/unitsPerBox + qtyEpsilon)
+	loose = math.Round((qty-cases*unitsPerBox)*1000) / 1000
+	if loose < 0 {
+		loose = 0
+	}
+	return cases, loose
+}
+
+// parseEmbeddedWeightBarcode reads a receipt-style weight barcode: prefix + weight (4 digits, 0.01 kg) + check
+// digit. Returns the weight in kg.
+func parseEmbeddedWeightBarcode(code, prefix string) (float64, bool) {
+	if prefix == "" || len(code) != len(prefix)+5 || !strings.HasPrefix(code, prefix) {
+		return 0, false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	centi, err := strconv.Atoi(code[len(prefix) : len(prefix)+4])
+	if err != nil || centi == 0 {
+		return 0, false
+	}
+	return float64(centi) / 100, true
+}
+
+// scannedBarcode is a barcode resolved to a product and the quantity one scan stands for.
+type scannedBarcode struct {
+	Product  models.Product
+	Quantity float64
+	Kind     string // barcode, weight_barcode, embedded_weight
+}
+
+// resolveScannedBarcode matches a scan against product Barcode, WeightBarcode, then embedded-weight receipt
+// barcodes of weight products.
+func resolveScannedBarcode(db *gorm.DB, code string) (scannedBarcode, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return scannedBarcode{}, fmt.Errorf("barcode is required")
+	}
+	var p models.Product
+	if err := db.Where("barcode = ?", code).First(&p).Error; err == nil {
+		return scannedBarcode{Product: p, Quantity: 1, Kind: "barcode"}, nil
+	}
+	if err := db.Where("weight_barcode = ?", code).First(&p).Error; err == nil {
+		return scannedBarcode{Product: p, Quantity: 1, Kind: "weight_barcode"}, nil
+	}
+	var weighted []models.Product
+	db.Where("unit_type = ? AND is_active = ?", "weight", true).Find(&weighted)
+	for _, wp := range weighted {
+		prefix := resolveWeightBarcodePrefix(wp.WeightBarcodePrefix, wp.WeightBarcode)
+		if prefix == "" {
+			prefix = resolveWeightBarcodePrefix("", wp.Barcode)
+		}
+		if kg, ok := parseEmbeddedWeightBarcode(code, prefix); ok {
+			return scannedBarcode{Product: wp, Quantity: kg, Kind: "embedded_weight"}, nil
+		}
+	}
+	return scannedBarcode{}, fmt.Errorf("barcode %s does not match any product", code)
+}
+
+// pickListShipments loads the shipments still to be packed at storeID for day: those with that delivery date,
+// plus undated shipments assigned on or before it (outstanding work carries forward).
+func (h *WholesaleOrderHandler) pickListShipments(storeID uint, day time.Time) ([]models.Shipment, error) {
+	dayStr := day.Format("2006-01-02")
+	next := day.AddDate(0, 0, 1).Format("2006-01-02")
+	var shipments []models.Shipment
+	err := h.db.Model(&models.Shipment{}).
+		Joins("INNER JOIN wholesale_orders wo ON wo.id = shipments.wholesale_order_id AND wo.status != ?", models.WholesaleOrderStatusDeleted).
+		Where("shipments.store_id = ? AND shipments.status IN ?", storeID, []string{models.ShipmentStatusAssigned, models.ShipmentStatusPacking}).
+		Where("(shipments.delivery_date = ? OR (shipments.delivery_date IS NULL AND shipments.created_at < ?))", dayStr, next).
+		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").
+		Preload("Items.WholesaleOrderItem.Product").
+		Order("shipments.id ASC").Find(&shipments).Error
+	return shipments, err
+}
+
+// buildPickList aggregates shipment lines by product, ordered by bin location so staff walk the store once.
+func buildPickList(store models.Store, day time.Time, shipments []models.Shipment, bins map[uint]string) pickList {
+	pl := pickList{StoreID: store.ID, StoreName: store.Name, Date: day.Format("2006-01-02"), ShipmentIDs: []uint{}, Lines: []pickListLine{}, GeneratedAt: time.Now()}
+	byProduct := map[uint]*pickListLine{}
+	var order []uint
+	for _, s := range shipments {
+		pl.ShipmentIDs = append(pl.ShipmentIDs, s.ID)
+		for _, si := range s.Items {
+			woi := si.WholesaleOrderItem
+			qty := effectiveShipmentItemQty(&si, woi.Quantity)
+			if qty <= 0 {
+				continue
+			}
+			line, ok := byProduct[woi.ProductID]
+			if !ok {
+				p := woi.Product
+				line = &pickListLine{
+					ProductID: p.ID, ProductName: p.Name, ProductNameChinese: p.NameChinese, SKU: p.SKU, Barcode: p.Barcode,
+					UnitType: p.UnitType, BinLocation: bins[p.ID], UnitsPerBox: p.WholesaleUnitsPerBox,
+				}
+				byProduct[woi.ProductID] = line
+				order = append(order, woi.ProductID)
+			}
+			cases, loose := pickCaseBreakdown(qty, si.CaseQty, line.UnitsPerBox)
+			line.Orders = append(line.Orders, pickListOrderRef{
+				WholesaleOrderID: s.WholesaleOrderID, OrderRef: wholesaleOrderRefLabel(&s.WholesaleOrder),
+				ClientName: s.WholesaleOrder.WholesaleClient.Name, ShipmentID: s.ID, ShipmentItemID: si.ID,
+				Quantity: qty, PickedQty: si.PickedQty, Cases: cases, LooseUnits: loose,
+			})
+			line.Quantity += qty
+			line.PickedQty += si.PickedQty
+			line.Cases += cases
+			line.LooseUnits += loose
+			pl.TotalQuantity += qty
+			pl.TotalPicked += si.PickedQty
+		}
+	}
+	for _, id := range order {
+		pl.Lines = append(pl.Lines, *byProduct[id])
+	}
+	sort.SliceStable(pl.Lines, func(i, j int) bool {
+		a, b := pl.Lines[i], pl.Lines[j]
+		// Lines without a bin go last.
+		if (a.BinLocation == "") != (b.BinLocation == "") {
+			return b.BinLocation == ""
+		}
+		if a.BinLocation != b.BinLocation {
+			return a.BinLocation < b.BinLocation
+		}
+		return a.ProductName < b.ProductName
+	})
+	return pl
+}
+
+// loadPickList reads store_id and date (YYYY-MM-DD, default today) from the query or body values given.
+func (h *WholesaleOrderHandler) loadPickList(storeIDStr, dateStr string) (pickList, []models.Shipment, int, error) {
+	storeID, err := strconv.ParseUint(strings.TrimSpace(storeIDStr), 10, 32)
+	if err != nil || storeID == 0 {
+		return pickList{}, nil, http.StatusBadRequest, fmt.Errorf("store_id is required")
+	}
+	day := dateOnly(time.Now())
+	if strings.TrimSpace(dateStr) != "" {
+		t, err := time.Parse("2006-01-02", strings.TrimSpace(dateStr))
+		if err != nil {
+			return pickList{}, nil, http.StatusBadRequest, fmt.Errorf("date must be YYYY-MM-DD")
+		}
+		day = t
+	}
+	var store models.Store
+	if err := h.db.First(&store, storeID).Error; err != nil {
+		return pickList{}, nil, http.StatusNotFound, fmt.Errorf("Store not found")
+	}
+	shipments, err := h.pickListShipments(store.ID, day)
+	if err != nil {
+		return pickList{}, nil, http.StatusInternalServerError, err
+	}
+	var productIDs []uint
+	for _, s := range shipments {
+		for _, si := range s.Items {
+			productIDs = append(productIDs, si.WholesaleOrderItem.ProductID)
+		}
+	}
+	bins := map[uint]string{}
+	if len(productIDs) > 0 {
+		var stocks []models.Stock
+		h.db.Where("store_id = ? AND product_id IN ?", store.ID, productIDs).Find(&stocks)
+		for _, st := range stocks {
+			bins[st.ProductID] = st.BinLocation
+		}
+	}
+	return buildPickList(store, day, shipments, bins), shipments, http.StatusOK, nil
+}
+
+// GetPickList returns the consolidated pick list for a store and day (JSON feed for the mobile app).
+func (h *WholesaleOrderHandler) GetPickList(c *gin.Context) {
+	pl, _, status, err := h.loadPickList(c.Query("store_id"), c.Query("date"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pl)
+}
+
+// GetPickListPDF renders the consolidated pick list as a printable PDF.
+func (h *WholesaleOrderHandler) GetPickListPDF(c *gin.Context) {
+	pl, _, status, err := h.loadPickList(c.Query("store_id"), c.Query("date"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := h.renderPickListPDF(&pl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pick list: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", fmt.Sprintf("pick-list-%d-%s.pdf", pl.StoreID, pl.Date)))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// ScanPickList confirms a scanned item against the pick list. The quantity is allocated to the oldest shipments
+// first; every shipment whose lines are then fully picked is packed (delivery note generated) as CompletePacking does.
+func (h *WholesaleOrderHandler) ScanPickList(c *gin.Context) {
+	var req struct {
+		StoreID  uint    `json:"store_id" binding:"required"`
+		Date     string  `json:"date"`
+		Barcode  string  `json:"barcode" binding:"required"`
+		Quantity float64 `json:"quantity"` // optional; defaults to one unit, or the weight embedded in the barcode
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity cannot be negative"})
+		return
+	}
+	storeIDStr := strconv.FormatUint(uint64(req.StoreID), 10)
+	pl, shipments, status, err := h.loadPickList(storeIDStr, req.Date)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	scan, err := resolveScannedBarcode(h.db, req.Barcode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	qty := scan.Quantity
+	if req.Quantity > 0 {
+		qty = req.Quantity
+	}
+	var line *pickListLine
+	for i := range pl.Lines {
+		if pl.Lines[i].ProductID == scan.Product.ID {
+			line = &pl.Lines[i]
+			break
+		}
+	}
+	if line == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is not on this pick list", scan.Product.Name)})
+		return
+	}
+	remaining := line.Quantity - line.PickedQty
+	if qty > remaining+qtyEpsilon {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Only %s left to pick for %s", formatNumberWithCommas(math.Max(remaining, 0), 2), line.ProductName)})
+		return
+	}
+
+	userID := contextUserID(c)
+	touched := map[uint]bool{}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		left := qty
+		for _, ref := range line.Orders {
+			if left <= qtyEpsilon {
+				break
+			}
+			open := ref.Quantity - ref.PickedQty
+			if open <= qtyEpsilon {
+				continue
+			}
+			take := math.Min(open, left)
+			// Claim on the previous picked_qty so two scanners cannot both fill the same line.
+			res := tx.Model(&models.ShipmentItem{}).Where("id = ? AND picked_qty = ?", ref.ShipmentItemID, ref.PickedQty).
+				Update("picked_qty", gorm.Expr("picked_qty + ?", take))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errPickListConflict
+			}
+			if err := tx.Create(&models.ShipmentPickScan{
+				ShipmentID: ref.ShipmentID, ShipmentItemID: ref.ShipmentItemID, ProductID: line.ProductID,
+				Barcode: strings.TrimSpace(req.Barcode), Quantity: take, UserID: userID, CreatedAt: time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			touched[ref.ShipmentID] = true
+			left -= take
+		}
+		return nil
+	})
+	if errors.Is(err, errPickListConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	packed := []uint{}
+	var packErrors []string
+	for _, s := range shipments {
+		if !touched[s.ID] {
+			continue
+		}
+		if err := h.packShipmentIfPicked(c, s.ID); err != nil {
+			packErrors = append(packErrors, fmt.Sprintf("shipment %d: %v", s.ID, err))
+		} else if h.shipmentIsPacked(s.ID) {
+			packed = append(packed, s.ID)
+		}
+	}
+	pl, _, _, _ = h.loadPickList(storeIDStr, req.Date)
+	resp := gin.H{
+		"product_id": line.ProductID, "quantity": qty, "scan_type": scan.Kind,
+		"packed_shipment_ids": packed, "pick_list": pl,
+	}
+	if len(packErrors) > 0 {
+		resp["pack_errors"] = packErrors
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *WholesaleOrderHandler) shipmentIsPacked(shipmentID uint) bool {
+	var s models.Shipment
+	return h.db.Select("id", "status").First(&s, shipmentID).Error == nil && s.Status == models.ShipmentStatusPacked
+}
+
+// packShipmentIfPicked packs the shipment once every line has been picked in full. Lines without a case count get
+// one derived from the product's units per box.
+func (h *WholesaleOrderHandler) packShipmentIfPicked(c *gin.Context, shipmentID uint) error {
+	var s models.Shipment
+	if err := h.db.Preload("Store").
+		Preload("WholesaleOrder").Preload("WholesaleOrder.WholesaleClient").Preload("WholesaleOrder.WholesaleClientStore").
+		Preload("Items.WholesaleOrderItem.Product").First(&s, shipmentID).Error; err != nil {
+		return err
+	}
+	if !models.ShipmentStatusAllowsPacking(s.Status) || len(s.Items) == 0 {
+		return nil
+	}
+	// A barcode packing session in progress owns the shipment; it completes packing itself.
+	var openSessions int64
+	h.db.Model(&models.PackingSession{}).Where("shipment_id = ? AND status = ?", s.ID, models.PackingSessionStatusOpen).Count(&openSessions)
+	if openSessions > 0 {
+		return nil
+	}
+	caseQty := map[uint]float64{}
+	for i := range s.Items {
+		si := &s.Items[i]
+		qty := effectiveShipmentItemQty(si, si.WholesaleOrderItem.Quantity)
+		if si.PickedQty+qtyEpsilon < qty {
+			return nil
+		}
+		if upb := si.WholesaleOrderItem.Product.WholesaleUnitsPerBox; si.CaseQty <= 0 && upb > 0 {
+			caseQty[si.WholesaleOrderItemID] = math.Ceil(qty/upb - qtyEpsilon)
+		}
+	}
+	return h.packShipment(c, &s, caseQty, false, map[string]interface{}{"trigger": "pick_list_scan"})
+}
+
+// newWholesalePDF starts an A4 document with the same CJK-capable font fallback as the delivery note.
+func (h *WholesaleOrderHandler) newWholesalePDF(orientation string) (*gofpdf.Fpdf, string, string) {
+	return h.newWholesalePDFCustom(&gofpdf.InitType{OrientationStr: orientation, UnitStr: "mm", SizeStr: "A4"})
+}
+
+// newWholesalePDFCustom is newWholesalePDF for other page sizes (labels).
+func (h *WholesaleOrderHandler) newWholesalePDFCustom(init *gofpdf.InitType) (*gofpdf.Fpdf, string, string) {
+	pdf := gofpdf.NewCustom(init)
+	uploadDir := "uploads"
+	fontOverride := ""
+	if h.cfg != nil {
+		if d := strings.TrimSuffix(h.cfg.UploadDir, "/"); d != "" {
+			uploadDir = d
+		}
+		fontOverride = strings.TrimSpace(h.cfg.PDFFontPath)
+	}
+	candidates := []string{}
+	if fontOverride != "" {
+		candidates = append(candidates, fontOverride)
+	}
+	candidates = append(candidates,
+		filepath.Join("pdf-assets", "fonts", "NotoSansTC-Regular.ttf"),
+		filepath.Join("pdf-assets", "fonts", "NotoSansSC-Regular.ttf"),
+		filepath.Join(uploadDir, "assets", "fonts", "NotoSansTC-Regular.ttf"),
+		filepath.Join(uploadDir, "assets", "fonts", "NotoSansSC-Regular.ttf"),
+		filepath.Join("pdf-assets", "fonts", "Arial Unicode MS.ttf"),
+		filepath.Join(uploadDir, "assets", "fonts", "Arial Unicode MS.ttf"),
+	)
+	for _, p := range candidates {
+		data, err := os.ReadFile(p)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		bold := data
+		if b, err := os.ReadFile(strings.Replace(p, "-Regular.", "-Bold.", 1)); err == nil && len(b) > 0 && strings.Contains(p, "-Regular.") {
+			bold = b
+		}
+		pdf.AddUTF8FontFromBytes("Uni", "", data)
+		pdf.AddUTF8FontFromBytes("Uni", "B", bold)
+		return pdf, "Uni", "Uni"
+	}
+	return pdf, "Helvetica", "Helvetica"
+}
+
+func (h *WholesaleOrderHandler) renderPickListPDF(pl *pickList) ([]byte, error) {
+	pdf, fontName, fontBold := h.newWholesalePDF("P")
+	margin := 12.0
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fontName, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	contentW := 210.0 - 2*margin
+
+	pdf.SetFont(fontBold, "B", 16)
+	pdf.CellFormat(contentW, 8, "Pick List", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontName, "", 10)
+	pdf.CellFormat(contentW, 5, fmt.Sprintf("Store: %s    Date: %s    Shipments: %d", pl.StoreName, pl.Date, len(pl.ShipmentIDs)), "", 1, "L", false, 0, "")
+	pdf.CellFormat(contentW, 5, "Generated: "+pl.GeneratedAt.Format("02/01/2006 15:04"), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	wBin, wQty, wCases, wLoose, wPicked, wTick := 22.0, 18.0, 16.0, 16.0, 18.0, 10.0
+	wProduct := contentW - wBin - wQty - wCases - wLoose - wPicked - wTick
+	header := func() {
+		pdf.SetFont(fontBold, "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		pdf.CellFormat(wBin, 7, "Bin", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(wProduct, 7, "Product", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(wQty, 7, "Qty", "1", 0, "R", true, 0, "")
+		pdf.CellFormat(wCases, 7, "Cases", "1", 0, "R", true, 0, "")
+		pdf.CellFormat(wLoose, 7, "Loose", "1", 0, "R", true, 0, "")
+		pdf.CellFormat(wPicked, 7, "Picked", "1", 0, "R", true, 0, "")
+		pdf.CellFormat(wTick, 7, "OK", "1", 1, "C", true, 0, "")
+	}
+	header()
+	if len(pl.Lines) == 0 {
+		pdf.SetFont(fontName, "", 10)
+		pdf.CellFormat(contentW, 8, "Nothing to pick for this day.", "1", 1, "C", false, 0, "")
+	}
+	for _, line := range pl.Lines {
+		// Keep a product and its order breakdown on one page.
+		if pdf.GetY()+7+4.5*float64(len(line.Orders)) > 297-20 {
+			pdf.AddPage()
+			header()
+		}
+		name := line.ProductName
+		if line.ProductNameChinese != "" {
+			name += " " + line.ProductNameChinese
+		}
+		if line.SKU != "" {
+			name += " (" + line.SKU + ")"
+		}
+		for pdf.GetStringWidth(name) > wProduct-2 && len([]rune(name)) > 4 {
+			r := []rune(name)
+			name = string(r[:len(r)-4]) + "..."
+		}
+		bin := line.BinLocation
+		if bin == "" {
+			bin = "-"
+		}
+		cases := "-"
+		if line.Cases > 0 {
+			cases = formatNumberWithCommas(line.Cases, 0)
+		}
+		pdf.SetFont(fontBold, "B", 9)
+		pdf.CellFormat(wBin, 7, bin, "LTR", 0, "L", false, 0, "")
+		pdf.CellFormat(wProduct, 7, name, "LTR", 0, "L", false, 0, "")
+		pdf.CellFormat(wQty, 7, formatNumberWithCommas(line.Quantity, 2), "LTR", 0, "R", false, 0, "")
+		pdf.CellFormat(wCases, 7, cases, "LTR", 0, "R", false, 0, "")
+		pdf.CellFormat(wLoose, 7, formatNumberWithCommas(line.LooseUnits, 2), "LTR", 0, "R", false, 0, "")
+		pdf.CellFormat(wPicked, 7, formatNumberWithCommas(line.PickedQty, 2), "LTR", 0, "R", false, 0, "")
+		pdf.CellFormat(wTick, 7, "[  ]", "LTR", 1, "C", false, 0, "")
+		pdf.SetFont(fontName, "", 8)
+		for i, ref := range line.Orders {
+			border := "LR"
+			if i == len(line.Orders)-1 {
+				border = "LRB"
+			}
+			label := fmt.Sprintf("  %s - %s (shipment %d)", ref.OrderRef, ref.ClientName, ref.ShipmentID)
+			pdf.CellFormat(wBin, 4.5, "", border, 0, "L", false, 0, "")
+			pdf.CellFormat(wProduct, 4.5, label, border, 0, "L", false, 0, "")
+			pdf.CellFormat(wQty, 4.5, formatNumberWithCommas(ref.Quantity, 2), border, 0, "R", false, 0, "")
+			refCases := "-"
+			if ref.Cases > 0 {
+				refCases = formatNumberWithCommas(ref.Cases, 0)
+			}
+			pdf.CellFormat(wCases, 4.5, refCases, border, 0, "R", false, 0, "")
+			pdf.CellFormat(wLoose, 4.5, formatNumberWithCommas(ref.LooseUnits, 2), border, 0, "R", false, 0, "")
+			pdf.CellFormat(wPicked, 4.5, formatNumberWithCommas(ref.PickedQty, 2), border, 0, "R", false, 0, "")
+			pdf.CellFormat(wTick, 4.5, "", border, 1, "C", false, 0, "")
+		}
+	}
+	pdf.Ln(2)
+	pdf.SetFont(fontBold, "B", 10)
+	pdf.CellFormat(contentW, 6, fmt.Sprintf("Total quantity: %s    Picked: %s", formatNumberWithCommas(pl.TotalQuantity, 2), formatNumberWithCommas(pl.TotalPicked, 2)), "", 1, "R", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestPickCaseBreakdown(t *testing.T) {
+	if cases, loose := pickCaseBreakdown(26, 0, 12); cases != 2 || loose != 2 {
+		t.Fatalf("by units per box: got %v cases %v loose", cases, loose)
+	}
+	if cases, loose := pickCaseBreakdown(26, 3, 12); cases != 3 || loose != 0 {
+		t.Fatalf("explicit case qty: got %v cases %v loose", cases, loose)
+	}
+	if cases, loose := pickCaseBreakdown(5, 0, 0); cases != 0 || loose != 5 {
+		t.Fatalf("no box size: got %v cases %v loose", cases, loose)
+	}
+}
+
+func TestParseEmbeddedWeightBarcode(t *testing.T) {
+	kg, ok := parseEmbeddedWeightBarcode("1234567801250", "12345678")
+	if !ok || kg != 1.25 {
+		t.Fatalf("got %v, %v", kg, ok)
+	}
+	if _, ok := parseEmbeddedWeightBarcode("123456780125", "12345678"); ok {
+		t.Fatal("short barcode accepted")
+	}
+	if _, ok := parseEmbeddedWeightBarcode("9934567801250", "12345678"); ok {
+		t.Fatal("wrong prefix accepted")
+	}
+}
@@ -0,0 +1,765 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	apimail "pos-system/backend/internal/mail"
+	"pos-system/backend/internal/models"
+)
+
+// auditEntity writes an audit log for a wholesale entity other than an order (quotation, standing order, ...).
+func (h *WholesaleOrderHandler) auditEntity(c *gin.Context, entityType, action string, entityID uint, changes map[string]interface{}) {
+	userIDVal, _ := c.Get("user_id")
+	var uid *uint
+	if id, ok := userIDVal.(uint); ok {
+		uid = &id
+	}
+	changesJSON, _ := json.Marshal(changes)
+	h.db.Create(&models.AuditLog{
+		UserID:     uid,
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   &entityID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+}
+
+type WholesaleQuotationItemRequest struct {
+	ProductID          uint     `json:"product_id" binding:"required"`
+	Quantity           float64  `json:"quantity" binding:"required"`
+	UnitPrice          *float64 `json:"unit_price"` // quoted price; defaults to the client's current wholesale price
+	LineDiscountAmount float64  `json:"line_discount_amount"`
+}
+
+type CreateWholesaleQuotationRequest struct {
+	Type                   string                          `json:"type"` // "quotation" (default) or "proforma"
+	WholesaleClientID      uint                            `json:"wholesale_client_id" binding:"required"`
+	WholesaleClientStoreID *uint                           `json:"wholesale_client_store_id"`
+	StoreID                *uint                           `json:"store_id"`
+	SectorID               *uint                           `json:"sector_id"`
+	ValidUntil             string                          `json:"valid_until"` // YYYY-MM-DD; defaults to 14 days from today
+	PaymentTerms           string                          `json:"payment_terms"`
+	Notes                  string                          `json:"notes"`
+	TotalDiscount          float64                         `json:"total_discount"`
+	ShippingFee            float64                         `json:"shipping_fee"`
+	Items                  []WholesaleQuotationItemRequest `json:"items" binding:"required,min=1"`
+}
+
+const defaultQuotationValidityDays = 14
+
+func quotationIsEditable(status string) bool {
+	return status == models.WholesaleQuotationStatusDraft || status == models.WholesaleQuotationStatusSent
+}
+
+// quotationExpired reports whether valid_until is before today (valid_until itself is still valid).
+func quotationExpired(q *models.WholesaleQuotation, now time.Time) bool {
+	if q.ValidUntil == nil {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	validUntil := time.Date(q.ValidUntil.Year(), q.ValidUntil.Month(), q.ValidUntil.Day(), 0, 0, 0, 0, time.UTC)
+	return validUntil.Before(today)
+}
+
+// buildQuotationItems prices each line, using the quoted unit price when given.
+func (h *WholesaleOrderHandler) buildQuotationItems(reqItems []WholesaleQuotationItemRequest, sectorID *uint) ([]models.WholesaleQuotationItem, error) {
+	now := time.Now()
+	priceDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	items := make([]models.WholesaleQuotationItem, 0, len(reqItems))
+	for _, it := range reqItems {
+		if it.Quantity <= 0 {
+			return nil, fmt.Errorf("Quantity must be greater than 0 for product %d", it.ProductID)
+		}
+		var product models.Product
+		if err := h.db.First(&product, it.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("Product %d not found", it.ProductID)
+		}
+		var unitPrice float64
+		if it.UnitPrice != nil {
+			unitPrice = *it.UnitPrice
+		} else {
+			unitPrice = h.wholesaleUnitPrice(product.ID, sectorID, priceDate, now)
+		}
+		if unitPrice < 0 {
+			unitPrice = 0
+		}
+		lineDiscount := it.LineDiscountAmount
+		if lineDiscount < 0 {
+			lineDiscount = 0
+		}
+		lineTotal := unitPrice*it.Quantity - lineDiscount
+		if lineTotal < 0 {
+			lineTotal = 0
+		}
+		items = append(items, models.WholesaleQuotationItem{
+			ProductID:          it.ProductID,
+			Quantity:           it.Quantity,
+			UnitPrice:          unitPrice,
+			LineDiscountAmount: lineDiscount,
+			LineTotal:          lineTotal,
+		})
+	}
+	return items, nil
+}
+
+// applyQuotationTotals recomputes subtotal/net/amount due from items, clamping the order-level discount.
+func applyQuotationTotals(q *models.WholesaleQuotation, items []models.WholesaleQuotationItem) {
+	var subtotal float64
+	for _, it := range items {
+		subtotal += it.LineTotal
+	}
+	if q.DiscountAmount < 0 {
+		q.DiscountAmount = 0
+	}
+	if q.DiscountAmount > subtotal {
+		q.DiscountAmount = subtotal
+	}
+	q.Subtotal = subtotal
+	q.TotalNet = subtotal - q.DiscountAmount
+	q.AmountDue = q.TotalNet + q.VATTotal
+}
+
+// quotationAsOrder maps a quotation onto a WholesaleOrder so the OC/invoice PDF layout can render it.
+func quotationAsOrder(q *models.WholesaleQuotation) *models.WholesaleOrder {
+	items := make([]models.WholesaleOrderItem, 0, len(q.Items))
+	for _, it := range q.Items {
+		items = append(items, models.WholesaleOrderItem{
+			ProductID:          it.ProductID,
+			Quantity:           it.Quantity,
+			UnitPrice:          it.UnitPrice,
+			LineDiscountAmount: it.LineDiscountAmount,
+			LineTotal:          it.LineTotal,
+			Product:            it.Product,
+		})
+	}
+	return &models.WholesaleOrder{
+		ID:                     q.ID,
+		OrderNumber:            q.QuoteNumber,
+		RefNo:                  q.QuoteNumber,
+		WholesaleClientID:      q.WholesaleClientID,
+		WholesaleClientStoreID: q.WholesaleClientStoreID,
+		Subtotal:               q.Subtotal,
+		DiscountAmount:         q.DiscountAmount,
+		TotalNet:               q.TotalNet,
+		VATTotal:               q.VATTotal,
+		AmountDue:              q.AmountDue,
+		ShippingFee:            q.ShippingFee,
+		PaymentTerms:           q.PaymentTerms,
+		Notes:                  q.Notes,
+		CreatedAt:              q.CreatedAt,
+		WholesaleClient:        q.WholesaleClient,
+		WholesaleClientStore:   q.WholesaleClientStore,
+		Items:                  items,
+	}
+}
+
+// generateQuotationPDF renders the quotation (or pro-forma) in the OC/invoice layout, stores it and saves pdf_url.
+func (h *WholesaleOrderHandler) generateQuotationPDF(q *models.WholesaleQuotation) (string, error) {
+	url, err := h.renderWholesaleOrderPDF(quotationAsOrder(q), q.Type, q.ValidUntil)
+	if err != nil {
+		return "", err
+	}
+	q.PDFURL = url
+	if err := h.db.Model(q).Update("pdf_url", url).Error; err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+func (h *WholesaleOrderHandler) loadQuotation(id interface{}) (models.WholesaleQuotation, error) {
+	var q models.WholesaleQuotation
+	err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").
+		Preload("Store").Preload("User").Preload("Sector").
+		First(&q, id).Error
+	return q, err
+}
+
+// rejectIfQuotationNotVisible aborts with 403 when a user without wholesale.view_all requests a quotation outside
+// their work scope.
+func (h *WholesaleOrderHandler) rejectIfQuotationNotVisible(c *gin.Context, q *models.WholesaleQuotation) bool {
+	if hasPermission(c, models.PermWholesaleViewAll) {
+		return false
+	}
+	userIDInterface, _ := c.Get("user_id")
+	userID, _ := userIDInterface.(uint)
+	if q.UserID == userID || uintSliceContains(posUserWholesaleClientIDs(h.db, userID), q.WholesaleClientID) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to view this quotation"})
+	c.Abort()
+	return true
+}
+
+// CreateQuotation creates a quotation or pro-forma invoice for a wholesale client and generates its PDF.
+func (h *WholesaleOrderHandler) CreateQuotation(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	var req CreateWholesaleQuotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quoteType := strings.TrimSpace(strings.ToLower(req.Type))
+	if quoteType == "" {
+		quoteType = models.WholesaleQuotationTypeQuotation
+	}
+	if quoteType != models.WholesaleQuotationTypeQuotation && quoteType != models.WholesaleQuotationTypeProforma {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be quotation or proforma"})
+		return
+	}
+
+	var client models.WholesaleClient
+	if err := h.db.First(&client, req.WholesaleClientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wholesale client not found"})
+		return
+	}
+	if !client.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wholesale client is inactive"})
+		return
+	}
+	sectorID := req.SectorID
+	if sectorID == nil && client.SectorID != nil {
+		sectorID = client.SectorID
+	}
+
+	items, err := h.buildQuotationItems(req.Items, sectorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	validUntil := parsePODate(req.ValidUntil)
+	if validUntil == nil {
+		d := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, defaultQuotationValidityDays)
+		validUntil = &d
+	}
+	paymentTerms := strings.TrimSpace(req.PaymentTerms)
+	if paymentTerms == "" {
+		paymentTerms = client.Terms
+	}
+	shippingFee := req.ShippingFee
+	if shippingFee < 0 {
+		shippingFee = 0
+	}
+	q := models.WholesaleQuotation{
+		Type:                   quoteType,
+		WholesaleClientID:      req.WholesaleClientID,
+		WholesaleClientStoreID: req.WholesaleClientStoreID,
+		StoreID:                req.StoreID,
+		UserID:                 userID,
+		SectorID:               sectorID,
+		Status:                 models.WholesaleQuotationStatusDraft,
+		ValidUntil:             validUntil,
+		DiscountAmount:         req.TotalDiscount,
+		ShippingFee:            shippingFee,
+		PaymentTerms:           paymentTerms,
+		Notes:                  req.Notes,
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+	applyQuotationTotals(&q, items)
+	if err := h.db.Create(&q).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	prefix := "Q"
+	if quoteType == models.WholesaleQuotationTypeProforma {
+		prefix = "PF"
+	}
+	q.QuoteNumber = fmt.Sprintf("%s%d", prefix, q.ID)
+	h.db.Model(&q).Update("quote_number", q.QuoteNumber)
+
+	for i := range items {
+		items[i].WholesaleQuotationID = q.ID
+	}
+	if err := h.db.Create(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.auditEntity(c, "wholesale_quotation", "wholesale_quotation_create", q.ID, map[string]interface{}{
+		"quote_number": q.QuoteNumber, "type": quoteType, "client_id": req.WholesaleClientID,
+		"item_count": len(items), "subtotal": q.Subtotal,
+	})
+
+	created, err := h.loadQuotation(q.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.generateQuotationPDF(&created); err != nil {
+		fmt.Printf("Failed to generate PDF for wholesale quotation %d: %v\n", created.ID, err)
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListQuotations lists quotations; filters: client_id, status, type. POS users see their own and their clients' quotations.
+func (h *WholesaleOrderHandler) ListQuotations(c *gin.Context) {
+	query := h.db.Model(&models.WholesaleQuotation{}).
+		Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("User").Preload("Items.Product")
+	if v := strings.TrimSpace(c.Query("client_id")); v != "" {
+		query = query.Where("wholesale_client_id = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("status")); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("type")); v != "" {
+		query = query.Where("type = ?", v)
+	}
+	if !hasPermission(c, models.PermWholesaleViewAll) {
+		userIDInterface, _ := c.Get("user_id")
+		userID := userIDInterface.(uint)
+		if clientIDs := posUserWholesaleClientIDs(h.db, userID); len(clientIDs) > 0 {
+			query = query.Where("(user_id = ? OR wholesale_client_id IN ?)", userID, clientIDs)
+		} else {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+	var list []models.WholesaleQuotation
+	if err := query.Order("created_at DESC").Limit(500).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetQuotation returns one quotation with items.
+func (h *WholesaleOrderHandler) GetQuotation(c *gin.Context) {
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+// UpdateQuotation edits a draft/sent quotation. When items are given they replace all lines. The PDF is regenerated.
+func (h *WholesaleOrderHandler) UpdateQuotation(c *gin.Context) {
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	if !quotationIsEditable(q.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quotation can no longer be edited"})
+		return
+	}
+	var req struct {
+		WholesaleClientStoreID *uint                           `json:"wholesale_client_store_id"`
+		StoreID                *uint                           `json:"store_id"`
+		ValidUntil             *string                         `json:"valid_until"`
+		PaymentTerms           *string                         `json:"payment_terms"`
+		Notes                  *string                         `json:"notes"`
+		DiscountAmount         *float64                        `json:"discount_amount"`
+		ShippingFee            *float64                        `json:"shipping_fee"`
+		Items                  []WholesaleQuotationItemRequest `json:"items"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	changes := map[string]interface{}{}
+	if req.WholesaleClientStoreID != nil {
+		changes["wholesale_client_store_id"] = map[string]interface{}{"old": q.WholesaleClientStoreID, "new": *req.WholesaleClientStoreID}
+		q.WholesaleClientStoreID = req.WholesaleClientStoreID
+		q.WholesaleClientStore = nil
+	}
+	if req.StoreID != nil {
+		changes["store_id"] = map[string]interface{}{"old": q.StoreID, "new": *req.StoreID}
+		q.StoreID = req.StoreID
+		q.Store = nil
+	}
+	if req.ValidUntil != nil {
+		oldDate := ""
+		if q.ValidUntil != nil {
+			oldDate = q.ValidUntil.Format("2006-01-02")
+		}
+		changes["valid_until"] = map[string]interface{}{"old": oldDate, "new": *req.ValidUntil}
+		q.ValidUntil = parsePODate(*req.ValidUntil)
+	}
+	if req.PaymentTerms != nil {
+		changes["payment_terms"] = map[string]interface{}{"old": q.PaymentTerms, "new": *req.PaymentTerms}
+		q.PaymentTerms = strings.TrimSpace(*req.PaymentTerms)
+	}
+	if req.Notes != nil {
+		q.Notes = *req.Notes
+		changes["notes"] = true
+	}
+	if req.DiscountAmount != nil {
+		changes["discount_amount"] = map[string]interface{}{"old": q.DiscountAmount, "new": *req.DiscountAmount}
+		q.DiscountAmount = *req.DiscountAmount
+	}
+	if req.ShippingFee != nil {
+		fee := *req.ShippingFee
+		if fee < 0 {
+			fee = 0
+		}
+		changes["shipping_fee"] = map[string]interface{}{"old": q.ShippingFee, "new": fee}
+		q.ShippingFee = fee
+	}
+	items := q.Items
+	if len(req.Items) > 0 {
+		newItems, err := h.buildQuotationItems(req.Items, q.SectorID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.db.Where("wholesale_quotation_id = ?", q.ID).Delete(&models.WholesaleQuotationItem{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range newItems {
+			newItems[i].WholesaleQuotationID = q.ID
+		}
+		if err := h.db.Create(&newItems).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes["items"] = map[string]interface{}{"old_count": len(q.Items), "new_count": len(newItems)}
+		items = newItems
+	}
+	applyQuotationTotals(&q, items)
+	q.Items = nil
+	if err := h.db.Omit("WholesaleClient", "WholesaleClientStore", "Store", "User", "Sector", "Items").Save(&q).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(changes) > 0 {
+		h.auditEntity(c, "wholesale_quotation", "wholesale_quotation_update", q.ID, changes)
+	}
+	updated, err := h.loadQuotation(q.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.generateQuotationPDF(&updated); err != nil {
+		fmt.Printf("Failed to regenerate PDF for wholesale quotation %d: %v\n", updated.ID, err)
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// CancelQuotation marks a draft/sent quotation as cancelled.
+func (h *WholesaleOrderHandler) CancelQuotation(c *gin.Context) {
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	if !quotationIsEditable(q.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or sent quotations can be cancelled"})
+		return
+	}
+	oldStatus := q.Status
+	if err := h.db.Model(&q).Update("status", models.WholesaleQuotationStatusCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "wholesale_quotation", "wholesale_quotation_cancel", q.ID, map[string]interface{}{
+		"old_status": oldStatus, "new_status": models.WholesaleQuotationStatusCancelled,
+	})
+	c.JSON(http.StatusOK, q)
+}
+
+// DownloadQuotationPDF streams the quotation PDF, generating it first if missing.
+func (h *WholesaleOrderHandler) DownloadQuotationPDF(c *gin.Context) {
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	pdfBytes, err := h.quotationPDFBytes(&q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read quotation PDF: " + err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", quotationAttachmentFilename(&q)))
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+func (h *WholesaleOrderHandler) quotationPDFBytes(q *models.WholesaleQuotation) ([]byte, error) {
+	if strings.TrimSpace(q.PDFURL) == "" {
+		if _, err := h.generateQuotationPDF(q); err != nil {
+			return nil, err
+		}
+	}
+	return h.readBytesFromFileURL(q.PDFURL)
+}
+
+func quotationDocLabel(q *models.WholesaleQuotation) string {
+	if q.Type == models.WholesaleQuotationTypeProforma {
+		return "Pro-forma invoice"
+	}
+	return "Quotation"
+}
+
+func quotationAttachmentFilename(q *models.WholesaleQuotation) string {
+	ref := strings.ReplaceAll(strings.ReplaceAll(q.QuoteNumber, "/", "_"), "\\", "_")
+	return fmt.Sprintf("%s_%s.pdf", ref, q.Type)
+}
+
+func wholesaleQuotationEmailBody(q *models.WholesaleQuotation, contactEmail string) string {
+	if strings.TrimSpace(contactEmail) == "" {
+		contactEmail = "hello@ducklincompany.co.uk"
+	}
+	clientName := strings.TrimSpace(q.WholesaleClient.Name)
+	if clientName == "" {
+		clientName = "Customer"
+	}
+	validUntil := "—"
+	if q.ValidUntil != nil {
+		validUntil = ordinalDay(q.ValidUntil.Day()) + " " + q.ValidUntil.Format("January 2006")
+	}
+	return fmt.Sprintf(
+		"Dear %s,\n\nPlease find attached our %s:\n\nReference: %s\nTotal: £%.2f\nValid until: %s\n\nPlease contact us by email %s if you have any queries or would like to go ahead.\n\nPlease do not reply this email. This message was sent from the Ducklin POS management portal.\n",
+		clientName,
+		strings.ToLower(quotationDocLabel(q)),
+		q.QuoteNumber,
+		q.TotalNet+q.ShippingFee,
+		validUntil,
+		strings.TrimSpace(contactEmail),
+	)
+}
+
+// EmailQuotation sends the quotation PDF to the client and marks a draft as sent.
+func (h *WholesaleOrderHandler) EmailQuotation(c *gin.Context) {
+	if strings.TrimSpace(h.cfg.SMTPHost) == "" || strings.TrimSpace(h.cfg.SMTPUser) == "" || h.cfg.SMTPPassword == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured (set SMTP_HOST, SMTP_USER, SMTP_PASSWORD)"})
+		return
+	}
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	if q.Status == models.WholesaleQuotationStatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quotation has been cancelled"})
+		return
+	}
+	var req struct {
+		To  []string `json:"to"`
+		CC  string   `json:"cc"`
+		BCC string   `json:"bcc"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	toList := append([]string{}, req.To...)
+	if len(toList) == 0 {
+		if q.WholesaleClientStore != nil && strings.TrimSpace(q.WholesaleClientStore.Email) != "" {
+			toList = append(toList, strings.TrimSpace(q.WholesaleClientStore.Email))
+		} else if email := strings.TrimSpace(q.WholesaleClient.Email); email != "" {
+			toList = append(toList, email)
+		}
+	}
+	if len(toList) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No recipient email address"})
+		return
+	}
+	ccList := parseEmailList(req.CC)
+	bccList := parseEmailList(req.BCC)
+
+	pdfBytes, err := h.quotationPDFBytes(&q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read quotation PDF: " + err.Error()})
+		return
+	}
+	var company models.CompanySettings
+	_ = h.db.Select("email").First(&company, companySettingsID).Error
+
+	attachFilename := quotationAttachmentFilename(&q)
+	subject := fmt.Sprintf("%s — %s (%s)", quotationDocLabel(&q), q.QuoteNumber, q.WholesaleClient.Name)
+	body := wholesaleQuotationEmailBody(&q, company.Email)
+	if err := apimail.SendWithAttachments(
+		c.Request.Context(), h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword, h.cfg.EffectiveSMTPFrom(),
+		toList, ccList, bccList, subject, body,
+		[]apimail.Attachment{{Filename: attachFilename, ContentType: "application/pdf", Data: pdfBytes}},
+	); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send email: " + err.Error()})
+		return
+	}
+
+	sentAt := time.Now().UTC()
+	updates := map[string]interface{}{"sent_at": sentAt}
+	if q.Status == models.WholesaleQuotationStatusDraft {
+		updates["status"] = models.WholesaleQuotationStatusSent
+	}
+	_ = h.db.Model(&q).Updates(updates).Error
+
+	changes := map[string]interface{}{
+		"to":         toList,
+		"sent_at":    sentAt.Format(time.RFC3339),
+		"attachment": attachFilename,
+	}
+	if len(ccList) > 0 {
+		changes["cc_list"] = ccList
+	}
+	if len(bccList) > 0 {
+		changes["bcc_list"] = bccList
+	}
+	h.auditEntity(c, "wholesale_quotation", "wholesale_quotation_email", q.ID, changes)
+	c.JSON(http.StatusOK, gin.H{"message": "Email sent", "to": toList, "sent_at": sentAt.Format(time.RFC3339), "quotation": q})
+}
+
+// ConvertQuotation creates a pending_approval WholesaleOrder from a quotation, keeping the quoted line prices.
+func (h *WholesaleOrderHandler) ConvertQuotation(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	q, err := h.loadQuotation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quotation not found"})
+		return
+	}
+	if h.rejectIfQuotationNotVisible(c, &q) {
+		return
+	}
+	var req struct {
+		StoreID      *uint  `json:"store_id"`
+		PONumber     string `json:"po_number"`
+		OrderChannel string `json:"order_channel"`
+		PODate       string `json:"po_date"`
+		OrderDate    string `json:"order_date"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !quotationIsEditable(q.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or sent quotations can be converted"})
+		return
+	}
+	if quotationExpired(&q, time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quotation has expired; update valid_until before converting"})
+		return
+	}
+	if len(q.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quotation has no lines"})
+		return
+	}
+	storeID := q.StoreID
+	if req.StoreID != nil {
+		storeID = req.StoreID
+	}
+	if storeID == nil || *storeID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store_id is required to convert a quotation"})
+		return
+	}
+
+	// Claim the quotation first so a double click cannot create two orders.
+	res := h.db.Model(&models.WholesaleQuotation{}).
+		Where("id = ? AND status IN ?", q.ID, []string{models.WholesaleQuotationStatusDraft, models.WholesaleQuotationStatusSent}).
+		Update("status", models.WholesaleQuotationStatusConverted)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Quotation was already converted or cancelled"})
+		return
+	}
+	releaseClaim := func() {
+		h.db.Model(&models.WholesaleQuotation{}).Where("id = ?", q.ID).Update("status", q.Status)
+	}
+
+	now := time.Now()
+	orderChannel := strings.TrimSpace(strings.ToLower(req.OrderChannel))
+	poNumber := strings.TrimSpace(req.PONumber)
+	if orderChannel == "whatsapp" {
+		poNumber = ""
+	}
+	quotationID := q.ID
+	wo := models.WholesaleOrder{
+		OrderNumber:            fmt.Sprintf("WO-%s-%d", now.Format("20060102"), now.Unix()%100000),
+		WholesaleClientID:      q.WholesaleClientID,
+		WholesaleClientStoreID: q.WholesaleClientStoreID,
+		StoreID:                *storeID,
+		UserID:                 userID,
+		SectorID:               q.SectorID,
+		PONumber:               poNumber,
+		PODate:                 parsePODate(req.PODate),
+		OrderDate:              parsePODate(req.OrderDate),
+		PaymentTerms:           q.PaymentTerms,
+		ShippingFee:            q.ShippingFee,
+		Status:                 models.WholesaleOrderStatusPending,
+		Subtotal:               q.Subtotal,
+		DiscountAmount:         q.DiscountAmount,
+		TotalNet:               q.TotalNet,
+		VATTotal:               q.VATTotal,
+		AmountDue:              q.AmountDue,
+		Notes:                  q.Notes,
+		WholesaleQuotationID:   &quotationID,
+		CreatedAt:              now,
+	}
+	if err := h.db.Create(&wo).Error; err != nil {
+		releaseClaim()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.assignOrderRefAndPONumber(&wo, orderChannel)
+
+	items := make([]models.WholesaleOrderItem, 0, len(q.Items))
+	for _, it := range q.Items {
+		items = append(items, models.WholesaleOrderItem{
+			WholesaleOrderID:   wo.ID,
+			ProductID:          it.ProductID,
+			Quantity:           it.Quantity,
+			UnitPrice:          it.UnitPrice,
+			LineDiscountAmount: it.LineDiscountAmount,
+			LineTotal:          it.LineTotal,
+		})
+	}
+	if err := h.db.Create(&items).Error; err != nil {
+		h.db.Delete(&models.WholesaleOrder{}, wo.ID)
+		releaseClaim()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.db.Model(&models.WholesaleQuotation{}).Where("id = ?", q.ID).Updates(map[string]interface{}{
+		"converted_order_id": wo.ID, "converted_at": now,
+	})
+
+	h.audit(c, "wholesale_order_create", wo.ID, map[string]interface{}{
+		"order_number": wo.OrderNumber, "client_id": wo.WholesaleClientID,
+		"po_number": req.PONumber, "item_count": len(items), "subtotal": wo.Subtotal,
+		"quotation_id": q.ID, "quote_number": q.QuoteNumber,
+	})
+	h.auditEntity(c, "wholesale_quotation", "wholesale_quotation_convert", q.ID, map[string]interface{}{
+		"order_id": wo.ID, "ref_no": wo.RefNo, "old_status": q.Status, "new_status": models.WholesaleQuotationStatusConverted,
+	})
+
+	var created models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("Store").Preload("User").Preload("Sector").
+		First(&created, wo.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
@@ -0,0 +1,527 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errWholesaleReturnStatusChanged aborts a transition when another request moved the return first.
+var errWholesaleReturnStatusChanged = errors.New("return status changed")
+
+// errReturnExceedsShipped aborts a return whose lines ask for more than was shipped and not yet returned.
+var errReturnExceedsShipped = errors.New("return exceeds shipped quantity")
+
+type WholesaleReturnItemRequest struct {
+	WholesaleOrderItemID uint    `json:"wholesale_order_item_id" binding:"required"`
+	Quantity             float64 `json:"quantity" binding:"required"`
+	Reason               string  `json:"reason"`
+}
+
+type CreateWholesaleReturnRequest struct {
+	StoreID *uint                        `json:"store_id"` // return-to store; defaults to the order's store
+	Notes   string                       `json:"notes"`
+	Items   []WholesaleReturnItemRequest `json:"items" binding:"required,min=1"`
+}
+
+func (h *WholesaleOrderHandler) loadWholesaleReturn(id interface{}) (models.WholesaleReturn, error) {
+	var ret models.WholesaleReturn
+	err := h.db.Preload("Items.Product").Preload("WholesaleOrder").Preload("WholesaleClient").
+		Preload("Store").Preload("User").Preload("Inspector").
+		First(&ret, id).Error
+	return ret, err
+}
+
+// returnedQtyForOrderItem sums quantities on non-cancelled returns for one order line.
+func returnedQtyForOrderItem(tx *gorm.DB, itemID uint) (float64, error) {
+	var total float64
+	err := tx.Model(&models.WholesaleReturnItem{}).
+		Joins("JOIN wholesale_returns r ON r.id = wholesale_return_items.wholesale_return_id").
+		Where("wholesale_return_items.wholesale_order_item_id = ? AND r.status != ?", itemID, models.WholesaleReturnStatusCancelled).
+		Select("COALESCE(SUM(wholesale_return_items.quantity), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// shippedQtyForOrderItem sums one order line's units on shipments that have left the store (shipped or completed).
+func shippedQtyForOrderItem(tx *gorm.DB, item models.WholesaleOrderItem) (float64, error) {
+	var rows []models.ShipmentItem
+	err := tx.Model(&models.ShipmentItem{}).
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipment_items.wholesale_order_item_id = ? AND shipments.status IN ?", item.ID,
+			[]string{models.ShipmentStatusShipped, models.ShipmentStatusCompleted}).
+		Find(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for i := range rows {
+		total += effectiveShipmentItemQty(&rows[i], item.Quantity)
+	}
+	return total, nil
+}
+
+// CreateReturn authorises a return (RMA) of delivered lines on a wholesale order.
+func (h *WholesaleOrderHandler) CreateReturn(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("Items").First(&wo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, wo.Status) {
+		return
+	}
+	if wo.Status != models.WholesaleOrderStatusApproved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Returns can only be raised against approved orders"})
+		return
+	}
+	var req CreateWholesaleReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	storeID := wo.StoreID
+	if req.StoreID != nil {
+		var st models.Store
+		if err := h.db.First(&st, *req.StoreID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Store %d not found", *req.StoreID)})
+			return
+		}
+		storeID = *req.StoreID
+	}
+
+	itemsByID := make(map[uint]models.WholesaleOrderItem, len(wo.Items))
+	for _, it := range wo.Items {
+		itemsByID[it.ID] = it
+	}
+	requested := make(map[uint]float64)
+	var requestedOrder []uint
+	items := make([]models.WholesaleReturnItem, 0, len(req.Items))
+	for _, it := range req.Items {
+		orderItem, ok := itemsByID[it.WholesaleOrderItemID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All item IDs must belong to this order"})
+			return
+		}
+		if it.Quantity <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quantity must be greater than 0 for item %d", orderItem.ID)})
+			return
+		}
+		if _, seen := requested[orderItem.ID]; !seen {
+			requestedOrder = append(requestedOrder, orderItem.ID)
+		}
+		requested[orderItem.ID] += it.Quantity
+		unitPrice := orderItem.UnitPrice
+		if orderItem.Quantity > 0 {
+			unitPrice = orderItem.LineTotal / orderItem.Quantity
+		}
+		items = append(items, models.WholesaleReturnItem{
+			WholesaleOrderItemID: orderItem.ID,
+			ProductID:            orderItem.ProductID,
+			Quantity:             it.Quantity,
+			UnitPrice:            unitPrice,
+			Reason:               strings.TrimSpace(it.Reason),
+		})
+	}
+
+	ret := models.WholesaleReturn{
+		WholesaleOrderID:  wo.ID,
+		WholesaleClientID: wo.WholesaleClientID,
+		StoreID:           storeID,
+		UserID:            userID,
+		Status:            models.WholesaleReturnStatusAuthorised,
+		Notes:             req.Notes,
+	}
+	var exceeded string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so two returns raised together cannot both claim the same units.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.WholesaleOrder{}, wo.ID).Error; err != nil {
+			return err
+		}
+		for _, id := range requestedOrder {
+			shipped, err := shippedQtyForOrderItem(tx, itemsByID[id])
+			if err != nil {
+				return err
+			}
+			returned, err := returnedQtyForOrderItem(tx, id)
+			if err != nil {
+				return err
+			}
+			if returnable := shipped - returned; requested[id] > returnable+0.0001 {
+				exceeded = fmt.Sprintf("Return quantity %.3f exceeds returnable %.3f (shipped %.3f, already returned %.3f) for item %d",
+					requested[id], returnable, shipped, returned, id)
+				return errReturnExceedsShipped
+			}
+		}
+		if err := tx.Create(&ret).Error; err != nil {
+			return err
+		}
+		ret.RMANumber = fmt.Sprintf("RMA%d", ret.ID)
+		if err := tx.Model(&ret).Update("rma_number", ret.RMANumber).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].WholesaleReturnID = ret.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if errors.Is(err, errReturnExceedsShipped) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": exceeded})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	lines := make([]map[string]interface{}, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, map[string]interface{}{"item_id": it.WholesaleOrderItemID, "quantity": it.Quantity, "reason": it.Reason})
+	}
+	h.audit(c, "wholesale_return_create", wo.ID, map[string]interface{}{
+		"return_id": ret.ID, "rma_number": ret.RMANumber, "store_id": storeID, "lines": lines,
+	})
+	created, err := h.loadWholesaleReturn(ret.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListOrderReturns lists returns raised against one wholesale order.
+func (h *WholesaleOrderHandler) ListOrderReturns(c *gin.Context) {
+	var list []models.WholesaleReturn
+	if err := h.db.Preload("Items.Product").Preload("Store").Preload("User").Preload("Inspector").
+		Where("wholesale_order_id = ?", c.Param("id")).Order("id DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ListReturns lists all returns, optionally filtered by status and client.
+func (h *WholesaleOrderHandler) ListReturns(c *gin.Context) {
+	q := h.db.Model(&models.WholesaleReturn{}).Preload("WholesaleOrder").Preload("WholesaleClient").Preload("Store")
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if cid := c.Query("client_id"); cid != "" {
+		q = q.Where("wholesale_client_id = ?", cid)
+	}
+	var list []models.WholesaleReturn
+	if err := q.Order("id DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetReturn returns one return with its lines.
+func (h *WholesaleOrderHandler) GetReturn(c *gin.Context) {
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// ReceiveReturn records that the goods arrived at the return-to store.
+func (h *WholesaleOrderHandler) ReceiveReturn(c *gin.Context) {
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	now := time.Now()
+	res := h.db.Model(&models.WholesaleReturn{}).
+		Where("id = ? AND status = ?", ret.ID, models.WholesaleReturnStatusAuthorised).
+		Updates(map[string]interface{}{"status": models.WholesaleReturnStatusReceived, "received_at": now})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only authorised returns can be received"})
+		return
+	}
+	h.audit(c, "wholesale_return_receive", ret.WholesaleOrderID, map[string]interface{}{
+		"return_id": ret.ID, "rma_number": ret.RMANumber, "old_status": ret.Status, "new_status": models.WholesaleReturnStatusReceived,
+	})
+	updated, _ := h.loadWholesaleReturn(ret.ID)
+	c.JSON(http.StatusOK, updated)
+}
+
+// InspectReturn sets each line's disposition and adds restocked quantities to the return-to store's stock.
+func (h *WholesaleOrderHandler) InspectReturn(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	if ret.Status != models.WholesaleReturnStatusReceived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Return must be received before inspection"})
+		return
+	}
+	var req struct {
+		Lines []struct {
+			ItemID      uint   `json:"item_id" binding:"required"` // wholesale_return_items.id
+			Disposition string `json:"disposition" binding:"required"`
+			Notes       string `json:"notes"`
+		} `json:"lines" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dispositions := make(map[uint]string, len(req.Lines))
+	notes := make(map[uint]string, len(req.Lines))
+	for _, l := range req.Lines {
+		d := strings.TrimSpace(strings.ToLower(l.Disposition))
+		switch d {
+		case models.WholesaleReturnDispositionRestock, models.WholesaleReturnDispositionWriteOff, models.WholesaleReturnDispositionSupplier:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "disposition must be restock, write_off or return_to_supplier"})
+			return
+		}
+		dispositions[l.ItemID] = d
+		notes[l.ItemID] = strings.TrimSpace(l.Notes)
+	}
+	for _, it := range ret.Items {
+		if _, ok := dispositions[it.ID]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Missing disposition for return line %d", it.ID)})
+			return
+		}
+	}
+
+	// The status claim, dispositions and restock commit together: a double submit cannot restock twice and a failed
+	// restock leaves the return received.
+	now := time.Now()
+	lines := make([]map[string]interface{}, 0, len(ret.Items))
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.WholesaleReturn{}).
+			Where("id = ? AND status = ?", ret.ID, models.WholesaleReturnStatusReceived).
+			Updates(map[string]interface{}{"status": models.WholesaleReturnStatusInspected, "inspected_at": now, "inspected_by": userID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errWholesaleReturnStatusChanged
+		}
+		for i := range ret.Items {
+			it := &ret.Items[i]
+			d := dispositions[it.ID]
+			if err := tx.Model(&models.WholesaleReturnItem{}).Where("id = ?", it.ID).
+				Updates(map[string]interface{}{"disposition": d, "inspection_notes": notes[it.ID]}).Error; err != nil {
+				return err
+			}
+			line := map[string]interface{}{"item_id": it.WholesaleOrderItemID, "product_id": it.ProductID, "quantity": it.Quantity, "disposition": d}
+			if d == models.WholesaleReturnDispositionRestock {
+				if err := restockReturnedItem(tx, c, &ret, it); err != nil {
+					return err
+				}
+				line["store_id"] = ret.StoreID
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if errors.Is(err, errWholesaleReturnStatusChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Return was already inspected"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "wholesale_return_inspect", ret.WholesaleOrderID, map[string]interface{}{
+		"return_id": ret.ID, "rma_number": ret.RMANumber, "lines": lines,
+	})
+	updated, _ := h.loadWholesaleReturn(ret.ID)
+	c.JSON(http.StatusOK, updated)
+}
+
+// restockReturnedItem adds a returned line back into the return-to store's stock and records a stock audit, inside
+// the caller's transaction.
+func restockReturnedItem(tx *gorm.DB, c *gin.Context, ret *models.WholesaleReturn, it *models.WholesaleReturnItem) error {
+	var stock models.Stock
+	oldQuantity := 0.0
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND store_id = ?", it.ProductID, ret.StoreID).First(&stock).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		stock = models.Stock{ProductID: it.ProductID, StoreID: ret.StoreID}
+	case err != nil:
+		return err
+	default:
+		oldQuantity = stock.Quantity
+	}
+	stock.Quantity += it.Quantity
+	stock.LastUpdated = time.Now()
+	if err := tx.Save(&stock).Error; err != nil {
+		return err
+	}
+
+	userIDVal, _ := c.Get("user_id")
+	var uid *uint
+	if id, ok := userIDVal.(uint); ok {
+		uid = &id
+	}
+	changesJSON, _ := json.Marshal(map[string]interface{}{
+		"product_id": it.ProductID, "store_id": ret.StoreID, "old_quantity": oldQuantity, "new_quantity": stock.Quantity,
+		"added_quantity": it.Quantity, "reason": "wholesale_return_restock", "wholesale_return_id": ret.ID,
+	})
+	return tx.Create(&models.AuditLog{
+		UserID:     uid,
+		APIKeyID:   contextAPIKeyID(c),
+		Action:     "stock_update",
+		EntityType: "stock",
+		EntityID:   &stock.ID,
+		Changes:    string(changesJSON),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}).Error
+}
+
+// CancelReturn cancels a return that has not been inspected yet.
+func (h *WholesaleOrderHandler) CancelReturn(c *gin.Context) {
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	res := h.db.Model(&models.WholesaleReturn{}).
+		Where("id = ? AND status IN ?", ret.ID, []string{models.WholesaleReturnStatusAuthorised, models.WholesaleReturnStatusReceived}).
+		Update("status", models.WholesaleReturnStatusCancelled)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only authorised or received returns can be cancelled"})
+		return
+	}
+	h.audit(c, "wholesale_return_cancel", ret.WholesaleOrderID, map[string]interface{}{
+		"return_id": ret.ID, "rma_number": ret.RMANumber, "old_status": ret.Status, "new_status": models.WholesaleReturnStatusCancelled,
+	})
+	updated, _ := h.loadWholesaleReturn(ret.ID)
+	c.JSON(http.StatusOK, updated)
+}
+
+// returnAsCreditNoteOrder maps an inspected return onto a WholesaleOrder so the invoice PDF layout can render it.
+func returnAsCreditNoteOrder(ret *models.WholesaleReturn, wo *models.WholesaleOrder, creditDate time.Time) *models.WholesaleOrder {
+	items := make([]models.WholesaleOrderItem, 0, len(ret.Items))
+	var subtotal float64
+	for _, it := range ret.Items {
+		lineTotal := it.UnitPrice * it.Quantity
+		subtotal += lineTotal
+		items = append(items, models.WholesaleOrderItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			UnitPrice: it.UnitPrice,
+			LineTotal: lineTotal,
+			Product:   it.Product,
+		})
+	}
+	return &models.WholesaleOrder{
+		ID:                     wo.ID,
+		OrderNumber:            wo.OrderNumber + "-" + ret.CreditNoteNumber,
+		RefNo:                  ret.CreditNoteNumber,
+		PONumber:               wo.PONumber,
+		WholesaleClientID:      wo.WholesaleClientID,
+		WholesaleClientStoreID: wo.WholesaleClientStoreID,
+		OrderDate:              &creditDate,
+		Subtotal:               subtotal,
+		TotalNet:               subtotal,
+		AmountDue:              subtotal,
+		PaymentTerms:           wo.PaymentTerms,
+		Notes:                  fmt.Sprintf("Returned goods %s\nAgainst order %s", ret.RMANumber, wholesaleOrderRefLabel(wo)),
+		CreatedAt:              creditDate,
+		WholesaleClient:        wo.WholesaleClient,
+		WholesaleClientStore:   wo.WholesaleClientStore,
+		Items:                  items,
+	}
+}
+
+// GenerateReturnCreditNote renders the credit note PDF for an inspected return and attaches it to the order. Running
+// it again keeps the credit note number and replaces the earlier PDF and its order document.
+func (h *WholesaleOrderHandler) GenerateReturnCreditNote(c *gin.Context) {
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	if ret.Status != models.WholesaleReturnStatusInspected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Return must be inspected before issuing a credit note"})
+		return
+	}
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("WholesaleClient").Preload("WholesaleClientStore").First(&wo, ret.WholesaleOrderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	now := time.Now()
+	if err := ensureCreditNoteNumber(h.db, c, &ret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign credit note number: " + err.Error()})
+		return
+	}
+	creditDate := now
+	if ret.CreditedAt != nil {
+		creditDate = *ret.CreditedAt
+	}
+	doc := returnAsCreditNoteOrder(&ret, &wo, creditDate)
+	url, err := h.renderWholesaleOrderPDF(doc, creditNoteDocType, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate credit note: " + err.Error()})
+		return
+	}
+	filename := ret.CreditNoteNumber + ".pdf"
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.WholesaleReturn{}).Where("id = ?", ret.ID).Updates(map[string]interface{}{
+			"credit_note_number": ret.CreditNoteNumber, "credit_note_total": doc.AmountDue,
+			"credit_note_url": url, "credited_at": creditDate,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("wholesale_order_id = ? AND type = ? AND original_filename = ?", wo.ID, creditNoteDocType, filename).
+			Delete(&models.WholesaleOrderDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WholesaleOrderDocument{
+			WholesaleOrderID: wo.ID,
+			Type:             creditNoteDocType,
+			FileURL:          url,
+			OriginalFilename: filename,
+			CreatedAt:        now,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	changes := map[string]interface{}{
+		"return_id": ret.ID, "rma_number": ret.RMANumber, "credit_note_number": ret.CreditNoteNumber,
+		"credit_total": doc.AmountDue, "file_url": url,
+	}
+	if ret.CreditNoteURL != "" {
+		changes["replaced_file_url"] = ret.CreditNoteURL
+	}
+	h.audit(c, "wholesale_return_credit_note", wo.ID, changes)
+	updated, _ := h.loadWholesaleReturn(ret.ID)
+	c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,471 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shippingQuote is the fee a shipping rule gives for an order, with the parts it was built from.
+type shippingQuote struct {
+	RuleID         uint    `json:"rule_id"`
+	RuleName       string  `json:"rule_name,omitempty"`
+	Scope          string  `json:"scope"` // "client" or "sector"
+	OrderNet       float64 `json:"order_net"`
+	Cases          float64 `json:"cases"`
+	FreeShipping   bool    `json:"free_shipping"`
+	FlatFee        float64 `json:"flat_fee"`
+	CaseFee        float64 `json:"case_fee"`
+	ZonePrefix     string  `json:"zone_prefix,omitempty"`
+	Surcharge      float64 `json:"surcharge"`
+	Fee            float64 `json:"fee"`
+	Basis          string  `json:"basis"` // printed on the order confirmation
+	MinOrderValue  float64 `json:"min_order_value"`
+	MinOrderAction string  `json:"min_order_action"`
+	BelowMinimum   bool    `json:"below_minimum"`
+}
+
+// blocking reports whether the order must be refused for being under the rule's minimum order value.
+func (q shippingQuote) blocking() bool {
+	return q.BelowMinimum && q.MinOrderAction == models.ShippingRuleMinOrderBlock
+}
+
+func (q shippingQuote) minimumMessage() string {
+	return fmt.Sprintf("Order total £%.2f is below the minimum order value of £%.2f", q.OrderNet, q.MinOrderValue)
+}
+
+// postcodeZoneMatch scores how specifically a surcharge prefix matches a postcode: 2 for the outward code
+// ("PA20"), 1 for the postcode area ("PA"), 0 when it does not match.
+func postcodeZoneMatch(prefix, postcode string) int {
+	prefix = strings.ToUpper(strings.Join(strings.Fields(prefix), ""))
+	pc, ok := parseUKPostcode(postcode)
+	if prefix == "" || !ok {
+		return 0
+	}
+	s := strings.ToUpper(strings.Join(strings.Fields(postcode), ""))
+	outward := s
+	if pc.Sector >= 0 {
+		outward = s[:len(s)-3]
+	}
+	switch prefix {
+	case outward:
+		return 2
+	case pc.Area:
+		return 1
+	}
+	return 0
+}
+
+// orderLineCases is the number of cases a line ships in: whole boxes when the product has a units-per-box size,
+// otherwise the line counts as one case.
+func orderLineCases(qty, unitsPerBox float64) float64 {
+	if qty <= 0 {
+		return 0
+	}
+	if unitsPerBox <= 0 {
+		return 1
+	}
+	return math.Ceil(qty/unitsPerBox - qtyEpsilon)
+}
+
+// quoteShipping applies rule to an order. Over the free-shipping threshold the flat and per-case fees are waived
+// but a postcode zone surcharge still applies.
+func quoteShipping(rule *models.ShippingRule, orderNet, cases float64, postcode string) shippingQuote {
+	q := shippingQuote{
+		RuleID: rule.ID, RuleName: rule.Name, Scope: "sector", OrderNet: roundPence(orderNet), Cases: cases,
+		MinOrderValue: rule.MinOrderValue, MinOrderAction: rule.MinOrderAction,
+	}
+	if rule.WholesaleClientID != nil {
+		q.Scope = "client"
+	}
+	if q.MinOrderAction == "" {
+		q.MinOrderAction = models.ShippingRuleMinOrderWarn
+	}
+	q.BelowMinimum = rule.MinOrderValue > 0 && orderNet+0.005 < rule.MinOrderValue
+	best := 0
+	for _, z := range rule.Surcharges {
+		if m := postcodeZoneMatch(z.PostcodePrefix, postcode); m > best {
+			best = m
+			q.ZonePrefix = strings.ToUpper(strings.TrimSpace(z.PostcodePrefix))
+			q.Surcharge = z.Surcharge
+		}
+	}
+	q.FreeShipping = rule.FreeOverAmount > 0 && orderNet+0.005 >= rule.FreeOverAmount
+	if !q.FreeShipping {
+		q.FlatFee = rule.FlatFee
+		q.CaseFee = roundPence(rule.PerCaseFee * cases)
+	}
+	q.Fee = roundPence(q.FlatFee + q.CaseFee + q.Surcharge)
+
+	var parts []string
+	if q.FreeShipping {
+		parts = append(parts, fmt.Sprintf("Free delivery on orders over £%.2f", rule.FreeOverAmount))
+	} else {
+		if q.FlatFee > 0 {
+			parts = append(parts, fmt.Sprintf("£%.2f delivery", q.FlatFee))
+		}
+		if q.CaseFee > 0 {
+			parts = append(parts, fmt.Sprintf("%s cases x £%.2f", strconv.FormatFloat(cases, 'f', -1, 64), rule.PerCaseFee))
+		}
+	}
+	if q.Surcharge > 0 {
+		parts = append(parts, fmt.Sprintf("%s area surcharge £%.2f", q.ZonePrefix, q.Surcharge))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "No delivery charge")
+	}
+	q.Basis = strings.Join(parts, " + ")
+	if !q.FreeShipping && rule.FreeOverAmount > 0 {
+		q.Basis += fmt.Sprintf(" (free over £%.2f)", rule.FreeOverAmount)
+	}
+	return q
+}
+
+// shippingRuleFor returns the active rule for a client, falling back to its sector's rule; nil when neither exists.
+func (h *WholesaleOrderHandler) shippingRuleFor(clientID uint, sectorID *uint) *models.ShippingRule {
+	var rule models.ShippingRule
+	if err := h.db.Preload("Surcharges").Where("wholesale_client_id = ? AND is_active = ?", clientID, true).
+		Order("id DESC").First(&rule).Error; err == nil {
+		return &rule
+	}
+	if sectorID == nil {
+		return nil
+	}
+	if err := h.db.Preload("Surcharges").Where("sector_id = ? AND wholesale_client_id IS NULL AND is_active = ?", *sectorID, true).
+		Order("id DESC").First(&rule).Error; err == nil {
+		return &rule
+	}
+	return nil
+}
+
+// deliveryPostcode is the postcode an order ships to: the delivery location when set, else the client's address.
+func (h *WholesaleOrderHandler) deliveryPostcode(client *models.WholesaleClient, storeID *uint) string {
+	wo := models.WholesaleOrder{WholesaleClient: *client}
+	if storeID != nil {
+		var st models.WholesaleClientStore
+		if err := h.db.First(&st, *storeID).Error; err == nil {
+			wo.WholesaleClientStore = &st
+		}
+	}
+	return shipmentRecipient(&wo).Postcode
+}
+
+// quoteOrderShipping quotes an order from items, its lines as they are about to be saved, or from its saved lines
+// when items is nil; ok is false when no rule applies.
+func (h *WholesaleOrderHandler) quoteOrderShipping(wo *models.WholesaleOrder, items []models.WholesaleOrderItem) (shippingQuote, *models.ShippingRule, bool) {
+	var client models.WholesaleClient
+	if err := h.db.First(&client, wo.WholesaleClientID).Error; err != nil {
+		return shippingQuote{}, nil, false
+	}
+	sectorID := wo.SectorID
+	if sectorID == nil {
+		sectorID = client.SectorID
+	}
+	rule := h.shippingRuleFor(client.ID, sectorID)
+	if rule == nil {
+		return shippingQuote{}, nil, false
+	}
+	if items == nil {
+		h.db.Where("wholesale_order_id = ?", wo.ID).Find(&items)
+	}
+	productIDs := make([]uint, 0, len(items))
+	for _, it := range items {
+		productIDs = append(productIDs, it.ProductID)
+	}
+	var products []models.Product
+	h.db.Select("id", "wholesale_units_per_box").Where("id IN ?", productIDs).Find(&products)
+	unitsPerBox := make(map[uint]float64, len(products))
+	for _, p := range products {
+		unitsPerBox[p.ID] = p.WholesaleUnitsPerBox
+	}
+	return quoteShipping(rule, wo.TotalNet, orderShippingCases(items, unitsPerBox), h.deliveryPostcode(&client, wo.WholesaleClientStoreID)), rule, true
+}
+
+// orderShippingCases totals orderLineCases over an order's lines, given each product's units per box.
+func orderShippingCases(items []models.WholesaleOrderItem, unitsPerBox map[uint]float64) float64 {
+	cases := 0.0
+	for _, it := range items {
+		cases += orderLineCases(it.Quantity, unitsPerBox[it.ProductID])
+	}
+	return cases
+}
+
+// applyShippingQuote sets a calculated fee on the order, clearing any manual override.
+func applyShippingQuote(wo *models.WholesaleOrder, q shippingQuote) {
+	ruleID := q.RuleID
+	wo.ShippingRuleID = &ruleID
+	wo.ShippingFee = q.Fee
+	wo.ShippingFeeBasis = q.Basis
+	wo.ShippingFeeOverridden = false
+}
+
+type shippingRuleRequest struct {
+	Name              string   `json:"name"`
+	WholesaleClientID *uint    `json:"wholesale_client_id"`
+	SectorID          *uint    `json:"sector_id"`
+	FreeOverAmount    *float64 `json:"free_over_amount"`
+	FlatFee           *float64 `json:"flat_fee"`
+	PerCaseFee        *float64 `json:"per_case_fee"`
+	MinOrderValue     *float64 `json:"min_order_value"`
+	MinOrderAction    string   `json:"min_order_action"`
+	IsActive          *bool    `json:"is_active"`
+	Surcharges        *[]struct {
+		PostcodePrefix string  `json:"postcode_prefix"`
+		Surcharge      float64 `json:"surcharge"`
+	} `json:"surcharges"` // replaces the rule's zones when present
+}
+
+// ListShippingRules lists rules with their zones. Query: wholesale_client_id, sector_id, active_only=1.
+func (h *WholesaleOrderHandler) ListShippingRules(c *gin.Context) {
+	q := h.db.Model(&models.ShippingRule{}).Preload("Surcharges").Preload("WholesaleClient").Preload("Sector")
+	if v := strings.TrimSpace(c.Query("wholesale_client_id")); v != "" {
+		q = q.Where("wholesale_client_id = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("sector_id")); v != "" {
+		q = q.Where("sector_id = ?", v)
+	}
+	if c.Query("active_only") == "1" {
+		q = q.Where("is_active = ?", true)
+	}
+	var rules []models.ShippingRule
+	if err := q.Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *WholesaleOrderHandler) CreateShippingRule(c *gin.Context) {
+	var req shippingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := models.ShippingRule{
+		WholesaleClientID: req.WholesaleClientID, SectorID: req.SectorID,
+		MinOrderAction: models.ShippingRuleMinOrderWarn, IsActive: true,
+	}
+	if req.WholesaleClientID != nil {
+		rule.SectorID = nil
+	}
+	h.saveShippingRule(c, &rule, &req, true)
+}
+
+func (h *WholesaleOrderHandler) UpdateShippingRule(c *gin.Context) {
+	var rule models.ShippingRule
+	if err := h.db.Preload("Surcharges").First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipping rule not found"})
+		return
+	}
+	var req shippingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.saveShippingRule(c, &rule, &req, false)
+}
+
+// saveShippingRule validates and stores a rule; the client or sector a rule belongs to cannot change after creation.
+func (h *WholesaleOrderHandler) saveShippingRule(c *gin.Context, rule *models.ShippingRule, req *shippingRuleRequest, isNew bool) {
+	if isNew {
+		if rule.WholesaleClientID == nil && rule.SectorID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wholesale_client_id or sector_id is required"})
+			return
+		}
+		if rule.WholesaleClientID != nil {
+			var client models.WholesaleClient
+			if err := h.db.First(&client, *rule.WholesaleClientID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Wholesale client not found"})
+				return
+			}
+		} else {
+			var sector models.Sector
+			if err := h.db.First(&sector, *rule.SectorID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Sector not found"})
+				return
+			}
+		}
+	}
+	if req.Name != "" {
+		rule.Name = strings.TrimSpace(req.Name)
+	}
+	for _, f := range []struct {
+		in  *float64
+		out *float64
+		key string
+	}{
+		{req.FreeOverAmount, &rule.FreeOverAmount, "free_over_amount"},
+		{req.FlatFee, &rule.FlatFee, "flat_fee"},
+		{req.PerCaseFee, &rule.PerCaseFee, "per_case_fee"},
+		{req.MinOrderValue, &rule.MinOrderValue, "min_order_value"},
+	} {
+		if f.in == nil {
+			continue
+		}
+		if *f.in < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": f.key + " cannot be negative"})
+			return
+		}
+		*f.out = *f.in
+	}
+	if req.MinOrderAction != "" {
+		action := strings.ToLower(strings.TrimSpace(req.MinOrderAction))
+		if action != models.ShippingRuleMinOrderBlock && action != models.ShippingRuleMinOrderWarn {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_order_action must be block or warn"})
+			return
+		}
+		rule.MinOrderAction = action
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	var surcharges []models.ShippingRuleSurcharge
+	if req.Surcharges != nil {
+		seen := map[string]bool{}
+		for _, z := range *req.Surcharges {
+			prefix := strings.ToUpper(strings.Join(strings.Fields(z.PostcodePrefix), ""))
+			if prefix == "" || len(prefix) > 10 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Each surcharge needs a postcode area or outward code"})
+				return
+			}
+			if z.Surcharge < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Surcharge for " + prefix + " cannot be negative"})
+				return
+			}
+			if seen[prefix] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate surcharge for " + prefix})
+				return
+			}
+			seen[prefix] = true
+			surcharges = append(surcharges, models.ShippingRuleSurcharge{PostcodePrefix: prefix, Surcharge: z.Surcharge})
+		}
+	}
+	if rule.IsActive {
+		dup := h.db.Model(&models.ShippingRule{}).Where("is_active = ? AND id <> ?", true, rule.ID)
+		if rule.WholesaleClientID != nil {
+			dup = dup.Where("wholesale_client_id = ?", *rule.WholesaleClientID)
+		} else {
+			dup = dup.Where("sector_id = ? AND wholesale_client_id IS NULL", *rule.SectorID)
+		}
+		var n int64
+		dup.Count(&n)
+		if n > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "An active shipping rule already exists for this client or sector"})
+			return
+		}
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Surcharges", "WholesaleClient", "Sector").Save(rule).Error; err != nil {
+			return err
+		}
+		if req.Surcharges == nil {
+			return nil
+		}
+		if err := tx.Where("shipping_rule_id = ?", rule.ID).Delete(&models.ShippingRuleSurcharge{}).Error; err != nil {
+			return err
+		}
+		for i := range surcharges {
+			surcharges[i].ShippingRuleID = rule.ID
+		}
+		if len(surcharges) > 0 {
+			return tx.Create(&surcharges).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	action := "shipping_rule_update"
+	status := http.StatusOK
+	if isNew {
+		action, status = "shipping_rule_create", http.StatusCreated
+	}
+	h.auditEntity(c, "shipping_rule", action, rule.ID, map[string]interface{}{
+		"wholesale_client_id": rule.WholesaleClientID, "sector_id": rule.SectorID, "free_over_amount": rule.FreeOverAmount,
+		"flat_fee": rule.FlatFee, "per_case_fee": rule.PerCaseFee, "min_order_value": rule.MinOrderValue,
+		"min_order_action": rule.MinOrderAction, "is_active": rule.IsActive, "surcharge_count": len(surcharges),
+	})
+	h.db.Preload("Surcharges").Preload("WholesaleClient").Preload("Sector").First(rule, rule.ID)
+	c.JSON(status, rule)
+}
+
+func (h *WholesaleOrderHandler) DeleteShippingRule(c *gin.Context) {
+	var rule models.ShippingRule
+	if err := h.db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipping rule not found"})
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shipping_rule_id = ?", rule.ID).Delete(&models.ShippingRuleSurcharge{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&rule).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "shipping_rule", "shipping_rule_delete", rule.ID, map[string]interface{}{
+		"wholesale_client_id": rule.WholesaleClientID, "sector_id": rule.SectorID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Shipping rule deleted"})
+}
+
+// QuoteShipping previews the fee and minimum-order check for an order being entered, before it is saved.
+func (h *WholesaleOrderHandler) QuoteShipping(c *gin.Context) {
+	var req struct {
+		WholesaleClientID      uint     `json:"wholesale_client_id" binding:"required"`
+		WholesaleClientStoreID *uint    `json:"wholesale_client_store_id"`
+		SectorID               *uint    `json:"sector_id"`
+		TotalNet               *float64 `json:"total_net"` // defaults to the priced lines when omitted
+		Items                  []struct {
+			ProductID uint    `json:"product_id"`
+			Quantity  float64 `json:"quantity"`
+		} `json:"items"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var client models.WholesaleClient
+	if err := h.db.First(&client, req.WholesaleClientID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wholesale client not found"})
+		return
+	}
+	sectorID := req.SectorID
+	if sectorID == nil {
+		sectorID = client.SectorID
+	}
+	rule := h.shippingRuleFor(client.ID, sectorID)
+	if rule == nil {
+		c.JSON(http.StatusOK, gin.H{"rule": nil})
+		return
+	}
+	now := dateOnly(time.Now())
+	cases, net := 0.0, 0.0
+	for _, it := range req.Items {
+		var product models.Product
+		if err := h.db.First(&product, it.ProductID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product %d not found", it.ProductID)})
+			return
+		}
+		cases += orderLineCases(it.Quantity, product.WholesaleUnitsPerBox)
+		if req.TotalNet == nil {
+			net += h.wholesaleUnitPrice(product.ID, sectorID, now, now) * it.Quantity
+		}
+	}
+	if req.TotalNet != nil {
+		net = *req.TotalNet
+	}
+	q := quoteShipping(rule, net, cases, h.deliveryPostcode(&client, req.WholesaleClientStoreID))
+	c.JSON(http.StatusOK, gin.H{"rule": rule, "quote": q})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"testing"
+
+	"pos-system/backend/internal/models"
+)
+
+func TestPostcodeZoneMatch(t *testing.T) {
+	cases := []struct {
+		prefix, postcode string
+		want             int
+	}{
+		{"BT", "BT1 1AA", 1},
+		{"pa20", "PA20 9AB", 2},
+		{"PA2", "PA20 9AB", 0},
+		{"B", "BT1 1AA", 0},
+		{"IV", "iv12", 1},
+		{"IV", "", 0},
+	}
+	for _, tc := range cases {
+		if got := postcodeZoneMatch(tc.prefix, tc.postcode); got != tc.want {
+			t.Errorf("postcodeZoneMatch(%q, %q) = %d, want %d", tc.prefix, tc.postcode, got, tc.want)
+		}
+	}
+}
+
+func TestOrderLineCases(t *testing.T) {
+	if got := orderLineCases(25, 12); got != 3 {
+		t.Errorf("orderLineCases(25, 12) = %v, want 3", got)
+	}
+	if got := orderLineCases(24, 12); got != 2 {
+		t.Errorf("orderLineCases(24, 12) = %v, want 2", got)
+	}
+	if got := orderLineCases(7.5, 0); got != 1 {
+		t.Errorf("orderLineCases(7.5, 0) = %v, want 1", got)
+	}
+}
+
+func TestQuoteShipping(t *testing.T) {
+	clientID := uint(4)
+	rule := &models.ShippingRule{
+		ID: 1, WholesaleClientID: &clientID, FreeOverAmount: 250, FlatFee: 8, PerCaseFee: 0.5,
+		MinOrderValue: 100, MinOrderAction: models.ShippingRuleMinOrderBlock,
+		Surcharges: []models.ShippingRuleSurcharge{{PostcodePrefix: "BT", Surcharge: 15}, {PostcodePrefix: "BT9", Surcharge: 20}},
+	}
+
+	q := quoteShipping(rule, 120, 6, "KT19 0SR")
+	if q.Fee != 11 || q.FreeShipping || q.BelowMinimum || q.Scope != "client" {
+		t.Errorf("standard quote = %+v", q)
+	}
+	if want := "£8.00 delivery + 6 cases x £0.50 (free over £250.00)"; q.Basis != want {
+		t.Errorf("basis = %q, want %q", q.Basis, want)
+	}
+
+	q = quoteShipping(rule, 250, 20, "BT9 5AA")
+	if q.Fee != 20 || !q.FreeShipping || q.ZonePrefix != "BT9" {
+		t.Errorf("free quote with surcharge = %+v", q)
+	}
+
+	q = quoteShipping(rule, 99.99, 2, "BT1 1AA")
+	if !q.BelowMinimum || !q.blocking() || q.Fee != 24 {
+		t.Errorf("below minimum quote = %+v", q)
+	}
+	rule.MinOrderAction = models.ShippingRuleMinOrderWarn
+	if q = quoteShipping(rule, 50, 1, ""); !q.BelowMinimum || q.blocking() {
+		t.Errorf("warn quote = %+v", q)
+	}
+}
+
+func TestOrderShippingCasesFollowsChangedQuantities(t *testing.T) {
+	rule := &models.ShippingRule{ID: 1, FlatFee: 5, PerCaseFee: 2, MinOrderValue: 60, MinOrderAction: models.ShippingRuleMinOrderBlock}
+	unitsPerBox := map[uint]float64{10: 12, 11: 6}
+	items := []models.WholesaleOrderItem{{ProductID: 10, Quantity: 12, UnitPrice: 4}, {ProductID: 11, Quantity: 6, UnitPrice: 3}}
+
+	if got := orderShippingCases(items, unitsPerBox); got != 2 {
+		t.Fatalf("cases before edit = %v, want 2", got)
+	}
+	before := quoteShipping(rule, 66, orderShippingCases(items, unitsPerBox), "")
+	if before.Fee != 9 || before.BelowMinimum {
+		t.Fatalf("quote before edit = %+v", before)
+	}
+
+	// One more unit spills into a second case of product 10; fewer of product 11 takes the order under the minimum.
+	items[0].Quantity = 13
+	items[1].Quantity = 1
+	if got := orderShippingCases(items, unitsPerBox); got != 3 {
+		t.Fatalf("cases after edit = %v, want 3", got)
+	}
+	after := quoteShipping(rule, 55, orderShippingCases(items, unitsPerBox), "")
+	if after.Fee != 11 || !after.BelowMinimum || !after.blocking() {
+		t.Fatalf("quote after edit = %+v", after)
+	}
+}
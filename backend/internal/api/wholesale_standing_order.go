@@ -0,0 +1,686 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/config"
+	"pos-system/backend/internal/jobs"
+	apimail "pos-system/backend/internal/mail"
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StandingOrderItemRequest struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  float64 `json:"quantity" binding:"required"`
+}
+
+type StandingOrderRequest struct {
+	Name                   string                     `json:"name"`
+	WholesaleClientID      uint                       `json:"wholesale_client_id" binding:"required"`
+	WholesaleClientStoreID *uint                      `json:"wholesale_client_store_id"`
+	StoreID                uint                       `json:"store_id" binding:"required"`
+	AssignedUserID         *uint                      `json:"assigned_user_id"`
+	Frequency              string                     `json:"frequency" binding:"required"` // weekly, fortnightly, monthly
+	Weekdays               []int                      `json:"weekdays"`                     // 0=Sun..6=Sat
+	DayOfMonth             int                        `json:"day_of_month"`
+	StartDate              string                     `json:"start_date" binding:"required"` // YYYY-MM-DD
+	EndDate                string                     `json:"end_date"`
+	LeadDays               *int                       `json:"lead_days"`
+	PaymentTerms           string                     `json:"payment_terms"`
+	Notes                  string                     `json:"notes"`
+	IsActive               *bool                      `json:"is_active"`
+	Items                  []StandingOrderItemRequest `json:"items" binding:"required,min=1"`
+}
+
+const defaultStandingOrderLeadDays = 3
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// standingOrderWeekdays parses the Weekdays CSV, ignoring values outside 0..6.
+func standingOrderWeekdays(csv string) map[time.Weekday]bool {
+	days := make(map[time.Weekday]bool)
+	for _, n := range parseCSVInts(csv) {
+		if n >= 0 && n <= 6 {
+			days[time.Weekday(n)] = true
+		}
+	}
+	return days
+}
+
+// standingOrderDueDates returns the delivery dates of the template that fall within [from, to] (inclusive).
+// Fortnightly schedules run on even weeks counted from the week containing StartDate; monthly day numbers
+// beyond the end of a month fall on its last day.
+func standingOrderDueDates(so *models.StandingOrder, from, to time.Time) []time.Time {
+	start := dateOnly(so.StartDate)
+	from, to = dateOnly(from), dateOnly(to)
+	if from.Before(start) {
+		from = start
+	}
+	if so.EndDate != nil && dateOnly(*so.EndDate).Before(to) {
+		to = dateOnly(*so.EndDate)
+	}
+	weekdays := standingOrderWeekdays(so.Weekdays)
+	startWeek := start.AddDate(0, 0, -int(start.Weekday()))
+	var dates []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		switch so.Frequency {
+		case models.StandingOrderFrequencyWeekly:
+			if weekdays[d.Weekday()] {
+				dates = append(dates, d)
+			}
+		case models.StandingOrderFrequencyFortnightly:
+			week := int(d.AddDate(0, 0, -int(d.Weekday())).Sub(startWeek).Hours()/24) / 7
+			if weekdays[d.Weekday()] && week%2 == 0 {
+				dates = append(dates, d)
+			}
+		case models.StandingOrderFrequencyMonthly:
+			lastDay := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			day := so.DayOfMonth
+			if day > lastDay {
+				day = lastDay
+			}
+			if d.Day() == day {
+				dates = append(dates, d)
+			}
+		}
+	}
+	return dates
+}
+
+func standingOrderIsDueOn(so *models.StandingOrder, date time.Time) bool {
+	return len(standingOrderDueDates(so, date, date)) == 1
+}
+
+// applyStandingOrderRequest validates the request and copies the schedule fields onto so.
+func (h *WholesaleOrderHandler) applyStandingOrderRequest(so *models.StandingOrder, req *StandingOrderRequest) error {
+	var client models.WholesaleClient
+	if err := h.db.First(&client, req.WholesaleClientID).Error; err != nil {
+		return fmt.Errorf("Wholesale client not found")
+	}
+	if req.WholesaleClientStoreID != nil {
+		var loc models.WholesaleClientStore
+		if err := h.db.Where("id = ? AND wholesale_client_id = ?", *req.WholesaleClientStoreID, req.WholesaleClientID).First(&loc).Error; err != nil {
+			return fmt.Errorf("Delivery location does not belong to this client")
+		}
+	}
+	frequency := strings.TrimSpace(strings.ToLower(req.Frequency))
+	weekdays := make([]string, 0, len(req.Weekdays))
+	for _, d := range req.Weekdays {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		weekdays = append(weekdays, strconv.Itoa(d))
+	}
+	switch frequency {
+	case models.StandingOrderFrequencyWeekly, models.StandingOrderFrequencyFortnightly:
+		if len(weekdays) == 0 {
+			return fmt.Errorf("weekdays is required for %s standing orders", frequency)
+		}
+	case models.StandingOrderFrequencyMonthly:
+		if req.DayOfMonth < 1 || req.DayOfMonth > 31 {
+			return fmt.Errorf("day_of_month must be between 1 and 31")
+		}
+	default:
+		return fmt.Errorf("frequency must be weekly, fortnightly or monthly")
+	}
+	startDate := parsePODate(req.StartDate)
+	if startDate == nil {
+		return fmt.Errorf("start_date must be YYYY-MM-DD")
+	}
+	endDate := parsePODate(req.EndDate)
+	if endDate != nil && endDate.Before(*startDate) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	leadDays := defaultStandingOrderLeadDays
+	if req.LeadDays != nil {
+		leadDays = *req.LeadDays
+	}
+	if leadDays < 0 || leadDays > 60 {
+		return fmt.Errorf("lead_days must be between 0 and 60")
+	}
+	for _, it := range req.Items {
+		if it.Quantity <= 0 {
+			return fmt.Errorf("Quantity must be greater than 0 for product %d", it.ProductID)
+		}
+		var product models.Product
+		if err := h.db.First(&product, it.ProductID).Error; err != nil {
+			return fmt.Errorf("Product %d not found", it.ProductID)
+		}
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = client.Name
+	}
+	so.Name = name
+	so.WholesaleClientID = req.WholesaleClientID
+	so.WholesaleClientStoreID = req.WholesaleClientStoreID
+	so.StoreID = req.StoreID
+	so.AssignedUserID = req.AssignedUserID
+	so.Frequency = frequency
+	so.Weekdays = strings.Join(weekdays, ",")
+	so.DayOfMonth = 0
+	if frequency == models.StandingOrderFrequencyMonthly {
+		so.Weekdays = ""
+		so.DayOfMonth = req.DayOfMonth
+	}
+	so.StartDate = *startDate
+	so.EndDate = endDate
+	so.LeadDays = leadDays
+	so.PaymentTerms = strings.TrimSpace(req.PaymentTerms)
+	so.Notes = req.Notes
+	if req.IsActive != nil {
+		so.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+func standingOrderItemsFromRequest(standingOrderID uint, reqItems []StandingOrderItemRequest) []models.StandingOrderItem {
+	items := make([]models.StandingOrderItem, 0, len(reqItems))
+	for _, it := range reqItems {
+		items = append(items, models.StandingOrderItem{StandingOrderID: standingOrderID, ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+	return items
+}
+
+func (h *WholesaleOrderHandler) loadStandingOrder(id interface{}) (models.StandingOrder, error) {
+	var so models.StandingOrder
+	err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").
+		Preload("Store").Preload("AssignedUser").
+		First(&so, id).Error
+	return so, err
+}
+
+// ListStandingOrders lists standing order templates, optionally filtered by client and active flag.
+func (h *WholesaleOrderHandler) ListStandingOrders(c *gin.Context) {
+	q := h.db.Model(&models.StandingOrder{}).Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("AssignedUser")
+	if cid := c.Query("client_id"); cid != "" {
+		q = q.Where("wholesale_client_id = ?", cid)
+	}
+	if active := c.Query("active"); active != "" {
+		q = q.Where("is_active = ?", active == "true" || active == "1")
+	}
+	var list []models.StandingOrder
+	if err := q.Order("name ASC, id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetStandingOrder returns one standing order template with its lines.
+func (h *WholesaleOrderHandler) GetStandingOrder(c *gin.Context) {
+	so, err := h.loadStandingOrder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standing order not found"})
+		return
+	}
+	c.JSON(http.StatusOK, so)
+}
+
+// CreateStandingOrder creates a recurring order template for a client and delivery location.
+func (h *WholesaleOrderHandler) CreateStandingOrder(c *gin.Context) {
+	userIDInterface, _ := c.Get("user_id")
+	userID := userIDInterface.(uint)
+
+	var req StandingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	so := models.StandingOrder{UserID: userID, IsActive: true}
+	if err := h.applyStandingOrderRequest(&so, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&so).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items := standingOrderItemsFromRequest(so.ID, req.Items)
+	if err := h.db.Create(&items).Error; err != nil {
+		h.db.Delete(&models.StandingOrder{}, so.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "standing_order", "standing_order_create", so.ID, map[string]interface{}{
+		"client_id": so.WholesaleClientID, "frequency": so.Frequency, "weekdays": so.Weekdays,
+		"day_of_month": so.DayOfMonth, "item_count": len(items),
+	})
+	created, err := h.loadStandingOrder(so.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateStandingOrder replaces the template schedule and lines. Occurrences already generated are unaffected.
+func (h *WholesaleOrderHandler) UpdateStandingOrder(c *gin.Context) {
+	so, err := h.loadStandingOrder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standing order not found"})
+		return
+	}
+	var req StandingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.applyStandingOrderRequest(&so, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items", "WholesaleClient", "WholesaleClientStore", "Store", "AssignedUser").Save(&so).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("standing_order_id = ?", so.ID).Delete(&models.StandingOrderItem{}).Error; err != nil {
+			return err
+		}
+		items := standingOrderItemsFromRequest(so.ID, req.Items)
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "standing_order", "standing_order_update", so.ID, map[string]interface{}{
+		"frequency": so.Frequency, "weekdays": so.Weekdays, "day_of_month": so.DayOfMonth,
+		"is_active": so.IsActive, "item_count": len(req.Items),
+	})
+	updated, err := h.loadStandingOrder(so.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteStandingOrder deactivates the template; generated orders and occurrence history are kept.
+func (h *WholesaleOrderHandler) DeleteStandingOrder(c *gin.Context) {
+	var so models.StandingOrder
+	if err := h.db.First(&so, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standing order not found"})
+		return
+	}
+	if err := h.db.Model(&so).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "standing_order", "standing_order_deactivate", so.ID, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Standing order deactivated"})
+}
+
+type standingOrderOccurrenceView struct {
+	DeliveryDate     string                     `json:"delivery_date"`
+	GenerateOn       string                     `json:"generate_on"`
+	Status           string                     `json:"status"`
+	Items            []StandingOrderItemRequest `json:"items"`
+	Overridden       bool                       `json:"overridden"`
+	Notes            string                     `json:"notes,omitempty"`
+	WholesaleOrderID *uint                      `json:"wholesale_order_id,omitempty"`
+}
+
+// occurrenceItems returns the lines for one delivery date: the occurrence override when present, else the template.
+func occurrenceItems(so *models.StandingOrder, occ *models.StandingOrderOccurrence) ([]StandingOrderItemRequest, bool) {
+	if occ != nil && occ.ItemsOverride != "" {
+		var items []StandingOrderItemRequest
+		if err := json.Unmarshal([]byte(occ.ItemsOverride), &items); err == nil {
+			return items, true
+		}
+	}
+	items := make([]StandingOrderItemRequest, 0, len(so.Items))
+	for _, it := range so.Items {
+		items = append(items, StandingOrderItemRequest{ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+	return items, false
+}
+
+// ListStandingOrderOccurrences lists upcoming delivery dates (default: next 28 days) with skip/override/generated state.
+func (h *WholesaleOrderHandler) ListStandingOrderOccurrences(c *gin.Context) {
+	so, err := h.loadStandingOrder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standing order not found"})
+		return
+	}
+	from := dateOnly(time.Now())
+	if d := parsePODate(c.Query("from")); d != nil {
+		from = *d
+	}
+	to := from.AddDate(0, 0, 28)
+	if d := parsePODate(c.Query("to")); d != nil {
+		to = *d
+	}
+	if to.Sub(from) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range must not exceed one year"})
+		return
+	}
+	var stored []models.StandingOrderOccurrence
+	h.db.Where("standing_order_id = ? AND delivery_date >= ? AND delivery_date <= ?", so.ID, from, to).Find(&stored)
+	byDate := make(map[string]*models.StandingOrderOccurrence, len(stored))
+	for i := range stored {
+		byDate[stored[i].DeliveryDate.Format("2006-01-02")] = &stored[i]
+	}
+	views := make([]standingOrderOccurrenceView, 0)
+	for _, d := range standingOrderDueDates(&so, from, to) {
+		key := d.Format("2006-01-02")
+		occ := byDate[key]
+		items, overridden := occurrenceItems(&so, occ)
+		v := standingOrderOccurrenceView{
+			DeliveryDate: key,
+			GenerateOn:   d.AddDate(0, 0, -so.LeadDays).Format("2006-01-02"),
+			Status:       models.StandingOrderOccurrenceScheduled,
+			Items:        items,
+			Overridden:   overridden,
+		}
+		if occ != nil {
+			v.Status = occ.Status
+			v.Notes = occ.Notes
+			v.WholesaleOrderID = occ.WholesaleOrderID
+		}
+		views = append(views, v)
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// UpdateStandingOrderOccurrence skips, restores or overrides the lines/notes of a single delivery date.
+// The template itself is not changed.
+func (h *WholesaleOrderHandler) UpdateStandingOrderOccurrence(c *gin.Context) {
+	so, err := h.loadStandingOrder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standing order not found"})
+		return
+	}
+	date := parsePODate(c.Param("date"))
+	if date == nil || !standingOrderIsDueOn(&so, *date) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date is not a delivery date of this standing order"})
+		return
+	}
+	var req struct {
+		Skip          *bool                      `json:"skip"`
+		Items         []StandingOrderItemRequest `json:"items"`
+		ClearOverride bool                       `json:"clear_override"`
+		Notes         *string                    `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var occ models.StandingOrderOccurrence
+	err = h.db.Where("standing_order_id = ? AND delivery_date = ?", so.ID, *date).First(&occ).Error
+	if err != nil {
+		occ = models.StandingOrderOccurrence{StandingOrderID: so.ID, DeliveryDate: *date, Status: models.StandingOrderOccurrenceScheduled}
+	}
+	if occ.Status == models.StandingOrderOccurrenceGenerated {
+		c.JSON(http.StatusConflict, gin.H{"error": "An order was already generated for this date; edit the order instead"})
+		return
+	}
+	oldStatus := occ.Status
+	if req.Skip != nil {
+		if *req.Skip {
+			occ.Status = models.StandingOrderOccurrenceSkipped
+		} else {
+			occ.Status = models.StandingOrderOccurrenceScheduled
+		}
+	}
+	if req.ClearOverride {
+		occ.ItemsOverride = ""
+	} else if len(req.Items) > 0 {
+		for _, it := range req.Items {
+			if it.Quantity <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quantity must be greater than 0 for product %d", it.ProductID)})
+				return
+			}
+			var product models.Product
+			if err := h.db.First(&product, it.ProductID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %d not found", it.ProductID)})
+				return
+			}
+		}
+		b, _ := json.Marshal(req.Items)
+		occ.ItemsOverride = string(b)
+	}
+	if req.Notes != nil {
+		occ.Notes = *req.Notes
+	}
+	// Only update rows still in the state we read, so a concurrent scheduler run wins over a late edit.
+	if occ.ID == 0 {
+		err = h.db.Create(&occ).Error
+	} else {
+		res := h.db.Model(&models.StandingOrderOccurrence{}).
+			Where("id = ? AND status = ?", occ.ID, oldStatus).
+			Updates(map[string]interface{}{"status": occ.Status, "items_override": occ.ItemsOverride, "notes": occ.Notes})
+		err = res.Error
+		if err == nil && res.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Occurrence changed in the meantime; reload and try again"})
+			return
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.auditEntity(c, "standing_order", "standing_order_occurrence_update", so.ID, map[string]interface{}{
+		"delivery_date": date.Format("2006-01-02"), "old_status": oldStatus, "new_status": occ.Status,
+		"items_override": occ.ItemsOverride != "",
+	})
+	c.JSON(http.StatusOK, occ)
+}
+
+// RunStandingOrders generates due drafts immediately instead of waiting for the scheduler.
+func (h *WholesaleOrderHandler) RunStandingOrders(c *gin.Context) {
+	n, err := h.generateDueStandingOrders(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "generated": n})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"generated": n})
+}
+
+// generateDueStandingOrders creates pending-approval order drafts for every active template whose delivery date
+// is within its lead time. Each date is claimed through its unique occurrence row, so overlapping runs
+// (several instances, manual run) never generate the same delivery twice.
+func (h *WholesaleOrderHandler) generateDueStandingOrders(now time.Time) (int, error) {
+	var templates []models.StandingOrder
+	if err := h.db.Preload("Items").Preload("WholesaleClient").Where("is_active = ?", true).Find(&templates).Error; err != nil {
+		return 0, err
+	}
+	today := dateOnly(now)
+	generated := 0
+	for i := range templates {
+		so := &templates[i]
+		if !so.WholesaleClient.IsActive {
+			continue
+		}
+		for _, d := range standingOrderDueDates(so, today, today.AddDate(0, 0, so.LeadDays)) {
+			occ, ok := h.claimStandingOrderOccurrence(so.ID, d, now)
+			if !ok {
+				continue
+			}
+			wo, err := h.createStandingOrderDraft(so, &occ, now)
+			if err != nil {
+				log.Printf("[standing-order] template %d delivery %s: %v", so.ID, d.Format("2006-01-02"), err)
+				h.db.Model(&models.StandingOrderOccurrence{}).Where("id = ?", occ.ID).
+					Updates(map[string]interface{}{"status": models.StandingOrderOccurrenceScheduled, "generated_at": nil})
+				continue
+			}
+			h.db.Model(&models.StandingOrderOccurrence{}).Where("id = ?", occ.ID).Update("wholesale_order_id", wo.ID)
+			generated++
+			h.notifyStandingOrderDraft(so, wo, d)
+		}
+	}
+	return generated, nil
+}
+
+// claimStandingOrderOccurrence marks the date as generated; false when it is skipped, already generated
+// or was claimed by a concurrent run.
+func (h *WholesaleOrderHandler) claimStandingOrderOccurrence(standingOrderID uint, date, now time.Time) (models.StandingOrderOccurrence, bool) {
+	var occ models.StandingOrderOccurrence
+	if err := h.db.Where("standing_order_id = ? AND delivery_date = ?", standingOrderID, date).First(&occ).Error; err != nil {
+		occ = models.StandingOrderOccurrence{
+			StandingOrderID: standingOrderID, DeliveryDate: date,
+			Status: models.StandingOrderOccurrenceGenerated, GeneratedAt: &now,
+		}
+		// Unique (standing_order_id, delivery_date): a concurrent insert fails here.
+		return occ, h.db.Create(&occ).Error == nil
+	}
+	if occ.Status != models.StandingOrderOccurrenceScheduled {
+		return occ, false
+	}
+	res := h.db.Model(&models.StandingOrderOccurrence{}).
+		Where("id = ? AND status = ?", occ.ID, models.StandingOrderOccurrenceScheduled).
+		Updates(map[string]interface{}{"status": models.StandingOrderOccurrenceGenerated, "generated_at": now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return occ, false
+	}
+	occ.Status = models.StandingOrderOccurrenceGenerated
+	return occ, true
+}
+
+// createStandingOrderDraft creates the pending-approval wholesale order for one occurrence at current prices.
+func (h *WholesaleOrderHandler) createStandingOrderDraft(so *models.StandingOrder, occ *models.StandingOrderOccurrence, now time.Time) (*models.WholesaleOrder, error) {
+	lines, _ := occurrenceItems(so, occ)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no lines")
+	}
+	sectorID := so.WholesaleClient.SectorID
+	priceDate := dateOnly(now)
+	var subtotal float64
+	items := make([]models.WholesaleOrderItem, 0, len(lines))
+	for _, it := range lines {
+		unitPrice := h.wholesaleUnitPrice(it.ProductID, sectorID, priceDate, now)
+		lineTotal := unitPrice * it.Quantity
+		subtotal += lineTotal
+		items = append(items, models.WholesaleOrderItem{
+			ProductID: it.ProductID,
+			Quantity:  it.Quantity,
+			UnitPrice: unitPrice,
+			LineTotal: lineTotal,
+		})
+	}
+	paymentTerms := so.PaymentTerms
+	if paymentTerms == "" {
+		paymentTerms = so.WholesaleClient.Terms
+	}
+	notes := []string{fmt.Sprintf("Standing order %q delivery %s", so.Name, occ.DeliveryDate.Format("02/01/2006"))}
+	if strings.TrimSpace(so.Notes) != "" {
+		notes = append(notes, so.Notes)
+	}
+	if strings.TrimSpace(occ.Notes) != "" {
+		notes = append(notes, occ.Notes)
+	}
+	orderDate := dateOnly(now)
+	standingOrderID := so.ID
+	wo := models.WholesaleOrder{
+		// Template + delivery date is unique, unlike the per-second number used for manual orders.
+		OrderNumber:            fmt.Sprintf("WO-%s-SO%d", occ.DeliveryDate.Format("20060102"), so.ID),
+		WholesaleClientID:      so.WholesaleClientID,
+		WholesaleClientStoreID: so.WholesaleClientStoreID,
+		StoreID:                so.StoreID,
+		UserID:                 so.UserID,
+		SectorID:               sectorID,
+		OrderDate:              &orderDate,
+		PaymentTerms:           paymentTerms,
+		Status:                 models.WholesaleOrderStatusPending,
+		Subtotal:               subtotal,
+		TotalNet:               subtotal,
+		AmountDue:              subtotal,
+		Notes:                  strings.Join(notes, "\n"),
+		StandingOrderID:        &standingOrderID,
+		CreatedAt:              now,
+	}
+	if err := h.db.Create(&wo).Error; err != nil {
+		return nil, err
+	}
+	h.assignOrderRefAndPONumber(&wo, "standing order")
+	for i := range items {
+		items[i].WholesaleOrderID = wo.ID
+	}
+	if err := h.db.Create(&items).Error; err != nil {
+		h.db.Delete(&models.WholesaleOrder{}, wo.ID)
+		return nil, err
+	}
+	// Drafts take their fee from the client's shipping rule; a minimum order shortfall is left to the reviewer.
+	if q, _, ok := h.quoteOrderShipping(&wo, items); ok {
+		applyShippingQuote(&wo, q)
+		h.db.Model(&wo).Updates(map[string]interface{}{
+			"shipping_rule_id": wo.ShippingRuleID, "shipping_fee": wo.ShippingFee, "shipping_fee_basis": wo.ShippingFeeBasis,
+		})
+	}
+	changesJSON, _ := json.Marshal(map[string]interface{}{
+		"order_number": wo.OrderNumber, "client_id": wo.WholesaleClientID, "item_count": len(items),
+		"subtotal": subtotal, "standing_order_id": so.ID, "delivery_date": occ.DeliveryDate.Format("2006-01-02"),
+		"source": "standing_order",
+	})
+	h.db.Create(&models.AuditLog{
+		Action:     "wholesale_order_create",
+		EntityType: "wholesale_order",
+		EntityID:   &wo.ID,
+		Changes:    string(changesJSON),
+	})
+	return &wo, nil
+}
+
+// notifyStandingOrderDraft emails the assigned staff member (or the template creator) about a new draft.
+func (h *WholesaleOrderHandler) notifyStandingOrderDraft(so *models.StandingOrder, wo *models.WholesaleOrder, deliveryDate time.Time) {
+	recipientID := so.UserID
+	if so.AssignedUserID != nil {
+		recipientID = *so.AssignedUserID
+	}
+	var user models.User
+	if err := h.db.First(&user, recipientID).Error; err != nil || strings.TrimSpace(user.Email) == "" {
+		log.Printf("[standing-order] draft %s generated; no email for user %d", wo.RefNo, recipientID)
+		return
+	}
+	if h.cfg.SMTPHost == "" || h.cfg.SMTPUser == "" || h.cfg.SMTPPassword == "" {
+		log.Printf("[standing-order] draft %s generated; SMTP not configured, %s not notified", wo.RefNo, user.Email)
+		return
+	}
+	subject := fmt.Sprintf("Standing order draft %s for %s", wo.RefNo, so.WholesaleClient.Name)
+	body := fmt.Sprintf("Hello %s,\n\nA wholesale order draft was generated from standing order %q.\n\n"+
+		"Client: %s\nDelivery date: %s\nOC Number: %s\nSubtotal: £%.2f\n\n"+
+		"Please review and approve it in Wholesale Orders.\n",
+		user.FirstName, so.Name, so.WholesaleClient.Name, deliveryDate.Format("02/01/2006"), wo.RefNo, wo.Subtotal)
+	if err := apimail.SendPlain(h.cfg.SMTPHost, h.cfg.SMTPPort, h.cfg.SMTPUser, h.cfg.SMTPPassword,
+		h.cfg.EffectiveSMTPFrom(), []string{strings.TrimSpace(user.Email)}, subject, body); err != nil {
+		log.Printf("[standing-order] notify %s failed: %v", user.Email, err)
+	}
+}
+
+// StartStandingOrderScheduler generates due standing order drafts in the background every
+// STANDING_ORDER_SCHEDULER_MINUTES while the wholesale module is enabled, on whichever instance holds its lease.
+func StartStandingOrderScheduler(db *gorm.DB, cfg *config.Config) {
+	h := NewWholesaleOrderHandler(db, cfg)
+	interval := time.Duration(cfg.StandingOrderSchedulerMinutes) * time.Minute
+	holder := jobs.InstanceName()
+	go func() {
+		for {
+			if !holdLoopLease(db, standingOrderLeaseName, holder, interval) {
+				time.Sleep(interval)
+				continue
+			}
+			var s models.CompanySettings
+			if err := db.Select("wholesale_order_enabled").First(&s, companySettingsID).Error; err == nil && s.WholesaleOrderEnabled {
+				if n, err := h.generateDueStandingOrders(time.Now()); err != nil {
+					log.Printf("[standing-order] scheduler run failed: %v", err)
+				} else if n > 0 {
+					log.Printf("[standing-order] generated %d draft order(s)", n)
+				}
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"pos-system/backend/internal/models"
+)
+
+func testDate(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func formatDates(dates []time.Time) []string {
+	out := make([]string, 0, len(dates))
+	for _, d := range dates {
+		out = append(out, d.Format("2006-01-02"))
+	}
+	return out
+}
+
+func assertDates(t *testing.T, got []time.Time, want ...string) {
+	t.Helper()
+	g := formatDates(got)
+	if len(g) != len(want) {
+		t.Fatalf("dates = %v, want %v", g, want)
+	}
+	for i := range want {
+		if g[i] != want[i] {
+			t.Fatalf("dates = %v, want %v", g, want)
+		}
+	}
+}
+
+func TestStandingOrderDueDatesWeekly(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	so := &models.StandingOrder{Frequency: models.StandingOrderFrequencyWeekly, Weekdays: "1,4", StartDate: testDate("2026-03-04")}
+	got := standingOrderDueDates(so, testDate("2026-03-01"), testDate("2026-03-12"))
+	assertDates(t, got, "2026-03-05", "2026-03-09", "2026-03-12")
+}
+
+func TestStandingOrderDueDatesFortnightly(t *testing.T) {
+	so := &models.StandingOrder{Frequency: models.StandingOrderFrequencyFortnightly, Weekdays: "2", StartDate: testDate("2026-03-02")}
+	got := standingOrderDueDates(so, testDate("2026-03-01"), testDate("2026-04-01"))
+	assertDates(t, got, "2026-03-03", "2026-03-17", "2026-03-31")
+}
+
+func TestStandingOrderDueDatesMonthlyClampsAndEnds(t *testing.T) {
+	end := testDate("2026-04-15")
+	so := &models.StandingOrder{Frequency: models.StandingOrderFrequencyMonthly, DayOfMonth: 31, StartDate: testDate("2026-01-01"), EndDate: &end}
+	got := standingOrderDueDates(so, testDate("2026-01-01"), testDate("2026-06-30"))
+	assertDates(t, got, "2026-01-31", "2026-02-28", "2026-03-31")
+}
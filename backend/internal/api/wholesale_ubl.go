@@ -0,0 +1,822 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Peppol BIS Billing 3.0 (EN 16931 CIUS) on UBL 2.1.
+const (
+	ublInvoiceDocType    = "ubl_invoice"
+	ublCreditNoteDocType = "ubl_credit_note"
+
+	ublCustomizationID     = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	ublProfileID           = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+	ublCurrency            = "GBP"
+	ublCountryCode         = "GB"
+	ublEndpointSchemeGBVAT = "9932" // Peppol EAS: United Kingdom VAT number
+
+	ublTaxStandard = "S"
+	ublTaxZero     = "Z"
+)
+
+// ublAmount marshals as a 2-decimal monetary amount with the currencyID attribute.
+type ublAmount struct {
+	Currency string
+	Value    float64
+}
+
+func (a ublAmount) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "currencyID"}, Value: a.Currency})
+	return e.EncodeElement(strconv.FormatFloat(roundPence(a.Value), 'f', 2, 64), start)
+}
+
+func gbp(v float64) ublAmount {
+	return ublAmount{Currency: ublCurrency, Value: roundPence(v)}
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublEndpointID struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// ublIdentifier is a cac aggregate holding only a cbc:ID; used as a pointer so absent values omit the whole element.
+type ublIdentifier struct {
+	ID string `xml:"cbc:ID"`
+}
+
+func ublIdent(id string) *ublIdentifier {
+	if strings.TrimSpace(id) == "" {
+		return nil
+	}
+	return &ublIdentifier{ID: strings.TrimSpace(id)}
+}
+
+type ublNote struct {
+	Note string `xml:"cbc:Note"`
+}
+
+type ublTaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type ublTaxCategory struct {
+	ID        string       `xml:"cbc:ID"`
+	Percent   float64      `xml:"cbc:Percent"`
+	TaxScheme ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublAddress struct {
+	StreetName           string `xml:"cbc:StreetName,omitempty"`
+	AdditionalStreetName string `xml:"cbc:AdditionalStreetName,omitempty"`
+	CityName             string `xml:"cbc:CityName,omitempty"`
+	PostalZone           string `xml:"cbc:PostalZone,omitempty"`
+	CountryCode          string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type ublPartyTaxScheme struct {
+	CompanyID string       `xml:"cbc:CompanyID"`
+	TaxScheme ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        string `xml:"cbc:CompanyID,omitempty"`
+}
+
+type ublContact struct {
+	Name           string `xml:"cbc:Name,omitempty"`
+	Telephone      string `xml:"cbc:Telephone,omitempty"`
+	ElectronicMail string `xml:"cbc:ElectronicMail,omitempty"`
+}
+
+type ublParty struct {
+	EndpointID    *ublEndpointID     `xml:"cbc:EndpointID,omitempty"`
+	Name          string             `xml:"cac:PartyName>cbc:Name"`
+	PostalAddress ublAddress         `xml:"cac:PostalAddress"`
+	TaxScheme     *ublPartyTaxScheme `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalEntity   ublLegalEntity     `xml:"cac:PartyLegalEntity"`
+	Contact       *ublContact        `xml:"cac:Contact,omitempty"`
+}
+
+type ublBillingReference struct {
+	ID        string `xml:"cac:InvoiceDocumentReference>cbc:ID"`
+	IssueDate string `xml:"cac:InvoiceDocumentReference>cbc:IssueDate,omitempty"`
+}
+
+type ublDeliveryParty struct {
+	Name string `xml:"cac:PartyName>cbc:Name"`
+}
+
+type ublDelivery struct {
+	Address ublAddress        `xml:"cac:DeliveryLocation>cac:Address"`
+	Party   *ublDeliveryParty `xml:"cac:DeliveryParty,omitempty"`
+}
+
+type ublFinancialAccount struct {
+	ID     string         `xml:"cbc:ID"`
+	Name   string         `xml:"cbc:Name,omitempty"`
+	Branch *ublIdentifier `xml:"cac:FinancialInstitutionBranch,omitempty"`
+}
+
+type ublPaymentMeans struct {
+	Code      string               `xml:"cbc:PaymentMeansCode"`
+	PaymentID string               `xml:"cbc:PaymentID,omitempty"`
+	Account   *ublFinancialAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+}
+
+type ublAllowanceCharge struct {
+	ChargeIndicator bool            `xml:"cbc:ChargeIndicator"`
+	ReasonCode      string          `xml:"cbc:AllowanceChargeReasonCode,omitempty"`
+	Reason          string          `xml:"cbc:AllowanceChargeReason,omitempty"`
+	Amount          ublAmount       `xml:"cbc:Amount"`
+	TaxCategory     *ublTaxCategory `xml:"cac:TaxCategory,omitempty"` // document level only
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+	Category      ublTaxCategory `xml:"cac:TaxCategory"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount        `xml:"cbc:TaxAmount"`
+	Subtotals []ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublMonetaryTotal struct {
+	LineExtensionAmount  ublAmount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount   ublAmount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount   ublAmount  `xml:"cbc:TaxInclusiveAmount"`
+	AllowanceTotalAmount *ublAmount `xml:"cbc:AllowanceTotalAmount,omitempty"`
+	ChargeTotalAmount    *ublAmount `xml:"cbc:ChargeTotalAmount,omitempty"`
+	PayableAmount        ublAmount  `xml:"cbc:PayableAmount"`
+}
+
+type ublItem struct {
+	Name                  string         `xml:"cbc:Name"`
+	SellersItemID         *ublIdentifier `xml:"cac:SellersItemIdentification,omitempty"`
+	ClassifiedTaxCategory ublTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+type ublLine struct {
+	ID                  string               `xml:"cbc:ID"`
+	InvoicedQuantity    *ublQuantity         `xml:"cbc:InvoicedQuantity,omitempty"`
+	CreditedQuantity    *ublQuantity         `xml:"cbc:CreditedQuantity,omitempty"`
+	LineExtensionAmount ublAmount            `xml:"cbc:LineExtensionAmount"`
+	AllowanceCharges    []ublAllowanceCharge `xml:"cac:AllowanceCharge"`
+	Item                ublItem              `xml:"cac:Item"`
+	PriceAmount         ublAmount            `xml:"cac:Price>cbc:PriceAmount"`
+
+	quantity float64 // for validation; the XML carries the formatted value
+}
+
+// ublDocument is a UBL 2.1 Invoice or CreditNote; fields that only apply to one of the two are omitted on the other.
+// Field order follows the UBL 2.1 schema sequence.
+type ublDocument struct {
+	XMLName              xml.Name             `xml:""`
+	Xmlns                string               `xml:"xmlns,attr"`
+	XmlnsCac             string               `xml:"xmlns:cac,attr"`
+	XmlnsCbc             string               `xml:"xmlns:cbc,attr"`
+	CustomizationID      string               `xml:"cbc:CustomizationID"`
+	ProfileID            string               `xml:"cbc:ProfileID"`
+	ID                   string               `xml:"cbc:ID"`
+	IssueDate            string               `xml:"cbc:IssueDate"`
+	DueDate              string               `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode      string               `xml:"cbc:InvoiceTypeCode,omitempty"`
+	CreditNoteTypeCode   string               `xml:"cbc:CreditNoteTypeCode,omitempty"`
+	Note                 string               `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode string               `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string               `xml:"cbc:BuyerReference,omitempty"`
+	OrderReference       *ublIdentifier       `xml:"cac:OrderReference,omitempty"`
+	BillingReference     *ublBillingReference `xml:"cac:BillingReference,omitempty"`
+	Supplier             ublParty             `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             ublParty             `xml:"cac:AccountingCustomerParty>cac:Party"`
+	Delivery             *ublDelivery         `xml:"cac:Delivery,omitempty"`
+	PaymentMeans         *ublPaymentMeans     `xml:"cac:PaymentMeans,omitempty"`
+	PaymentTerms         *ublNote             `xml:"cac:PaymentTerms,omitempty"`
+	AllowanceCharges     []ublAllowanceCharge `xml:"cac:AllowanceCharge"`
+	TaxTotal             ublTaxTotal          `xml:"cac:TaxTotal"`
+	Totals               ublMonetaryTotal     `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines         []ublLine            `xml:"cac:InvoiceLine"`
+	CreditNoteLines      []ublLine            `xml:"cac:CreditNoteLine"`
+}
+
+func (d *ublDocument) isCreditNote() bool {
+	return d.XMLName.Local == "CreditNote"
+}
+
+func (d *ublDocument) lines() []ublLine {
+	if d.isCreditNote() {
+		return d.CreditNoteLines
+	}
+	return d.InvoiceLines
+}
+
+func normalizeVATNumber(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+}
+
+func ublTaxCategoryFor(id string, percent float64) ublTaxCategory {
+	return ublTaxCategory{ID: id, Percent: percent, TaxScheme: ublTaxScheme{ID: "VAT"}}
+}
+
+// wholesaleOrderTaxCategory derives the order's VAT category from its stored VAT total (wholesale food lines are zero rated).
+func wholesaleOrderTaxCategory(wo *models.WholesaleOrder) ublTaxCategory {
+	if wo.VATTotal > 0 && wo.TotalNet > 0 {
+		return ublTaxCategoryFor(ublTaxStandard, math.Round(wo.VATTotal/wo.TotalNet*10000)/100)
+	}
+	return ublTaxCategoryFor(ublTaxZero, 0)
+}
+
+func ublPartyForCompany(company models.CompanySettings) ublParty {
+	p := ublParty{
+		Name: company.CompanyName,
+		PostalAddress: ublAddress{
+			StreetName:           company.AddressLine1,
+			AdditionalStreetName: company.AddressLine2,
+			CityName:             company.City,
+			PostalZone:           company.Postcode,
+			CountryCode:          ublCountryCode,
+		},
+		LegalEntity: ublLegalEntity{RegistrationName: company.CompanyName},
+	}
+	if vat := normalizeVATNumber(company.VATNumber); vat != "" {
+		p.EndpointID = &ublEndpointID{SchemeID: ublEndpointSchemeGBVAT, Value: vat}
+		p.TaxScheme = &ublPartyTaxScheme{CompanyID: vat, TaxScheme: ublTaxScheme{ID: "VAT"}}
+	}
+	if company.Telephone != "" || company.Email != "" {
+		p.Contact = &ublContact{Telephone: company.Telephone, ElectronicMail: company.Email}
+	}
+	return p
+}
+
+func ublPartyForClient(client models.WholesaleClient) ublParty {
+	street := strings.TrimSpace(client.AddressLine1)
+	if street == "" {
+		street = strings.TrimSpace(strings.SplitN(client.Address, "\n", 2)[0])
+	}
+	p := ublParty{
+		Name: client.Name,
+		PostalAddress: ublAddress{
+			StreetName:           street,
+			AdditionalStreetName: client.AddressLine2,
+			PostalZone:           client.Postcode,
+			CountryCode:          ublCountryCode,
+		},
+		LegalEntity: ublLegalEntity{RegistrationName: client.Name, CompanyID: client.CompanyNumber},
+	}
+	if vat := normalizeVATNumber(client.VATNumber); vat != "" {
+		p.EndpointID = &ublEndpointID{SchemeID: ublEndpointSchemeGBVAT, Value: vat}
+		p.TaxScheme = &ublPartyTaxScheme{CompanyID: vat, TaxScheme: ublTaxScheme{ID: "VAT"}}
+	}
+	if client.ContactName != "" || client.Phone != "" || client.Email != "" {
+		p.Contact = &ublContact{Name: client.ContactName, Telephone: client.Phone, ElectronicMail: client.Email}
+	}
+	return p
+}
+
+// ublPaymentMeansForCompany pays by credit transfer (UNCL4461 code 30) to the IBAN, or the UK account number and sort code.
+func ublPaymentMeansForCompany(company models.CompanySettings, paymentID string) *ublPaymentMeans {
+	pm := &ublPaymentMeans{Code: "30", PaymentID: paymentID}
+	if iban := strings.ReplaceAll(strings.TrimSpace(company.BankIBAN), " ", ""); iban != "" {
+		pm.Account = &ublFinancialAccount{ID: iban, Name: company.BankAccountName}
+	} else if acct := strings.TrimSpace(company.BankAccountNumber); acct != "" {
+		pm.Account = &ublFinancialAccount{ID: acct, Name: company.BankAccountName,
+			Branch: ublIdent(strings.ReplaceAll(company.BankSortCode, "-", ""))}
+	}
+	return pm
+}
+
+// buildUBLDocument maps a wholesale order (or a credit note mapped onto one, see returnAsCreditNoteOrder) to UBL.
+// For credit notes, billingRef/billingDate identify the invoice being credited.
+func buildUBLDocument(wo *models.WholesaleOrder, company models.CompanySettings, creditNote bool, issueDate time.Time, billingRef string, billingDate *time.Time) *ublDocument {
+	cat := wholesaleOrderTaxCategory(wo)
+	// Shipping is charged without VAT on the PDF, so it sits in the zero-rated category.
+	shippingCat := ublTaxCategoryFor(ublTaxZero, 0)
+
+	doc := &ublDocument{
+		Xmlns:                "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+		XmlnsCac:             "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		XmlnsCbc:             "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		CustomizationID:      ublCustomizationID,
+		ProfileID:            ublProfileID,
+		ID:                   wholesaleInvoiceNumberLabel(wo),
+		IssueDate:            issueDate.Format("2006-01-02"),
+		DocumentCurrencyCode: ublCurrency,
+		BuyerReference:       strings.TrimSpace(wo.PONumber),
+		OrderReference:       ublIdent(wo.PONumber),
+		Supplier:             ublPartyForCompany(company),
+		Customer:             ublPartyForClient(wo.WholesaleClient),
+		PaymentMeans:         ublPaymentMeansForCompany(company, wholesaleInvoiceNumberLabel(wo)),
+	}
+	if terms := strings.TrimSpace(wo.PaymentTerms); terms != "" {
+		doc.PaymentTerms = &ublNote{Note: terms}
+	}
+	if doc.BuyerReference == "" {
+		doc.BuyerReference = wholesaleOrderRefLabel(wo)
+	}
+	if creditNote {
+		doc.XMLName = xml.Name{Local: "CreditNote"}
+		doc.Xmlns = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+		doc.CreditNoteTypeCode = "381"
+		doc.Note = strings.TrimSpace(wo.Notes)
+		if billingRef != "" {
+			doc.BillingReference = &ublBillingReference{ID: billingRef}
+			if billingDate != nil {
+				doc.BillingReference.IssueDate = billingDate.Format("2006-01-02")
+			}
+		}
+	} else {
+		doc.XMLName = xml.Name{Local: "Invoice"}
+		doc.InvoiceTypeCode = "380"
+		doc.DueDate = dueDateFromTerms(issueDate, wo.PaymentTerms).Format("2006-01-02")
+	}
+	if st := wo.WholesaleClientStore; st != nil {
+		doc.Delivery = &ublDelivery{
+			Address: ublAddress{
+				StreetName:           st.AddressLine1,
+				AdditionalStreetName: st.AddressLine2,
+				CityName:             st.City,
+				PostalZone:           st.Postcode,
+				CountryCode:          ublCountryCode,
+			},
+		}
+		if strings.TrimSpace(st.Name) != "" {
+			doc.Delivery.Party = &ublDeliveryParty{Name: st.Name}
+		}
+	}
+
+	var lineExt float64
+	lines := make([]ublLine, 0, len(wo.Items))
+	for i, it := range wo.Items {
+		net := roundPence(it.LineTotal)
+		unitCode := "C62" // UN/ECE rec 20: one (unit)
+		if it.Product.UnitType == "weight" {
+			unitCode = "KGM"
+		}
+		qty := &ublQuantity{UnitCode: unitCode, Value: strconv.FormatFloat(it.Quantity, 'f', -1, 64)}
+		name := strings.TrimSpace(it.Product.Name)
+		if name == "" {
+			name = fmt.Sprintf("Product #%d", it.ProductID)
+		}
+		line := ublLine{
+			ID:                  strconv.Itoa(i + 1),
+			LineExtensionAmount: gbp(net),
+			Item:                ublItem{Name: name, SellersItemID: ublIdent(it.Product.SKU), ClassifiedTaxCategory: cat},
+			PriceAmount:         gbp(it.UnitPrice),
+			quantity:            it.Quantity,
+		}
+		if creditNote {
+			line.CreditedQuantity = qty
+		} else {
+			line.InvoicedQuantity = qty
+		}
+		// Line discount as a line allowance so quantity x price - allowance = line net.
+		if discount := roundPence(it.Quantity*it.UnitPrice - net); discount > 0 {
+			line.AllowanceCharges = []ublAllowanceCharge{{ChargeIndicator: false, ReasonCode: "95", Reason: "Discount", Amount: gbp(discount)}}
+		}
+		lineExt += net
+		lines = append(lines, line)
+	}
+	if creditNote {
+		doc.CreditNoteLines = lines
+	} else {
+		doc.InvoiceLines = lines
+	}
+
+	taxable := map[string]float64{cat.ID: lineExt}
+	var allowances, charges float64
+	if wo.DiscountAmount > 0 {
+		allowances = roundPence(wo.DiscountAmount)
+		taxable[cat.ID] -= allowances
+		doc.AllowanceCharges = append(doc.AllowanceCharges, ublAllowanceCharge{
+			ChargeIndicator: false, ReasonCode: "95", Reason: "Discount", Amount: gbp(allowances), TaxCategory: &cat,
+		})
+	}
+	if wo.ShippingFee > 0 {
+		charges = roundPence(wo.ShippingFee)
+		taxable[shippingCat.ID] += charges
+		doc.AllowanceCharges = append(doc.AllowanceCharges, ublAllowanceCharge{
+			ChargeIndicator: true, ReasonCode: "FC", Reason: "Shipping", Amount: gbp(charges), TaxCategory: &shippingCat,
+		})
+	}
+
+	var vat float64
+	for _, c := range []ublTaxCategory{cat, shippingCat} {
+		amount, ok := taxable[c.ID]
+		if !ok {
+			continue
+		}
+		delete(taxable, c.ID) // S and Z may coincide with shipping; emit each category once
+		tax := 0.0
+		if c.ID == ublTaxStandard {
+			tax = roundPence(wo.VATTotal)
+		}
+		vat += tax
+		doc.TaxTotal.Subtotals = append(doc.TaxTotal.Subtotals, ublTaxSubtotal{
+			TaxableAmount: gbp(amount), TaxAmount: gbp(tax), Category: c,
+		})
+	}
+	doc.TaxTotal.TaxAmount = gbp(vat)
+
+	taxExclusive := roundPence(lineExt - allowances + charges)
+	doc.Totals = ublMonetaryTotal{
+		LineExtensionAmount: gbp(lineExt),
+		TaxExclusiveAmount:  gbp(taxExclusive),
+		TaxInclusiveAmount:  gbp(taxExclusive + vat),
+		PayableAmount:       gbp(taxExclusive + vat),
+	}
+	if allowances > 0 {
+		a := gbp(allowances)
+		doc.Totals.AllowanceTotalAmount = &a
+	}
+	if charges > 0 {
+		a := gbp(charges)
+		doc.Totals.ChargeTotalAmount = &a
+	}
+	return doc
+}
+
+var ublDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+var ublVATIDRe = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{2,13}$`)
+
+func ublAmountsDiffer(a, b float64, tolerance float64) bool {
+	return math.Abs(a-b) > tolerance+0.0001
+}
+
+// validateUBLDocument checks the EN 16931 / Peppol BIS 3.0 business rules that the data we map can break.
+// It runs offline (no schematron service); each message carries the rule ID it reports.
+func validateUBLDocument(d *ublDocument) []string {
+	var errs []string
+	fail := func(rule, format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("[%s] %s", rule, fmt.Sprintf(format, args...)))
+	}
+	if d.CustomizationID != ublCustomizationID {
+		fail("BR-01", "specification identifier must be %s", ublCustomizationID)
+	}
+	if d.ProfileID == "" {
+		fail("PEPPOL-EN16931-R001", "business process (ProfileID) is required")
+	}
+	if strings.TrimSpace(d.ID) == "" {
+		fail("BR-02", "document number is required")
+	}
+	if _, err := time.Parse("2006-01-02", d.IssueDate); err != nil || !ublDateRe.MatchString(d.IssueDate) {
+		fail("BR-03", "issue date must be YYYY-MM-DD")
+	}
+	if d.isCreditNote() {
+		if d.CreditNoteTypeCode != "381" {
+			fail("BR-04", "credit note type code must be 381")
+		}
+	} else if d.InvoiceTypeCode != "380" {
+		fail("BR-04", "invoice type code must be 380")
+	}
+	if d.DocumentCurrencyCode == "" {
+		fail("BR-05", "document currency is required")
+	}
+	if d.BuyerReference == "" && d.OrderReference == nil {
+		fail("PEPPOL-EN16931-R003", "buyer reference or purchase order reference is required")
+	}
+	if strings.TrimSpace(d.Supplier.Name) == "" || strings.TrimSpace(d.Supplier.LegalEntity.RegistrationName) == "" {
+		fail("BR-06", "seller name is required")
+	}
+	if strings.TrimSpace(d.Customer.Name) == "" || strings.TrimSpace(d.Customer.LegalEntity.RegistrationName) == "" {
+		fail("BR-07", "buyer name is required")
+	}
+	if d.Supplier.PostalAddress.CountryCode == "" {
+		fail("BR-09", "seller country code is required")
+	}
+	if d.Customer.PostalAddress.CountryCode == "" {
+		fail("BR-11", "buyer country code is required")
+	}
+	if d.Supplier.EndpointID == nil || d.Supplier.EndpointID.Value == "" {
+		fail("PEPPOL-EN16931-R020", "seller electronic address is required; set the company VAT number in settings")
+	}
+	if d.Customer.EndpointID == nil || d.Customer.EndpointID.Value == "" {
+		fail("PEPPOL-EN16931-R010", "buyer electronic address is required; set the client's VAT number")
+	}
+	if d.Supplier.TaxScheme == nil {
+		fail("BR-S-02/BR-Z-02", "seller VAT identifier is required for standard or zero rated lines")
+	}
+	for who, p := range map[string]*ublParty{"seller": &d.Supplier, "buyer": &d.Customer} {
+		if p.TaxScheme != nil && !ublVATIDRe.MatchString(p.TaxScheme.CompanyID) {
+			fail("BR-CO-09", "%s VAT identifier %q must start with a country prefix (e.g. GB123456789)", who, p.TaxScheme.CompanyID)
+		}
+	}
+	if pm := d.PaymentMeans; pm != nil && (pm.Code == "30" || pm.Code == "58") && (pm.Account == nil || pm.Account.ID == "") {
+		fail("BR-61", "payment account is required for credit transfer; set bank details in settings")
+	}
+	if !d.isCreditNote() && d.Totals.PayableAmount.Value > 0 && d.DueDate == "" && d.PaymentTerms == nil {
+		fail("BR-CO-25", "due date or payment terms are required when an amount is payable")
+	}
+
+	lines := d.lines()
+	if len(lines) == 0 {
+		fail("BR-16", "at least one line is required")
+	}
+	var lineExt float64
+	for _, l := range lines {
+		if l.ID == "" {
+			fail("BR-21", "line identifier is required")
+		}
+		if l.InvoicedQuantity == nil && l.CreditedQuantity == nil {
+			fail("BR-22", "line %s: quantity is required", l.ID)
+		}
+		if strings.TrimSpace(l.Item.Name) == "" {
+			fail("BR-25", "line %s: item name is required", l.ID)
+		}
+		if l.PriceAmount.Value < 0 {
+			fail("BR-27", "line %s: item net price must not be negative", l.ID)
+		}
+		if l.Item.ClassifiedTaxCategory.ID == "" {
+			fail("BR-CO-04", "line %s: VAT category is required", l.ID)
+		}
+		var lineAllowances float64
+		for _, ac := range l.AllowanceCharges {
+			if ac.ChargeIndicator {
+				lineAllowances -= ac.Amount.Value
+			} else {
+				lineAllowances += ac.Amount.Value
+			}
+		}
+		if ublAmountsDiffer(roundPence(l.quantity*l.PriceAmount.Value-lineAllowances), l.LineExtensionAmount.Value, 0.01) {
+			fail("PEPPOL-EN16931-R120", "line %s: net amount must equal quantity x price - allowances + charges", l.ID)
+		}
+		lineExt += l.LineExtensionAmount.Value
+	}
+
+	var allowances, charges float64
+	for _, ac := range d.AllowanceCharges {
+		if ac.TaxCategory == nil {
+			fail("BR-32/BR-37", "document allowance or charge needs a VAT category")
+		}
+		if ac.ChargeIndicator {
+			charges += ac.Amount.Value
+		} else {
+			allowances += ac.Amount.Value
+		}
+	}
+	t := d.Totals
+	if ublAmountsDiffer(t.LineExtensionAmount.Value, roundPence(lineExt), 0) {
+		fail("BR-CO-10", "sum of line net amounts %.2f does not match %.2f", lineExt, t.LineExtensionAmount.Value)
+	}
+	if t.AllowanceTotalAmount != nil && ublAmountsDiffer(t.AllowanceTotalAmount.Value, allowances, 0) {
+		fail("BR-CO-11", "allowance total does not match document allowances")
+	}
+	if t.ChargeTotalAmount != nil && ublAmountsDiffer(t.ChargeTotalAmount.Value, charges, 0) {
+		fail("BR-CO-12", "charge total does not match document charges")
+	}
+	if ublAmountsDiffer(t.TaxExclusiveAmount.Value, roundPence(t.LineExtensionAmount.Value-allowances+charges), 0) {
+		fail("BR-CO-13", "total without VAT must equal lines - allowances + charges")
+	}
+	var subtotalVAT float64
+	for _, st := range d.TaxTotal.Subtotals {
+		subtotalVAT += st.TaxAmount.Value
+		switch st.Category.ID {
+		case ublTaxStandard:
+			if st.Category.Percent <= 0 {
+				fail("BR-S-05", "standard rated VAT percentage must be greater than zero")
+			}
+		case ublTaxZero:
+			if st.Category.Percent != 0 || st.TaxAmount.Value != 0 {
+				fail("BR-Z-05", "zero rated VAT percentage and amount must be zero")
+			}
+		}
+		if ublAmountsDiffer(roundPence(st.TaxableAmount.Value*st.Category.Percent/100), st.TaxAmount.Value, 0.01) {
+			fail("BR-CO-17", "VAT category %s amount must equal taxable amount x rate", st.Category.ID)
+		}
+	}
+	if ublAmountsDiffer(d.TaxTotal.TaxAmount.Value, subtotalVAT, 0) {
+		fail("BR-CO-14", "invoice VAT total must equal the sum of VAT category amounts")
+	}
+	if ublAmountsDiffer(t.TaxInclusiveAmount.Value, roundPence(t.TaxExclusiveAmount.Value+d.TaxTotal.TaxAmount.Value), 0) {
+		fail("BR-CO-15", "total with VAT must equal total without VAT + VAT total")
+	}
+	if ublAmountsDiffer(t.PayableAmount.Value, t.TaxInclusiveAmount.Value, 0) {
+		fail("BR-CO-16", "amount due must equal total with VAT (no prepaid amount)")
+	}
+	return errs
+}
+
+// marshalUBLDocument renders the XML and checks it is well formed by reading it back.
+func marshalUBLDocument(d *ublDocument) ([]byte, error) {
+	out, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte(xml.Header), out...)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("generated UBL is not well formed: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// ublValidationError carries business-rule failures so handlers can return them to the user.
+type ublValidationError struct {
+	Errors []string
+}
+
+func (e *ublValidationError) Error() string {
+	return "UBL validation failed: " + strings.Join(e.Errors, "; ")
+}
+
+func (h *WholesaleOrderHandler) loadCompanySettings() models.CompanySettings {
+	company := defaultCompanySettings
+	_ = h.db.First(&company, companySettingsID).Error // defaults if not configured
+	return company
+}
+
+// wholesaleInvoiceIssueDate is the invoice PDF "Date:": the editable invoice date, else when the invoice was issued.
+func wholesaleInvoiceIssueDate(wo *models.WholesaleOrder) time.Time {
+	if wo.InvoiceDate != nil {
+		return *wo.InvoiceDate
+	}
+	for _, d := range wo.Documents {
+		if d.Type == "invoice" {
+			return d.CreatedAt
+		}
+	}
+	return time.Now()
+}
+
+// generateUBLInvoice builds, validates and stores the UBL invoice for an invoiced order, replacing any previous one.
+func (h *WholesaleOrderHandler) generateUBLInvoice(orderID uint) (*models.WholesaleOrderDocument, error) {
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Documents").
+		First(&wo, orderID).Error; err != nil {
+		return nil, err
+	}
+	hasInvoice := false
+	for _, d := range wo.Documents {
+		if d.Type == "invoice" {
+			hasInvoice = true
+			break
+		}
+	}
+	if !hasInvoice {
+		return nil, fmt.Errorf("no invoice on this order; generate an invoice first")
+	}
+	doc := buildUBLDocument(&wo, h.loadCompanySettings(), false, wholesaleInvoiceIssueDate(&wo), "", nil)
+	if errs := validateUBLDocument(doc); len(errs) > 0 {
+		return nil, &ublValidationError{Errors: errs}
+	}
+	data, err := marshalUBLDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	url, err := h.uploadWholesaleFile(fmt.Sprintf("ubl/%s-invoice-%d.xml", wo.OrderNumber, time.Now().UnixNano()), data)
+	if err != nil {
+		return nil, err
+	}
+	refSafe := strings.ReplaceAll(strings.ReplaceAll(wholesaleOrderRefLabel(&wo), "/", "_"), "\\", "_")
+	stored := models.WholesaleOrderDocument{
+		WholesaleOrderID: wo.ID,
+		Type:             ublInvoiceDocType,
+		FileURL:          url,
+		OriginalFilename: refSafe + "_invoice.xml",
+		CreatedAt:        time.Now(),
+	}
+	h.db.Where("wholesale_order_id = ? AND type = ?", wo.ID, ublInvoiceDocType).Delete(&models.WholesaleOrderDocument{})
+	if err := h.db.Create(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// syncUBLInvoice regenerates an existing UBL invoice after the PDF invoice is regenerated, so the two never disagree.
+// Orders without a UBL invoice are left alone; if the refreshed XML no longer validates, the stale file is removed.
+func (h *WholesaleOrderHandler) syncUBLInvoice(actor auditActor, orderID uint) {
+	var count int64
+	h.db.Model(&models.WholesaleOrderDocument{}).Where("wholesale_order_id = ? AND type = ?", orderID, ublInvoiceDocType).Count(&count)
+	if count == 0 {
+		return
+	}
+	doc, err := h.generateUBLInvoice(orderID)
+	if err != nil {
+		fmt.Printf("Failed to refresh UBL invoice for wholesale order %d: %v\n", orderID, err)
+		h.db.Where("wholesale_order_id = ? AND type = ?", orderID, ublInvoiceDocType).Delete(&models.WholesaleOrderDocument{})
+		h.auditAs(actor, "wholesale_order_ubl_invoice_removed", orderID, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	h.auditAs(actor, "wholesale_order_generate_ubl_invoice", orderID, map[string]interface{}{
+		"document_type": ublInvoiceDocType, "file_url": doc.FileURL, "trigger": "invoice_regenerated",
+	})
+}
+
+func respondUBLError(c *gin.Context, err error) {
+	if verr, ok := err.(*ublValidationError); ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "E-invoice failed validation", "errors": verr.Errors})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// GenerateUBLInvoice builds the Peppol BIS 3.0 UBL invoice for an invoiced order and stores it as a document.
+func (h *WholesaleOrderHandler) GenerateUBLInvoice(c *gin.Context) {
+	var wo models.WholesaleOrder
+	if err := h.db.First(&wo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	if abortIfWholesaleOrderDeleted(c, wo.Status) {
+		return
+	}
+	doc, err := h.generateUBLInvoice(wo.ID)
+	if err != nil {
+		respondUBLError(c, err)
+		return
+	}
+	h.audit(c, "wholesale_order_generate_ubl_invoice", wo.ID, map[string]interface{}{
+		"document_type": ublInvoiceDocType, "file_url": doc.FileURL,
+	})
+	c.JSON(http.StatusOK, doc)
+}
+
+// ValidateUBLInvoice reports whether the order's UBL invoice would pass validation, without storing anything.
+func (h *WholesaleOrderHandler) ValidateUBLInvoice(c *gin.Context) {
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("Items.Product").Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Documents").
+		First(&wo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	doc := buildUBLDocument(&wo, h.loadCompanySettings(), false, wholesaleInvoiceIssueDate(&wo), "", nil)
+	errs := validateUBLDocument(doc)
+	c.JSON(http.StatusOK, gin.H{"valid": len(errs) == 0, "errors": errs})
+}
+
+// GenerateReturnUBLCreditNote builds the UBL credit note for a return whose PDF credit note has been issued.
+func (h *WholesaleOrderHandler) GenerateReturnUBLCreditNote(c *gin.Context) {
+	ret, err := h.loadWholesaleReturn(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+	if ret.CreditNoteNumber == "" || ret.CreditedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Issue the credit note before generating its e-invoice"})
+		return
+	}
+	var wo models.WholesaleOrder
+	if err := h.db.Preload("WholesaleClient").Preload("WholesaleClientStore").Preload("Documents").
+		First(&wo, ret.WholesaleOrderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wholesale order not found"})
+		return
+	}
+	invoiceDate := wholesaleInvoiceIssueDate(&wo)
+	cn := returnAsCreditNoteOrder(&ret, &wo, *ret.CreditedAt)
+	doc := buildUBLDocument(cn, h.loadCompanySettings(), true, *ret.CreditedAt, wholesaleInvoiceNumberLabel(&wo), &invoiceDate)
+	if errs := validateUBLDocument(doc); len(errs) > 0 {
+		respondUBLError(c, &ublValidationError{Errors: errs})
+		return
+	}
+	data, err := marshalUBLDocument(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	url, err := h.uploadWholesaleFile(fmt.Sprintf("ubl/%s-credit-note-%d.xml", cn.OrderNumber, time.Now().UnixNano()), data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	filename := ret.CreditNoteNumber + ".xml"
+	h.db.Where("wholesale_order_id = ? AND type = ? AND original_filename = ?", wo.ID, ublCreditNoteDocType, filename).
+		Delete(&models.WholesaleOrderDocument{})
+	stored := models.WholesaleOrderDocument{
+		WholesaleOrderID: wo.ID,
+		Type:             ublCreditNoteDocType,
+		FileURL:          url,
+		OriginalFilename: filename,
+		CreatedAt:        time.Now(),
+	}
+	if err := h.db.Create(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "wholesale_return_ubl_credit_note", wo.ID, map[string]interface{}{
+		"return_id": ret.ID, "credit_note_number": ret.CreditNoteNumber, "file_url": url,
+	})
+	c.JSON(http.StatusOK, stored)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"pos-system/backend/internal/models"
+)
+
+func testUBLOrder() *models.WholesaleOrder {
+	return &models.WholesaleOrder{
+		ID:             7,
+		RefNo:          "D7",
+		PONumber:       "PO-123",
+		PaymentTerms:   "30 days",
+		Subtotal:       29,
+		DiscountAmount: 4,
+		TotalNet:       25,
+		ShippingFee:    5,
+		WholesaleClient: models.WholesaleClient{
+			Name: "Acme Foods", AddressLine1: "1 High St", Postcode: "E1 1AA", VATNumber: "gb 987654321",
+		},
+		Items: []models.WholesaleOrderItem{
+			{ProductID: 1, Quantity: 2, UnitPrice: 10, LineTotal: 20, Product: models.Product{Name: "Rice", SKU: "R1"}},
+			{ProductID: 2, Quantity: 1.5, UnitPrice: 7, LineDiscountAmount: 1.5, LineTotal: 9, Product: models.Product{Name: "Tea", UnitType: "weight"}},
+		},
+	}
+}
+
+func testUBLCompany() models.CompanySettings {
+	company := defaultCompanySettings
+	company.VATNumber = "GB123456789"
+	return company
+}
+
+func TestUBLInvoiceValidatesAndMarshals(t *testing.T) {
+	doc := buildUBLDocument(testUBLOrder(), testUBLCompany(), false, testDate("2025-02-01"), "", nil)
+	if errs := validateUBLDocument(doc); len(errs) > 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	if doc.DueDate != "2025-03-03" {
+		t.Fatalf("due date = %s, want 2025-03-03", doc.DueDate)
+	}
+	if got := doc.Totals.PayableAmount.Value; got != 30 {
+		t.Fatalf("payable = %.2f, want 30.00 (25 net + 5 shipping)", got)
+	}
+	data, err := marshalUBLDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlStr := string(data)
+	for _, want := range []string{
+		`<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"`,
+		`<cbc:EndpointID schemeID="9932">GB987654321</cbc:EndpointID>`,
+		`<cbc:InvoicedQuantity unitCode="KGM">1.5</cbc:InvoicedQuantity>`,
+		`<cbc:PayableAmount currencyID="GBP">30.00</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlStr, want) {
+			t.Errorf("missing %s in:\n%s", want, xmlStr)
+		}
+	}
+}
+
+func TestUBLValidationReportsMissingVATNumbers(t *testing.T) {
+	wo := testUBLOrder()
+	wo.WholesaleClient.VATNumber = ""
+	doc := buildUBLDocument(wo, defaultCompanySettings, false, testDate("2025-02-01"), "", nil)
+	errs := strings.Join(validateUBLDocument(doc), "\n")
+	for _, rule := range []string{"PEPPOL-EN16931-R020", "PEPPOL-EN16931-R010", "BR-S-02/BR-Z-02"} {
+		if !strings.Contains(errs, rule) {
+			t.Errorf("expected %s in %q", rule, errs)
+		}
+	}
+}
+
+func TestUBLCreditNoteReferencesInvoice(t *testing.T) {
+	wo := testUBLOrder()
+	wo.DiscountAmount, wo.ShippingFee, wo.TotalNet = 0, 0, 29
+	invoiceDate := testDate("2025-02-01")
+	doc := buildUBLDocument(wo, testUBLCompany(), true, testDate("2025-02-10"), "D7", &invoiceDate)
+	if errs := validateUBLDocument(doc); len(errs) > 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	data, err := marshalUBLDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlStr := string(data)
+	for _, want := range []string{"<CreditNote ", "<cbc:CreditNoteTypeCode>381</cbc:CreditNoteTypeCode>",
+		"<cbc:ID>D7</cbc:ID>\n      <cbc:IssueDate>2025-02-01</cbc:IssueDate>", "<cac:CreditNoteLine>", "<cbc:CreditedQuantity"} {
+		if !strings.Contains(xmlStr, want) {
+			t.Errorf("missing %q in:\n%s", want, xmlStr)
+		}
+	}
+}
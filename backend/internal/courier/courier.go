@@ -0,0 +1,163 @@
+// Package courier wraps carrier APIs behind one Provider interface: book a consignment, fetch its label and
+// poll tracking events. Events are normalised to a small set of statuses so callers can act on delivery.
+//
+// Only the Royal Mail group is integrated: Royal Mail and Parcelforce are both booked through one Click & Drop
+// account (they differ only by service code) and tracked through the Royal Mail Tracking API. The file provider
+// is for testing. Other carriers (DPD, DHL, Evri, ...) have no adapter yet; shipments sent with them keep a
+// hand-typed courier and tracking number and are completed by hand.
+package courier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Normalised tracking statuses.
+const (
+	StatusInfo           = "info"
+	StatusInTransit      = "in_transit"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusException      = "exception"
+)
+
+// ErrNotConfigured is returned when a provider is selected but its credentials are missing.
+var ErrNotConfigured = errors.New("courier provider is not configured")
+
+// Address is a sender or recipient postal address.
+type Address struct {
+	Name         string
+	Company      string
+	AddressLine1 string
+	AddressLine2 string
+	City         string
+	Postcode     string
+	CountryCode  string // ISO 3166-1 alpha-2; empty = GB
+	Phone        string
+	Email        string
+}
+
+// Parcel is one physical package in a consignment.
+type Parcel struct {
+	WeightKg float64
+}
+
+// ConsignmentRequest books one shipment with a carrier.
+type ConsignmentRequest struct {
+	Reference   string // our shipment reference, printed on the label
+	ServiceCode string // carrier service; empty = provider default
+	ShipDate    time.Time
+	Sender      Address
+	Recipient   Address
+	Parcels     []Parcel
+	Value       float64 // goods value (GBP), where the carrier asks for it
+}
+
+// Consignment is a booked shipment.
+type Consignment struct {
+	ID             string // carrier's own identifier, used to fetch the label
+	TrackingNumber string
+	ServiceCode    string
+	Label          []byte // PDF, when the carrier returns it at booking time
+}
+
+// TrackingEvent is one carrier scan, with Status normalised.
+type TrackingEvent struct {
+	Code        string
+	Status      string
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// Provider is implemented by each carrier adapter.
+type Provider interface {
+	Key() string
+	Name() string
+	CreateConsignment(ctx context.Context, req ConsignmentRequest) (Consignment, error)
+	Label(ctx context.Context, consignmentID string) ([]byte, error)
+	TrackingEvents(ctx context.Context, trackingNumber string) ([]TrackingEvent, error)
+}
+
+// Config holds credentials for every adapter; providers without credentials are still listed but return
+// ErrNotConfigured.
+type Config struct {
+	MockDir                 string // file-based provider storage
+	RoyalMailAPIKey         string // Click & Drop API key
+	RoyalMailTrackingID     string // Royal Mail Tracking API client ID
+	RoyalMailTrackingSecret string
+	HTTPClient              *http.Client
+}
+
+// Registry returns every available provider keyed by Key(). "royal_mail" and "parcelforce" are the same Click &
+// Drop adapter with different default services.
+func Registry(cfg Config) map[string]Provider {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	providers := []Provider{
+		NewFileProvider(cfg.MockDir),
+		newClickAndDrop("royal_mail", "Royal Mail", "TPN24", cfg, client),
+		newClickAndDrop("parcelforce", "Parcelforce Worldwide", "PFE48", cfg, client),
+	}
+	out := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		out[p.Key()] = p
+	}
+	return out
+}
+
+// Lookup finds a provider by key or display name (case-insensitive), so shipments whose Courier was typed
+// by hand still resolve.
+func Lookup(providers map[string]Provider, keyOrName string) (Provider, error) {
+	k := strings.ToLower(strings.TrimSpace(keyOrName))
+	if p, ok := providers[k]; ok {
+		return p, nil
+	}
+	for _, p := range providers {
+		if strings.EqualFold(p.Name(), k) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown courier provider %q", keyOrName)
+}
+
+// SortEvents orders events oldest first.
+func SortEvents(events []TrackingEvent) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OccurredAt.Before(events[j].OccurredAt) })
+}
+
+// Delivered returns the delivery event, if any.
+func Delivered(events []TrackingEvent) (TrackingEvent, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Status == StatusDelivered {
+			return events[i], true
+		}
+	}
+	return TrackingEvent{}, false
+}
+
+// NormaliseStatus maps a carrier's free-text event description to a status, for carriers without stable codes.
+func NormaliseStatus(description string) string {
+	d := strings.ToLower(description)
+	switch {
+	case strings.Contains(d, "out for delivery"):
+		return StatusOutForDelivery
+	case strings.Contains(d, "delivered") && !strings.Contains(d, "not delivered") && !strings.Contains(d, "undelivered"):
+		return StatusDelivered
+	case strings.Contains(d, "not delivered"), strings.Contains(d, "undelivered"), strings.Contains(d, "failed"),
+		strings.Contains(d, "returned"), strings.Contains(d, "exception"), strings.Contains(d, "damaged"):
+		return StatusException
+	case strings.Contains(d, "transit"), strings.Contains(d, "collected"), strings.Contains(d, "received"),
+		strings.Contains(d, "hub"), strings.Contains(d, "depot"), strings.Contains(d, "sorted"):
+		return StatusInTransit
+	default:
+		return StatusInfo
+	}
+}
@@ -0,0 +1,65 @@
+package courier
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNormaliseStatus(t *testing.T) {
+	cases := map[string]string{
+		"Out for delivery":               StatusOutForDelivery,
+		"Delivered to recipient":         StatusDelivered,
+		"Item not delivered - card left": StatusException,
+		"Arrived at depot":               StatusInTransit,
+		"Label printed":                  StatusInfo,
+	}
+	for desc, want := range cases {
+		if got := NormaliseStatus(desc); got != want {
+			t.Errorf("NormaliseStatus(%q) = %q, want %q", desc, got, want)
+		}
+	}
+}
+
+func TestFileProviderRoundTrip(t *testing.T) {
+	p := NewFileProvider(t.TempDir())
+	ctx := context.Background()
+	c, err := p.CreateConsignment(ctx, ConsignmentRequest{Reference: "WO1", Recipient: Address{AddressLine1: "1 High St", Postcode: "AB1 2CD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.TrackingNumber == "" || len(c.Label) == 0 {
+		t.Fatalf("expected tracking number and label, got %+v", c)
+	}
+	events, err := p.TrackingEvents(ctx, c.TrackingNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Delivered(events); ok || len(events) != 1 {
+		t.Fatalf("new consignment should only be booked, got %+v", events)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	raw := []fileEvent{
+		{Code: "BOOKED", Status: StatusInfo, Description: "Consignment booked", OccurredAt: now.Add(-2 * time.Hour)},
+		{Code: "DLV", Description: "Delivered", OccurredAt: now},
+		{Code: "OFD", Description: "Out for delivery", OccurredAt: now.Add(-time.Hour)},
+	}
+	data, _ := json.Marshal(raw)
+	if err := os.WriteFile(p.path(c.TrackingNumber+".events.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	events, err = p.TrackingEvents(ctx, c.TrackingNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[1].Status != StatusOutForDelivery {
+		t.Errorf("events not sorted oldest first: %+v", events)
+	}
+	d, ok := Delivered(events)
+	if !ok || !d.OccurredAt.Equal(now) {
+		t.Errorf("Delivered = %+v, %v", d, ok)
+	}
+}
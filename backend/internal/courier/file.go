@@ -0,0 +1,138 @@
+package courier
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// FileProvider is a courier that books nothing externally: consignments and tracking events live in JSON files
+// under Dir. Staff (or tests) append events to <tracking>.events.json to simulate carrier scans.
+type FileProvider struct {
+	Dir string
+}
+
+// NewFileProvider stores data in dir, or ./uploads/courier-mock when empty.
+func NewFileProvider(dir string) *FileProvider {
+	if strings.TrimSpace(dir) == "" {
+		dir = filepath.Join("uploads", "courier-mock")
+	}
+	return &FileProvider{Dir: dir}
+}
+
+func (p *FileProvider) Key() string  { return "file" }
+func (p *FileProvider) Name() string { return "Manual / test courier" }
+
+type fileConsignment struct {
+	ID             string             `json:"id"`
+	TrackingNumber string             `json:"tracking_number"`
+	ServiceCode    string             `json:"service_code"`
+	Request        ConsignmentRequest `json:"request"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+type fileEvent struct {
+	Code        string    `json:"code"`
+	Status      string    `json:"status,omitempty"` // optional; derived from description when empty
+	Description string    `json:"description"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+func (p *FileProvider) path(name string) string {
+	return filepath.Join(p.Dir, filepath.Base(name))
+}
+
+func (p *FileProvider) CreateConsignment(ctx context.Context, req ConsignmentRequest) (Consignment, error) {
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return Consignment{}, err
+	}
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return Consignment{}, err
+	}
+	tracking := "TEST" + strings.ToUpper(hex.EncodeToString(raw))
+	service := req.ServiceCode
+	if service == "" {
+		service = "STANDARD"
+	}
+	rec := fileConsignment{ID: tracking, TrackingNumber: tracking, ServiceCode: service, Request: req, CreatedAt: time.Now()}
+	data, _ := json.MarshalIndent(rec, "", "  ")
+	if err := os.WriteFile(p.path(tracking+".json"), data, 0o644); err != nil {
+		return Consignment{}, err
+	}
+	events := []fileEvent{{Code: "BOOKED", Status: StatusInfo, Description: "Consignment booked", OccurredAt: rec.CreatedAt}}
+	data, _ = json.MarshalIndent(events, "", "  ")
+	if err := os.WriteFile(p.path(tracking+".events.json"), data, 0o644); err != nil {
+		return Consignment{}, err
+	}
+	label, err := p.Label(ctx, tracking)
+	if err != nil {
+		return Consignment{}, err
+	}
+	return Consignment{ID: tracking, TrackingNumber: tracking, ServiceCode: service, Label: label}, nil
+}
+
+// Label renders a plain A6 label; real carriers return their own.
+func (p *FileProvider) Label(ctx context.Context, consignmentID string) ([]byte, error) {
+	data, err := os.ReadFile(p.path(consignmentID + ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("consignment %s not found", consignmentID)
+	}
+	var rec fileConsignment
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "mm", Size: gofpdf.SizeType{Wd: 105, Ht: 148}})
+	pdf.SetMargins(6, 6, 6)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, "TEST LABEL - NOT FOR DESPATCH", "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	r := rec.Request.Recipient
+	for _, line := range []string{r.Name, r.Company, r.AddressLine1, r.AddressLine2, r.City, r.Postcode} {
+		if strings.TrimSpace(line) != "" {
+			pdf.CellFormat(0, 5, line, "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, rec.TrackingNumber, "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 5, "Ref: "+rec.Request.Reference+"   Service: "+rec.ServiceCode, "", 1, "C", false, 0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *FileProvider) TrackingEvents(ctx context.Context, trackingNumber string) ([]TrackingEvent, error) {
+	data, err := os.ReadFile(p.path(trackingNumber + ".events.json"))
+	if err != nil {
+		return nil, fmt.Errorf("no tracking data for %s", trackingNumber)
+	}
+	var raw []fileEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("tracking file for %s: %w", trackingNumber, err)
+	}
+	events := make([]TrackingEvent, 0, len(raw))
+	for _, e := range raw {
+		status := e.Status
+		if status == "" {
+			status = NormaliseStatus(e.Description)
+		}
+		events = append(events, TrackingEvent{Code: e.Code, Status: status, Description: e.Description, Location: e.Location, OccurredAt: e.OccurredAt})
+	}
+	SortEvents(events)
+	return events, nil
+}
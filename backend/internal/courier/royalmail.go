@@ -0,0 +1,261 @@
+package courier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Royal Mail Click & Drop books Royal Mail and Parcelforce services from one account; tracking for both comes from
+// the Royal Mail Tracking API.
+const (
+	clickAndDropBaseURL      = "https://api.parcel.royalmail.com/api/v1"
+	royalMailTrackingBaseURL = "https://api.royalmail.net/mailpieces/v2"
+)
+
+type clickAndDrop struct {
+	key, name      string
+	defaultService string
+	apiKey         string
+	trackingID     string
+	trackingSecret string
+	client         *http.Client
+	baseURL        string
+	trackingURL    string
+}
+
+func newClickAndDrop(key, name, defaultService string, cfg Config, client *http.Client) *clickAndDrop {
+	return &clickAndDrop{
+		key: key, name: name, defaultService: defaultService,
+		apiKey: strings.TrimSpace(cfg.RoyalMailAPIKey), trackingID: strings.TrimSpace(cfg.RoyalMailTrackingID),
+		trackingSecret: strings.TrimSpace(cfg.RoyalMailTrackingSecret), client: client,
+		baseURL: clickAndDropBaseURL, trackingURL: royalMailTrackingBaseURL,
+	}
+}
+
+func (p *clickAndDrop) Key() string  { return p.key }
+func (p *clickAndDrop) Name() string { return p.name }
+
+type cdAddress struct {
+	FullName     string `json:"fullName,omitempty"`
+	CompanyName  string `json:"companyName,omitempty"`
+	AddressLine1 string `json:"addressLine1"`
+	AddressLine2 string `json:"addressLine2,omitempty"`
+	City         string `json:"city"`
+	Postcode     string `json:"postcode"`
+	CountryCode  string `json:"countryCode"`
+}
+
+type cdOrder struct {
+	OrderReference string `json:"orderReference"`
+	Recipient      struct {
+		Address      cdAddress `json:"address"`
+		PhoneNumber  string    `json:"phoneNumber,omitempty"`
+		EmailAddress string    `json:"emailAddress,omitempty"`
+	} `json:"recipient"`
+	Sender struct {
+		TradingName  string `json:"tradingName,omitempty"`
+		PhoneNumber  string `json:"phoneNumber,omitempty"`
+		EmailAddress string `json:"emailAddress,omitempty"`
+	} `json:"sender"`
+	Packages []struct {
+		WeightInGrams           int    `json:"weightInGrams"`
+		PackageFormatIdentifier string `json:"packageFormatIdentifier"`
+	} `json:"packages"`
+	OrderDate           string  `json:"orderDate"`
+	Subtotal            float64 `json:"subtotal"`
+	ShippingCostCharged float64 `json:"shippingCostCharged"`
+	Total               float64 `json:"total"`
+	CurrencyCode        string  `json:"currencyCode"`
+	PostageDetails      struct {
+		ServiceCode string `json:"serviceCode"`
+	} `json:"postageDetails"`
+	Label struct {
+		IncludeLabelInResponse bool `json:"includeLabelInResponse"`
+	} `json:"label"`
+}
+
+type cdCreateResponse struct {
+	CreatedOrders []struct {
+		OrderIdentifier int64  `json:"orderIdentifier"`
+		TrackingNumber  string `json:"trackingNumber"`
+		Label           string `json:"label"` // base64 PDF
+	} `json:"createdOrders"`
+	FailedOrders []struct {
+		Errors []struct {
+			ErrorMessage string `json:"errorMessage"`
+		} `json:"errors"`
+	} `json:"failedOrders"`
+}
+
+func (p *clickAndDrop) do(req *http.Request) ([]byte, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return nil, fmt.Errorf("%s API returned %d: %s", p.name, resp.StatusCode, msg)
+	}
+	return body, nil
+}
+
+func (p *clickAndDrop) CreateConsignment(ctx context.Context, req ConsignmentRequest) (Consignment, error) {
+	if p.apiKey == "" {
+		return Consignment{}, ErrNotConfigured
+	}
+	var o cdOrder
+	o.OrderReference = req.Reference
+	country := req.Recipient.CountryCode
+	if country == "" {
+		country = "GB"
+	}
+	o.Recipient.Address = cdAddress{
+		FullName: req.Recipient.Name, CompanyName: req.Recipient.Company, AddressLine1: req.Recipient.AddressLine1,
+		AddressLine2: req.Recipient.AddressLine2, City: req.Recipient.City, Postcode: req.Recipient.Postcode, CountryCode: country,
+	}
+	o.Recipient.PhoneNumber = req.Recipient.Phone
+	o.Recipient.EmailAddress = req.Recipient.Email
+	o.Sender.TradingName = req.Sender.Company
+	o.Sender.PhoneNumber = req.Sender.Phone
+	o.Sender.EmailAddress = req.Sender.Email
+	parcels := req.Parcels
+	if len(parcels) == 0 {
+		parcels = []Parcel{{WeightKg: 1}}
+	}
+	for _, parcel := range parcels {
+		grams := int(parcel.WeightKg * 1000)
+		if grams <= 0 {
+			grams = 1000
+		}
+		o.Packages = append(o.Packages, struct {
+			WeightInGrams           int    `json:"weightInGrams"`
+			PackageFormatIdentifier string `json:"packageFormatIdentifier"`
+		}{grams, "parcel"})
+	}
+	shipDate := req.ShipDate
+	if shipDate.IsZero() {
+		shipDate = time.Now()
+	}
+	o.OrderDate = shipDate.UTC().Format(time.RFC3339)
+	o.Subtotal, o.Total, o.CurrencyCode = req.Value, req.Value, "GBP"
+	o.PostageDetails.ServiceCode = req.ServiceCode
+	if o.PostageDetails.ServiceCode == "" {
+		o.PostageDetails.ServiceCode = p.defaultService
+	}
+	o.Label.IncludeLabelInResponse = true
+
+	payload, _ := json.Marshal(map[string]interface{}{"items": []cdOrder{o}})
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/orders", bytes.NewReader(payload))
+	if err != nil {
+		return Consignment{}, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	body, err := p.do(httpReq)
+	if err != nil {
+		return Consignment{}, err
+	}
+	var resp cdCreateResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Consignment{}, fmt.Errorf("%s: unexpected response: %w", p.name, err)
+	}
+	if len(resp.CreatedOrders) == 0 {
+		var msgs []string
+		for _, f := range resp.FailedOrders {
+			for _, e := range f.Errors {
+				msgs = append(msgs, e.ErrorMessage)
+			}
+		}
+		return Consignment{}, fmt.Errorf("%s rejected the consignment: %s", p.name, strings.Join(msgs, "; "))
+	}
+	created := resp.CreatedOrders[0]
+	out := Consignment{
+		ID: fmt.Sprintf("%d", created.OrderIdentifier), TrackingNumber: created.TrackingNumber, ServiceCode: o.PostageDetails.ServiceCode,
+	}
+	if created.Label != "" {
+		if label, err := base64.StdEncoding.DecodeString(created.Label); err == nil {
+			out.Label = label
+		}
+	}
+	return out, nil
+}
+
+func (p *clickAndDrop) Label(ctx context.Context, consignmentID string) ([]byte, error) {
+	if p.apiKey == "" {
+		return nil, ErrNotConfigured
+	}
+	u := fmt.Sprintf("%s/orders/%s/label?documentType=postageLabel&includeReturnsLabel=false", p.baseURL, url.PathEscape(consignmentID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Accept", "application/pdf")
+	return p.do(req)
+}
+
+type rmTrackingResponse struct {
+	MailPieces struct {
+		Events []struct {
+			EventCode     string `json:"eventCode"`
+			EventName     string `json:"eventName"`
+			EventDateTime string `json:"eventDateTime"`
+			LocationName  string `json:"locationName"`
+		} `json:"events"`
+	} `json:"mailPieces"`
+}
+
+// royalMailDeliveredCodes are tracking event codes that mean the item reached the recipient.
+var royalMailDeliveredCodes = map[string]bool{"EVKSP": true, "EVKOP": true, "EVGPD": true}
+
+func (p *clickAndDrop) TrackingEvents(ctx context.Context, trackingNumber string) ([]TrackingEvent, error) {
+	if p.trackingID == "" || p.trackingSecret == "" {
+		return nil, ErrNotConfigured
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.trackingURL+"/"+url.PathEscape(trackingNumber)+"/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-IBM-Client-Id", p.trackingID)
+	req.Header.Set("X-IBM-Client-Secret", p.trackingSecret)
+	req.Header.Set("X-Accept-RMG-Terms", "yes")
+	req.Header.Set("Accept", "application/json")
+	body, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp rmTrackingResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("%s tracking: unexpected response: %w", p.name, err)
+	}
+	events := make([]TrackingEvent, 0, len(resp.MailPieces.Events))
+	for _, e := range resp.MailPieces.Events {
+		at, err := time.Parse(time.RFC3339, e.EventDateTime)
+		if err != nil {
+			continue
+		}
+		status := NormaliseStatus(e.EventName)
+		if royalMailDeliveredCodes[e.EventCode] {
+			status = StatusDelivered
+		}
+		events = append(events, TrackingEvent{Code: e.EventCode, Status: status, Description: e.EventName, Location: e.LocationName, OccurredAt: at})
+	}
+	SortEvents(events)
+	return events, nil
+}
@@ -0,0 +1,44 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	cases := []struct {
+		expr, tz string
+		from     time.Time
+		want     time.Time
+	}{
+		{"*/15 * * * *", "", time.Date(2026, 3, 1, 10, 7, 30, 0, time.UTC), time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 6 * * *", "Europe/London", time.Date(2026, 7, 1, 6, 0, 0, 0, london), time.Date(2026, 7, 2, 6, 0, 0, 0, london)},
+		{"30 18 * * mon-fri", "", time.Date(2026, 10, 16, 19, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 18, 30, 0, 0, time.UTC)}, // Fri -> Mon
+		{"0 0 1,15 * 0", "", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 4, 0, 0, 0, 0, time.UTC)},           // dom OR dow
+		{"@monthly", "", time.Date(2026, 12, 5, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 01:30 does not exist in London on 2026-03-29 (clocks go forward at 01:00), so that day is skipped.
+		{"30 1 * * *", "Europe/London", time.Date(2026, 3, 28, 12, 0, 0, 0, london), time.Date(2026, 3, 30, 1, 30, 0, 0, london)},
+		{"0 0 30 2 *", "", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, tc := range cases {
+		s, err := Parse(tc.expr, tc.tz)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.expr, err)
+		}
+		if got := s.Next(tc.from); !got.Equal(tc.want) {
+			t.Errorf("%q Next(%v) = %v, want %v", tc.expr, tc.from, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 5-2 * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := Parse(expr, ""); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+	if _, err := Parse("* * * * *", "Mars/Olympus"); err == nil {
+		t.Error("unknown time zone accepted")
+	}
+}
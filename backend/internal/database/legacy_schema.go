@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"pos-system/backend/internal/migrate"
+	"pos-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// adoptLegacySchema brings a database built by AutoMigrate (before versioned migrations) to the baseline and
+// records the baseline as applied, so that only later migrations run against it. It does nothing for empty or
+// already adopted databases.
+//
+// AutoMigrate uses the models as they are in this build. Once a migration after the baseline changes a table that
+// the models also describe, such a database must first be started with a build from before that migration.
+func adoptLegacySchema(db *gorm.DB, baseline migrate.Migration) error {
+	legacy, err := IsLegacySchema(db)
+	if err != nil || !legacy {
+		return err
+	}
+	log.Println("Adopting existing database schema into versioned migrations...")
+
+	// Backfill empty ref_no before adding unique index (OC Number)
+	_ = db.Exec("UPDATE wholesale_orders SET ref_no = CAST(id AS CHAR) WHERE ref_no = '' OR ref_no IS NULL").Error
+
+	// Resolve duplicate ref_no so unique index can be applied (e.g. D123 → D123, D123.1, D123.2)
+	var duplicates []struct {
+		RefNo string
+		Cnt   int64
+	}
+	db.Raw("SELECT ref_no, COUNT(*) as cnt FROM wholesale_orders GROUP BY ref_no HAVING cnt > 1").Scan(&duplicates)
+	for _, d := range duplicates {
+		var ids []uint
+		db.Model(&models.WholesaleOrder{}).Where("ref_no = ?", d.RefNo).Order("id ASC").Pluck("id", &ids)
+		for i, oid := range ids {
+			newRef := d.RefNo
+			if i > 0 {
+				newRef = fmt.Sprintf("%s.%d", d.RefNo, i+1)
+			}
+			db.Exec("UPDATE wholesale_orders SET ref_no = ? WHERE id = ?", newRef, oid)
+		}
+	}
+
+	if err := db.AutoMigrate(legacyModels...); err != nil {
+		return fmt.Errorf("adopt legacy schema: %w", err)
+	}
+
+	// shipment_items: allow same order line on multiple store shipments (composite unique key).
+	if err := db.Exec(
+		"ALTER TABLE shipment_items ADD INDEX idx_shipment_items_wo_item (wholesale_order_item_id)",
+	).Error; err != nil {
+		log.Printf("shipment_items index migration (add wo_item index): %v", err)
+	}
+	if err := db.Exec("ALTER TABLE shipment_items DROP INDEX idx_shipment_wo_item").Error; err != nil {
+		log.Printf("shipment_items index migration (drop old): %v", err)
+	}
+	if err := db.Exec(
+		"ALTER TABLE shipment_items ADD UNIQUE INDEX idx_shipment_wo_item (shipment_id, wholesale_order_item_id)",
+	).Error; err != nil {
+		log.Printf("shipment_items index migration (add composite): %v", err)
+	}
+
+	// Convert effective_from/effective_to from datetime to date
+	for _, stmt := range []string{
+		"ALTER TABLE product_costs MODIFY effective_from DATE",
+		"ALTER TABLE product_costs MODIFY effective_to DATE",
+		"ALTER TABLE product_sector_discounts MODIFY effective_from DATE",
+		"ALTER TABLE product_sector_discounts MODIFY effective_to DATE",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			log.Printf("Column migration (may already be date): %v", err)
+		}
+	}
+
+	// users.role names an editable Role, so it is no longer an enum.
+	_ = db.Exec("ALTER TABLE users MODIFY COLUMN role VARCHAR(50) NOT NULL").Error
+
+	if err := migrate.MarkApplied(db, baseline); err != nil {
+		return fmt.Errorf("record baseline: %w", err)
+	}
+	log.Printf("Existing schema adopted as migration %04d_%s", baseline.Version, baseline.Name)
+	return nil
+}
+
+// legacyModels is the AutoMigrate list at the time of the baseline. Do not add to it: new tables and columns
+// get a migration file.
+var legacyModels = []interface{}{
+	// Base tables
+	&models.User{},
+	&models.Store{},
+	&models.Sector{},
+	&models.ProductLine{},
+	&models.Product{},
+	// Dependent tables
+	&models.POSDevice{},
+	&models.ProductCost{},
+	&models.ProductSectorDiscount{},
+	&models.Stock{},
+	&models.RestockOrder{},
+	&models.RestockOrderItem{},
+	&models.Order{},
+	&models.OrderItem{},
+	&models.PriceHistory{},
+	&models.CurrencyRate{},
+	&models.AuditLog{},
+	&models.Role{},
+	&models.RolePermission{},
+	&models.UserStoreRole{},
+	&models.UserSession{},
+	&models.SessionRefreshToken{},
+	&models.UserTwoFactor{},
+	&models.UserRecoveryCode{},
+	&models.APIKey{},
+	&models.WebhookSubscription{},
+	&models.WebhookDelivery{},
+	&models.Job{},
+	&models.ScheduledTask{},
+	&models.SchedulerLease{},
+	&models.StocktakeInventorySnapshot{},
+	&models.StocktakeDayStartRecord{},
+	&models.UserActivityEvent{},
+	&models.WholesaleClient{},
+	&models.WholesaleClientStore{},
+	&models.ShippingRule{},
+	&models.ShippingRuleSurcharge{},
+	&models.WholesaleOrder{},
+	&models.WholesaleOrderItem{},
+	&models.WholesaleOrderDocument{},
+	&models.CompanySettings{},
+	&models.Shipment{},
+	&models.ShipmentItem{},
+	&models.ShipmentPickScan{},
+	&models.ShipmentTrackingEvent{},
+	&models.DeliveryProof{},
+	&models.PackingSession{},
+	&models.PackingCarton{},
+	&models.PackingCartonItem{},
+	&models.DeliveryRun{},
+	&models.DeliveryRunStop{},
+	&models.WholesaleQuotation{},
+	&models.WholesaleQuotationItem{},
+	&models.StandingOrder{},
+	&models.StandingOrderItem{},
+	&models.StandingOrderOccurrence{},
+	&models.WholesaleReturn{},
+	&models.WholesaleReturnItem{},
+	&models.AccountingNominalCode{},
+	&models.AccountingExport{},
+	&models.AccountingExportDocument{},
+	&models.DocumentNumberSeries{},
+	&models.DocumentNumberCounter{},
+	&models.DocumentNumber{},
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"pos-system/backend/internal/migrate"
+	"pos-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Schema changes are SQL files in migrations/ (add one with `go run ./cmd/migrate create <name>`); data fixes that
+// are easier in Go go in dataMigrations. Both share one version sequence.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationsDir is where `migrate create` writes new files, relative to backend/.
+const MigrationsDir = "internal/database/migrations"
+
+// baselineVersion is the migration that creates the schema AutoMigrate used to maintain.
+const baselineVersion = 1
+
+// dataMigrations are one-time data fixes that used to run on every start. Their Down does nothing: the fixed
+// data is just as valid for the older code.
+var dataMigrations = []migrate.Migration{
+	{Version: 2, Name: "wholesale_archived_to_deleted", Up: migrateArchivedWholesaleOrders},
+	{Version: 3, Name: "store_receipt_settings_configured", Up: markConfiguredReceiptSettings},
+	{Version: 4, Name: "product_sell_modes", Up: normaliseProductSellModes},
+	{Version: 5, Name: "product_lines", Up: migrateProductLines},
+	{Version: 6, Name: "wholesale_payment_confirmed_at", Up: backfillWholesalePaymentConfirmedAt},
+	{Version: 7, Name: "pos_user_stock_adjust", Up: grantPosUserStockAdjust},
+}
+
+// Migrations returns every migration this build knows, in version order.
+func Migrations() ([]migrate.Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	files, err := migrate.Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	list, err := migrate.Sorted(files, dataMigrations)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 || list[0].Version != baselineVersion {
+		return nil, errors.New("migration 0001 (baseline) is missing")
+	}
+	return list, nil
+}
+
+// MigrateUp applies pending migrations up to target (0 for all) under the migration lock.
+func MigrateUp(db *gorm.DB, target int64) error {
+	return WithMigrationLock(db, func() error { return migrateUp(db, target) })
+}
+
+// MigrateDown rolls back the newest steps migrations under the migration lock.
+func MigrateDown(db *gorm.DB, steps int) ([]migrate.Migration, error) {
+	list, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var reverted []migrate.Migration
+	err = WithMigrationLock(db, func() error {
+		if err := migrate.EnsureTable(db); err != nil {
+			return err
+		}
+		reverted, err = migrate.Down(db, list, steps, log.Printf)
+		return err
+	})
+	return reverted, err
+}
+
+func migrateUp(db *gorm.DB, target int64) error {
+	list, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := migrate.EnsureTable(db); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	if err := adoptLegacySchema(db, list[0]); err != nil {
+		return err
+	}
+	_, err = migrate.Up(db, list, target, log.Printf)
+	return err
+}
+
+func checkSchema(db *gorm.DB) error {
+	list, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := migrate.EnsureTable(db); err != nil {
+		return err
+	}
+	if err := migrate.Check(db, list); err != nil {
+		if errors.Is(err, migrate.ErrPending) {
+			return fmt.Errorf("%w; run `go run ./cmd/migrate up` or start with MIGRATE_ON_START enabled", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// IsLegacySchema reports whether the database was built by AutoMigrate and has not been adopted yet: it has
+// tables but no recorded migrations. The next MigrateUp adopts it.
+func IsLegacySchema(db *gorm.DB) (bool, error) {
+	if !db.Migrator().HasTable("users") {
+		return false, nil
+	}
+	if !db.Migrator().HasTable(&migrate.Record{}) {
+		return true, nil
+	}
+	var count int64
+	if err := db.Model(&migrate.Record{}).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+func migrateArchivedWholesaleOrders(tx *gorm.DB) error {
+	// Align legacy archived rows with status-based soft delete; only old databases still have is_archived.
+	if !tx.Migrator().HasColumn("wholesale_orders", "is_archived") {
+		return nil
+	}
+	return tx.Exec(
+		"UPDATE wholesale_orders SET status = ? WHERE COALESCE(is_archived, 0) = 1 AND status != ?",
+		models.WholesaleOrderStatusDeleted,
+		models.WholesaleOrderStatusDeleted,
+	).Error
+}
+
+// grantPosUserStockAdjust gives stock.adjust to a pos_user role seeded without it, so tills can keep saving
+// stocktakes and stock edits. Fresh databases get it from the seed instead.
+func grantPosUserStockAdjust(tx *gorm.DB) error {
+	return tx.Exec(
+		"INSERT IGNORE INTO role_permissions (role_id, permission) SELECT id, ? FROM roles WHERE name = ?",
+		models.PermStockAdjust, models.RoleNamePosUser,
+	).Error
+}
+
+func markConfiguredReceiptSettings(tx *gorm.DB) error {
+	return tx.Exec(`
+		UPDATE stores SET pos_receipt_settings_configured = 1
+		WHERE pos_receipt_types IS NOT NULL
+		  AND pos_receipt_types != ''
+		  AND pos_receipt_types != '[]'
+		  AND pos_auto_print_receipt_types IS NOT NULL
+	`).Error
+}
+
+// normaliseProductSellModes derives sell_by_qty / sell_by_weight from unit_type for products created before
+// those flags existed, and nulls empty barcodes (empty strings violate the unique indexes).
+func normaliseProductSellModes(tx *gorm.DB) error {
+	for _, stmt := range []string{
+		`UPDATE products SET sell_by_qty = 1
+		WHERE (sell_by_qty = 0 OR sell_by_qty IS NULL)
+		  AND (unit_type = 'quantity' OR can_sell_by_weight = 1)`,
+		`UPDATE products SET sell_by_weight = 1
+		WHERE (sell_by_weight = 0 OR sell_by_weight IS NULL)
+		  AND (can_sell_by_weight = 1 OR unit_type = 'weight')`,
+		`UPDATE products SET weight_barcode = barcode
+		WHERE (weight_barcode IS NULL OR weight_barcode = '')
+		  AND unit_type = 'weight'
+		  AND barcode IS NOT NULL AND barcode != ''`,
+		`UPDATE products SET weight_barcode = NULL WHERE weight_barcode = ''`,
+		`UPDATE products SET barcode = NULL WHERE barcode = ''`,
+		`UPDATE products SET sell_by_qty = 0
+		WHERE unit_type = 'weight' AND can_sell_by_weight = 0 AND sell_by_weight = 1`,
+		`UPDATE products SET sell_by_qty = 0, sell_by_weight = 0, can_sell_by_weight = 0
+		WHERE unit_type NOT IN ('quantity', 'weight') OR unit_type IS NULL OR unit_type = ''`,
+		`UPDATE products SET unit_type = 'quantity', sell_by_qty = 1, sell_by_weight = 0, can_sell_by_weight = 0
+		WHERE unit_type = 'quantity' OR (unit_type IS NULL OR unit_type = '')`,
+		`UPDATE products SET unit_type = 'weight', sell_by_qty = 0, sell_by_weight = 1, can_sell_by_weight = 0
+		WHERE unit_type = 'weight'`,
+		`UPDATE products SET sell_by_qty = 1, sell_by_weight = 0, can_sell_by_weight = 0
+		WHERE unit_type = 'quantity' AND (sell_by_weight = 1 OR can_sell_by_weight = 1)`,
+		`UPDATE products SET sell_by_qty = 0, sell_by_weight = 1, can_sell_by_weight = 0
+		WHERE unit_type = 'weight' AND sell_by_qty = 1`,
+	} {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+-- 0001 baseline: drops every table, newest dependants first. Only for resetting a development database.
+
+DROP TABLE IF EXISTS `document_numbers`;
+DROP TABLE IF EXISTS `document_number_counters`;
+DROP TABLE IF EXISTS `document_number_series`;
+DROP TABLE IF EXISTS `accounting_export_documents`;
+DROP TABLE IF EXISTS `accounting_exports`;
+DROP TABLE IF EXISTS `accounting_nominal_codes`;
+DROP TABLE IF EXISTS `wholesale_return_items`;
+DROP TABLE IF EXISTS `wholesale_returns`;
+DROP TABLE IF EXISTS `standing_order_occurrences`;
+DROP TABLE IF EXISTS `standing_order_items`;
+DROP TABLE IF EXISTS `standing_orders`;
+DROP TABLE IF EXISTS `wholesale_quotation_items`;
+DROP TABLE IF EXISTS `wholesale_quotations`;
+DROP TABLE IF EXISTS `delivery_run_stops`;
+DROP TABLE IF EXISTS `delivery_runs`;
+DROP TABLE IF EXISTS `packing_carton_items`;
+DROP TABLE IF EXISTS `packing_cartons`;
+DROP TABLE IF EXISTS `packing_sessions`;
+DROP TABLE IF EXISTS `delivery_proofs`;
+DROP TABLE IF EXISTS `shipment_tracking_events`;
+DROP TABLE IF EXISTS `shipment_pick_scans`;
+DROP TABLE IF EXISTS `shipment_items`;
+DROP TABLE IF EXISTS `shipments`;
+DROP TABLE IF EXISTS `company_settings`;
+DROP TABLE IF EXISTS `wholesale_order_documents`;
+DROP TABLE IF EXISTS `wholesale_order_items`;
+DROP TABLE IF EXISTS `wholesale_orders`;
+DROP TABLE IF EXISTS `shipping_rule_surcharges`;
+DROP TABLE IF EXISTS `shipping_rules`;
+DROP TABLE IF EXISTS `wholesale_client_delivery_locations`;
+DROP TABLE IF EXISTS `user_activity_events`;
+DROP TABLE IF EXISTS `stocktake_day_start_records`;
+DROP TABLE IF EXISTS `stocktake_inventory_snapshots`;
+DROP TABLE IF EXISTS `scheduler_leases`;
+DROP TABLE IF EXISTS `scheduled_tasks`;
+DROP TABLE IF EXISTS `jobs`;
+DROP TABLE IF EXISTS `webhook_deliveries`;
+DROP TABLE IF EXISTS `webhook_subscriptions`;
+DROP TABLE IF EXISTS `api_keys`;
+DROP TABLE IF EXISTS `user_recovery_codes`;
+DROP TABLE IF EXISTS `user_two_factors`;
+DROP TABLE IF EXISTS `session_refresh_tokens`;
+DROP TABLE IF EXISTS `user_sessions`;
+DROP TABLE IF EXISTS `user_store_roles`;
+DROP TABLE IF EXISTS `role_permissions`;
+DROP TABLE IF EXISTS `roles`;
+DROP TABLE IF EXISTS `audit_logs`;
+DROP TABLE IF EXISTS `currency_rates`;
+DROP TABLE IF EXISTS `price_histories`;
+DROP TABLE IF EXISTS `order_items`;
+DROP TABLE IF EXISTS `orders`;
+DROP TABLE IF EXISTS `restock_order_items`;
+DROP TABLE IF EXISTS `restock_orders`;
+DROP TABLE IF EXISTS `stocks`;
+DROP TABLE IF EXISTS `product_sector_discounts`;
+DROP TABLE IF EXISTS `product_costs`;
+DROP TABLE IF EXISTS `pos_devices`;
+DROP TABLE IF EXISTS `products`;
+DROP TABLE IF EXISTS `product_lines`;
+DROP TABLE IF EXISTS `user_stores`;
+DROP TABLE IF EXISTS `stores`;
+DROP TABLE IF EXISTS `user_wholesale_clients`;
+DROP TABLE IF EXISTS `wholesale_clients`;
+DROP TABLE IF EXISTS `sectors`;
+DROP TABLE IF EXISTS `users`;
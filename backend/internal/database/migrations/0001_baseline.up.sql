@@ -0,0 +1,1189 @@
+-- 0001 baseline: the schema as GORM AutoMigrate left it when versioned migrations were introduced.
+-- Databases that predate this file are adopted instead (see adoptLegacySchema); it only runs on empty databases.
+
+CREATE TABLE `users` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `username` varchar(100) NOT NULL,
+  `password_hash` varchar(255) NOT NULL,
+  `pin_hash` longtext,
+  `first_name` longtext NOT NULL,
+  `last_name` longtext NOT NULL,
+  `email` longtext,
+  `role` varchar(50) NOT NULL,
+  `icon_url` longtext,
+  `icon_color` longtext,
+  `icon_bg_color` longtext,
+  `icon_text_color` longtext,
+  `is_active` boolean DEFAULT true,
+  `default_store_id` bigint unsigned,
+  `default_wholesale_client_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_users_username` (`username`),
+  INDEX `idx_users_role` (`role`),
+  INDEX `idx_users_default_store_id` (`default_store_id`),
+  INDEX `idx_users_default_wholesale_client_id` (`default_wholesale_client_id`)
+);
+
+CREATE TABLE `sectors` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `description` text,
+  `discount_rate` decimal(5,2) DEFAULT 0,
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_sectors_name` (`name`)
+);
+
+CREATE TABLE `wholesale_clients` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(200) NOT NULL,
+  `contact_name` varchar(200),
+  `email` varchar(255),
+  `phone` varchar(100),
+  `address` text,
+  `address_line1` varchar(255),
+  `address_line2` varchar(255),
+  `postcode` varchar(50),
+  `vat_number` varchar(50),
+  `company_number` varchar(50),
+  `terms` varchar(500),
+  `account_code` varchar(50),
+  `sector_id` bigint unsigned,
+  `is_active` boolean DEFAULT true,
+  `credit_limit` decimal(10,2) NOT NULL DEFAULT 0,
+  `credit_action` varchar(10) NOT NULL DEFAULT 'block',
+  `on_stop` boolean DEFAULT false,
+  `on_stop_reason` varchar(500),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_clients_name` (`name`),
+  INDEX `idx_wholesale_clients_sector_id` (`sector_id`),
+  CONSTRAINT `fk_wholesale_clients_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`)
+);
+
+CREATE TABLE `user_wholesale_clients` (
+  `user_id` bigint unsigned,
+  `wholesale_client_id` bigint unsigned,
+  PRIMARY KEY (`user_id`,`wholesale_client_id`),
+  CONSTRAINT `fk_user_wholesale_clients_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_user_wholesale_clients_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`)
+);
+
+CREATE TABLE `stores` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` longtext NOT NULL,
+  `address` longtext,
+  `is_warehouse_only` boolean DEFAULT false,
+  `is_active` boolean DEFAULT true,
+  `pos_receipt_types` text,
+  `pos_auto_print_receipt_types` text,
+  `pos_receipt_settings_configured` boolean DEFAULT false,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `user_stores` (
+  `store_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  PRIMARY KEY (`store_id`,`user_id`),
+  CONSTRAINT `fk_user_stores_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_user_stores_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `product_lines` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `name_chinese` varchar(255),
+  `category` varchar(255),
+  `image_url` varchar(500),
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `products` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `product_line_id` bigint unsigned,
+  `name` varchar(255) NOT NULL,
+  `name_chinese` varchar(255),
+  `barcode` varchar(100),
+  `sku` varchar(100),
+  `category` varchar(255),
+  `image_url` varchar(500),
+  `variant_label` varchar(255),
+  `units_per_pack` decimal(10,3) DEFAULT 0,
+  `unit_type` enum('quantity','weight') DEFAULT 'quantity',
+  `sell_by_qty` boolean DEFAULT true,
+  `sell_by_weight` boolean DEFAULT false,
+  `weight_barcode` varchar(100),
+  `weight_barcode_prefix` varchar(8),
+  `price_weight_g` decimal(10,3) DEFAULT 0,
+  `can_sell_by_weight` boolean DEFAULT false,
+  `prepack_weight_g` decimal(10,3) DEFAULT 0,
+  `wholesale_units_per_box` decimal(10,3) DEFAULT 0,
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_products_product_line_id` (`product_line_id`),
+  UNIQUE INDEX `idx_products_barcode` (`barcode`),
+  UNIQUE INDEX `idx_products_sku` (`sku`),
+  UNIQUE INDEX `idx_products_weight_barcode` (`weight_barcode`),
+  CONSTRAINT `fk_product_lines_variants` FOREIGN KEY (`product_line_id`) REFERENCES `product_lines`(`id`)
+);
+
+CREATE TABLE `pos_devices` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `device_code` varchar(100) NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `device_name` varchar(255),
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `token_hash` varchar(64),
+  `token_issued_at` datetime(3) NULL,
+  `enrolment_code_hash` varchar(64),
+  `enrolment_code_expires_at` datetime(3) NULL,
+  `enrolment_attempts` bigint NOT NULL DEFAULT 0,
+  `revoked_at` datetime(3) NULL,
+  `allow_cost_data` boolean DEFAULT false,
+  `last_seen_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_pos_devices_device_code` (`device_code`),
+  INDEX `idx_pos_devices_token_hash` (`token_hash`),
+  CONSTRAINT `fk_stores_pos_devices` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`)
+);
+
+CREATE TABLE `product_costs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `product_id` bigint unsigned NOT NULL,
+  `exchange_rate` decimal(10,4) NOT NULL,
+  `purchasing_cost_hkd` decimal(10,2),
+  `purchasing_cost_gbp` decimal(10,2),
+  `unit_weight_g` bigint NOT NULL,
+  `purchasing_cost_buffer_percent` decimal(5,2) DEFAULT 0,
+  `cost_buffer_gbp` decimal(10,2) DEFAULT 0,
+  `adjusted_purchasing_cost_gbp` decimal(10,2),
+  `weight_g` bigint NOT NULL,
+  `weight_buffer_percent` decimal(5,2) DEFAULT 0,
+  `freight_rate_hkd_per_kg` decimal(10,2) NOT NULL,
+  `freight_buffer_hkd` decimal(10,2) DEFAULT 0,
+  `freight_hkd` decimal(10,2),
+  `freight_gbp` decimal(10,2),
+  `import_duty_percent` decimal(5,2) DEFAULT 0,
+  `import_duty_gbp` decimal(10,2) DEFAULT 0,
+  `packaging_gbp` decimal(10,2) DEFAULT 0,
+  `wholesale_cost_gbp` decimal(10,2) NOT NULL,
+  `direct_retail_online_store_price_gbp` decimal(10,2) DEFAULT 0,
+  `effective_from` date,
+  `effective_to` date,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_product_costs_product_id` (`product_id`),
+  CONSTRAINT `fk_product_costs_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+
+CREATE TABLE `product_sector_discounts` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `product_id` bigint unsigned NOT NULL,
+  `sector_id` bigint unsigned NOT NULL,
+  `discount_percent` decimal(5,2) NOT NULL DEFAULT 0,
+  `sector_price_gbp` decimal(10,2) NOT NULL DEFAULT 0,
+  `effective_from` date,
+  `effective_to` date,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_product_sector_discounts_product_id` (`product_id`),
+  INDEX `idx_product_sector_discounts_sector_id` (`sector_id`),
+  CONSTRAINT `fk_product_sector_discounts_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`),
+  CONSTRAINT `fk_products_discounts` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+
+CREATE TABLE `stocks` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `product_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL DEFAULT 0,
+  `weight_quantity_g` decimal(10,3) NOT NULL DEFAULT 0,
+  `track_prepacked` boolean NOT NULL DEFAULT true,
+  `track_weight` boolean NOT NULL DEFAULT false,
+  `wholesale_ship_from` boolean NOT NULL DEFAULT false,
+  `bin_location` varchar(50),
+  `low_stock_threshold` decimal(10,3) DEFAULT 0,
+  `last_updated` datetime,
+  PRIMARY KEY (`id`),
+  INDEX `idx_stocks_product_id` (`product_id`),
+  INDEX `idx_stocks_store_id` (`store_id`),
+  CONSTRAINT `fk_stocks_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_stores_stock` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`)
+);
+
+CREATE TABLE `restock_orders` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `store_id` bigint unsigned NOT NULL,
+  `initiated_by` bigint unsigned NOT NULL,
+  `tracking_number` longtext,
+  `status` enum('initiated','in_transit','received','cancelled') DEFAULT 'initiated',
+  `initiated_at` datetime,
+  `received_at` datetime,
+  `notes` longtext,
+  PRIMARY KEY (`id`),
+  INDEX `idx_restock_orders_store_id` (`store_id`),
+  CONSTRAINT `fk_restock_orders_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_restock_orders_initiator` FOREIGN KEY (`initiated_by`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `restock_order_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `restock_order_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_restock_order_items_restock_order_id` (`restock_order_id`),
+  CONSTRAINT `fk_restock_order_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_restock_orders_items` FOREIGN KEY (`restock_order_id`) REFERENCES `restock_orders`(`id`)
+);
+
+CREATE TABLE `orders` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `order_number` varchar(100) NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `device_code` longtext,
+  `sector_id` bigint unsigned,
+  `subtotal` decimal(10,2) NOT NULL,
+  `discount_amount` decimal(10,2) DEFAULT 0,
+  `total_amount` decimal(10,2) NOT NULL,
+  `status` enum('pending','paid','completed','cancelled','picked_up') DEFAULT 'pending',
+  `qr_code_data` text,
+  `invoice_check_code` varchar(4),
+  `receipt_check_code` varchar(4),
+  `created_at` datetime,
+  `paid_at` datetime,
+  `completed_at` datetime,
+  `picked_up_at` datetime,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_orders_order_number` (`order_number`),
+  INDEX `idx_orders_store_id` (`store_id`),
+  INDEX `idx_orders_user_id` (`user_id`),
+  CONSTRAINT `fk_orders_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_orders_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`)
+);
+
+CREATE TABLE `order_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `order_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  `unit_price` decimal(10,2) NOT NULL,
+  `discount_percent` decimal(5,2) DEFAULT 0,
+  `discount_amount` decimal(10,2) DEFAULT 0,
+  `line_total` decimal(10,2) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_order_items_order_id` (`order_id`),
+  CONSTRAINT `fk_order_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_orders_items` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`)
+);
+
+CREATE TABLE `price_histories` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `product_id` bigint unsigned NOT NULL,
+  `sector_id` bigint unsigned,
+  `wholesale_cost_gbp` decimal(10,2) NOT NULL,
+  `discount_percent` decimal(5,2) DEFAULT 0,
+  `final_price_gbp` decimal(10,2) NOT NULL,
+  `recorded_at` datetime,
+  PRIMARY KEY (`id`),
+  INDEX `idx_price_histories_recorded_at` (`recorded_at`),
+  INDEX `idx_price_histories_product_id` (`product_id`),
+  INDEX `idx_price_histories_sector_id` (`sector_id`),
+  CONSTRAINT `fk_price_histories_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_price_histories_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`)
+);
+
+CREATE TABLE `currency_rates` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `currency_code` varchar(3) NOT NULL,
+  `rate_to_gbp` decimal(10,6) NOT NULL,
+  `is_pinned` boolean DEFAULT false,
+  `last_updated` datetime,
+  `updated_by` longtext,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_currency_rates_currency_code` (`currency_code`)
+);
+
+CREATE TABLE `audit_logs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `api_key_id` bigint unsigned,
+  `action` varchar(191) NOT NULL,
+  `entity_type` longtext,
+  `entity_id` bigint unsigned,
+  `changes` json,
+  `ip_address` longtext,
+  `user_agent` longtext,
+  `created_at` datetime,
+  PRIMARY KEY (`id`),
+  INDEX `idx_audit_logs_user_id` (`user_id`),
+  INDEX `idx_audit_logs_api_key_id` (`api_key_id`),
+  INDEX `idx_audit_logs_action` (`action`),
+  INDEX `idx_audit_logs_created_at` (`created_at`),
+  CONSTRAINT `fk_audit_logs_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `roles` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL,
+  `description` longtext,
+  `is_system` boolean DEFAULT false,
+  `require_two_factor` boolean DEFAULT false,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_roles_name` (`name`)
+);
+
+CREATE TABLE `role_permissions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `role_id` bigint unsigned NOT NULL,
+  `permission` varchar(100) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_role_permission` (`role_id`,`permission`),
+  CONSTRAINT `fk_roles_permissions` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE `user_store_roles` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `role_id` bigint unsigned NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_store_role` (`user_id`,`store_id`,`role_id`),
+  INDEX `idx_user_store_roles_role_id` (`role_id`),
+  CONSTRAINT `fk_user_store_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`),
+  CONSTRAINT `fk_user_store_roles_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`)
+);
+
+CREATE TABLE `user_sessions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `device_id` bigint unsigned,
+  `login_method` varchar(20),
+  `ip_address` varchar(64),
+  `user_agent` varchar(255),
+  `last_seen_at` datetime(3) NULL,
+  `expires_at` datetime(3) NULL,
+  `revoked_at` datetime(3) NULL,
+  `revoked_reason` varchar(30),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_sessions_user_id` (`user_id`),
+  INDEX `idx_user_sessions_device_id` (`device_id`),
+  INDEX `idx_user_sessions_expires_at` (`expires_at`),
+  INDEX `idx_user_sessions_revoked_at` (`revoked_at`),
+  CONSTRAINT `fk_user_sessions_device` FOREIGN KEY (`device_id`) REFERENCES `pos_devices`(`id`)
+);
+
+CREATE TABLE `session_refresh_tokens` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `session_id` bigint unsigned NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `rotated_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_session_refresh_tokens_session_id` (`session_id`),
+  UNIQUE INDEX `idx_session_refresh_tokens_token_hash` (`token_hash`)
+);
+
+CREATE TABLE `user_two_factors` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `secret_sealed` varchar(255) NOT NULL,
+  `confirmed_at` datetime(3) NULL,
+  `last_used_step` bigint,
+  `last_used_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_two_factors_user_id` (`user_id`)
+);
+
+CREATE TABLE `user_recovery_codes` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `code_hash` varchar(64) NOT NULL,
+  `used_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_recovery_codes_user_id` (`user_id`)
+);
+
+CREATE TABLE `api_keys` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `key_prefix` varchar(16) NOT NULL,
+  `key_hash` varchar(64) NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `scopes` text,
+  `allowed_ips` text,
+  `expires_at` datetime(3) NULL,
+  `last_used_at` datetime(3) NULL,
+  `last_used_ip` varchar(64),
+  `revoked_at` datetime(3) NULL,
+  `created_by_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_api_keys_key_hash` (`key_hash`),
+  INDEX `idx_api_keys_user_id` (`user_id`),
+  CONSTRAINT `fk_api_keys_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `webhook_subscriptions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `url` varchar(500) NOT NULL,
+  `secret` varchar(100) NOT NULL,
+  `events` text,
+  `is_active` boolean DEFAULT true,
+  `created_by_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `webhook_deliveries` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `subscription_id` bigint unsigned NOT NULL,
+  `event_id` varchar(64) NOT NULL,
+  `event_type` varchar(50) NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'pending',
+  `next_attempt_at` datetime NOT NULL,
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `last_attempt_at` datetime(3) NULL,
+  `response_status` bigint,
+  `response_body` text,
+  `last_error` text,
+  `delivered_at` datetime(3) NULL,
+  `redelivery_of_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_webhook_delivery_due` (`status`,`next_attempt_at`),
+  INDEX `idx_webhook_deliveries_created_at` (`created_at`),
+  INDEX `idx_webhook_deliveries_subscription_id` (`subscription_id`),
+  INDEX `idx_webhook_deliveries_event_id` (`event_id`),
+  INDEX `idx_webhook_deliveries_event_type` (`event_type`),
+  CONSTRAINT `fk_webhook_deliveries_subscription` FOREIGN KEY (`subscription_id`) REFERENCES `webhook_subscriptions`(`id`)
+);
+
+CREATE TABLE `jobs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `type` varchar(50) NOT NULL,
+  `payload` mediumtext NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'queued',
+  `run_at` datetime NOT NULL,
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `max_attempts` bigint NOT NULL DEFAULT 5,
+  `locked_by` varchar(100),
+  `locked_at` datetime(3) NULL,
+  `finished_at` datetime(3) NULL,
+  `last_error` text,
+  `result` text,
+  `created_by_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_jobs_created_at` (`created_at`),
+  INDEX `idx_jobs_type` (`type`),
+  INDEX `idx_job_due` (`status`,`run_at`),
+  INDEX `idx_jobs_created_by_id` (`created_by_id`)
+);
+
+CREATE TABLE `scheduled_tasks` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `key` varchar(50) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `description` varchar(500),
+  `cron_expr` varchar(100) NOT NULL,
+  `timezone` varchar(64) NOT NULL,
+  `enabled` boolean NOT NULL DEFAULT false,
+  `recipients` varchar(500),
+  `next_run_at` datetime(3) NULL,
+  `last_run_at` datetime(3) NULL,
+  `last_finished_at` datetime(3) NULL,
+  `last_status` varchar(20),
+  `last_result` text,
+  `last_error` text,
+  `last_job_id` bigint unsigned,
+  `last_trigger` varchar(20),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_scheduled_tasks_key` (`key`),
+  INDEX `idx_scheduled_tasks_next_run_at` (`next_run_at`)
+);
+
+CREATE TABLE `scheduler_leases` (
+  `name` varchar(50),
+  `holder` varchar(100) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`name`)
+);
+
+CREATE TABLE `stocktake_inventory_snapshots` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `store_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  `snapshot_date` date NOT NULL,
+  `snapshot_type` varchar(20) NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_stocktake_snapshot_store_product_date_type` (`store_id`,`product_id`,`snapshot_date`,`snapshot_type`),
+  CONSTRAINT `fk_stocktake_inventory_snapshots_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_stocktake_inventory_snapshots_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+
+CREATE TABLE `stocktake_day_start_records` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned,
+  `date` date NOT NULL,
+  `first_login_at` datetime NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'pending',
+  `done_at` datetime,
+  `skip_reason` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_stocktake_day_start_user_date_store` (`user_id`,`store_id`,`date`),
+  CONSTRAINT `fk_stocktake_day_start_records_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_stocktake_day_start_records_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`)
+);
+
+CREATE TABLE `user_activity_events` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned,
+  `event_type` varchar(50) NOT NULL,
+  `occurred_at` datetime NOT NULL,
+  `skip_reason` text,
+  `detail` varchar(255),
+  `created_at` datetime,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_activity_events_user_id` (`user_id`),
+  INDEX `idx_user_activity_events_store_id` (`store_id`),
+  INDEX `idx_user_activity_events_event_type` (`event_type`),
+  CONSTRAINT `fk_user_activity_events_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_user_activity_events_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `wholesale_client_delivery_locations` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_client_id` bigint unsigned NOT NULL,
+  `name` varchar(200) NOT NULL,
+  `address_line1` varchar(255),
+  `address_line2` varchar(255),
+  `city` varchar(100),
+  `postcode` varchar(50),
+  `contact_name` varchar(200),
+  `email` varchar(255),
+  `phone` varchar(100),
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_client_delivery_locations_wholesale_client_id` (`wholesale_client_id`),
+  CONSTRAINT `fk_wholesale_clients_stores` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`)
+);
+
+CREATE TABLE `shipping_rules` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(200),
+  `wholesale_client_id` bigint unsigned,
+  `sector_id` bigint unsigned,
+  `free_over_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `flat_fee` decimal(10,2) NOT NULL DEFAULT 0,
+  `per_case_fee` decimal(10,2) NOT NULL DEFAULT 0,
+  `min_order_value` decimal(10,2) NOT NULL DEFAULT 0,
+  `min_order_action` varchar(10) NOT NULL DEFAULT 'warn',
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_shipping_rules_wholesale_client_id` (`wholesale_client_id`),
+  INDEX `idx_shipping_rules_sector_id` (`sector_id`),
+  CONSTRAINT `fk_shipping_rules_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`),
+  CONSTRAINT `fk_shipping_rules_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`)
+);
+
+CREATE TABLE `shipping_rule_surcharges` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipping_rule_id` bigint unsigned NOT NULL,
+  `postcode_prefix` varchar(10) NOT NULL,
+  `surcharge` decimal(10,2) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_shipping_rule_surcharges_shipping_rule_id` (`shipping_rule_id`),
+  CONSTRAINT `fk_shipping_rules_surcharges` FOREIGN KEY (`shipping_rule_id`) REFERENCES `shipping_rules`(`id`)
+);
+
+CREATE TABLE `wholesale_orders` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `order_number` varchar(100) NOT NULL,
+  `wholesale_client_id` bigint unsigned NOT NULL,
+  `wholesale_client_store_id` bigint unsigned,
+  `store_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `sector_id` bigint unsigned,
+  `status` varchar(20) NOT NULL DEFAULT 'pending_approval',
+  `subtotal` decimal(10,2) NOT NULL DEFAULT 0,
+  `discount_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `total_net` decimal(10,2) NOT NULL DEFAULT 0,
+  `vat_total` decimal(10,2) NOT NULL DEFAULT 0,
+  `amount_due` decimal(10,2) NOT NULL DEFAULT 0,
+  `shipping_fee` decimal(10,2) DEFAULT 0,
+  `shipping_rule_id` bigint unsigned,
+  `shipping_fee_basis` varchar(255),
+  `shipping_fee_overridden` boolean DEFAULT false,
+  `po_number` varchar(100),
+  `order_channel` varchar(80),
+  `ref_no` varchar(100),
+  `invoice_number` varchar(50),
+  `po_date` date,
+  `order_date` date,
+  `invoice_date` date,
+  `invoice_sent_at` date,
+  `payment_terms` varchar(500),
+  `notes` text,
+  `rejection_reason` text,
+  `created_at` datetime NOT NULL,
+  `reviewed_at` datetime,
+  `reviewed_by` bigint unsigned,
+  `payment_confirmed_at` datetime,
+  `payment_proof_url` text,
+  `wholesale_quotation_id` bigint unsigned,
+  `standing_order_id` bigint unsigned,
+  `backorder_of_id` bigint unsigned,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_orders_wholesale_client_store_id` (`wholesale_client_store_id`),
+  INDEX `idx_wholesale_orders_store_id` (`store_id`),
+  INDEX `idx_wholesale_orders_user_id` (`user_id`),
+  INDEX `idx_wholesale_orders_sector_id` (`sector_id`),
+  INDEX `idx_wholesale_orders_status` (`status`),
+  UNIQUE INDEX `idx_wholesale_orders_ref_no` (`ref_no`),
+  INDEX `idx_wholesale_orders_reviewed_by` (`reviewed_by`),
+  UNIQUE INDEX `idx_wholesale_orders_order_number` (`order_number`),
+  INDEX `idx_wholesale_orders_shipping_rule_id` (`shipping_rule_id`),
+  INDEX `idx_wholesale_orders_invoice_number` (`invoice_number`),
+  INDEX `idx_wholesale_orders_wholesale_quotation_id` (`wholesale_quotation_id`),
+  INDEX `idx_wholesale_orders_standing_order_id` (`standing_order_id`),
+  INDEX `idx_wholesale_orders_backorder_of_id` (`backorder_of_id`),
+  INDEX `idx_wholesale_orders_wholesale_client_id` (`wholesale_client_id`),
+  CONSTRAINT `fk_wholesale_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_wholesale_orders_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`),
+  CONSTRAINT `fk_wholesale_orders_backorders` FOREIGN KEY (`backorder_of_id`) REFERENCES `wholesale_orders`(`id`),
+  CONSTRAINT `fk_wholesale_orders_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_wholesale_orders_reviewer` FOREIGN KEY (`reviewed_by`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_wholesale_orders_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`),
+  CONSTRAINT `fk_wholesale_orders_wholesale_client_store` FOREIGN KEY (`wholesale_client_store_id`) REFERENCES `wholesale_client_delivery_locations`(`id`)
+);
+
+CREATE TABLE `wholesale_order_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_order_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  `unit_price` decimal(10,2) NOT NULL,
+  `line_discount_type` varchar(32) NOT NULL DEFAULT 'order_entry',
+  `line_discount_unit` decimal(10,2) NOT NULL DEFAULT 0,
+  `line_discount_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `line_total` decimal(10,2) NOT NULL,
+  `assigned_store_id` bigint unsigned,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_order_items_wholesale_order_id` (`wholesale_order_id`),
+  INDEX `idx_wholesale_order_items_assigned_store_id` (`assigned_store_id`),
+  CONSTRAINT `fk_wholesale_orders_items` FOREIGN KEY (`wholesale_order_id`) REFERENCES `wholesale_orders`(`id`),
+  CONSTRAINT `fk_wholesale_order_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_wholesale_order_items_assigned_store` FOREIGN KEY (`assigned_store_id`) REFERENCES `stores`(`id`)
+);
+
+CREATE TABLE `wholesale_order_documents` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_order_id` bigint unsigned NOT NULL,
+  `type` varchar(50) NOT NULL,
+  `file_url` text NOT NULL,
+  `original_filename` varchar(255),
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_order_documents_wholesale_order_id` (`wholesale_order_id`),
+  INDEX `idx_wholesale_order_documents_type` (`type`),
+  CONSTRAINT `fk_wholesale_orders_documents` FOREIGN KEY (`wholesale_order_id`) REFERENCES `wholesale_orders`(`id`)
+);
+
+CREATE TABLE `company_settings` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `company_name` varchar(255),
+  `logo_url` text,
+  `pdf_logo_url` text,
+  `web_logo_url` text,
+  `pos_logo_url` text,
+  `address_line1` varchar(255),
+  `address_line2` varchar(255),
+  `city` varchar(100),
+  `postcode` varchar(20),
+  `telephone` varchar(50),
+  `email` varchar(255),
+  `vat_number` varchar(50),
+  `bank_account_name` varchar(255),
+  `bank_account_number` varchar(50),
+  `bank_sort_code` varchar(20),
+  `bank_address` varchar(255),
+  `bank_iban` varchar(50),
+  `payment_info` text,
+  `payment_transfer_to_info` text,
+  `shipment_couriers` text,
+  `wholesale_order_email_subject_template` varchar(500),
+  `wholesale_order_email_default_cc` text,
+  `wholesale_order_email_default_bcc` text,
+  `wholesale_order_enabled` boolean DEFAULT true,
+  `wholesale_serial_activated` boolean DEFAULT false,
+  `pos_module_enabled` boolean DEFAULT true,
+  `pos_dlc_activated` boolean DEFAULT false,
+  `installation_id` varchar(64),
+  `system_fingerprint` varchar(128),
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `shipments` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_order_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `courier` varchar(100),
+  `tracking_number` varchar(200),
+  `shipment_fee` decimal(10,2) DEFAULT 0,
+  `delivery_note_pdf_url` text,
+  `signed_delivery_note_pdf_url` text,
+  `delivery_date` date,
+  `status` varchar(20) NOT NULL DEFAULT 'assigned',
+  `courier_provider` varchar(50),
+  `consignment_id` varchar(100),
+  `courier_label_url` text,
+  `tracking_status` varchar(30),
+  `tracking_checked_at` datetime,
+  `tracking_error` varchar(500),
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_shipments_wholesale_order_id` (`wholesale_order_id`),
+  INDEX `idx_shipments_store_id` (`store_id`),
+  INDEX `idx_shipments_status` (`status`),
+  INDEX `idx_shipments_courier_provider` (`courier_provider`),
+  CONSTRAINT `fk_shipments_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_wholesale_orders_shipments` FOREIGN KEY (`wholesale_order_id`) REFERENCES `wholesale_orders`(`id`)
+);
+
+CREATE TABLE `shipment_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipment_id` bigint unsigned NOT NULL,
+  `wholesale_order_item_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) DEFAULT 0,
+  `case_qty` decimal(10,2) DEFAULT 0,
+  `picked_qty` decimal(10,3) DEFAULT 0,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_shipment_wo_item` (`shipment_id`,`wholesale_order_item_id`),
+  CONSTRAINT `fk_shipment_items_wholesale_order_item` FOREIGN KEY (`wholesale_order_item_id`) REFERENCES `wholesale_order_items`(`id`),
+  CONSTRAINT `fk_shipments_items` FOREIGN KEY (`shipment_id`) REFERENCES `shipments`(`id`)
+);
+
+CREATE TABLE `shipment_pick_scans` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipment_id` bigint unsigned NOT NULL,
+  `shipment_item_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `barcode` varchar(100),
+  `quantity` decimal(10,3) NOT NULL,
+  `user_id` bigint unsigned,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_shipment_pick_scans_user_id` (`user_id`),
+  INDEX `idx_shipment_pick_scans_shipment_id` (`shipment_id`),
+  INDEX `idx_shipment_pick_scans_shipment_item_id` (`shipment_item_id`),
+  INDEX `idx_shipment_pick_scans_product_id` (`product_id`)
+);
+
+CREATE TABLE `shipment_tracking_events` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipment_id` bigint unsigned NOT NULL,
+  `provider` varchar(50),
+  `code` varchar(50),
+  `status` varchar(30) NOT NULL,
+  `description` varchar(500),
+  `location` varchar(200),
+  `occurred_at` datetime NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_shipment_tracking_event` (`shipment_id`,`code`,`occurred_at`)
+);
+
+CREATE TABLE `delivery_proofs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipment_id` bigint unsigned NOT NULL,
+  `recipient_name` varchar(200) NOT NULL,
+  `signature_url` text NOT NULL,
+  `photo_urls` text,
+  `latitude` decimal(10,7),
+  `longitude` decimal(10,7),
+  `accuracy_m` decimal(10,2),
+  `captured_at` datetime NOT NULL,
+  `signed_pdf_url` text,
+  `user_id` bigint unsigned,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_delivery_proofs_shipment_id` (`shipment_id`),
+  INDEX `idx_delivery_proofs_user_id` (`user_id`)
+);
+
+CREATE TABLE `packing_sessions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `shipment_id` bigint unsigned NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'open',
+  `scan_count` bigint NOT NULL DEFAULT 0,
+  `started_by` bigint unsigned,
+  `completed_by` bigint unsigned,
+  `completed_at` datetime,
+  `discrepancy_accepted` boolean NOT NULL DEFAULT false,
+  `discrepancy_accepted_by` bigint unsigned,
+  `discrepancy_reason` text,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_packing_sessions_shipment_id` (`shipment_id`),
+  INDEX `idx_packing_sessions_status` (`status`),
+  INDEX `idx_packing_sessions_started_by` (`started_by`),
+  CONSTRAINT `fk_packing_sessions_shipment` FOREIGN KEY (`shipment_id`) REFERENCES `shipments`(`id`)
+);
+
+CREATE TABLE `packing_cartons` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `packing_session_id` bigint unsigned NOT NULL,
+  `number` bigint NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_packing_carton_number` (`packing_session_id`,`number`),
+  CONSTRAINT `fk_packing_sessions_cartons` FOREIGN KEY (`packing_session_id`) REFERENCES `packing_sessions`(`id`)
+);
+
+CREATE TABLE `packing_carton_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `packing_carton_id` bigint unsigned NOT NULL,
+  `shipment_item_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL DEFAULT 0,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_packing_carton_item` (`packing_carton_id`,`shipment_item_id`),
+  INDEX `idx_packing_carton_items_product_id` (`product_id`),
+  CONSTRAINT `fk_packing_carton_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_packing_cartons_items` FOREIGN KEY (`packing_carton_id`) REFERENCES `packing_cartons`(`id`)
+);
+
+CREATE TABLE `delivery_runs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `run_date` date NOT NULL,
+  `driver_user_id` bigint unsigned,
+  `driver_name` varchar(200),
+  `vehicle_reg` varchar(20),
+  `start_postcode` varchar(20),
+  `status` varchar(20) NOT NULL DEFAULT 'planned',
+  `notes` text,
+  `created_by` bigint unsigned,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_delivery_runs_run_date` (`run_date`),
+  INDEX `idx_delivery_runs_driver_user_id` (`driver_user_id`),
+  INDEX `idx_delivery_runs_status` (`status`),
+  CONSTRAINT `fk_delivery_runs_driver` FOREIGN KEY (`driver_user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `delivery_run_stops` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `delivery_run_id` bigint unsigned NOT NULL,
+  `shipment_id` bigint unsigned NOT NULL,
+  `sequence` bigint NOT NULL DEFAULT 0,
+  `collect_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `status` varchar(20) NOT NULL DEFAULT 'pending',
+  `delivered_at` datetime,
+  `failure_reason` varchar(500),
+  `notes` varchar(500),
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_delivery_run_stops_delivery_run_id` (`delivery_run_id`),
+  INDEX `idx_delivery_run_stops_shipment_id` (`shipment_id`),
+  CONSTRAINT `fk_delivery_run_stops_shipment` FOREIGN KEY (`shipment_id`) REFERENCES `shipments`(`id`),
+  CONSTRAINT `fk_delivery_runs_stops` FOREIGN KEY (`delivery_run_id`) REFERENCES `delivery_runs`(`id`)
+);
+
+CREATE TABLE `wholesale_quotations` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `quote_number` varchar(100),
+  `type` varchar(20) NOT NULL DEFAULT 'quotation',
+  `wholesale_client_id` bigint unsigned NOT NULL,
+  `wholesale_client_store_id` bigint unsigned,
+  `store_id` bigint unsigned,
+  `user_id` bigint unsigned NOT NULL,
+  `sector_id` bigint unsigned,
+  `status` varchar(20) NOT NULL DEFAULT 'draft',
+  `valid_until` date,
+  `subtotal` decimal(10,2) NOT NULL DEFAULT 0,
+  `discount_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `total_net` decimal(10,2) NOT NULL DEFAULT 0,
+  `vat_total` decimal(10,2) NOT NULL DEFAULT 0,
+  `amount_due` decimal(10,2) NOT NULL DEFAULT 0,
+  `shipping_fee` decimal(10,2) DEFAULT 0,
+  `payment_terms` varchar(500),
+  `notes` text,
+  `pdf_url` text,
+  `sent_at` datetime,
+  `converted_order_id` bigint unsigned,
+  `converted_at` datetime,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_quotations_quote_number` (`quote_number`),
+  INDEX `idx_wholesale_quotations_wholesale_client_id` (`wholesale_client_id`),
+  INDEX `idx_wholesale_quotations_wholesale_client_store_id` (`wholesale_client_store_id`),
+  INDEX `idx_wholesale_quotations_user_id` (`user_id`),
+  INDEX `idx_wholesale_quotations_status` (`status`),
+  INDEX `idx_wholesale_quotations_type` (`type`),
+  INDEX `idx_wholesale_quotations_store_id` (`store_id`),
+  INDEX `idx_wholesale_quotations_sector_id` (`sector_id`),
+  INDEX `idx_wholesale_quotations_converted_order_id` (`converted_order_id`),
+  CONSTRAINT `fk_wholesale_quotations_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_wholesale_quotations_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_wholesale_quotations_sector` FOREIGN KEY (`sector_id`) REFERENCES `sectors`(`id`),
+  CONSTRAINT `fk_wholesale_quotations_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`),
+  CONSTRAINT `fk_wholesale_quotations_wholesale_client_store` FOREIGN KEY (`wholesale_client_store_id`) REFERENCES `wholesale_client_delivery_locations`(`id`)
+);
+
+CREATE TABLE `wholesale_quotation_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_quotation_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  `unit_price` decimal(10,2) NOT NULL,
+  `line_discount_amount` decimal(10,2) NOT NULL DEFAULT 0,
+  `line_total` decimal(10,2) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_quotation_items_wholesale_quotation_id` (`wholesale_quotation_id`),
+  CONSTRAINT `fk_wholesale_quotation_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_wholesale_quotations_items` FOREIGN KEY (`wholesale_quotation_id`) REFERENCES `wholesale_quotations`(`id`)
+);
+
+CREATE TABLE `standing_orders` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(200),
+  `wholesale_client_id` bigint unsigned NOT NULL,
+  `wholesale_client_store_id` bigint unsigned,
+  `store_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `assigned_user_id` bigint unsigned,
+  `frequency` varchar(20) NOT NULL,
+  `weekdays` varchar(20),
+  `day_of_month` bigint DEFAULT 0,
+  `start_date` date NOT NULL,
+  `end_date` date,
+  `lead_days` bigint NOT NULL DEFAULT 3,
+  `payment_terms` varchar(500),
+  `notes` text,
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_standing_orders_user_id` (`user_id`),
+  INDEX `idx_standing_orders_assigned_user_id` (`assigned_user_id`),
+  INDEX `idx_standing_orders_wholesale_client_id` (`wholesale_client_id`),
+  INDEX `idx_standing_orders_wholesale_client_store_id` (`wholesale_client_store_id`),
+  INDEX `idx_standing_orders_store_id` (`store_id`),
+  CONSTRAINT `fk_standing_orders_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`),
+  CONSTRAINT `fk_standing_orders_wholesale_client_store` FOREIGN KEY (`wholesale_client_store_id`) REFERENCES `wholesale_client_delivery_locations`(`id`),
+  CONSTRAINT `fk_standing_orders_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_standing_orders_assigned_user` FOREIGN KEY (`assigned_user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `standing_order_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `standing_order_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_standing_order_items_standing_order_id` (`standing_order_id`),
+  CONSTRAINT `fk_standing_order_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_standing_orders_items` FOREIGN KEY (`standing_order_id`) REFERENCES `standing_orders`(`id`)
+);
+
+CREATE TABLE `standing_order_occurrences` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `standing_order_id` bigint unsigned NOT NULL,
+  `delivery_date` date NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'scheduled',
+  `items_override` text,
+  `notes` text,
+  `wholesale_order_id` bigint unsigned,
+  `generated_at` datetime,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_standing_order_date` (`standing_order_id`,`delivery_date`),
+  INDEX `idx_standing_order_occurrences_wholesale_order_id` (`wholesale_order_id`)
+);
+
+CREATE TABLE `wholesale_returns` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `rma_number` varchar(50),
+  `wholesale_order_id` bigint unsigned NOT NULL,
+  `wholesale_client_id` bigint unsigned NOT NULL,
+  `store_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'authorised',
+  `notes` text,
+  `received_at` datetime,
+  `inspected_at` datetime,
+  `inspected_by` bigint unsigned,
+  `credit_note_number` varchar(50),
+  `credit_note_total` decimal(10,2) NOT NULL DEFAULT 0,
+  `credit_note_url` text,
+  `credited_at` datetime,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_returns_rma_number` (`rma_number`),
+  INDEX `idx_wholesale_returns_wholesale_order_id` (`wholesale_order_id`),
+  INDEX `idx_wholesale_returns_wholesale_client_id` (`wholesale_client_id`),
+  INDEX `idx_wholesale_returns_store_id` (`store_id`),
+  INDEX `idx_wholesale_returns_user_id` (`user_id`),
+  INDEX `idx_wholesale_returns_status` (`status`),
+  INDEX `idx_wholesale_returns_inspected_by` (`inspected_by`),
+  CONSTRAINT `fk_wholesale_returns_wholesale_order` FOREIGN KEY (`wholesale_order_id`) REFERENCES `wholesale_orders`(`id`),
+  CONSTRAINT `fk_wholesale_returns_wholesale_client` FOREIGN KEY (`wholesale_client_id`) REFERENCES `wholesale_clients`(`id`),
+  CONSTRAINT `fk_wholesale_returns_store` FOREIGN KEY (`store_id`) REFERENCES `stores`(`id`),
+  CONSTRAINT `fk_wholesale_returns_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_wholesale_returns_inspector` FOREIGN KEY (`inspected_by`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `wholesale_return_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `wholesale_return_id` bigint unsigned NOT NULL,
+  `wholesale_order_item_id` bigint unsigned NOT NULL,
+  `product_id` bigint unsigned NOT NULL,
+  `quantity` decimal(10,3) NOT NULL,
+  `unit_price` decimal(10,2) NOT NULL,
+  `reason` varchar(500),
+  `disposition` varchar(30),
+  `inspection_notes` text,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wholesale_return_items_wholesale_return_id` (`wholesale_return_id`),
+  INDEX `idx_wholesale_return_items_wholesale_order_item_id` (`wholesale_order_item_id`),
+  CONSTRAINT `fk_wholesale_return_items_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`),
+  CONSTRAINT `fk_wholesale_returns_items` FOREIGN KEY (`wholesale_return_id`) REFERENCES `wholesale_returns`(`id`)
+);
+
+CREATE TABLE `accounting_nominal_codes` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `key` varchar(255) NOT NULL,
+  `nominal_code` varchar(20) NOT NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_accounting_nominal_codes_key` (`key`)
+);
+
+CREATE TABLE `accounting_exports` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `format` varchar(10) NOT NULL,
+  `document_type` varchar(20) NOT NULL,
+  `date_from` date NOT NULL,
+  `date_to` date NOT NULL,
+  `document_count` bigint NOT NULL DEFAULT 0,
+  `total` decimal(12,2) NOT NULL DEFAULT 0,
+  `filename` varchar(255),
+  `content` longtext,
+  `user_id` bigint unsigned NOT NULL,
+  `voided_at` datetime,
+  `void_reason` varchar(500),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_accounting_exports_user_id` (`user_id`),
+  CONSTRAINT `fk_accounting_exports_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `accounting_export_documents` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `accounting_export_id` bigint unsigned NOT NULL,
+  `document_type` varchar(20) NOT NULL,
+  `document_ref` varchar(100) NOT NULL,
+  `amount` decimal(12,2) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_accounting_export_documents_accounting_export_id` (`accounting_export_id`),
+  INDEX `idx_accounting_export_doc` (`document_type`,`document_ref`),
+  CONSTRAINT `fk_accounting_exports_documents` FOREIGN KEY (`accounting_export_id`) REFERENCES `accounting_exports`(`id`)
+);
+
+CREATE TABLE `document_number_series` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `type` varchar(20) NOT NULL,
+  `prefix` varchar(20),
+  `padding` bigint NOT NULL DEFAULT 6,
+  `reset_yearly` boolean NOT NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_document_number_series_type` (`type`)
+);
+
+CREATE TABLE `document_number_counters` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `series_type` varchar(20) NOT NULL,
+  `year` bigint NOT NULL,
+  `last_value` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_doc_number_counter` (`series_type`,`year`)
+);
+
+CREATE TABLE `document_numbers` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `series_type` varchar(20) NOT NULL,
+  `year` bigint NOT NULL,
+  `sequence` bigint NOT NULL,
+  `number` varchar(50) NOT NULL,
+  `wholesale_order_id` bigint unsigned,
+  `wholesale_return_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `issued_at` datetime NOT NULL,
+  `voided_at` datetime,
+  `voided_by` bigint unsigned,
+  `void_reason` varchar(500),
+  PRIMARY KEY (`id`),
+  INDEX `idx_document_numbers_user_id` (`user_id`),
+  INDEX `idx_document_numbers_voided_by` (`voided_by`),
+  INDEX `idx_document_numbers_series_type` (`series_type`),
+  INDEX `idx_document_numbers_year` (`year`),
+  UNIQUE INDEX `idx_document_numbers_number` (`number`),
+  INDEX `idx_document_numbers_wholesale_order_id` (`wholesale_order_id`),
+  INDEX `idx_document_numbers_wholesale_return_id` (`wholesale_return_id`),
+  CONSTRAINT `fk_document_numbers_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_document_numbers_voider` FOREIGN KEY (`voided_by`) REFERENCES `users`(`id`)
+);
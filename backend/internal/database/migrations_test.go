@@ -0,0 +1,30 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"pos-system/backend/internal/migrate"
+)
+
+func TestMigrations(t *testing.T) {
+	list, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range list {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %04d_%s: versions should run 1, 2, 3... without gaps", m.Version, m.Name)
+		}
+	}
+	up := migrate.SplitStatements(list[0].UpSQL)
+	down := migrate.SplitStatements(list[0].DownSQL)
+	if len(up) == 0 || len(up) != len(down) {
+		t.Fatalf("baseline creates %d tables but drops %d", len(up), len(down))
+	}
+	for _, stmt := range up {
+		if !strings.HasPrefix(stmt, "CREATE TABLE `") {
+			t.Errorf("unexpected baseline statement: %.60s", stmt)
+		}
+	}
+}
@@ -10,7 +10,8 @@ import (
 	"gorm.io/gorm"
 )
 
-// migrateProductLines backfills product_lines and splits legacy dual-inventory products into variants.
+// migrateProductLines backfills product_lines and splits legacy dual-inventory products into variants
+// (data migration 0005).
 func migrateProductLines(db *gorm.DB) error {
 	var products []models.Product
 	if err := db.Where("product_line_id IS NULL OR product_line_id = 0").Find(&products).Error; err != nil {
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"log"
+
+	"pos-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var systemRoleDescriptions = map[string]string{
+	models.RoleNameManagement: "Full access",
+	models.RoleNameSupervisor: "Store and wholesale management without credit overrides or number series changes",
+	models.RoleNameHQStaff:    "Head-office wholesale and stock work; cannot edit costs or delete products",
+	models.RoleNamePosUser:    "Till staff: sales, own wholesale orders and dispatch",
+}
+
+// seedSystemRoles creates any missing system role with its default permissions. Existing roles are left as
+// edited, so this is safe to re-run on every start.
+func seedSystemRoles(db *gorm.DB) {
+	for name, perms := range models.DefaultRolePermissions {
+		var count int64
+		if err := db.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			log.Printf("WARNING: role seed (%s): %v", name, err)
+			continue
+		}
+		if count > 0 {
+			continue
+		}
+		role := models.Role{Name: name, Description: systemRoleDescriptions[name], IsSystem: true}
+		for _, p := range perms {
+			role.Permissions = append(role.Permissions, models.RolePermission{Permission: p})
+		}
+		if err := db.Create(&role).Error; err != nil {
+			log.Printf("WARNING: role seed (%s): %v", name, err)
+		}
+	}
+	grantAddedPermissions(db)
+}
+
+// grantAddedPermissions gives newly introduced permissions to their default system roles, unless some role already
+// holds the permission (it was granted before, and roles may have been edited since).
+func grantAddedPermissions(db *gorm.DB) {
+	for perm, roles := range models.AddedPermissionGrants {
+		var count int64
+		if err := db.Model(&models.RolePermission{}).Where("permission = ?", perm).Count(&count).Error; err != nil || count > 0 {
+			continue
+		}
+		for _, name := range roles {
+			var role models.Role
+			if err := db.Where("name = ?", name).First(&role).Error; err != nil {
+				continue
+			}
+			if err := db.Create(&models.RolePermission{RoleID: role.ID, Permission: perm}).Error; err != nil {
+				log.Printf("WARNING: permission grant (%s to %s): %v", perm, name, err)
+			}
+		}
+	}
+}
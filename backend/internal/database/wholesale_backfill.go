@@ -9,8 +9,8 @@ import (
 )
 
 // backfillWholesalePaymentConfirmedAt sets payment_confirmed_at on historical orders that
-// finished delivery before the payment-confirmation workflow existed. Safe to re-run.
-func backfillWholesalePaymentConfirmedAt(db *gorm.DB) {
+// finished delivery before the payment-confirmation workflow existed (data migration 0006).
+func backfillWholesalePaymentConfirmedAt(db *gorm.DB) error {
 	const updateSQL = `
 UPDATE wholesale_orders wo
 JOIN (
@@ -69,10 +69,10 @@ WHERE wo.status NOT IN (?, ?)
 		models.WholesaleOrderStatusDeleted,
 	)
 	if result.Error != nil {
-		log.Printf("WARNING: wholesale payment_confirmed_at backfill: %v", result.Error)
-		return
+		return result.Error
 	}
 	if result.RowsAffected > 0 {
 		log.Printf("Backfilled payment_confirmed_at on %d wholesale order(s)", result.RowsAffected)
 	}
+	return nil
 }
@@ -0,0 +1,344 @@
+// Package jobs runs background work from the jobs table. Enqueue inserts a row (inside the caller's
+// transaction when given one, so the job commits with the change that caused it); a Runner's workers claim due
+// rows, call the handler registered for the job type and record the outcome. Failed attempts are retried with
+// backoff until MaxAttempts, after which the job is dead and waits for a manual Retry.
+//
+// Jobs survive restarts: a row is only removed from the queue when its handler returns. A worker that dies
+// mid-job leaves the row running; it is re-queued once its lock is older than StaleAfter. Each claim writes its
+// own lock token to locked_by, and the outcome is only recorded while the row still carries it, so a worker that
+// outlives its lock cannot overwrite the run that took the job over.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pos-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultMaxAttempts applies when Enqueue is not given one.
+const DefaultMaxAttempts = 5
+
+// Timeout bounds one attempt. StaleAfter is when a running job is assumed abandoned; it must exceed Timeout.
+const (
+	Timeout    = 10 * time.Minute
+	StaleAfter = 15 * time.Minute
+)
+
+// Handler does the work for one job. The returned result is stored as JSON on the job for pollers.
+type Handler func(ctx context.Context, job *models.Job) (interface{}, error)
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix (bad payload, deleted record); the job goes straight to dead.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type laterError struct {
+	after  time.Duration
+	reason string
+}
+
+func (e laterError) Error() string { return e.reason }
+
+// Later asks for the job to run again after d without counting this attempt, for work that is waiting on
+// something else (another job, say) rather than failing.
+func Later(d time.Duration, reason string) error {
+	return laterError{after: d, reason: reason}
+}
+
+// Backoff is the wait before the next attempt after n failed attempts: 30 seconds doubling, capped at an hour.
+func Backoff(failedAttempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < failedAttempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// Options tune a single Enqueue call. The zero value runs the job now with DefaultMaxAttempts.
+type Options struct {
+	RunAt       time.Time
+	MaxAttempts int
+	CreatedByID *uint
+}
+
+// Enqueue queues a job of jobType with payload marshalled as JSON. Pass a transaction to make the job part of it.
+func Enqueue(db *gorm.DB, jobType string, payload interface{}, opts Options) (*models.Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s payload: %w", jobType, err)
+	}
+	job := models.Job{
+		Type:        jobType,
+		Payload:     string(body),
+		Status:      models.JobStatusQueued,
+		RunAt:       opts.RunAt,
+		MaxAttempts: opts.MaxAttempts,
+		CreatedByID: opts.CreatedByID,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ErrNotRetryable is returned by Retry for jobs that are queued or running.
+var ErrNotRetryable = errors.New("only dead or succeeded jobs can be retried")
+
+// Retry re-queues a dead (or succeeded) job to run now with a fresh attempt budget.
+func Retry(db *gorm.DB, id uint) error {
+	res := db.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []string{models.JobStatusDead, models.JobStatusSucceeded}).
+		Updates(map[string]interface{}{
+			"status": models.JobStatusQueued, "run_at": time.Now(), "attempts": 0,
+			"locked_by": "", "locked_at": nil, "finished_at": nil,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotRetryable
+	}
+	return nil
+}
+
+// Decode unmarshals a job's payload into v; a payload that does not decode is a permanent failure.
+func Decode(job *models.Job, v interface{}) error {
+	if err := json.Unmarshal([]byte(job.Payload), v); err != nil {
+		return Permanent(fmt.Errorf("decode %s payload: %w", job.Type, err))
+	}
+	return nil
+}
+
+// Runner polls the jobs table with a fixed number of workers.
+type Runner struct {
+	db       *gorm.DB
+	workers  int
+	poll     time.Duration
+	name     string
+	claims   atomic.Uint64
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// InstanceName identifies this process in locks and leases: host name and process id.
+func InstanceName() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// NewRunner returns a runner with workers goroutines that check for due jobs every poll when idle.
+func NewRunner(db *gorm.DB, workers int, poll time.Duration) *Runner {
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+	r := &Runner{
+		db:       db,
+		workers:  workers,
+		poll:     poll,
+		name:     InstanceName(),
+		handlers: map[string]Handler{},
+	}
+	// Start the sequence from the clock so a restarted process that reuses its pid does not reuse tokens.
+	r.claims.Store(uint64(time.Now().UnixNano()))
+	return r
+}
+
+// Register sets the handler for jobType. Jobs of unregistered types stay queued so a newer instance can run them.
+func (r *Runner) Register(jobType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = h
+}
+
+func (r *Runner) types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		out = append(out, t)
+	}
+	return out
+}
+
+func (r *Runner) handler(jobType string) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handlers[jobType]
+}
+
+// Start launches the workers and returns. It does nothing when workers is 0.
+func (r *Runner) Start() {
+	if r.workers <= 0 {
+		return
+	}
+	go func() {
+		for {
+			if n, err := r.requeueStale(); err != nil {
+				log.Printf("[jobs] requeue stale jobs: %v", err)
+			} else if n > 0 {
+				log.Printf("[jobs] requeued %d stale job(s)", n)
+			}
+			time.Sleep(StaleAfter / 3)
+		}
+	}()
+	for i := 0; i < r.workers; i++ {
+		go func() {
+			for {
+				ran, err := r.RunNext(context.Background())
+				if err != nil {
+					log.Printf("[jobs] %v", err)
+				}
+				if !ran {
+					time.Sleep(r.poll)
+				}
+			}
+		}()
+	}
+}
+
+// requeueStale returns abandoned running jobs to the queue, or marks them dead when they have used every attempt.
+// Each row is only changed while it still holds the lock that was found stale, so a job whose worker finishes in
+// the meantime keeps its outcome.
+func (r *Runner) requeueStale() (int64, error) {
+	var stale []models.Job
+	if err := r.db.Select("id", "attempts", "max_attempts", "locked_by").
+		Where("status = ? AND locked_at < ?", models.JobStatusRunning, time.Now().Add(-StaleAfter)).
+		Find(&stale).Error; err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, job := range stale {
+		updates := map[string]interface{}{
+			"status": models.JobStatusQueued, "run_at": time.Now(), "last_error": "worker stopped during the attempt",
+			"locked_by": "", "locked_at": nil,
+		}
+		if job.Attempts >= job.MaxAttempts {
+			updates = map[string]interface{}{
+				"status": models.JobStatusDead, "last_error": "worker stopped during the last attempt", "finished_at": time.Now(),
+				"locked_by": "", "locked_at": nil,
+			}
+		}
+		res := r.db.Model(&models.Job{}).Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobStatusRunning, job.LockedBy).
+			Updates(updates)
+		if res.Error != nil {
+			return n, res.Error
+		}
+		n += res.RowsAffected
+	}
+	return n, nil
+}
+
+// lockToken names one claim of a job: the instance plus a per-process sequence, so that two claims of the same
+// job, even by the same instance, never share a token.
+func (r *Runner) lockToken() string {
+	return fmt.Sprintf("%s#%d", r.name, r.claims.Add(1))
+}
+
+// RunNext claims and runs one due job. ran is false when nothing was due.
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	types := r.types()
+	if len(types) == 0 {
+		return false, nil
+	}
+	var job models.Job
+	for {
+		err := r.db.Where("status = ? AND run_at <= ? AND type IN ?", models.JobStatusQueued, time.Now(), types).
+			Order("run_at, id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("find due job: %w", err)
+		}
+		now := time.Now()
+		token := r.lockToken()
+		claim := r.db.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.JobStatusQueued).
+			Updates(map[string]interface{}{
+				"status": models.JobStatusRunning, "locked_by": token, "locked_at": now, "attempts": gorm.Expr("attempts + 1"),
+			})
+		if claim.Error != nil {
+			return false, fmt.Errorf("claim job %d: %w", job.ID, claim.Error)
+		}
+		if claim.RowsAffected == 1 {
+			job.Status, job.LockedBy, job.LockedAt, job.Attempts = models.JobStatusRunning, token, &now, job.Attempts+1
+			break
+		}
+		// Another worker took it; look again.
+	}
+	r.run(ctx, &job)
+	return true, nil
+}
+
+func (r *Runner) run(ctx context.Context, job *models.Job) {
+	h := r.handler(job.Type)
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	result, err := func() (res interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return h(ctx, job)
+	}()
+	cancel()
+
+	now := time.Now()
+	updates := map[string]interface{}{"locked_by": "", "locked_at": nil}
+	var later laterError
+	switch {
+	case errors.As(err, &later):
+		updates["status"], updates["last_error"], updates["run_at"], updates["attempts"] =
+			models.JobStatusQueued, later.reason, now.Add(later.after), gorm.Expr("attempts - 1")
+	case err == nil:
+		body, _ := json.Marshal(result)
+		updates["status"], updates["result"], updates["last_error"], updates["finished_at"] =
+			models.JobStatusSucceeded, string(body), "", now
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		updates["status"], updates["last_error"], updates["finished_at"] = models.JobStatusDead, err.Error(), now
+		log.Printf("[jobs] %s job %d dead after %d attempt(s): %v", job.Type, job.ID, job.Attempts, err)
+	default:
+		updates["status"], updates["last_error"], updates["run_at"] =
+			models.JobStatusQueued, err.Error(), now.Add(Backoff(job.Attempts))
+	}
+	res := r.db.Model(&models.Job{}).Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobStatusRunning, job.LockedBy).
+		Updates(updates)
+	switch {
+	case res.Error != nil:
+		log.Printf("[jobs] record outcome of job %d: %v", job.ID, res.Error)
+	case res.RowsAffected == 0:
+		log.Printf("[jobs] %s job %d lost its lock before finishing; outcome discarded", job.Type, job.ID)
+	}
+}
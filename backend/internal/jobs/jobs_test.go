@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 7: 32 * time.Minute, 8: time.Hour, 20: time.Hour}
+	for n, want := range cases {
+		if got := Backoff(n); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("order not found")
+	err := fmt.Errorf("render invoice: %w", Permanent(base))
+	if !IsPermanent(err) || !errors.Is(err, base) {
+		t.Errorf("IsPermanent(%v) = false or lost the cause", err)
+	}
+	if IsPermanent(base) || Permanent(nil) != nil {
+		t.Error("plain errors and nil must not be permanent")
+	}
+}
+
+func TestLater(t *testing.T) {
+	err := fmt.Errorf("email: %w", Later(15*time.Second, "waiting for document job 7"))
+	var later laterError
+	if !errors.As(err, &later) || later.after != 15*time.Second || later.reason != "waiting for document job 7" {
+		t.Fatalf("Later lost its delay or reason: %#v", later)
+	}
+	if IsPermanent(err) {
+		t.Error("a deferred job must not be treated as permanently failed")
+	}
+}
+
+func TestLockTokensAreUniquePerClaim(t *testing.T) {
+	a, b := NewRunner(nil, 1, 0), NewRunner(nil, 1, 0)
+	seen := map[string]bool{}
+	for _, r := range []*Runner{a, a, b, b} {
+		tok := r.lockToken()
+		if seen[tok] || len(tok) > 100 {
+			t.Fatalf("lock token %q repeated or too long for locked_by", tok)
+		}
+		seen[tok] = true
+	}
+}
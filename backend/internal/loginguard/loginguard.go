@@ -0,0 +1,290 @@
+// Package loginguard throttles password and PIN guessing. Failures are counted per key (a user, a till, a client
+// IP); past a few free attempts each further try must wait an exponentially growing delay, and enough failures lock
+// the key out for a while. Counters live in a Store so several API instances can share them.
+package loginguard
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is the failure record for one key.
+type Entry struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	// BlockedUntil is when the next attempt is allowed: the backoff delay, or the end of a lockout.
+	BlockedUntil time.Time `json:"blocked_until"`
+	Locked       bool      `json:"locked"`
+}
+
+// Store holds entries. Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) (Entry, bool)
+	Put(e Entry)
+	Delete(key string)
+	// Update replaces the key's entry with fn's result in one step, so that concurrent updates of the same key
+	// cannot overwrite each other; ok is false when the key has no entry, and keep false deletes it.
+	Update(key string, fn func(e Entry, ok bool) (next Entry, keep bool))
+	// List returns every entry, for the lockout view.
+	List() []Entry
+}
+
+// Policy sets how quickly a key is throttled.
+type Policy struct {
+	FreeAttempts    int           // failures allowed before any delay
+	BaseDelay       time.Duration // delay after the first failure beyond FreeAttempts; doubles each time
+	MaxDelay        time.Duration
+	LockoutAfter    int // failures that lock the key; 0 = never lock
+	LockoutDuration time.Duration
+	ResetAfter      time.Duration // a key with no failure for this long starts again from zero
+}
+
+// Limiter applies a Policy to the keys in a Store.
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// New returns a Limiter; store nil means a MemoryStore.
+func New(policy Policy, store Store) *Limiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// live returns the key's entry, or false when it has none or it has aged out.
+func (l *Limiter) live(key string) (Entry, bool) {
+	e, ok := l.store.Get(key)
+	if !ok {
+		return Entry{}, false
+	}
+	if l.expired(e, l.now()) {
+		l.store.Delete(key)
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (l *Limiter) expired(e Entry, now time.Time) bool {
+	return now.After(e.BlockedUntil) && now.Sub(e.LastFailure) > l.policy.ResetAfter
+}
+
+// Wait reports how long the caller must wait before trying any of keys again; 0 means go ahead. locked is set
+// when the longest wait is a lockout rather than a backoff delay.
+func (l *Limiter) Wait(keys ...string) (wait time.Duration, locked bool) {
+	now := l.now()
+	for _, k := range keys {
+		e, ok := l.live(k)
+		if !ok {
+			continue
+		}
+		if d := e.BlockedUntil.Sub(now); d > wait {
+			wait, locked = d, e.Locked
+		}
+	}
+	return wait, locked
+}
+
+// Fail records a failed attempt against each key and returns the keys that became locked by it.
+func (l *Limiter) Fail(keys ...string) []string {
+	now := l.now()
+	var newlyLocked []string
+	for _, k := range keys {
+		l.store.Update(k, func(e Entry, ok bool) (Entry, bool) {
+			if !ok || l.expired(e, now) {
+				e = Entry{Key: k}
+			}
+			if l.count(&e, now) {
+				newlyLocked = append(newlyLocked, k)
+			}
+			return e, true
+		})
+	}
+	return newlyLocked
+}
+
+// count adds one failure to e and reports whether that locked it.
+func (l *Limiter) count(e *Entry, now time.Time) bool {
+	e.Failures++
+	e.LastFailure = now
+	switch {
+	case l.policy.LockoutAfter > 0 && e.Failures >= l.policy.LockoutAfter:
+		newlyLocked := !e.Locked
+		e.Locked = true
+		e.BlockedUntil = now.Add(l.policy.LockoutDuration)
+		return newlyLocked
+	case e.Failures > l.policy.FreeAttempts:
+		e.BlockedUntil = now.Add(l.delay(e.Failures - l.policy.FreeAttempts))
+	}
+	return false
+}
+
+// Attempt is a login attempt counted as a failure before the credentials are checked, so that guesses sent in
+// parallel each see the ones before them. Keep it when the credentials turn out wrong; Release it otherwise.
+type Attempt struct {
+	l       *Limiter
+	keys    []string
+	locked  []string
+	settled bool
+}
+
+// Reserve counts an attempt against each key unless one of them must still wait, in which case nothing is
+// counted and the wait is returned as by Wait.
+func (l *Limiter) Reserve(keys ...string) (a *Attempt, wait time.Duration, locked bool) {
+	now := l.now()
+	a = &Attempt{l: l}
+	for _, k := range keys {
+		l.store.Update(k, func(e Entry, ok bool) (Entry, bool) {
+			if !ok || l.expired(e, now) {
+				e = Entry{Key: k}
+			}
+			if d := e.BlockedUntil.Sub(now); d > 0 {
+				if d > wait {
+					wait, locked = d, e.Locked
+				}
+				return e, ok
+			}
+			if l.count(&e, now) {
+				a.locked = append(a.locked, k)
+			}
+			a.keys = append(a.keys, k)
+			return e, true
+		})
+	}
+	if wait > 0 {
+		a.Release()
+		return nil, wait, locked
+	}
+	return a, 0, false
+}
+
+// Locked returns the keys this attempt locked; they stay locked only if it is kept.
+func (a *Attempt) Locked() []string {
+	return a.locked
+}
+
+// Keep leaves the attempt counted as a failure.
+func (a *Attempt) Keep() {
+	a.settled = true
+}
+
+// Release takes the attempt back off each key's count, lifting the delay or lockout it caused. It does nothing
+// once the attempt has been kept or released, so it can be deferred.
+func (a *Attempt) Release() {
+	if a == nil || a.settled {
+		return
+	}
+	a.settled = true
+	l := a.l
+	for _, k := range a.keys {
+		lockedHere := false
+		for _, lk := range a.locked {
+			lockedHere = lockedHere || lk == k
+		}
+		l.store.Update(k, func(e Entry, ok bool) (Entry, bool) {
+			if !ok {
+				return e, false
+			}
+			e.Failures--
+			if e.Failures <= 0 {
+				return e, false
+			}
+			if lockedHere {
+				e.Locked = false
+			}
+			if !e.Locked && e.Failures <= l.policy.FreeAttempts {
+				e.BlockedUntil = time.Time{}
+			} else if !e.Locked {
+				e.BlockedUntil = e.LastFailure.Add(l.delay(e.Failures - l.policy.FreeAttempts))
+			}
+			return e, true
+		})
+	}
+}
+
+// delay is BaseDelay doubled for each failure past the first beyond the free ones, capped at MaxDelay.
+func (l *Limiter) delay(over int) time.Duration {
+	d := l.policy.BaseDelay
+	for i := 1; i < over && d < l.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if l.policy.MaxDelay > 0 && d > l.policy.MaxDelay {
+		d = l.policy.MaxDelay
+	}
+	return d
+}
+
+// Reset clears keys, after a successful login or a supervisor unlock.
+func (l *Limiter) Reset(keys ...string) {
+	for _, k := range keys {
+		l.store.Delete(k)
+	}
+}
+
+// Blocked lists the keys currently waiting out a delay or lockout, longest first.
+func (l *Limiter) Blocked() []Entry {
+	now := l.now()
+	var out []Entry
+	for _, e := range l.store.List() {
+		if e.BlockedUntil.After(now) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BlockedUntil.After(out[j].BlockedUntil) })
+	return out
+}
+
+// MemoryStore keeps entries in process memory; counters reset on restart and are not shared between instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Get(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+func (s *MemoryStore) Put(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.Key] = e
+}
+
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *MemoryStore) Update(key string, fn func(e Entry, ok bool) (Entry, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if next, keep := fn(e, ok); keep {
+		next.Key = key
+		s.entries[key] = next
+	} else {
+		delete(s.entries, key)
+	}
+}
+
+func (s *MemoryStore) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e)
+	}
+	return out
+}
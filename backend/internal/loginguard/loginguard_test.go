@@ -0,0 +1,109 @@
+package loginguard
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterBackoffAndLockout(t *testing.T) {
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	l := New(Policy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockoutAfter: 6,
+		LockoutDuration: 15 * time.Minute, ResetAfter: time.Hour}, nil)
+	l.now = func() time.Time { return now }
+
+	l.Fail("user:ana")
+	l.Fail("user:ana")
+	if w, _ := l.Wait("user:ana"); w != 0 {
+		t.Fatalf("wait after free attempts = %v, want 0", w)
+	}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		l.Fail("user:ana")
+		if w, locked := l.Wait("user:ana"); w != want || locked {
+			t.Fatalf("failure %d: wait = %v locked = %v, want %v", i+3, w, locked, want)
+		}
+	}
+	if got := l.Fail("user:ana", "ip:10.0.0.1"); len(got) != 1 || got[0] != "user:ana" {
+		t.Fatalf("newly locked = %v, want [user:ana]", got)
+	}
+	if w, locked := l.Wait("ip:10.0.0.1", "user:ana"); w != 15*time.Minute || !locked {
+		t.Fatalf("wait = %v locked = %v, want 15m lockout", w, locked)
+	}
+	if len(l.Blocked()) != 1 {
+		t.Fatalf("Blocked() = %v", l.Blocked())
+	}
+
+	l.Reset("user:ana")
+	if w, _ := l.Wait("user:ana"); w != 0 {
+		t.Fatalf("wait after reset = %v", w)
+	}
+
+	now = now.Add(2 * time.Hour)
+	l.Fail("ip:10.0.0.1")
+	if e, _ := l.store.Get("ip:10.0.0.1"); e.Failures != 1 {
+		t.Fatalf("failures after ResetAfter = %d, want 1", e.Failures)
+	}
+}
+
+func TestReserveCountsParallelAttempts(t *testing.T) {
+	l := New(Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockoutAfter: 10,
+		LockoutDuration: 15 * time.Minute, ResetAfter: time.Hour}, nil)
+
+	const guesses = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, _, _ := l.Reserve("user:ana")
+			if a == nil {
+				return
+			}
+			mu.Lock()
+			admitted++
+			mu.Unlock()
+			a.Keep()
+		}()
+	}
+	wg.Wait()
+	if admitted != 4 {
+		t.Fatalf("admitted %d parallel guesses, want 4 (3 free + 1 before the delay)", admitted)
+	}
+	if e, _ := l.store.Get("user:ana"); e.Failures != 4 {
+		t.Fatalf("failures = %d, want 4", e.Failures)
+	}
+}
+
+func TestReleaseHandsAttemptBack(t *testing.T) {
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	l := New(Policy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockoutAfter: 2,
+		LockoutDuration: 15 * time.Minute, ResetAfter: time.Hour}, nil)
+	l.now = func() time.Time { return now }
+
+	a, _, _ := l.Reserve("user:ana")
+	a.Keep()
+	a, _, _ = l.Reserve("user:ana")
+	if len(a.Locked()) != 1 {
+		t.Fatalf("second reservation locked %v, want [user:ana]", a.Locked())
+	}
+	if b, w, locked := l.Reserve("user:ana"); b != nil || w != 15*time.Minute || !locked {
+		t.Fatalf("reserve while reserved: attempt %v wait %v locked %v, want refused with lockout", b, w, locked)
+	}
+	a.Release()
+	a.Release()
+	if w, _ := l.Wait("user:ana"); w != 0 {
+		t.Fatalf("wait after release = %v, want 0", w)
+	}
+	if e, _ := l.store.Get("user:ana"); e.Failures != 1 || e.Locked {
+		t.Fatalf("entry after release = %+v, want 1 failure, unlocked", e)
+	}
+
+	a, _, _ = l.Reserve("user:ana")
+	a.Keep()
+	a.Release()
+	if w, locked := l.Wait("user:ana"); w != 15*time.Minute || !locked {
+		t.Fatalf("kept attempt released: wait %v locked %v, want lockout", w, locked)
+	}
+}
@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/base64"
@@ -33,7 +34,7 @@ func defaultNoReplyFrom(username string) string {
 	return u
 }
 
-func dialSMTP(host string, port int) (net.Conn, error) {
+func dialSMTP(ctx context.Context, host string, port int) (net.Conn, error) {
 	if port <= 0 {
 		port = 587
 	}
@@ -42,27 +43,27 @@ func dialSMTP(host string, port int) (net.Conn, error) {
 	// Port 465 uses implicit TLS (SMTPS); 587 uses plain TCP then STARTTLS.
 	if port == 465 {
 		tcfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
-		conn, err := tls.DialWithDialer(&dialer, "tcp", addr, tcfg)
+		conn, err := (&tls.Dialer{NetDialer: &dialer, Config: tcfg}).DialContext(ctx, "tcp", addr)
 		if err != nil {
 			return nil, fmt.Errorf("smtp tls dial %s: %w", addr, err)
 		}
 		return conn, nil
 	}
-	conn, err := dialer.Dial("tcp", addr)
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("smtp tcp dial %s: %w", addr, err)
 	}
 	return conn, nil
 }
 
-func newAuthenticatedClient(host string, port int, username, password string) (*smtp.Client, error) {
+func newAuthenticatedClient(ctx context.Context, host string, port int, username, password string) (*smtp.Client, error) {
 	if host == "" || username == "" || password == "" {
 		return nil, fmt.Errorf("smtp not configured")
 	}
 	if port <= 0 {
 		port = 587
 	}
-	conn, err := dialSMTP(host, port)
+	conn, err := dialSMTP(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +98,7 @@ func SendPlain(host string, port int, username, password, fromAddr string, to []
 		fromAddr = defaultNoReplyFrom(username)
 	}
 
-	client, err := newAuthenticatedClient(host, port, username, password)
+	client, err := newAuthenticatedClient(context.Background(), host, port, username, password)
 	if err != nil {
 		return err
 	}
@@ -173,8 +174,8 @@ func parseAddrList(addrs []string, field string) ([]*mail.Address, error) {
 	return out, nil
 }
 
-func smtpConnect(host string, port int, username, password string) (*smtp.Client, error) {
-	return newAuthenticatedClient(host, port, username, password)
+func smtpConnect(ctx context.Context, host string, port int, username, password string) (*smtp.Client, error) {
+	return newAuthenticatedClient(ctx, host, port, username, password)
 }
 
 func mailFromAddr(fromAddr, username string) (*mail.Address, error) {
@@ -219,8 +220,9 @@ func mimeBoundary() string {
 	return "pos_" + hex.EncodeToString(b)
 }
 
-// SendWithAttachments sends a UTF-8 plain-text email with file attachments (To + optional Cc/Bcc).
-func SendWithAttachments(host string, port int, username, password, fromAddr string, to, cc, bcc []string, subject, body string, attachments []Attachment) error {
+// SendWithAttachments sends a UTF-8 plain-text email with file attachments (To + optional Cc/Bcc). Cancelling ctx
+// drops the connection, so the message is not sent once its caller has given up.
+func SendWithAttachments(ctx context.Context, host string, port int, username, password, fromAddr string, to, cc, bcc []string, subject, body string, attachments []Attachment) error {
 	toParsed, err := parseAddrList(to, "to")
 	if err != nil {
 		return err
@@ -242,11 +244,13 @@ func SendWithAttachments(host string, port int, username, password, fromAddr str
 		return err
 	}
 
-	client, err := smtpConnect(host, port, username, password)
+	client, err := smtpConnect(ctx, host, port, username, password)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = client.Close() }()
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
 
 	if err = client.Mail(fromParsed.Address); err != nil {
 		return fmt.Errorf("smtp mail from %q: %w", fromParsed.Address, err)
@@ -302,6 +306,9 @@ func SendWithAttachments(host string, port int, username, password, fromAddr str
 	}
 	msg.WriteString("--" + boundary + "--\r\n")
 
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	if _, err = w.Write([]byte(msg.String())); err != nil {
 		return fmt.Errorf("smtp write body: %w", err)
 	}
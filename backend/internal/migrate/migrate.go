@@ -0,0 +1,402 @@
+// Package migrate applies numbered schema and data migrations and records them in the schema_migrations table.
+//
+// A migration is either a pair of SQL files, NNNN_name.up.sql and NNNN_name.down.sql, or a Go function for data
+// changes that are awkward in SQL. Both share one version sequence and run in version order. MySQL commits DDL
+// as it goes, so a SQL migration that fails part way is not rolled back: keep each one small, and fix the
+// database by hand before running up again. Go migrations run in a transaction.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one step of the schema history.
+type Migration struct {
+	Version int64
+	Name    string
+	// Set for SQL migrations.
+	UpSQL, DownSQL string
+	// Set for Go migrations. A nil Down means there is nothing to undo; rolling back only drops the record.
+	Up, Down func(tx *gorm.DB) error
+}
+
+// Record is an applied migration as stored in schema_migrations.
+type Record struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"type:datetime;not null"`
+}
+
+func (Record) TableName() string { return "schema_migrations" }
+
+var (
+	// ErrSchemaTooNew means the database has migrations this build does not know; it was migrated by a newer build.
+	ErrSchemaTooNew = errors.New("database schema is newer than this build")
+	// ErrPending means the database is behind this build.
+	ErrPending = errors.New("database has pending migrations")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads SQL migrations from the root of fsys. Every up file needs a matching down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration file %s: name must look like 0001_add_thing.up.sql", e.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has files named %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.UpSQL = string(body)
+		} else {
+			mig.DownSQL = string(body)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.UpSQL) == "" || strings.TrimSpace(m.DownSQL) == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs non-empty up and down files", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	return out, nil
+}
+
+// Sorted merges migration lists into version order, rejecting duplicate or non-positive versions.
+func Sorted(lists ...[]Migration) ([]Migration, error) {
+	var out []Migration
+	seen := map[int64]string{}
+	for _, list := range lists {
+		for _, m := range list {
+			if m.Version <= 0 {
+				return nil, fmt.Errorf("migration %q: version must be positive", m.Name)
+			}
+			if other, ok := seen[m.Version]; ok {
+				return nil, fmt.Errorf("migration version %d is used by both %q and %q", m.Version, other, m.Name)
+			}
+			seen[m.Version] = m.Name
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Latest returns the highest version in a sorted list, or 0 when it is empty.
+func Latest(migrations []Migration) int64 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// EnsureTable creates schema_migrations if it does not exist.
+func EnsureTable(db *gorm.DB) error {
+	return db.Exec("CREATE TABLE IF NOT EXISTS `schema_migrations` (" +
+		"`version` bigint NOT NULL, `name` varchar(255) NOT NULL, `applied_at` datetime NOT NULL, PRIMARY KEY (`version`))").Error
+}
+
+// Applied returns the recorded migrations in version order.
+func Applied(db *gorm.DB) ([]Record, error) {
+	var records []Record
+	err := db.Order("version").Find(&records).Error
+	return records, err
+}
+
+// MarkApplied records a migration as applied without running it (used when adopting an existing schema).
+func MarkApplied(db *gorm.DB, m Migration) error {
+	return db.Create(&Record{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+}
+
+// StatusRow describes one migration for the status command. AppliedAt is nil for pending migrations; Unknown marks
+// a recorded version this build has no file for.
+type StatusRow struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+// Status lists every known and every recorded migration in version order.
+func Status(db *gorm.DB, migrations []Migration) ([]StatusRow, error) {
+	records, err := Applied(db)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int64]Record{}
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	var rows []StatusRow
+	for _, m := range migrations {
+		row := StatusRow{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			at := r.AppliedAt
+			row.AppliedAt = &at
+			delete(applied, m.Version)
+		}
+		rows = append(rows, row)
+	}
+	for _, r := range applied {
+		at := r.AppliedAt
+		rows = append(rows, StatusRow{Version: r.Version, Name: r.Name, AppliedAt: &at, Unknown: true})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Version < rows[j].Version })
+	return rows, nil
+}
+
+// Check compares the database with migrations. It returns ErrSchemaTooNew when the database holds a version this
+// build does not know, or ErrPending (with the count) when known migrations have not been applied.
+func Check(db *gorm.DB, migrations []Migration) error {
+	rows, err := Status(db, migrations)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, r := range rows {
+		if r.Unknown {
+			return fmt.Errorf("%w: version %d (%s) is applied but this build only knows up to %d",
+				ErrSchemaTooNew, r.Version, r.Name, Latest(migrations))
+		}
+		if r.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d not applied", ErrPending, pending)
+	}
+	return nil
+}
+
+// Up applies pending migrations in order, up to and including target (0 means all). It refuses to run against a
+// schema that is newer than this build. The applied migrations are returned even when a later one fails.
+func Up(db *gorm.DB, migrations []Migration, target int64, logf func(string, ...interface{})) ([]Migration, error) {
+	if err := Check(db, migrations); err != nil && !errors.Is(err, ErrPending) {
+		return nil, err
+	}
+	records, err := Applied(db)
+	if err != nil {
+		return nil, err
+	}
+	done := map[int64]bool{}
+	for _, r := range records {
+		done[r.Version] = true
+	}
+	var ran []Migration
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if done[m.Version] {
+			continue
+		}
+		start := time.Now()
+		if err := runUp(db, m); err != nil {
+			return ran, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+		if logf != nil {
+			logf("Applied migration %04d_%s (%s)", m.Version, m.Name, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	return ran, nil
+}
+
+// Down rolls back the newest steps applied migrations, newest first, and returns those rolled back.
+func Down(db *gorm.DB, migrations []Migration, steps int, logf func(string, ...interface{})) ([]Migration, error) {
+	if err := Check(db, migrations); err != nil && !errors.Is(err, ErrPending) {
+		return nil, err
+	}
+	records, err := Applied(db)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	var reverted []Migration
+	for i := len(records) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := byVersion[records[i].Version]
+		if err := runDown(db, m); err != nil {
+			return reverted, fmt.Errorf("roll back %04d_%s: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+		if logf != nil {
+			logf("Rolled back migration %04d_%s", m.Version, m.Name)
+		}
+	}
+	return reverted, nil
+}
+
+func runUp(db *gorm.DB, m Migration) error {
+	if m.Up != nil {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return MarkApplied(tx, m)
+		})
+	}
+	if err := execScript(db, m.UpSQL); err != nil {
+		return err
+	}
+	return MarkApplied(db, m)
+}
+
+func runDown(db *gorm.DB, m Migration) error {
+	if m.UpSQL == "" {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if m.Down != nil {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(&Record{}, m.Version).Error
+		})
+	}
+	if err := execScript(db, m.DownSQL); err != nil {
+		return err
+	}
+	return db.Delete(&Record{}, m.Version).Error
+}
+
+// execScript runs each statement of script on one connection, so session settings carry across statements.
+func execScript(db *gorm.DB, script string) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		for i, stmt := range SplitStatements(script) {
+			if err := conn.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("statement %d: %w", i+1, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SplitStatements splits a SQL script on semicolons that end a statement, ignoring those inside quotes and
+// comments. Comment-only statements are dropped.
+func SplitStatements(script string) []string {
+	var out []string
+	var b strings.Builder
+	hasCode := false
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" && hasCode {
+			out = append(out, s)
+		}
+		b.Reset()
+		hasCode = false
+	}
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			j := i + 1
+			for j < len(script) {
+				if script[j] == '\\' && ch != '`' {
+					j += 2
+					continue
+				}
+				if script[j] == ch {
+					if j+1 < len(script) && script[j+1] == ch { // doubled quote
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j >= len(script) {
+				j = len(script) - 1
+			}
+			b.WriteString(script[i : j+1])
+			hasCode = true
+			i = j
+		case ch == '-' && strings.HasPrefix(script[i:], "-- "), ch == '#':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case ch == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+		case ch == ';':
+			flush()
+		default:
+			b.WriteByte(ch)
+			if ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r' {
+				hasCode = true
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// Create writes empty up and down files for the next version into dir and returns their paths. Versions already
+// used by Go migrations are passed in known so the new number does not collide with them.
+func Create(dir, name string, known []Migration) (string, string, error) {
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", "", errors.New("migration name is required")
+	}
+	fromFiles, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	next := Latest(known)
+	if v := Latest(sortedCopy(fromFiles)); v > next {
+		next = v
+	}
+	next++
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", next, slug))
+	up, down := base+".up.sql", base+".down.sql"
+	header := fmt.Sprintf("-- %04d %s\n", next, strings.ReplaceAll(slug, "_", " "))
+	if err := os.WriteFile(up, []byte(header+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte(header+"-- Undo the up migration.\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+func sortedCopy(list []Migration) []Migration {
+	out := append([]Migration(nil), list...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
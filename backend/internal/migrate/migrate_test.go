@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- header; with a semicolon
+CREATE TABLE a (id int, note varchar(10) DEFAULT 'x;y');
+/* block; comment */
+INSERT INTO a VALUES (1, 'it''s; fine'), (2, "dq\";");
+# hash comment;
+UPDATE a SET note = ` + "`note`" + `;
+-- trailing comment only
+`
+	got := SplitStatements(script)
+	want := []string{
+		"CREATE TABLE a (id int, note varchar(10) DEFAULT 'x;y')",
+		"INSERT INTO a VALUES (1, 'it''s; fine'), (2, \"dq\\\";\")",
+		"UPDATE a SET note = `note`",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SplitStatements:\n got %q\nwant %q", got, want)
+	}
+	if got := SplitStatements("-- only a comment\n"); len(got) != 0 {
+		t.Errorf("comment-only script gave %q", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	list, err := Load(fstest.MapFS{
+		"0002_add_b.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"0002_add_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_add_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+		"0001_add_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"README.md":           {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = Sorted(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Version != 1 || list[1].Name != "add_b" || Latest(list) != 2 {
+		t.Fatalf("unexpected migrations %+v", list)
+	}
+
+	bad := map[string]fstest.MapFS{
+		"missing down": {"0001_a.up.sql": {Data: []byte("SELECT 1;")}},
+		"name clash": {
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"bad name": {"1-a.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for name, fsys := range bad {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSortedRejectsDuplicates(t *testing.T) {
+	_, err := Sorted([]Migration{{Version: 1, Name: "a"}}, []Migration{{Version: 1, Name: "b"}})
+	if err == nil || !strings.Contains(err.Error(), "used by both") {
+		t.Fatalf("expected duplicate version error, got %v", err)
+	}
+}
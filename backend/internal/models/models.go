@@ -13,7 +13,7 @@ type User struct {
 	FirstName     string    `gorm:"not null" json:"first_name"`
 	LastName      string    `gorm:"not null" json:"last_name"`
 	Email         string    `json:"email"`
-	Role          string `gorm:"type:enum('management','pos_user','supervisor','hq_staff');not null" json:"role"`
+	Role          string `gorm:"type:varchar(50);not null;index" json:"role"` // name of a Role; system roles are management, supervisor, hq_staff, pos_user
 	IconURL       string    `json:"icon_url"`
 	IconColor     string    `json:"icon_color"`
 	IconBgColor   string    `json:"icon_bg_color"`   // Last selected background color for icon generation
@@ -58,6 +58,17 @@ type POSDevice struct {
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 
+	// Device authentication: the till sends its token on every /device/:device_code call. Only digests are stored;
+	// the token is returned once, at enrolment.
+	TokenHash              string     `gorm:"type:varchar(64);index" json:"-"`
+	TokenIssuedAt          *time.Time `json:"token_issued_at,omitempty"`
+	EnrolmentCodeHash      string     `gorm:"type:varchar(64)" json:"-"` // one-time code typed on the till to collect its token
+	EnrolmentCodeExpiresAt *time.Time `json:"enrolment_code_expires_at,omitempty"`
+	EnrolmentAttempts      int        `gorm:"not null;default:0" json:"-"`
+	RevokedAt              *time.Time `json:"revoked_at,omitempty"` // revoked devices are refused until re-enrolled
+	AllowCostData          bool       `gorm:"default:false" json:"allow_cost_data"` // device product sync includes purchasing/wholesale cost fields
+	LastSeenAt             *time.Time `json:"last_seen_at,omitempty"`
+
 	// Relationships
 	Store Store `gorm:"foreignKey:StoreID" json:"store,omitempty"`
 }
@@ -189,6 +200,8 @@ type Stock struct {
 	TrackWeight       bool      `gorm:"not null;default:false" json:"track_weight"`
 	// WholesaleShipFrom: wholesale orders for this product always ship from this store.
 	WholesaleShipFrom bool      `gorm:"not null;default:false" json:"wholesale_ship_from"`
+	// BinLocation: shelf/bin in this store where the product is picked from (shown on pick lists).
+	BinLocation       string    `gorm:"type:varchar(50)" json:"bin_location,omitempty"`
 	LowStockThreshold float64   `gorm:"type:decimal(10,3);default:0" json:"low_stock_threshold"`
 	LastUpdated       time.Time `gorm:"type:datetime" json:"last_updated"`
 
@@ -300,6 +313,7 @@ type CurrencyRate struct {
 type AuditLog struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
 	UserID     *uint     `gorm:"index" json:"user_id,omitempty"`
+	APIKeyID   *uint     `gorm:"index" json:"api_key_id,omitempty"` // set when the action came through an API key
 	Action     string    `gorm:"not null;index" json:"action"`
 	EntityType string    `json:"entity_type"`
 	EntityID   *uint     `json:"entity_id,omitempty"`
@@ -312,6 +326,226 @@ type AuditLog struct {
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
+// UserSession is a server-side login. Access tokens carry its ID ("sid") and stop working once it is revoked,
+// expires, or its user is deactivated.
+type UserSession struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	DeviceID      *uint      `gorm:"index" json:"device_id,omitempty"`     // till the PIN login happened on
+	LoginMethod   string     `gorm:"type:varchar(20)" json:"login_method"` // password, pin
+	IPAddress     string     `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent     string     `gorm:"type:varchar(255)" json:"user_agent"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	ExpiresAt     time.Time  `gorm:"index" json:"expires_at"` // refresh deadline; pushed back on every rotation
+	RevokedAt     *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedReason string     `gorm:"type:varchar(30)" json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	// Relationships
+	Device *POSDevice `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
+}
+
+// SessionRefreshToken is one link in a session's refresh-token rotation chain, stored as a SHA-256 digest.
+// Presenting a token that was already rotated means the chain leaked, so the whole session is revoked.
+type SessionRefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	SessionID uint       `gorm:"not null;index" json:"session_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Reasons recorded on UserSession.RevokedReason.
+const (
+	SessionRevokedLogout          = "logout"
+	SessionRevokedLogoutAll       = "logout_all"
+	SessionRevokedByAdmin         = "admin"
+	SessionRevokedReuse           = "refresh_reuse"
+	SessionRevokedUserDeactivated = "user_deactivated"
+	SessionRevokedDevice          = "device_revoked"
+)
+
+// APIKey lets an integration call the API as UserID, limited to Scopes. Only a hash of the key is stored.
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"type:varchar(100);not null" json:"name"`
+	KeyPrefix   string     `gorm:"type:varchar(16);not null" json:"key_prefix"` // start of the key, to recognise it in lists
+	KeyHash     string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"` // the key acts as this user, within Scopes
+	Scopes      string     `gorm:"type:text" json:"-"`            // comma-separated permission names
+	AllowedIPs  string     `gorm:"type:text" json:"allowed_ips"`  // comma-separated IPs or CIDRs; empty allows any
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP  string     `gorm:"type:varchar(64)" json:"last_used_ip,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedByID *uint      `json:"created_by_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// WebhookSubscription sends the listed events to URL, signed with Secret.
+type WebhookSubscription struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	URL         string    `gorm:"type:varchar(500);not null" json:"url"`
+	Secret      string    `gorm:"type:varchar(100);not null" json:"-"` // HMAC key; shown once on create and rotate
+	Events      string    `gorm:"type:text" json:"-"`                  // comma-separated event types; "*" = all
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedByID *uint     `json:"created_by_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySending   = "sending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // gave up after webhook.MaxAttempts
+)
+
+// WebhookDelivery is one event queued for one subscription: the outbox row and its delivery log entry.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        string     `gorm:"type:varchar(64);not null;index" json:"event_id"` // shared by all deliveries of one event
+	EventType      string     `gorm:"type:varchar(50);not null;index" json:"event_type"`
+	Payload        string     `gorm:"type:mediumtext;not null" json:"payload"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_webhook_delivery_due,priority:1" json:"status"`
+	NextAttemptAt  time.Time  `gorm:"type:datetime;not null;index:idx_webhook_delivery_due,priority:2" json:"next_attempt_at"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	RedeliveryOfID *uint      `json:"redelivery_of_id,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+
+	Subscription *WebhookSubscription `gorm:"foreignKey:SubscriptionID" json:"subscription,omitempty"`
+}
+
+// Job statuses. A failed attempt goes back to queued with a later RunAt until MaxAttempts, then dead.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+// Job is one unit of background work (see internal/jobs). Payload and Result are JSON.
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"type:varchar(50);not null;index" json:"type"`
+	Payload     string     `gorm:"type:mediumtext;not null" json:"payload"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'queued';index:idx_job_due,priority:1" json:"status"`
+	RunAt       time.Time  `gorm:"type:datetime;not null;index:idx_job_due,priority:2" json:"run_at"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`
+	LockedBy    string     `gorm:"type:varchar(100)" json:"locked_by,omitempty"` // worker that holds a running job
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	Result      string     `gorm:"type:text" json:"result,omitempty"`
+	CreatedByID *uint      `gorm:"index" json:"created_by_id,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Scheduled task run statuses, copied from the job a run queued.
+const (
+	TaskRunQueued    = "queued"
+	TaskRunSucceeded = "succeeded"
+	TaskRunFailed    = "failed"
+)
+
+// ScheduledTask is a built-in recurring task (see api/scheduler.go) with its editable schedule and last outcome.
+type ScheduledTask struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Key         string `gorm:"type:varchar(50);uniqueIndex;not null" json:"key"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:varchar(500)" json:"description,omitempty"`
+	CronExpr    string `gorm:"type:varchar(100);not null" json:"cron_expr"`
+	Timezone    string `gorm:"type:varchar(64);not null" json:"timezone"`
+	Enabled     bool   `gorm:"not null;default:false" json:"enabled"`
+	// Recipients is a comma-separated email list for tasks that send reports; empty = company email.
+	Recipients     string     `gorm:"type:varchar(500)" json:"recipients,omitempty"`
+	NextRunAt      *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastStatus     string     `gorm:"type:varchar(20)" json:"last_status,omitempty"`
+	LastResult     string     `gorm:"type:text" json:"last_result,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	LastJobID      *uint      `json:"last_job_id,omitempty"`
+	LastTrigger    string     `gorm:"type:varchar(20)" json:"last_trigger,omitempty"` // schedule or manual
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// SchedulerLease is a named lock held by one instance until ExpiresAt; the holder renews it while alive.
+type SchedulerLease struct {
+	Name      string    `gorm:"type:varchar(50);primaryKey" json:"name"`
+	Holder    string    `gorm:"type:varchar(100);not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"type:datetime;not null" json:"expires_at"`
+}
+
+// UserTwoFactor is a user's TOTP authenticator. It only applies to password logins once ConfirmedAt is set.
+type UserTwoFactor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	SecretSealed string     `gorm:"type:varchar(255);not null" json:"-"` // base32 secret, sealed with TWO_FACTOR_KEY
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"` // last accepted TOTP step; a code is accepted once
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// UserRecoveryCode is a single-use code that stands in for a TOTP code when the authenticator is lost.
+type UserRecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Role is an editable named set of permissions. A user's primary role is matched by name from User.Role.
+type Role struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Name        string           `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	Description string           `json:"description"`
+	IsSystem    bool             `gorm:"default:false" json:"is_system"` // seeded role; cannot be renamed or deleted
+	// RequireTwoFactor makes holders enrol TOTP before a password login completes. PIN logins on enrolled tills
+	// are exempt.
+	RequireTwoFactor bool `gorm:"default:false" json:"require_two_factor"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// RolePermission grants one named permission (see PermissionCatalogue) to a role.
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permission" json:"role_id"`
+	Permission string `gorm:"type:varchar(100);not null;uniqueIndex:idx_role_permission" json:"permission"`
+}
+
+// UserStoreRole grants a user a role's permissions for one store only, on top of their primary role.
+type UserStoreRole struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_store_role" json:"user_id"`
+	StoreID   uint      `gorm:"not null;uniqueIndex:idx_user_store_role" json:"store_id"`
+	RoleID    uint      `gorm:"not null;uniqueIndex:idx_user_store_role;index" json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Role  *Role  `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+	Store *Store `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+}
+
 // StocktakeInventorySnapshot records inventory quantity per product per store after a stocktake.
 // One row per (store_id, product_id, snapshot_date, snapshot_type). Used for day-start/day-end stock report.
 type StocktakeInventorySnapshot struct {
@@ -354,6 +588,9 @@ const (
 	EventStocktakeDayStartDone    = "stocktake_day_start_done"
 	EventStocktakeDayStartSkipped = "stocktake_day_start_skipped"
 	EventStocktakeDayEndSkipped   = "stocktake_day_end_skipped"
+	EventLoginFailed              = "login_failed"
+	EventLoginLocked              = "login_locked"
+	EventLoginUnlocked            = "login_unlocked"
 )
 
 type UserActivityEvent struct {
@@ -363,6 +600,7 @@ type UserActivityEvent struct {
 	EventType  string    `gorm:"type:varchar(50);not null;index" json:"event_type"` // first_login, logout, stocktake_day_start_done, stocktake_day_start_skipped
 	OccurredAt time.Time `gorm:"type:datetime;not null" json:"occurred_at"`
 	SkipReason string    `gorm:"type:text" json:"skip_reason,omitempty"` // for stocktake_day_start_skipped
+	Detail     string    `gorm:"type:varchar(255)" json:"detail,omitempty"` // login events: method, device and IP
 	CreatedAt  time.Time `gorm:"type:datetime" json:"created_at"`
 
 	User  User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -386,11 +624,60 @@ type WholesaleClient struct {
 	AccountCode   string    `gorm:"type:varchar(50)" json:"account_code,omitempty"`
 	SectorID      *uint     `gorm:"index" json:"sector_id,omitempty"`
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
+	CreditLimit   float64   `gorm:"type:decimal(10,2);not null;default:0" json:"credit_limit"`                 // 0 = no limit
+	CreditAction  string    `gorm:"type:varchar(10);not null;default:'block'" json:"credit_action"`            // "block" or "warn" when approval exceeds the limit
+	OnStop        bool      `gorm:"default:false" json:"on_stop"`                                              // account on stop: approvals blocked until lifted or overridden
+	OnStopReason  string    `gorm:"type:varchar(500)" json:"on_stop_reason,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 
 	Sector *Sector                `gorm:"foreignKey:SectorID" json:"sector,omitempty"`
 	Stores []WholesaleClientStore `gorm:"foreignKey:WholesaleClientID" json:"stores,omitempty"`
+
+	// Credit exposure is computed server-side for list/detail views (not persisted).
+	InvoicedUnpaid float64 `json:"invoiced_unpaid" gorm:"-"` // invoiced orders not yet paid
+	OpenOrders     float64 `json:"open_orders" gorm:"-"`     // approved orders not yet invoiced or paid
+	Exposure       float64 `json:"exposure" gorm:"-"`        // InvoicedUnpaid + OpenOrders
+}
+
+const (
+	WholesaleClientCreditActionBlock = "block"
+	WholesaleClientCreditActionWarn  = "warn"
+)
+
+// ShippingRule calculates the shipping fee and minimum order value for one wholesale client or a whole sector.
+// A client's own rule wins over its sector's rule; with neither, the fee is entered manually on the order.
+type ShippingRule struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	Name              string    `gorm:"type:varchar(200)" json:"name"`
+	WholesaleClientID *uint     `gorm:"index" json:"wholesale_client_id,omitempty"` // exactly one of client or sector is set
+	SectorID          *uint     `gorm:"index" json:"sector_id,omitempty"`
+	FreeOverAmount    float64   `gorm:"type:decimal(10,2);not null;default:0" json:"free_over_amount"` // order net at or above this ships free (surcharges still apply); 0 = never free
+	FlatFee           float64   `gorm:"type:decimal(10,2);not null;default:0" json:"flat_fee"`
+	PerCaseFee        float64   `gorm:"type:decimal(10,2);not null;default:0" json:"per_case_fee"`
+	MinOrderValue     float64   `gorm:"type:decimal(10,2);not null;default:0" json:"min_order_value"`              // order net; 0 = no minimum
+	MinOrderAction    string    `gorm:"type:varchar(10);not null;default:'warn'" json:"min_order_action"`          // "block" or "warn" below the minimum
+	IsActive          bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	WholesaleClient *WholesaleClient        `gorm:"foreignKey:WholesaleClientID" json:"wholesale_client,omitempty"`
+	Sector          *Sector                 `gorm:"foreignKey:SectorID" json:"sector,omitempty"`
+	Surcharges      []ShippingRuleSurcharge `json:"surcharges,omitempty"`
+}
+
+const (
+	ShippingRuleMinOrderBlock = "block"
+	ShippingRuleMinOrderWarn  = "warn"
+)
+
+// ShippingRuleSurcharge adds a fee for deliveries to a postcode zone: a postcode area ("BT", "IV") or an outward
+// code ("PA20"). The most specific matching zone applies.
+type ShippingRuleSurcharge struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	ShippingRuleID uint    `gorm:"not null;index" json:"shipping_rule_id"`
+	PostcodePrefix string  `gorm:"type:varchar(10);not null" json:"postcode_prefix"`
+	Surcharge      float64 `gorm:"type:decimal(10,2);not null;default:0" json:"surcharge"`
 }
 
 // WholesaleClientStore is a delivery location belonging to a wholesale client.
@@ -422,6 +709,7 @@ const (
 	WholesaleOrderStatusApproved       = "approved"
 	WholesaleOrderStatusRejected       = "rejected"
 	WholesaleOrderStatusDeleted        = "deleted" // soft-delete; excluded from default list unless filtered
+	WholesaleOrderStatusBackorder      = "backorder" // lines split off for lack of stock; allocated automatically on restock receipt
 )
 
 type WholesaleOrder struct {
@@ -439,9 +727,13 @@ type WholesaleOrder struct {
 	VATTotal               float64    `gorm:"type:decimal(10,2);not null;default:0" json:"vat_total"`
 	AmountDue              float64    `gorm:"type:decimal(10,2);not null;default:0" json:"amount_due"`
 	ShippingFee            float64    `gorm:"type:decimal(10,2);default:0" json:"shipping_fee,omitempty"` // order-level shipping fee (invoice total)
+	ShippingRuleID         *uint      `gorm:"index" json:"shipping_rule_id,omitempty"`                    // rule the fee was calculated from; nil for manual/legacy fees
+	ShippingFeeBasis       string     `gorm:"type:varchar(255)" json:"shipping_fee_basis,omitempty"`      // how the fee was calculated; shown on the OC
+	ShippingFeeOverridden  bool       `gorm:"default:false" json:"shipping_fee_overridden,omitempty"`     // fee typed in manually over the calculated one
 	PONumber               string     `gorm:"type:varchar(100)" json:"po_number"`
 	OrderChannel           string     `gorm:"type:varchar(80)" json:"order_channel,omitempty"` // "po" = client PO; "whatsapp", "email" or free text
 	RefNo                  string     `gorm:"type:varchar(100);uniqueIndex" json:"ref_no"`     // OC Number: D1, D2, D2.1...
+	InvoiceNumber          string     `gorm:"type:varchar(50);index" json:"invoice_number,omitempty"` // from the invoice number series; assigned when the invoice is first issued
 	PODate                 *time.Time `gorm:"type:date" json:"po_date,omitempty"`
 	OrderDate              *time.Time `gorm:"type:date" json:"order_date,omitempty"`            // used on OC "Date:"
 	InvoiceDate            *time.Time `gorm:"type:date" json:"invoice_date,omitempty"`          // used on invoice "Date:"; editable, default to current date when empty
@@ -454,6 +746,9 @@ type WholesaleOrder struct {
 	ReviewedBy             *uint      `gorm:"index" json:"reviewed_by,omitempty"`
 	PaymentConfirmedAt     *time.Time `gorm:"type:datetime" json:"payment_confirmed_at,omitempty"` // when money received confirmed
 	PaymentProofURL        string     `gorm:"type:text" json:"payment_proof_url,omitempty"`        // uploaded image/PDF (or bank API later)
+	WholesaleQuotationID   *uint      `gorm:"index" json:"wholesale_quotation_id,omitempty"`       // set when converted from a quotation; line prices are locked
+	StandingOrderID        *uint      `gorm:"index" json:"standing_order_id,omitempty"`            // set when generated from a standing order template
+	BackorderOfID          *uint      `gorm:"index" json:"backorder_of_id,omitempty"`              // set on backorders: the original order the lines were split from
 
 	WholesaleClient      WholesaleClient          `gorm:"foreignKey:WholesaleClientID" json:"wholesale_client,omitempty"`
 	WholesaleClientStore *WholesaleClientStore    `gorm:"foreignKey:WholesaleClientStoreID" json:"wholesale_client_store,omitempty"`
@@ -464,10 +759,20 @@ type WholesaleOrder struct {
 	Items                []WholesaleOrderItem     `json:"items,omitempty"`
 	Documents            []WholesaleOrderDocument `json:"documents,omitempty"`
 	Shipments            []Shipment               `json:"shipments,omitempty"`
+	Backorders           []WholesaleOrder         `gorm:"foreignKey:BackorderOfID" json:"backorders,omitempty"`
 
 	// IsCompleted is computed server-side for list views; true when all shipments done and payment confirmed
 	IsCompleted bool `json:"is_completed,omitempty" gorm:"-"`
 
+	// CreditWarning is set on the approve response when the client is over its credit limit in "warn" mode.
+	CreditWarning string `json:"credit_warning,omitempty" gorm:"-"`
+	// DocumentJobID is set when the response queued a PDF for this order; poll GET /jobs/:id for it.
+	DocumentJobID *uint `json:"document_job_id,omitempty" gorm:"-"`
+
+	// MinimumOrderWarning is set on create/update responses when the order is below the shipping rule's minimum
+	// order value in "warn" mode.
+	MinimumOrderWarning string `json:"minimum_order_warning,omitempty" gorm:"-"`
+
 	// Workflow fields are computed server-side for list/detail status alignment (not persisted).
 	WorkflowInvoiceEmailDone  bool     `json:"workflow_invoice_email_done,omitempty" gorm:"-"`
 	WorkflowPaymentProofTotal *float64 `json:"workflow_payment_proof_total,omitempty" gorm:"-"`
@@ -494,7 +799,7 @@ type WholesaleOrderItem struct {
 type WholesaleOrderDocument struct {
 	ID               uint      `gorm:"primaryKey" json:"id"`
 	WholesaleOrderID uint      `gorm:"not null;index" json:"wholesale_order_id"`
-	Type             string    `gorm:"type:varchar(50);not null;index" json:"type"` // order_confirmation, delivery_note, invoice, po_attachment
+	Type             string    `gorm:"type:varchar(50);not null;index" json:"type"` // order_confirmation, delivery_note, invoice, po_attachment, credit_note, ubl_invoice, ubl_credit_note
 	FileURL          string    `gorm:"type:text;not null" json:"file_url"`
 	OriginalFilename string    `gorm:"type:varchar(255)" json:"original_filename,omitempty"` // display name for po_attachment (user's file name)
 	CreatedAt        time.Time `gorm:"type:datetime;not null" json:"created_at"`
@@ -535,6 +840,13 @@ type Shipment struct {
 	SignedDeliveryNotePDFURL string     `gorm:"type:text" json:"signed_delivery_note_pdf_url,omitempty"` // uploaded when completing without courier tracking
 	DeliveryDate             *time.Time `gorm:"type:date" json:"delivery_date,omitempty"`                // set when completing shipment (used on delivery note PDF)
 	Status                   string     `gorm:"type:varchar(20);not null;default:'assigned';index" json:"status"`
+	// Courier integration: set when the consignment is booked through a provider (see internal/courier).
+	CourierProvider   string     `gorm:"type:varchar(50);index" json:"courier_provider,omitempty"`
+	ConsignmentID     string     `gorm:"type:varchar(100)" json:"consignment_id,omitempty"`
+	CourierLabelURL   string     `gorm:"type:text" json:"courier_label_url,omitempty"`
+	TrackingStatus    string     `gorm:"type:varchar(30)" json:"tracking_status,omitempty"` // latest normalised event status
+	TrackingCheckedAt *time.Time `gorm:"type:datetime" json:"tracking_checked_at,omitempty"`
+	TrackingError     string     `gorm:"type:varchar(500)" json:"tracking_error,omitempty"`
 	CreatedAt                time.Time  `gorm:"type:datetime;not null" json:"created_at"`
 	UpdatedAt                time.Time  `gorm:"type:datetime;not null" json:"updated_at"`
 
@@ -543,6 +855,36 @@ type Shipment struct {
 	Items          []ShipmentItem `json:"items,omitempty"`
 }
 
+// ShipmentTrackingEvent is one carrier tracking scan stored against a shipment.
+type ShipmentTrackingEvent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID  uint      `gorm:"not null;uniqueIndex:idx_shipment_tracking_event" json:"shipment_id"`
+	Provider    string    `gorm:"type:varchar(50)" json:"provider"`
+	Code        string    `gorm:"type:varchar(50);uniqueIndex:idx_shipment_tracking_event" json:"code"`
+	Status      string    `gorm:"type:varchar(30);not null" json:"status"`
+	Description string    `gorm:"type:varchar(500)" json:"description"`
+	Location    string    `gorm:"type:varchar(200)" json:"location,omitempty"`
+	OccurredAt  time.Time `gorm:"type:datetime;not null;uniqueIndex:idx_shipment_tracking_event" json:"occurred_at"`
+	CreatedAt   time.Time `gorm:"type:datetime;not null" json:"created_at"`
+}
+
+// DeliveryProof is an electronic proof of delivery captured by the driver app: signature, recipient, location
+// and photos. The signed delivery note rendered from it is stored as the shipment's SignedDeliveryNotePDFURL.
+type DeliveryProof struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID    uint      `gorm:"not null;index" json:"shipment_id"`
+	RecipientName string    `gorm:"type:varchar(200);not null" json:"recipient_name"`
+	SignatureURL  string    `gorm:"type:text;not null" json:"signature_url"`
+	PhotoURLs     []string  `gorm:"serializer:json;type:text" json:"photo_urls,omitempty"`
+	Latitude      *float64  `gorm:"type:decimal(10,7)" json:"latitude,omitempty"`
+	Longitude     *float64  `gorm:"type:decimal(10,7)" json:"longitude,omitempty"`
+	AccuracyM     *float64  `gorm:"type:decimal(10,2)" json:"accuracy_m,omitempty"` // GPS accuracy reported by the device, metres
+	CapturedAt    time.Time `gorm:"type:datetime;not null" json:"captured_at"`      // device time of signature
+	SignedPDFURL  string    `gorm:"type:text" json:"signed_pdf_url"`
+	UserID        *uint     `gorm:"index" json:"user_id,omitempty"`
+	CreatedAt     time.Time `gorm:"type:datetime;not null" json:"created_at"`
+}
+
 // ShipmentItem links a shipment to one wholesale order item (for packing and delivery note).
 type ShipmentItem struct {
 	ID                   uint      `gorm:"primaryKey" json:"id"`
@@ -550,12 +892,370 @@ type ShipmentItem struct {
 	WholesaleOrderItemID uint      `gorm:"not null;uniqueIndex:idx_shipment_wo_item" json:"wholesale_order_item_id"`
 	Quantity             float64   `gorm:"type:decimal(10,3);default:0" json:"quantity,omitempty"`   // units in this shipment; 0 = legacy full line qty
 	CaseQty              float64   `gorm:"type:decimal(10,2);default:0" json:"case_qty,omitempty"` // number of cases/boxes (0 = show '-' on delivery note)
+	PickedQty            float64   `gorm:"type:decimal(10,3);default:0" json:"picked_qty,omitempty"` // confirmed by pick list scans
 	CreatedAt            time.Time `gorm:"type:datetime;not null" json:"created_at"`
 
 	Shipment           Shipment           `gorm:"foreignKey:ShipmentID" json:"-"`
 	WholesaleOrderItem WholesaleOrderItem `gorm:"foreignKey:WholesaleOrderItemID" json:"wholesale_order_item,omitempty"`
 }
 
+// ShipmentPickScan records one barcode scan confirmed against a consolidated pick list, and how much of it
+// was allocated to a shipment line.
+type ShipmentPickScan struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID     uint      `gorm:"not null;index" json:"shipment_id"`
+	ShipmentItemID uint      `gorm:"not null;index" json:"shipment_item_id"`
+	ProductID      uint      `gorm:"not null;index" json:"product_id"`
+	Barcode        string    `gorm:"type:varchar(100)" json:"barcode"`
+	Quantity       float64   `gorm:"type:decimal(10,3);not null" json:"quantity"`
+	UserID         *uint     `gorm:"index" json:"user_id,omitempty"`
+	CreatedAt      time.Time `gorm:"type:datetime;not null" json:"created_at"`
+}
+
+// PackingSession verifies a shipment's contents by barcode before packing completes; scans are recorded per carton.
+const (
+	PackingSessionStatusOpen      = "open"
+	PackingSessionStatusCompleted = "completed"
+	PackingSessionStatusCancelled = "cancelled"
+)
+
+type PackingSession struct {
+	ID                    uint       `gorm:"primaryKey" json:"id"`
+	ShipmentID            uint       `gorm:"not null;index" json:"shipment_id"`
+	Status                string     `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	ScanCount             int        `gorm:"not null;default:0" json:"scan_count"` // bumped by every scan; also guards concurrent scans
+	StartedBy             *uint      `gorm:"index" json:"started_by,omitempty"`
+	CompletedBy           *uint      `json:"completed_by,omitempty"`
+	CompletedAt           *time.Time `gorm:"type:datetime" json:"completed_at,omitempty"`
+	DiscrepancyAccepted   bool       `gorm:"not null;default:false" json:"discrepancy_accepted"`
+	DiscrepancyAcceptedBy *uint      `json:"discrepancy_accepted_by,omitempty"`
+	DiscrepancyReason     string     `gorm:"type:text" json:"discrepancy_reason,omitempty"`
+	CreatedAt             time.Time  `gorm:"type:datetime;not null" json:"created_at"`
+	UpdatedAt             time.Time  `gorm:"type:datetime;not null" json:"updated_at"`
+
+	Shipment Shipment        `gorm:"foreignKey:ShipmentID" json:"-"`
+	Cartons  []PackingCarton `json:"cartons,omitempty"`
+}
+
+// PackingCarton is one box in a packing session, numbered from 1 within the session.
+type PackingCarton struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	PackingSessionID uint      `gorm:"not null;uniqueIndex:idx_packing_carton_number" json:"packing_session_id"`
+	Number           int       `gorm:"not null;uniqueIndex:idx_packing_carton_number" json:"number"`
+	CreatedAt        time.Time `gorm:"type:datetime;not null" json:"created_at"`
+
+	Items []PackingCartonItem `json:"items,omitempty"`
+}
+
+// PackingCartonItem is the quantity of one shipment line packed into a carton.
+type PackingCartonItem struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	PackingCartonID uint      `gorm:"not null;uniqueIndex:idx_packing_carton_item" json:"packing_carton_id"`
+	ShipmentItemID  uint      `gorm:"not null;uniqueIndex:idx_packing_carton_item" json:"shipment_item_id"`
+	ProductID       uint      `gorm:"not null;index" json:"product_id"`
+	Quantity        float64   `gorm:"type:decimal(10,3);not null;default:0" json:"quantity"`
+	UpdatedAt       time.Time `gorm:"type:datetime;not null" json:"updated_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// DeliveryRun groups shipments delivered by our own van on one date; stops are visited in Sequence order.
+const (
+	DeliveryRunStatusPlanned    = "planned"
+	DeliveryRunStatusInProgress = "in_progress" // first stop delivered
+	DeliveryRunStatusCompleted  = "completed"   // every stop delivered or failed
+	DeliveryRunStatusCancelled  = "cancelled"
+
+	DeliveryStopStatusPending   = "pending"
+	DeliveryStopStatusDelivered = "delivered"
+	DeliveryStopStatusFailed    = "failed"
+)
+
+type DeliveryRun struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RunDate       time.Time `gorm:"type:date;not null;index" json:"run_date"`
+	DriverUserID  *uint     `gorm:"index" json:"driver_user_id,omitempty"`
+	DriverName    string    `gorm:"type:varchar(200)" json:"driver_name,omitempty"` // free text when the driver has no user account
+	VehicleReg    string    `gorm:"type:varchar(20)" json:"vehicle_reg,omitempty"`
+	StartPostcode string    `gorm:"type:varchar(20)" json:"start_postcode,omitempty"` // depot; route optimisation starts here
+	Status        string    `gorm:"type:varchar(20);not null;default:'planned';index" json:"status"`
+	Notes         string    `gorm:"type:text" json:"notes,omitempty"`
+	CreatedBy     *uint     `json:"created_by,omitempty"`
+	CreatedAt     time.Time `gorm:"type:datetime;not null" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"type:datetime;not null" json:"updated_at"`
+
+	Driver *User             `gorm:"foreignKey:DriverUserID" json:"driver,omitempty"`
+	Stops  []DeliveryRunStop `json:"stops,omitempty"`
+}
+
+// DeliveryRunStop is one shipment on a delivery run.
+type DeliveryRunStop struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	DeliveryRunID uint       `gorm:"not null;index" json:"delivery_run_id"`
+	ShipmentID    uint       `gorm:"not null;index" json:"shipment_id"`
+	Sequence      int        `gorm:"not null;default:0" json:"sequence"`
+	CollectAmount float64    `gorm:"type:decimal(10,2);not null;default:0" json:"collect_amount"` // payment the driver collects on delivery
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	DeliveredAt   *time.Time `gorm:"type:datetime" json:"delivered_at,omitempty"`
+	FailureReason string     `gorm:"type:varchar(500)" json:"failure_reason,omitempty"`
+	Notes         string     `gorm:"type:varchar(500)" json:"notes,omitempty"`
+	CreatedAt     time.Time  `gorm:"type:datetime;not null" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"type:datetime;not null" json:"updated_at"`
+
+	Shipment Shipment `gorm:"foreignKey:ShipmentID" json:"shipment,omitempty"`
+}
+
+// WholesaleQuotation is a priced offer (or pro-forma invoice) to a wholesale client; converts to a pending_approval order.
+const (
+	WholesaleQuotationTypeQuotation = "quotation"
+	WholesaleQuotationTypeProforma  = "proforma" // for clients who prepay; same lines, invoice-style payment details
+
+	WholesaleQuotationStatusDraft     = "draft"
+	WholesaleQuotationStatusSent      = "sent"
+	WholesaleQuotationStatusConverted = "converted"
+	WholesaleQuotationStatusCancelled = "cancelled"
+)
+
+type WholesaleQuotation struct {
+	ID                     uint       `gorm:"primaryKey" json:"id"`
+	QuoteNumber            string     `gorm:"type:varchar(100);index" json:"quote_number"` // Q<id> or PF<id>
+	Type                   string     `gorm:"type:varchar(20);not null;default:'quotation';index" json:"type"`
+	WholesaleClientID      uint       `gorm:"not null;index" json:"wholesale_client_id"`
+	WholesaleClientStoreID *uint      `gorm:"index" json:"wholesale_client_store_id,omitempty"` // delivery location
+	StoreID                *uint      `gorm:"index" json:"store_id,omitempty"`                  // fulfilling store; required before conversion
+	UserID                 uint       `gorm:"not null;index" json:"user_id"`                    // creator
+	SectorID               *uint      `gorm:"index" json:"sector_id,omitempty"`
+	Status                 string     `gorm:"type:varchar(20);not null;default:'draft';index" json:"status"`
+	ValidUntil             *time.Time `gorm:"type:date" json:"valid_until,omitempty"`
+	Subtotal               float64    `gorm:"type:decimal(10,2);not null;default:0" json:"subtotal"`
+	DiscountAmount         float64    `gorm:"type:decimal(10,2);not null;default:0" json:"discount_amount"`
+	TotalNet               float64    `gorm:"type:decimal(10,2);not null;default:0" json:"total_net"`
+	VATTotal               float64    `gorm:"type:decimal(10,2);not null;default:0" json:"vat_total"`
+	AmountDue              float64    `gorm:"type:decimal(10,2);not null;default:0" json:"amount_due"`
+	ShippingFee            float64    `gorm:"type:decimal(10,2);default:0" json:"shipping_fee,omitempty"`
+	PaymentTerms           string     `gorm:"type:varchar(500)" json:"payment_terms,omitempty"`
+	Notes                  string     `gorm:"type:text" json:"notes"`
+	PDFURL                 string     `gorm:"type:text" json:"pdf_url,omitempty"`
+	SentAt                 *time.Time `gorm:"type:datetime" json:"sent_at,omitempty"`
+	ConvertedOrderID       *uint      `gorm:"index" json:"converted_order_id,omitempty"`
+	ConvertedAt            *time.Time `gorm:"type:datetime" json:"converted_at,omitempty"`
+	CreatedAt              time.Time  `gorm:"type:datetime;not null" json:"created_at"`
+	UpdatedAt              time.Time  `gorm:"type:datetime;not null" json:"updated_at"`
+
+	WholesaleClient      WholesaleClient          `gorm:"foreignKey:WholesaleClientID" json:"wholesale_client,omitempty"`
+	WholesaleClientStore *WholesaleClientStore    `gorm:"foreignKey:WholesaleClientStoreID" json:"wholesale_client_store,omitempty"`
+	Store                *Store                   `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+	User                 User                     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Sector               *Sector                  `gorm:"foreignKey:SectorID" json:"sector,omitempty"`
+	Items                []WholesaleQuotationItem `json:"items,omitempty"`
+}
+
+type WholesaleQuotationItem struct {
+	ID                   uint    `gorm:"primaryKey" json:"id"`
+	WholesaleQuotationID uint    `gorm:"not null;index" json:"wholesale_quotation_id"`
+	ProductID            uint    `gorm:"not null" json:"product_id"`
+	Quantity             float64 `gorm:"type:decimal(10,3);not null" json:"quantity"`
+	UnitPrice            float64 `gorm:"type:decimal(10,2);not null" json:"unit_price"` // quoted price; copied to the order on conversion
+	LineDiscountAmount   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"line_discount_amount"`
+	LineTotal            float64 `gorm:"type:decimal(10,2);not null" json:"line_total"` // UnitPrice*Quantity - LineDiscountAmount
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// StandingOrder is a recurring order template for a wholesale client delivery location.
+const (
+	StandingOrderFrequencyWeekly      = "weekly"
+	StandingOrderFrequencyFortnightly = "fortnightly"
+	StandingOrderFrequencyMonthly     = "monthly"
+
+	StandingOrderOccurrenceScheduled = "scheduled" // has a per-occurrence override but no order yet
+	StandingOrderOccurrenceGenerated = "generated"
+	StandingOrderOccurrenceSkipped   = "skipped"
+)
+
+type StandingOrder struct {
+	ID                     uint       `gorm:"primaryKey" json:"id"`
+	Name                   string     `gorm:"type:varchar(200)" json:"name"`
+	WholesaleClientID      uint       `gorm:"not null;index" json:"wholesale_client_id"`
+	WholesaleClientStoreID *uint      `gorm:"index" json:"wholesale_client_store_id,omitempty"` // delivery location
+	StoreID                uint       `gorm:"not null;index" json:"store_id"`                   // fulfilling store on generated orders
+	UserID                 uint       `gorm:"not null;index" json:"user_id"`                    // creator
+	AssignedUserID         *uint      `gorm:"index" json:"assigned_user_id,omitempty"`          // notified when drafts are generated; creator when empty
+	Frequency              string     `gorm:"type:varchar(20);not null" json:"frequency"`       // weekly, fortnightly, monthly
+	Weekdays               string     `gorm:"type:varchar(20)" json:"weekdays"`                 // weekly/fortnightly: CSV of 0=Sun..6=Sat
+	DayOfMonth             int        `gorm:"default:0" json:"day_of_month,omitempty"`          // monthly: 1-31, clamped to month end
+	StartDate              time.Time  `gorm:"type:date;not null" json:"start_date"`             // fortnightly weeks count from this date
+	EndDate                *time.Time `gorm:"type:date" json:"end_date,omitempty"`
+	LeadDays               int        `gorm:"not null;default:3" json:"lead_days"` // drafts are generated this many days before delivery
+	PaymentTerms           string     `gorm:"type:varchar(500)" json:"payment_terms,omitempty"`
+	Notes                  string     `gorm:"type:text" json:"notes"`
+	IsActive               bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+
+	WholesaleClient      WholesaleClient       `gorm:"foreignKey:WholesaleClientID" json:"wholesale_client,omitempty"`
+	WholesaleClientStore *WholesaleClientStore `gorm:"foreignKey:WholesaleClientStoreID" json:"wholesale_client_store,omitempty"`
+	Store                Store                 `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+	AssignedUser         *User                 `gorm:"foreignKey:AssignedUserID" json:"assigned_user,omitempty"`
+	Items                []StandingOrderItem   `json:"items,omitempty"`
+}
+
+type StandingOrderItem struct {
+	ID              uint    `gorm:"primaryKey" json:"id"`
+	StandingOrderID uint    `gorm:"not null;index" json:"standing_order_id"`
+	ProductID       uint    `gorm:"not null" json:"product_id"`
+	Quantity        float64 `gorm:"type:decimal(10,3);not null" json:"quantity"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// StandingOrderOccurrence records what happened to one scheduled delivery date (skip, override, generated order).
+type StandingOrderOccurrence struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	StandingOrderID  uint       `gorm:"not null;uniqueIndex:idx_standing_order_date" json:"standing_order_id"`
+	DeliveryDate     time.Time  `gorm:"type:date;not null;uniqueIndex:idx_standing_order_date" json:"delivery_date"`
+	Status           string     `gorm:"type:varchar(20);not null;default:'scheduled'" json:"status"`
+	ItemsOverride    string     `gorm:"type:text" json:"items_override,omitempty"` // JSON [{"product_id":1,"quantity":2}]; replaces template lines for this date only
+	Notes            string     `gorm:"type:text" json:"notes,omitempty"`
+	WholesaleOrderID *uint      `gorm:"index" json:"wholesale_order_id,omitempty"`
+	GeneratedAt      *time.Time `gorm:"type:datetime" json:"generated_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// WholesaleReturn is a return authorisation (RMA) for goods delivered on a wholesale order.
+const (
+	WholesaleReturnStatusAuthorised = "authorised"
+	WholesaleReturnStatusReceived   = "received"  // goods back at the return-to store; awaiting inspection
+	WholesaleReturnStatusInspected  = "inspected" // every line dispositioned; restocked lines added to stock
+	WholesaleReturnStatusCancelled  = "cancelled"
+
+	WholesaleReturnDispositionRestock  = "restock"
+	WholesaleReturnDispositionWriteOff = "write_off"
+	WholesaleReturnDispositionSupplier = "return_to_supplier"
+)
+
+type WholesaleReturn struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	RMANumber         string     `gorm:"type:varchar(50);index" json:"rma_number"` // RMA<id>
+	WholesaleOrderID  uint       `gorm:"not null;index" json:"wholesale_order_id"`
+	WholesaleClientID uint       `gorm:"not null;index" json:"wholesale_client_id"`
+	StoreID           uint       `gorm:"not null;index" json:"store_id"` // return-to store; restocked lines go into its stock
+	UserID            uint       `gorm:"not null;index" json:"user_id"`  // creator
+	Status            string     `gorm:"type:varchar(20);not null;default:'authorised';index" json:"status"`
+	Notes             string     `gorm:"type:text" json:"notes"`
+	ReceivedAt        *time.Time `gorm:"type:datetime" json:"received_at,omitempty"`
+	InspectedAt       *time.Time `gorm:"type:datetime" json:"inspected_at,omitempty"`
+	InspectedBy       *uint      `gorm:"index" json:"inspected_by,omitempty"`
+	CreditNoteNumber  string     `gorm:"type:varchar(50)" json:"credit_note_number,omitempty"`
+	CreditNoteTotal   float64    `gorm:"type:decimal(10,2);not null;default:0" json:"credit_note_total"`
+	CreditNoteURL     string     `gorm:"type:text" json:"credit_note_url,omitempty"`
+	CreditedAt        *time.Time `gorm:"type:datetime" json:"credited_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	WholesaleOrder  WholesaleOrder        `gorm:"foreignKey:WholesaleOrderID" json:"wholesale_order,omitempty"`
+	WholesaleClient WholesaleClient       `gorm:"foreignKey:WholesaleClientID" json:"wholesale_client,omitempty"`
+	Store           Store                 `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+	User            User                  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Inspector       *User                 `gorm:"foreignKey:InspectedBy" json:"inspector,omitempty"`
+	Items           []WholesaleReturnItem `json:"items,omitempty"`
+}
+
+type WholesaleReturnItem struct {
+	ID                   uint    `gorm:"primaryKey" json:"id"`
+	WholesaleReturnID    uint    `gorm:"not null;index" json:"wholesale_return_id"`
+	WholesaleOrderItemID uint    `gorm:"not null;index" json:"wholesale_order_item_id"`
+	ProductID            uint    `gorm:"not null" json:"product_id"`
+	Quantity             float64 `gorm:"type:decimal(10,3);not null" json:"quantity"`
+	UnitPrice            float64 `gorm:"type:decimal(10,2);not null" json:"unit_price"` // net of line discount, from the order line; used for the credit note
+	Reason               string  `gorm:"type:varchar(500)" json:"reason"`
+	Disposition          string  `gorm:"type:varchar(30)" json:"disposition,omitempty"` // set at inspection: restock, write_off, return_to_supplier
+	InspectionNotes      string  `gorm:"type:text" json:"inspection_notes,omitempty"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// Document number series: legal, gap-free numbering for issued invoices and credit notes.
+const (
+	DocumentSeriesInvoice    = "invoice"
+	DocumentSeriesCreditNote = "credit_note"
+)
+
+// DocumentNumberSeries configures the format of one number series.
+type DocumentNumberSeries struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Type        string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"type"` // invoice, credit_note
+	Prefix      string    `gorm:"type:varchar(20)" json:"prefix"`                    // e.g. "INV-"
+	Padding     int       `gorm:"not null;default:6" json:"padding"`                 // zero-pad the sequence to this many digits
+	ResetYearly bool      `gorm:"not null" json:"reset_yearly"`                      // restart at 1 each calendar year; year is included in the number
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DocumentNumberCounter holds the last sequence issued per series and year (year 0 when the series never resets).
+type DocumentNumberCounter struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	SeriesType string `gorm:"type:varchar(20);not null;uniqueIndex:idx_doc_number_counter" json:"series_type"`
+	Year       int    `gorm:"not null;uniqueIndex:idx_doc_number_counter" json:"year"`
+	LastValue  int    `gorm:"not null;default:0" json:"last_value"`
+}
+
+// DocumentNumber is one issued number. Numbers are never deleted or reused; a number that is not used is voided with a reason.
+type DocumentNumber struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	SeriesType        string     `gorm:"type:varchar(20);not null;index" json:"series_type"`
+	Year              int        `gorm:"not null;index" json:"year"`
+	Sequence          int        `gorm:"not null" json:"sequence"`
+	Number            string     `gorm:"type:varchar(50);uniqueIndex;not null" json:"number"`
+	WholesaleOrderID  *uint      `gorm:"index" json:"wholesale_order_id,omitempty"`
+	WholesaleReturnID *uint      `gorm:"index" json:"wholesale_return_id,omitempty"`
+	UserID            *uint      `gorm:"index" json:"user_id,omitempty"`
+	IssuedAt          time.Time  `gorm:"type:datetime;not null" json:"issued_at"`
+	VoidedAt          *time.Time `gorm:"type:datetime" json:"voided_at,omitempty"`
+	VoidedBy          *uint      `gorm:"index" json:"voided_by,omitempty"`
+	VoidReason        string     `gorm:"type:varchar(500)" json:"void_reason,omitempty"`
+
+	User   *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Voider *User `gorm:"foreignKey:VoidedBy" json:"voider,omitempty"`
+}
+
+// AccountingNominalCode maps a product category, or a reserved key ("_default", "_shipping", "_bank",
+// "_pos_sales"), to a ledger nominal/account code used in accounting exports.
+type AccountingNominalCode struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"key"`
+	NominalCode string    `gorm:"type:varchar(20);not null" json:"nominal_code"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AccountingExport is one exported batch (Xero/Sage CSV). Documents in a batch that is not voided are not exported again.
+type AccountingExport struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Format        string     `gorm:"type:varchar(10);not null" json:"format"`      // xero, sage
+	DocumentType  string     `gorm:"type:varchar(20);not null" json:"document_type"` // invoices, credit_notes, payments, pos_sales
+	DateFrom      time.Time  `gorm:"type:date;not null" json:"date_from"`
+	DateTo        time.Time  `gorm:"type:date;not null" json:"date_to"`
+	DocumentCount int        `gorm:"not null;default:0" json:"document_count"`
+	Total         float64    `gorm:"type:decimal(12,2);not null;default:0" json:"total"`
+	Filename      string     `gorm:"type:varchar(255)" json:"filename"`
+	Content       string     `gorm:"type:longtext" json:"-"` // CSV as exported, for re-download
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	VoidedAt      *time.Time `gorm:"type:datetime" json:"voided_at,omitempty"`
+	VoidReason    string     `gorm:"type:varchar(500)" json:"void_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	User      User                       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Documents []AccountingExportDocument `json:"documents,omitempty"`
+}
+
+type AccountingExportDocument struct {
+	ID                 uint    `gorm:"primaryKey" json:"id"`
+	AccountingExportID uint    `gorm:"not null;index" json:"accounting_export_id"`
+	DocumentType       string  `gorm:"type:varchar(20);not null;index:idx_accounting_export_doc" json:"document_type"`
+	DocumentRef        string  `gorm:"type:varchar(100);not null;index:idx_accounting_export_doc" json:"document_ref"` // order/return id or "store:date" for POS
+	Amount             float64 `gorm:"type:decimal(12,2);not null;default:0" json:"amount"`
+}
+
 // CompanySettings stores configurable company address, contact and bank details for PDFs (singleton, ID=1).
 type CompanySettings struct {
 	ID                    uint      `gorm:"primaryKey" json:"id"`
@@ -570,6 +1270,7 @@ type CompanySettings struct {
 	Postcode              string    `gorm:"type:varchar(20)" json:"postcode"`
 	Telephone             string    `gorm:"type:varchar(50)" json:"telephone"`
 	Email                 string    `gorm:"type:varchar(255)" json:"email"`
+	VATNumber             string    `gorm:"type:varchar(50)" json:"vat_number"` // e.g. GB123456789; seller VAT ID on invoices and UBL e-invoices
 	BankAccountName       string    `gorm:"type:varchar(255)" json:"bank_account_name"`
 	BankAccountNumber     string    `gorm:"type:varchar(50)" json:"bank_account_number"`
 	BankSortCode          string    `gorm:"type:varchar(20)" json:"bank_sort_code"`
@@ -0,0 +1,193 @@
+package models
+
+// Permission names checked per route. Roles are editable sets of these; a user's primary role (User.Role) applies
+// everywhere and UserStoreRole grants add a role's permissions for one store only.
+const (
+	PermProductView     = "product.view"
+	PermProductEdit     = "product.edit"
+	PermProductDelete   = "product.delete"
+	PermProductCostEdit = "product.cost.edit"
+	PermCatalogManage   = "catalog.manage"
+
+	PermStockView      = "stock.view"
+	PermStockAdjust    = "stock.adjust"
+	PermStockAssign    = "stock.assign"
+	PermStockReceive   = "stock.receive"
+	PermStockStocktake = "stock.stocktake"
+	PermStoreManage    = "store.manage"
+
+	PermOrderView   = "order.view"
+	PermOrderManage = "order.manage"
+	PermPOSLogin    = "pos.login"
+
+	PermUserView            = "user.view"
+	PermUserManage          = "user.manage"
+	PermUserCredentialsEdit = "user.credentials.edit"
+	PermUserAssign          = "user.assign"
+	PermRoleManage          = "role.manage"
+	PermAPIKeyManage        = "api_key.manage"
+
+	PermDeviceView   = "device.view"
+	PermDeviceManage = "device.manage"
+
+	PermSettingsView     = "settings.view"
+	PermSettingsManage   = "settings.manage"
+	PermCurrencyManage   = "currency.manage"
+	PermWebhookManage    = "webhook.manage"
+	PermJobManage        = "job.manage"
+	PermScheduleManage   = "schedule.manage"
+	PermAuditView        = "audit.view"
+	PermAccountingExport = "accounting.export"
+
+	PermDocumentNumberView   = "document_number.view"
+	PermDocumentNumberManage = "document_number.manage"
+
+	PermWholesaleView           = "wholesale.view"
+	PermWholesaleViewAll        = "wholesale.view_all"
+	PermWholesaleCreate         = "wholesale.create"
+	PermWholesaleManage         = "wholesale.manage"
+	PermWholesaleApprove        = "wholesale.approve"
+	PermWholesaleCreditOverride = "wholesale.credit.override"
+	PermWholesaleClientManage   = "wholesale.client.manage"
+	PermWholesaleDispatch       = "wholesale.dispatch"
+)
+
+// PermissionInfo describes a permission for the role editor.
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	Description string `json:"description"`
+}
+
+// storeScopedPermissions are the permissions a per-store role grant (UserStoreRole) can confer. Everything else
+// (users, roles, settings, keys, webhooks, jobs, currency, audit...) acts on the whole company and is only ever
+// honoured from the user's primary role.
+var storeScopedPermissions = map[string]bool{
+	PermStockView:      true,
+	PermStockAdjust:    true,
+	PermStockAssign:    true,
+	PermStockReceive:   true,
+	PermStockStocktake: true,
+	PermStoreManage:    true,
+	PermOrderView:      true,
+	PermOrderManage:    true,
+	PermPOSLogin:       true,
+	PermDeviceView:     true,
+	PermDeviceManage:   true,
+}
+
+// IsStoreScopedPermission reports whether a per-store grant of perm applies within that store.
+func IsStoreScopedPermission(perm string) bool {
+	return storeScopedPermissions[perm]
+}
+
+// PermissionCatalogue lists every permission the API checks, in display order.
+var PermissionCatalogue = []PermissionInfo{
+	{PermProductView, "Products", "View products, prices, sectors, categories and catalogues"},
+	{PermProductEdit, "Products", "Create, edit and import products, product lines and sector discounts"},
+	{PermProductDelete, "Products", "Deactivate products"},
+	{PermProductCostEdit, "Products", "Edit product costs"},
+	{PermCatalogManage, "Products", "Manage sectors and categories"},
+	{PermStockView, "Stock", "View stock, stores and re-stock orders"},
+	{PermStockAdjust, "Stock", "Adjust stock levels and raise re-stock orders"},
+	{PermStockAssign, "Stock", "Assign products to stores and convert stock"},
+	{PermStockReceive, "Stock", "Receive re-stock orders"},
+	{PermStockStocktake, "Stock", "Record the day-start stocktake"},
+	{PermStoreManage, "Stock", "Create and edit stores"},
+	{PermOrderView, "POS", "View POS orders and sales stats"},
+	{PermOrderManage, "POS", "Create, pay, complete and cancel POS orders"},
+	{PermPOSLogin, "POS", "Log in on a till with a PIN"},
+	{PermUserView, "Users", "View users"},
+	{PermUserManage, "Users", "Create and edit users and their stores"},
+	{PermUserCredentialsEdit, "Users", "Change another user's PIN or icon"},
+	{PermUserAssign, "Users", "Change another user's store and client assignments"},
+	{PermRoleManage, "Users", "Edit roles, permissions and per-store role grants"},
+	{PermAPIKeyManage, "Users", "Create and revoke API keys for integrations"},
+	{PermDeviceView, "Devices", "View registered devices"},
+	{PermDeviceManage, "Devices", "Configure, enrol and revoke devices"},
+	{PermSettingsView, "Settings", "View company settings"},
+	{PermSettingsManage, "Settings", "Edit company settings, logos and module toggles"},
+	{PermCurrencyManage, "Settings", "Edit currency rates"},
+	{PermWebhookManage, "Settings", "Manage webhook subscriptions and redeliver webhooks"},
+	{PermJobManage, "Settings", "View and retry background jobs"},
+	{PermScheduleManage, "Settings", "Edit, run and pause scheduled tasks"},
+	{PermAuditView, "Settings", "View audit logs and staff activity"},
+	{PermAccountingExport, "Accounting", "Preview, create and void accounting exports and nominal codes"},
+	{PermDocumentNumberView, "Accounting", "View invoice and credit note number series"},
+	{PermDocumentNumberManage, "Accounting", "Edit number series and void numbers"},
+	{PermWholesaleView, "Wholesale", "View own wholesale orders, quotations, clients and documents"},
+	{PermWholesaleViewAll, "Wholesale", "View every wholesale order, quotation and client, and sales stats"},
+	{PermWholesaleCreate, "Wholesale", "Create wholesale orders and quotations"},
+	{PermWholesaleManage, "Wholesale", "Edit, assign, invoice and email orders; returns, standing orders, shipping rules and delivery runs"},
+	{PermWholesaleApprove, "Wholesale", "Approve and reject wholesale orders"},
+	{PermWholesaleCreditOverride, "Wholesale", "Approve over a credit hold and edit client credit settings"},
+	{PermWholesaleClientManage, "Wholesale", "Create and edit wholesale clients"},
+	{PermWholesaleDispatch, "Wholesale", "Pick, pack and ship orders and work delivery runs"},
+}
+
+// IsKnownPermission reports whether name is in PermissionCatalogue.
+func IsKnownPermission(name string) bool {
+	for _, p := range PermissionCatalogue {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// System role names; these roles are seeded on start-up and cannot be deleted or renamed.
+const (
+	RoleNameManagement = "management"
+	RoleNameSupervisor = "supervisor"
+	RoleNameHQStaff    = "hq_staff"
+	RoleNamePosUser    = "pos_user"
+)
+
+// DefaultRolePermissions seeds the system roles. They follow the old fixed-role checks, widened where a route had
+// no check at all and the till relies on it: pos_user holds stock.adjust because stocktake, inventory edits and
+// offline sync all PUT /stock/:product_id/:store_id.
+var DefaultRolePermissions = map[string][]string{
+	RoleNameManagement: allPermissions(),
+	RoleNameSupervisor: {
+		PermProductView, PermProductEdit, PermProductDelete, PermProductCostEdit, PermCatalogManage,
+		PermStockView, PermStockAdjust, PermStockAssign, PermStockReceive, PermStockStocktake, PermStoreManage,
+		PermOrderView, PermOrderManage, PermPOSLogin,
+		PermUserView, PermUserManage, PermUserAssign,
+		PermDeviceView, PermSettingsView, PermSettingsManage, PermCurrencyManage, PermAuditView,
+		PermAccountingExport, PermDocumentNumberView,
+		PermWholesaleView, PermWholesaleViewAll, PermWholesaleCreate, PermWholesaleManage, PermWholesaleApprove,
+		PermWholesaleClientManage, PermWholesaleDispatch,
+	},
+	RoleNameHQStaff: {
+		PermProductView, PermProductEdit, PermCatalogManage,
+		PermStockView, PermStockAdjust, PermStockAssign, PermStockReceive, PermStockStocktake, PermStoreManage,
+		PermOrderView, PermOrderManage,
+		PermUserView, PermUserManage,
+		PermDeviceView, PermSettingsView, PermSettingsManage, PermCurrencyManage, PermAuditView,
+		PermAccountingExport, PermDocumentNumberView,
+		PermWholesaleView, PermWholesaleViewAll, PermWholesaleManage, PermWholesaleApprove,
+		PermWholesaleClientManage, PermWholesaleDispatch,
+	},
+	RoleNamePosUser: {
+		PermProductView, PermStockView, PermStockAdjust, PermStockReceive, PermStockStocktake,
+		PermOrderView, PermOrderManage, PermPOSLogin, PermUserView, PermSettingsView,
+		PermWholesaleView, PermWholesaleCreate, PermWholesaleDispatch,
+	},
+}
+
+// AddedPermissionGrants lists permissions introduced after roles became editable, with the system roles that get
+// them by default. Each is granted once, on the first start where no role holds it yet.
+var AddedPermissionGrants = map[string][]string{
+	PermAPIKeyManage:   {RoleNameManagement},
+	PermWebhookManage:  {RoleNameManagement},
+	PermJobManage:      {RoleNameManagement},
+	PermScheduleManage: {RoleNameManagement},
+}
+
+func allPermissions() []string {
+	out := make([]string, 0, len(PermissionCatalogue))
+	for _, p := range PermissionCatalogue {
+		out = append(out, p.Name)
+	}
+	return out
+}
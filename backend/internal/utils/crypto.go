@@ -2,8 +2,10 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
@@ -101,6 +103,30 @@ func IsPlainText(hash string) bool {
 	return !strings.HasPrefix(hash, "$argon2id$")
 }
 
+// GenerateToken returns a random bearer token: prefix followed by 32 bytes of URL-safe base64.
+func GenerateToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest stored in place of a bearer token. Tokens are long and random,
+// so a fast hash is enough; lookups are by digest.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatches compares a presented token against a stored digest in constant time.
+func TokenMatches(token, digest string) bool {
+	if token == "" || digest == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(digest)) == 1
+}
+
 // GenerateIconColor generates a random color for user icons
 func GenerateIconColor(firstName, lastName string) string {
 	// Simple hash-based color generation
@@ -0,0 +1,59 @@
+package utils
+
+import "errors"
+
+// commonPINs are frequently chosen PINs not caught by the repeat and sequence rules.
+var commonPINs = map[string]bool{
+	"1212": true, "2580": true, "1004": true, "2000": true, "6969": true, "1122": true, "1313": true,
+	"4545": true, "5683": true, "0852": true, "7777": true, "112233": true, "121212": true, "123123": true,
+	"147258": true, "159753": true, "696969": true, "520520": true,
+}
+
+// ValidatePIN checks that a new PIN is 4-6 digits and not trivially guessable: no single repeated digit (0000),
+// no run of consecutive digits up or down (1234, 9876), no repeated pair or triple (1212, 123123) and nothing on
+// the common-PIN list.
+func ValidatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 6 {
+		return errors.New("PIN must be 4 to 6 digits")
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return errors.New("PIN must contain digits only")
+		}
+	}
+	if commonPINs[pin] || isRepeatedBlock(pin) || isDigitRun(pin) {
+		return errors.New("PIN is too easy to guess; avoid repeated digits, sequences like 1234 and common PINs")
+	}
+	return nil
+}
+
+// isRepeatedBlock reports whether pin is one short block repeated: 0000, 1212, 123123.
+func isRepeatedBlock(pin string) bool {
+	for size := 1; size <= len(pin)/2; size++ {
+		if len(pin)%size != 0 {
+			continue
+		}
+		repeated := true
+		for i := size; i < len(pin); i++ {
+			if pin[i] != pin[i-size] {
+				repeated = false
+				break
+			}
+		}
+		if repeated {
+			return true
+		}
+	}
+	return false
+}
+
+// isDigitRun reports whether each digit is one more, or each one less, than the one before (wrapping 9-0).
+func isDigitRun(pin string) bool {
+	up, down := true, true
+	for i := 1; i < len(pin); i++ {
+		d := (int(pin[i]) - int(pin[i-1]) + 10) % 10
+		up = up && d == 1
+		down = down && d == 9
+	}
+	return up || down
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "testing"
+
+func TestValidatePIN(t *testing.T) {
+	for _, weak := range []string{"1234", "0000", "9876", "7890", "1212", "123123", "2580", "123", "1234567", "12a4"} {
+		if ValidatePIN(weak) == nil {
+			t.Errorf("ValidatePIN(%q) = nil, want error", weak)
+		}
+	}
+	for _, ok := range []string{"4817", "39052", "730148", "1235"} {
+		if err := ValidatePIN(ok); err != nil {
+			t.Errorf("ValidatePIN(%q) = %v", ok, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range cases {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+	if step, ok := VerifyTOTP(secret, "287 082", time.Unix(59+30, 0)); !ok || step != 1 {
+		t.Errorf("VerifyTOTP with one step of drift = %d, %v", step, ok)
+	}
+	if _, ok := VerifyTOTP(secret, "287082", time.Unix(59+90, 0)); ok {
+		t.Error("VerifyTOTP accepted a code three steps old")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Acme Foods", "ana", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Acme%20Foods:ana?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("TOTPProvisioningURI = %q", uri)
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	sealed, err := SealSecret("k", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := OpenSecret("k", sealed); err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("OpenSecret = %q, %v", got, err)
+	}
+	if _, err := OpenSecret("other", sealed); err == nil {
+		t.Error("OpenSecret with the wrong key succeeded")
+	}
+}
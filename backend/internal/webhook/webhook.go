@@ -0,0 +1,154 @@
+// Package webhook signs and sends outbound webhook requests. Queuing, retries and the delivery log live with the
+// API (see api/webhook.go); this package only knows the wire format.
+//
+// Each request is a JSON POST with headers X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and
+// X-Webhook-Signature. The signature is "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" under the
+// subscription secret, so receivers can reject tampered or replayed requests.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Event types a subscription can ask for. EventPing is sent by the "test" action only.
+const (
+	EventOrderCreated           = "order.created"
+	EventOrderPaid              = "order.paid"
+	EventWholesaleOrderApproved = "wholesale_order.approved"
+	EventShipmentShipped        = "shipment.shipped"
+	EventStockLow               = "stock.low"
+	EventInvoiceGenerated       = "invoice.generated"
+	EventPing                   = "ping"
+)
+
+// Events lists the subscribable event types.
+var Events = []string{
+	EventOrderCreated, EventOrderPaid, EventWholesaleOrderApproved, EventShipmentShipped, EventStockLow,
+	EventInvoiceGenerated,
+}
+
+// IsKnownEvent reports whether name is in Events.
+func IsKnownEvent(name string) bool {
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxAttempts is how many times a delivery is tried before it is marked failed.
+const MaxAttempts = 8
+
+// RetryDelay is the wait before attempt n+1 after n failed attempts: 1 minute doubling up to 6 hours, so the
+// last of MaxAttempts is tried roughly a day after the first.
+func RetryDelay(failedAttempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < failedAttempts && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+	if d > 6*time.Hour {
+		d = 6 * time.Hour
+	}
+	return d
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrBlockedAddress is returned for receivers on loopback, private, link-local or other internal addresses, so
+// that a subscription cannot be pointed at services inside the network.
+var ErrBlockedAddress = errors.New("webhook receiver address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range (100.64.0.0/10), which net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsBlockedIP reports whether ip is an address webhooks must not be sent to.
+func IsBlockedIP(ip net.IP) bool {
+	return ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// NewClient returns the client deliveries are sent with. The address is checked after DNS resolution, on the
+// connection actually being made, so a hostname that resolves (or later re-resolves) to an internal address is
+// refused too. Redirects are not followed and no proxy is used, since either would dial somewhere unchecked.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if IsBlockedIP(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Result is the receiver's answer to one attempt.
+type Result struct {
+	StatusCode int
+	Body       string // first 2 KB of the response
+}
+
+// OK reports whether the receiver accepted the delivery.
+func (r Result) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Send posts one signed delivery. err is set when no response was received.
+func Send(ctx context.Context, client *http.Client, url, secret, event, deliveryID string, body []byte) (Result, error) {
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pos-system-webhooks/1")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Delivery", deliveryID)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(secret, ts, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("webhook post: %w", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return Result{StatusCode: resp.StatusCode, Body: string(b)}, nil
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 5: 16 * time.Minute, 9: 4*time.Hour + 16*time.Minute, 12: 6 * time.Hour}
+	for n, want := range cases {
+		if got := RetryDelay(n); got != want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestSendSigns(t *testing.T) {
+	body := []byte(`{"event":"ping"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if r.Header.Get("X-Webhook-Signature") != Sign("s3cret", ts, body) || r.Header.Get("X-Webhook-Event") != EventPing {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	res, err := Send(context.Background(), srv.Client(), srv.URL, "s3cret", EventPing, "1", body)
+	if err != nil || !res.OK() {
+		t.Fatalf("Send = %+v, %v", res, err)
+	}
+	if res, _ := Send(context.Background(), srv.Client(), srv.URL, "wrong", EventPing, "2", body); res.OK() {
+		t.Error("receiver accepted a request signed with the wrong secret")
+	}
+}
+
+func TestNewClientRefusesInternalReceivers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := Send(context.Background(), NewClient(5*time.Second), srv.URL, "s3cret", EventPing, "1", []byte(`{}`))
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Send to %s = %v, want ErrBlockedAddress", srv.URL, err)
+	}
+
+	for ip, blocked := range map[string]bool{
+		"127.0.0.1": true, "10.1.2.3": true, "172.16.0.9": true, "192.168.1.1": true, "169.254.169.254": true,
+		"100.64.0.1": true, "0.0.0.0": true, "::1": true, "fd00::1": true, "fe80::1": true, "::ffff:127.0.0.1": true,
+		"8.8.8.8": false, "2001:4860:4860::8888": false,
+	} {
+		if got := IsBlockedIP(net.ParseIP(ip)); got != blocked {
+			t.Errorf("IsBlockedIP(%s) = %v, want %v", ip, got, blocked)
+		}
+	}
+}
+
+func TestNewClientDoesNotFollowRedirects(t *testing.T) {
+	c := NewClient(5 * time.Second)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	if err := c.CheckRedirect(req, []*http.Request{req}); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Fatalf("CheckRedirect = %v, want ErrUseLastResponse", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"pos-system/backend/internal/api"
 	"pos-system/backend/internal/config"
 	"pos-system/backend/internal/database"
+	"pos-system/backend/internal/migrate"
 
 	"gorm.io/gorm"
 )
@@ -64,11 +66,15 @@ func main() {
 
 	// Try to connect quickly, but don't fail if it doesn't work
 	for i := 0; i < maxRetries; i++ {
-		db, err = database.Initialize(cfg.DatabaseURL)
+		db, err = database.Initialize(cfg.DatabaseURL, cfg.MigrateOnStart)
 		if err == nil {
 			log.Println("Database connection established successfully")
 			break
 		}
+		// A schema that does not match this build will not fix itself; serving requests against it would corrupt data.
+		if errors.Is(err, migrate.ErrSchemaTooNew) || errors.Is(err, migrate.ErrPending) {
+			log.Fatalf("Refusing to start: %v", err)
+		}
 
 		if i < maxRetries-1 {
 			log.Printf("Database connection failed (attempt %d/%d): %v. Retrying in %d seconds...", i+1, maxRetries, err, retryDelay)
@@ -85,6 +91,13 @@ func main() {
 	log.Println("Setting up router...")
 	router := api.SetupRouter(db, cfg)
 	log.Println("Router setup complete")
+	if db != nil {
+		api.StartStandingOrderScheduler(db, cfg)
+		api.StartCourierTrackingScheduler(db, cfg)
+		api.StartWebhookDispatcher(db, cfg)
+		api.StartJobRunner(db, cfg)
+		api.StartTaskScheduler(db, cfg)
+	}
 
 	// Start server immediately - this is critical for Cloud Run health checks
 	log.Printf("Starting HTTP server on port %s...", port)
@@ -96,7 +109,7 @@ func main() {
 			log.Println("Retrying database connection in background...")
 			for {
 				time.Sleep(5 * time.Second)
-				connectedDB, err := database.Initialize(cfg.DatabaseURL)
+				connectedDB, err := database.Initialize(cfg.DatabaseURL, cfg.MigrateOnStart)
 				if err == nil {
 					log.Println("Database connection established in background!")
 					// Note: Router/handlers would need to be updated to use new DB
@@ -211,7 +211,7 @@ main() {
     print_info "=========================================="
     print_info "Next steps:"
     print_info "1. Initialize database schema (if database was created):"
-    print_info "   mysql -h <DB_IP> -u pos_user -p pos_system < database/schema.sql"
+    print_info "   cd backend && DATABASE_URL=mysql://pos_user:<password>@<DB_IP>:3306/pos_system go run ./cmd/migrate up"
     print_info "2. Deploy backend: ./scripts/deploy.sh backend"
     print_info "3. Deploy frontend: ./scripts/deploy.sh frontend"
     print_info ""